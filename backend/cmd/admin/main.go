@@ -2,10 +2,14 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log/slog"
+	"mime"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -45,6 +49,7 @@ func main() {
 	// Get configuration from environment
 	// This sets up where our plaintext database and our photo uploads are stored
 	dataDir := getEnv("DATA_DIR", "../data")
+	publicDataDir := getEnv("PUBLIC_DATA_DIR", "../static/data")
 	uploadDir := getEnv("UPLOAD_DIR", "../static/uploads")
 	port := getEnv("PORT", "6180")
 
@@ -55,75 +60,187 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Load admin configuration from file
-	var adminConfig models.AdminConfig
-	if err := fileService.ReadJSON("admin_config.json", &adminConfig); err != nil {
-		logger.Error("failed to load admin config",
-			slog.String("error", err.Error()),
-			slog.String("hint", "run ./bootstrap.sh to create admin_config.json"),
-		)
+	// Password hashing (argon2id) and the password policy
+	if err := services.SetPasswordConfig(loadPasswordConfig(logger)); err != nil {
+		logger.Error("invalid password settings", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
 
-	// Allow environment variables to override config file
-	adminUsername := getEnv("ADMIN_USERNAME", adminConfig.Username)
+	// Admin user accounts. On first start the single account in
+	// admin_config.json becomes the owner.
+	userService := services.NewUserService(fileService)
+	hasUsers, err := userService.HasUsers()
+	if err != nil {
+		logger.Error("failed to load users", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	if !hasUsers {
+		migrateAdminConfig(fileService, userService, logger)
+	}
 
-	// Now to retrieve the password hash
-	var adminPasswordHash string
+	albumService := services.NewAlbumService(fileService)
+	configService := services.NewSiteConfigService(fileService)
 
-	// Check for password from environmental variables first (for development/testing)
-	adminPassword := os.Getenv("ADMIN_PASSWORD")
-	if adminPassword != "" {
-		logger.Info("using ADMIN_PASSWORD from environment (dev mode)")
-		// Hash the plain text password
-		hash, err := services.HashPassword(adminPassword) // pragma: allowlist secret
-		if err != nil {
-			logger.Error("failed to hash ADMIN_PASSWORD",
-				slog.String("error", err.Error()),
-			)
-			os.Exit(1)
-		}
-		adminPasswordHash = hash // pragma: allowlist secret
-	} else {
-		// Fall back to hashed password from env or config file
-		logger.Info("using ADMIN_PASSWORD from admin_config.json (prod mode)")
-		adminPasswordHash = adminConfig.PasswordHash // pragma: allowlist secret
+	// Publish the sanitized dataset the public site reads, and keep it current
+	publishService, err := services.NewPublishService(publicDataDir, albumService, configService)
+	if err != nil {
+		logger.Error("failed to create publish service", slog.String("error", err.Error()))
+		os.Exit(1)
 	}
-
-	if adminPasswordHash == "" {
-		logger.Error("admin password hash not configured",
-			slog.String("hint", "run ./bootstrap.sh to set admin password or set ADMIN_PASSWORD in env"),
-		)
+	// Map upload URLs to their albums, so media access follows album access
+	mediaIndex := services.NewMediaIndex(albumService)
+	if err := mediaIndex.Publish(); err != nil {
+		logger.Error("failed to index media", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	if err := publishService.Publish(); err != nil {
+		logger.Error("failed to publish public data", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
 
-	albumService := services.NewAlbumService(fileService)
-	configService := services.NewSiteConfigService(fileService)
+	// libvips resource limits (0 keeps the default)
+	processingLimits := services.ProcessingLimits{
+		MaxConcurrentImages: getEnvInt(logger, "IMAGE_MAX_CONCURRENT", 0),
+		CacheMaxMemMB:       getEnvInt(logger, "VIPS_CACHE_MAX_MEM_MB", 0),
+		CacheMaxOperations:  getEnvInt(logger, "VIPS_CACHE_MAX_OPS", 0),
+	}
 
-	imageService, err := services.NewImageService(uploadDir, configService)
+	imageService, err := services.NewImageServiceWithLimits(uploadDir, configService, processingLimits)
 	if err != nil {
 		logger.Error("failed to create image service", slog.String("error", err.Error()))
 		os.Exit(1)
 	}
 
+	// ZIP downloads of albums, cached until the album changes
+	archiveService, err := services.NewArchiveService(
+		uploadDir,
+		getEnv("DOWNLOAD_CACHE_DIR", filepath.Join(dataDir, "cache", "downloads")),
+		albumService,
+		configService,
+		imageService,
+		getEnvInt(logger, "DOWNLOAD_LARGE_SIZE", services.DefaultArchiveLargeSize),
+		getEnvInt(logger, "DOWNLOAD_LARGE_QUALITY", services.DefaultArchiveLargeQuality),
+	)
+	if err != nil {
+		logger.Error("failed to create archive service", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	albumService.SetPublisher(services.Publishers{mediaIndex, publishService, archiveService})
+	configService.SetPublisher(services.Publishers{publishService, archiveService})
+	// Changes are saved before they are published, so failures are only logged
+	reportPublishError := func(err error) {
+		logger.Error("failed to publish public data", slog.String("error", err.Error()))
+	}
+	albumService.SetPublishErrorHandler(reportPublishError)
+	configService.SetPublishErrorHandler(reportPublishError)
+	if err := archiveService.Publish(); err != nil {
+		logger.Warn("failed to prune download cache", slog.String("error", err.Error()))
+	}
+
 	// Initialize auth service (24 hour session TTL)
-	authService := services.NewAuthService(adminUsername, adminPasswordHash, 24*time.Hour)
-	// Configure persistence so password changes are saved to disk
-	authService.SetConfigPersistence(fileService, "admin_config.json")
+	authService := services.NewAuthService(userService, 24*time.Hour)
+	switch sessionStore := getEnv("SESSION_STORE", "memory"); sessionStore {
+	case "memory":
+	case "file":
+		// Keep sessions in the data directory, so restarts do not sign everyone out
+		store, err := services.NewFileSessionStore(fileService)
+		if err != nil {
+			logger.Error("failed to load sessions", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		authService.SetSessionStore(store)
+	default:
+		logger.Error("invalid SESSION_STORE, must be memory or file", slog.String("value", sessionStore))
+		os.Exit(1)
+	}
+	authService.SetMaxSessionLifetime(time.Duration(getEnvInt(logger, "SESSION_MAX_LIFETIME_HOURS", 0)) * time.Hour)
+
+	// Album access tokens for password-protected albums. Without a secret a random
+	// one is used, and visitors must re-enter album passwords after a restart.
+	albumAccessService, err := services.NewAlbumAccessService([]byte(os.Getenv("ALBUM_ACCESS_SECRET")), services.DefaultAlbumAccessTTL)
+	if err != nil {
+		logger.Error("failed to create album access service", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	// Per-client share links to albums, an alternative to album passwords
+	shareLinkService := services.NewShareLinkService(fileService)
+	albumAccessService.SetShareLinkService(shareLinkService)
+	// Named guest passwords, each with its own expiry and usage count
+	albumPasswordService := services.NewAlbumPasswordService(fileService)
+	albumAccessService.SetPasswordService(albumPasswordService)
+	albumAccessService.SetAlbumService(albumService)
+	selectionService := services.NewSelectionService(fileService)
+	// Scheduled publishing and album expiry, warning the admin ahead of expiry
+	expiryWarningDays := getEnvInt(logger, "ALBUM_EXPIRY_WARNING_DAYS", 7)
+	albumScheduler := services.NewAlbumScheduler(albumService, time.Duration(expiryWarningDays)*24*time.Hour)
 
 	// Initialize handlers
 	albumHandler := handlers.NewAlbumHandler(albumService, imageService, logger)
+	albumAccessHandler := handlers.NewAlbumAccessHandler(albumService, albumAccessService, logger)
+	shareLinkHandler := handlers.NewShareLinkHandler(albumService, shareLinkService, logger)
+	albumPasswordHandler := handlers.NewAlbumPasswordHandler(albumService, albumPasswordService, logger)
+	downloadHandler := handlers.NewDownloadHandler(albumService, albumAccessService, archiveService, logger)
+	proofingHandler := handlers.NewProofingHandler(albumService, albumAccessService, selectionService, logger)
+	mediaHandler := handlers.NewMediaHandler(mediaIndex, albumAccessService, authService, uploadDir, logger)
 	authHandler := handlers.NewAuthHandler(authService, logger)
+	secureCookies := getEnv("SECURE_COOKIES", "false") == "true"
+	albumAccessHandler.SetSecureCookies(secureCookies)
+	authHandler.SetSecureCookies(secureCookies)
+	// Optional single sign-on with an OpenID Connect identity provider
+	if issuer := os.Getenv("OIDC_ISSUER_URL"); issuer != "" {
+		oidcService, err := loadOIDCService(issuer)
+		if err != nil {
+			logger.Error("invalid OIDC settings", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		authService.SetOIDCService(oidcService)
+		authHandler.SetFrontendURL(getEnv("OIDC_FRONTEND_URL", ""))
+		logger.Info("single sign-on enabled", slog.String("issuer", issuer))
+	}
+	// Personal access tokens for scripts, sent as "Authorization: Bearer"
+	apiTokenService := services.NewAPITokenService(fileService)
+	authService.SetAPITokenService(apiTokenService)
+	apiTokenHandler := handlers.NewAPITokenHandler(apiTokenService, logger)
+	sessionHandler := handlers.NewSessionHandler(authService, logger)
+	userHandler := handlers.NewUserHandler(userService, authService, logger)
+	// Rate limits, backoff and lockout against password guessing
+	loginGuard := services.NewLoginGuard(fileService, loadLoginGuardConfig(logger))
+	authHandler.SetLoginGuard(loginGuard)
+	loginFailureHandler := handlers.NewLoginFailureHandler(loginGuard, logger)
+	// Append-only log of admin changes, pruned by its retention settings
+	auditService := services.NewAuditService(fileService, loadAuditConfig(logger))
+	auditService.SetReadErrorHandler(func(err error) {
+		logger.Warn("skipping unreadable audit entry", slog.String("error", err.Error()))
+	})
+	auditHandler := handlers.NewAuditHandler(auditService, logger)
+	// Optional TOTP two-factor authentication, named in authenticator apps by TOTP_ISSUER
+	twoFactorHandler := handlers.NewTwoFactorHandler(userService, authService, getEnv("TOTP_ISSUER", "Photo Admin"), logger)
 	configHandler := handlers.NewConfigHandler(configService, logger)
 	storageHandler := handlers.NewStorageHandler(configService, uploadDir)
+	scheduleHandler := handlers.NewScheduleHandler(albumScheduler, logger)
+
+	// Hotlink protection for uploads, off unless allowed hosts are configured
+	hotlinkConfig := loadHotlinkConfig(logger)
+	mediaHandler.SetHotlinkProtection(hotlinkConfig != nil)
+	if hotlinkConfig != nil && hotlinkConfig.Signer != nil {
+		albumAccessHandler.SetMediaSigner(hotlinkConfig.Signer)
+	}
 
 	// Start session cleanup goroutine
 	authHandler.StartSessionCleanup()
+	albumAccessHandler.StartRateLimitCleanup()
+	proofingHandler.StartRateLimitCleanup()
+	scheduleHandler.StartScheduler(services.DefaultSchedulerInterval)
+	auditHandler.StartPruning()
 
 	// Setup router
 	r := chi.NewRouter()
 
 	// Global middleware
+	if getEnv("TRUST_PROXY_HEADERS", "false") == "true" {
+		// Take the client IP from X-Forwarded-For/X-Real-IP when behind a reverse proxy
+		r.Use(chimiddleware.RealIP)
+	}
 	r.Use(middleware.RequestID)
 	r.Use(middleware.Recoverer(logger))
 	r.Use(middleware.Logger(logger))
@@ -132,11 +249,13 @@ func main() {
 	// Strip trailing slashes to handle /api/albums and /api/albums/ consistently
 	r.Use(chimiddleware.StripSlashes)
 
-	// CORS middleware (allow frontend in development)
+	// CORS middleware (allow frontend in development). The same origins may
+	// make state-changing admin requests.
+	allowedOrigins := strings.Split(getEnv("CORS_ALLOWED_ORIGINS", "http://localhost:5173,http://localhost:3000"), ",")
 	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"http://localhost:5173", "http://localhost:3000"},
+		AllowedOrigins:   allowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Request-ID"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Request-ID", middleware.CSRFHeader},
 		ExposedHeaders:   []string{"X-Request-ID"},
 		AllowCredentials: true,
 		MaxAge:           300,
@@ -150,64 +269,176 @@ func main() {
 
 	// Data endpoints for Admin Frontend
 	r.Route("/api", func(r chi.Router) {
-		r.Use(middleware.Auth(authService, logger))
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Auth(authService, logger))
 
-		// Album endpoints
-		r.Get("/albums", albumHandler.GetAll)
-		r.Get("/albums/{id}", albumHandler.GetByID)
+			// Album endpoints
+			r.With(middleware.RequireScope(models.ScopeAlbumsRead, logger)).Get("/albums", albumHandler.GetAll)
 
-		// Site config
-		r.Get("/config", configHandler.Get)
+			// Site config
+			r.With(middleware.RequireScope(models.ScopeConfig, logger)).Get("/config", configHandler.Get)
+		})
+
+		// Public album access (no auth required)
+		r.Get("/albums/{slug}", albumAccessHandler.GetAlbum)
+		r.Post("/albums/{slug}/access", albumAccessHandler.RequestAccess)
+		r.Get("/albums/{slug}/download", downloadHandler.DownloadAlbum)
+
+		// Client proofing (album access token required)
+		r.Post("/albums/{slug}/selections", proofingHandler.StartSelection)
+		r.Get("/albums/{slug}/selections/{selectionId}", proofingHandler.GetSelection)
+		r.Put("/albums/{slug}/selections/{selectionId}", proofingHandler.UpdateSelection)
+		r.Post("/albums/{slug}/selections/{selectionId}/submit", proofingHandler.SubmitSelection)
 	})
 
 	// Admin API endpoints (require authentication)
 	r.Route("/api/admin", func(r chi.Router) {
+		// CSRF protection: state-changing requests must come from the site
+		// itself or an allowed origin, and browser sessions must send their
+		// CSRF token
+		r.Use(middleware.CheckOrigin(allowedOrigins, logger))
+
 		// Auth endpoints (no auth required for login)
 		r.Post("/login", authHandler.Login)
 		r.Post("/logout", authHandler.Logout)
+		r.Get("/oidc", authHandler.OIDCStatus)
+		r.Get("/oidc/login", authHandler.OIDCLogin)
+		r.Get("/oidc/callback", authHandler.OIDCCallback)
 
-		// Protected admin routes
+		// Protected admin routes. Every signed-in user can view; each group
+		// below needs a role that includes the one it names. API tokens can
+		// only use the routes of their scopes.
 		r.Group(func(r chi.Router) {
 			r.Use(middleware.Auth(authService, logger))
+			r.Use(middleware.CSRF(logger))
+			r.Use(middleware.Audit(auditService, logger))
 
 			// Auth check endpoint
-			r.Get("/auth/check", func(w http.ResponseWriter, r *http.Request) {
-				w.Header().Set("Content-Type", "application/json")
-				_, _ = w.Write([]byte(`{"authenticated":true}`))
-			})
+			r.Get("/auth/check", authHandler.Check)
+
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireScope(models.ScopeAlbumsRead, logger))
 
-			// Album management
-			r.Post("/albums", albumHandler.Create)
-			r.Put("/albums/{id}", albumHandler.Update)
-			r.Delete("/albums/{id}", albumHandler.Delete)
-			r.Post("/albums/{id}/photos/upload", albumHandler.UploadPhotos)
-			r.Delete("/albums/{id}/photos", albumHandler.DeleteAllPhotos)
-			r.Delete("/albums/{id}/photos/{photoId}", albumHandler.DeletePhoto)
-			r.Post("/albums/{id}/set-cover", albumHandler.SetCoverPhoto)
-			r.Post("/albums/{id}/reorder-photos", albumHandler.ReorderPhotos)
-			r.Post("/albums/{id}/set-password", albumHandler.SetPassword)
-			r.Delete("/albums/{id}/password", albumHandler.RemovePassword) // Site configuration
-			r.Put("/config", configHandler.Update)
-			r.Put("/config/main-portfolio-album", configHandler.SetMainPortfolioAlbum)
-
-			// Auth management
-			r.Post("/change-password", authHandler.ChangePassword)
+				// Album management
+				r.Get("/albums/{id}", albumHandler.GetByID)
+				r.Get("/albums/{id}/shares", shareLinkHandler.List)
+				r.Get("/albums/{id}/passwords", albumPasswordHandler.List)
+				r.Get("/albums/{id}/selections", proofingHandler.ListSelections)
+				r.Get("/albums/{id}/selections/{selectionId}/export", proofingHandler.ExportSelection)
+
+				// Scheduled publishing and expiry
+				r.Get("/schedule", scheduleHandler.Upcoming)
+			})
 
 			// Storage management
-			r.Get("/storage/stats", storageHandler.GetStats)
+			r.With(middleware.RequireScope(models.ScopeConfig, logger)).Get("/storage/stats", storageHandler.GetStats)
+
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireRole(models.RoleUploader, logger))
+				r.Use(middleware.RequireScope(models.ScopeUpload, logger))
+
+				r.Post("/albums/{id}/photos/upload", albumHandler.UploadPhotos)
+			})
+
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireRole(models.RoleEditor, logger))
+
+				// Album management
+				r.Group(func(r chi.Router) {
+					r.Use(middleware.RequireScope(models.ScopeAlbumsWrite, logger))
+
+					r.Post("/albums", albumHandler.Create)
+					r.Put("/albums/{id}", albumHandler.Update)
+					r.Delete("/albums/{id}", albumHandler.Delete)
+					r.Delete("/albums/{id}/photos", albumHandler.DeleteAllPhotos)
+					r.Delete("/albums/{id}/photos/{photoId}", albumHandler.DeletePhoto)
+					r.Post("/albums/{id}/photos/{photoId}/focal-point", albumHandler.SetFocalPoint)
+					r.Delete("/albums/{id}/photos/{photoId}/focal-point", albumHandler.ClearFocalPoint)
+					r.Post("/albums/{id}/photos/{photoId}/edit", albumHandler.EditPhoto)
+					r.Delete("/albums/{id}/photos/{photoId}/edit", albumHandler.RevertPhotoEdits)
+					r.Put("/albums/{id}/photos/{photoId}/file", albumHandler.ReplacePhotoFile)
+					r.Post("/albums/{id}/photos/{photoId}/versions/{versionId}/restore", albumHandler.RestorePhotoVersion)
+					r.Delete("/albums/{id}/photos/{photoId}/versions/{versionId}", albumHandler.DeletePhotoVersion)
+					r.Post("/albums/{id}/set-cover", albumHandler.SetCoverPhoto)
+					r.Post("/albums/{id}/reorder-photos", albumHandler.ReorderPhotos)
+					r.Post("/albums/{id}/set-password", albumHandler.SetPassword)
+					r.Delete("/albums/{id}/password", albumHandler.RemovePassword)
+					r.Post("/albums/{id}/shares", shareLinkHandler.Create)
+					r.Delete("/albums/{id}/shares/{shareId}", shareLinkHandler.Revoke)
+					r.Post("/albums/{id}/previews", shareLinkHandler.CreatePreview)
+					r.Post("/albums/{id}/passwords", albumPasswordHandler.Create)
+					r.Delete("/albums/{id}/passwords/{passwordId}", albumPasswordHandler.Delete)
+					r.Post("/albums/{id}/selections/{selectionId}/lock", proofingHandler.LockSelection)
+					r.Delete("/albums/{id}/selections/{selectionId}/lock", proofingHandler.UnlockSelection)
+				})
+
+				// Site configuration
+				r.Group(func(r chi.Router) {
+					r.Use(middleware.RequireScope(models.ScopeConfig, logger))
+
+					r.Put("/config", configHandler.Update)
+					r.Put("/config/main-portfolio-album", configHandler.SetMainPortfolioAlbum)
+				})
+			})
+
+			// Account and user management, for signed-in users only
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireBrowserSession(logger))
+
+				// Auth management
+				r.Post("/change-password", authHandler.ChangePassword)
+				r.Get("/two-factor", twoFactorHandler.Status)
+				r.Post("/two-factor/enroll", twoFactorHandler.Enroll)
+				r.Post("/two-factor/verify", twoFactorHandler.Verify)
+				r.Post("/two-factor/disable", twoFactorHandler.Disable)
+
+				// API tokens
+				r.Get("/tokens", apiTokenHandler.List)
+				r.Post("/tokens", apiTokenHandler.Create)
+				r.Delete("/tokens/{tokenId}", apiTokenHandler.Revoke)
+
+				// Sessions
+				r.Get("/sessions", sessionHandler.List)
+				r.Post("/sessions/revoke-others", sessionHandler.RevokeOthers)
+				r.Delete("/sessions/{sessionId}", sessionHandler.Revoke)
+
+				r.Group(func(r chi.Router) {
+					r.Use(middleware.RequireRole(models.RoleOwner, logger))
+
+					// User management
+					r.Get("/users", userHandler.List)
+					r.Post("/users", userHandler.Create)
+					r.Get("/users/{userId}", userHandler.Get)
+					r.Put("/users/{userId}", userHandler.Update)
+					r.Delete("/users/{userId}", userHandler.Delete)
+					r.Delete("/users/{userId}/two-factor", twoFactorHandler.Reset)
+
+					// Failed logins and lockouts
+					r.Get("/login-failures", loginFailureHandler.List)
+					r.Delete("/login-failures/{kind}/{key}", loginFailureHandler.Clear)
+
+					// Audit log
+					r.Get("/audit", auditHandler.List)
+				})
+			})
 		})
 	})
 
-	// Serve static files (uploaded images)
-	workDir, _ := os.Getwd()
-	staticPath := filepath.Join(workDir, uploadDir)
-	r.Handle("/uploads/*", http.StripPrefix("/uploads/", http.FileServer(http.Dir(staticPath))))
+	// Serve uploaded images, with access decided by each file's album
+	r.Group(func(r chi.Router) {
+		if hotlinkConfig != nil {
+			r.Use(middleware.Hotlink(*hotlinkConfig, logger))
+		}
+		r.Get("/uploads/*", mediaHandler.ServeMedia)
+		r.Head("/uploads/*", mediaHandler.ServeMedia)
+	})
 
 	// Start server
 	addr := ":" + port
 	logger.Info("admin server starting",
 		slog.String("addr", addr),
 		slog.String("data_dir", dataDir),
+		slog.String("public_data_dir", publicDataDir),
 		slog.String("upload_dir", uploadDir),
 	)
 
@@ -225,6 +456,168 @@ func main() {
 	}
 }
 
+// migrateAdminConfig creates the first owner from admin_config.json, with
+// ADMIN_USERNAME and ADMIN_PASSWORD overriding it.
+func migrateAdminConfig(fileService *services.FileService, userService *services.UserService, logger *slog.Logger) {
+	// Load admin configuration from file
+	var adminConfig models.AdminConfig
+	if err := fileService.ReadJSON("admin_config.json", &adminConfig); err != nil {
+		logger.Error("failed to load admin config",
+			slog.String("error", err.Error()),
+			slog.String("hint", "run ./bootstrap.sh to create admin_config.json"),
+		)
+		os.Exit(1)
+	}
+
+	// Allow environment variables to override config file
+	adminUsername := getEnv("ADMIN_USERNAME", adminConfig.Username)
+
+	// Now to retrieve the password hash
+	var adminPasswordHash string
+
+	// Check for password from environmental variables first (for development/testing)
+	adminPassword := os.Getenv("ADMIN_PASSWORD")
+	if adminPassword != "" {
+		logger.Info("using ADMIN_PASSWORD from environment (dev mode)")
+		// Hash the plain text password
+		hash, err := services.HashPassword(adminPassword) // pragma: allowlist secret
+		if err != nil {
+			logger.Error("failed to hash ADMIN_PASSWORD",
+				slog.String("error", err.Error()),
+			)
+			os.Exit(1)
+		}
+		adminPasswordHash = hash // pragma: allowlist secret
+	} else {
+		// Fall back to hashed password from env or config file
+		logger.Info("using ADMIN_PASSWORD from admin_config.json (prod mode)")
+		adminPasswordHash = adminConfig.PasswordHash // pragma: allowlist secret
+	}
+
+	if adminPasswordHash == "" {
+		logger.Error("admin password hash not configured",
+			slog.String("hint", "run ./bootstrap.sh to set admin password or set ADMIN_PASSWORD in env"),
+		)
+		os.Exit(1)
+	}
+
+	if _, err := userService.Migrate(models.AdminConfig{Username: adminUsername, PasswordHash: adminPasswordHash}); err != nil {
+		logger.Error("failed to migrate admin config to users", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	logger.Info("migrated admin account to users.json", slog.String("username", adminUsername))
+}
+
+// loadPasswordConfig reads the password hashing and policy settings, each
+// defaulting to services.DefaultPasswordConfig.
+func loadPasswordConfig(logger *slog.Logger) services.PasswordConfig {
+	config := services.DefaultPasswordConfig()
+	config.Memory = uint32(getEnvInt(logger, "PASSWORD_ARGON2_MEMORY_KB", int(config.Memory)))
+	config.Iterations = uint32(getEnvInt(logger, "PASSWORD_ARGON2_ITERATIONS", int(config.Iterations)))
+	config.Parallelism = uint8(getEnvInt(logger, "PASSWORD_ARGON2_PARALLELISM", int(config.Parallelism)))
+	config.MinLength = getEnvInt(logger, "PASSWORD_MIN_LENGTH", config.MinLength)
+	config.AlbumMinLength = getEnvInt(logger, "ALBUM_PASSWORD_MIN_LENGTH", config.AlbumMinLength)
+	return config
+}
+
+// loadOIDCService reads the single sign-on settings for the identity provider
+// at issuer.
+func loadOIDCService(issuer string) (*services.OIDCService, error) {
+	emailRoles, err := services.ParseRoleMap(os.Getenv("OIDC_EMAIL_ROLES"))
+	if err != nil {
+		return nil, fmt.Errorf("OIDC_EMAIL_ROLES: %w", err)
+	}
+	groupRoles, err := services.ParseRoleMap(os.Getenv("OIDC_GROUP_ROLES"))
+	if err != nil {
+		return nil, fmt.Errorf("OIDC_GROUP_ROLES: %w", err)
+	}
+	return services.NewOIDCService(services.OIDCConfig{
+		IssuerURL:    issuer,
+		ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+		ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+		RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		Scopes:       strings.Fields(getEnv("OIDC_SCOPES", "email profile")),
+		EmailRoles:   emailRoles,
+		GroupRoles:   groupRoles,
+		GroupsClaim:  getEnv("OIDC_GROUPS_CLAIM", "groups"),
+	})
+}
+
+// loadLoginGuardConfig reads the login protection settings, each defaulting to
+// services.DefaultLoginGuardConfig.
+func loadLoginGuardConfig(logger *slog.Logger) services.LoginGuardConfig {
+	config := services.DefaultLoginGuardConfig()
+	config.IPLimit = getEnvInt(logger, "LOGIN_IP_LIMIT", config.IPLimit)
+	config.IPWindow = time.Duration(getEnvInt(logger, "LOGIN_IP_WINDOW_MINUTES", int(config.IPWindow/time.Minute))) * time.Minute
+	config.FreeFailures = getEnvInt(logger, "LOGIN_FREE_FAILURES", config.FreeFailures)
+	config.BackoffBase = time.Duration(getEnvInt(logger, "LOGIN_BACKOFF_BASE_SECONDS", int(config.BackoffBase/time.Second))) * time.Second
+	config.BackoffMax = time.Duration(getEnvInt(logger, "LOGIN_BACKOFF_MAX_SECONDS", int(config.BackoffMax/time.Second))) * time.Second
+	config.LockoutFailures = getEnvInt(logger, "LOGIN_LOCKOUT_FAILURES", config.LockoutFailures)
+	config.LockoutDuration = time.Duration(getEnvInt(logger, "LOGIN_LOCKOUT_MINUTES", int(config.LockoutDuration/time.Minute))) * time.Minute
+	config.ResetAfter = time.Duration(getEnvInt(logger, "LOGIN_FAILURE_RESET_HOURS", int(config.ResetAfter/time.Hour))) * time.Hour
+	return config
+}
+
+// loadAuditConfig reads the audit log retention settings, each defaulting to
+// services.DefaultAuditConfig.
+func loadAuditConfig(logger *slog.Logger) services.AuditConfig {
+	config := services.DefaultAuditConfig()
+	config.Retention = time.Duration(getEnvInt(logger, "AUDIT_RETENTION_DAYS", int(config.Retention/(24*time.Hour)))) * 24 * time.Hour
+	config.MaxEntries = getEnvInt(logger, "AUDIT_MAX_ENTRIES", config.MaxEntries)
+	return config
+}
+
+// loadHotlinkConfig reads the hotlink protection settings. It returns nil when
+// HOTLINK_ALLOWED_HOSTS is not set.
+func loadHotlinkConfig(logger *slog.Logger) *middleware.HotlinkConfig {
+	allowedHosts := getEnv("HOTLINK_ALLOWED_HOSTS", "")
+	if allowedHosts == "" {
+		return nil
+	}
+
+	config := &middleware.HotlinkConfig{
+		AllowedHosts:      strings.Split(allowedHosts, ","),
+		AllowEmptyReferer: getEnv("HOTLINK_ALLOW_EMPTY_REFERER", "true") == "true",
+	}
+
+	if secret := os.Getenv("HOTLINK_SIGNING_SECRET"); secret != "" {
+		ttl := time.Duration(getEnvInt(logger, "HOTLINK_SIGNED_URL_TTL_MINUTES", 60)) * time.Minute
+		signer, err := services.NewMediaSigner([]byte(secret), ttl)
+		if err != nil {
+			logger.Error("failed to create media signer", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		config.Signer = signer
+	}
+
+	switch response := getEnv("HOTLINK_BLOCK_RESPONSE", "403"); response {
+	case "403":
+	case "placeholder":
+		placeholderPath := os.Getenv("HOTLINK_PLACEHOLDER")
+		if placeholderPath == "" {
+			config.Placeholder, config.PlaceholderType = middleware.DefaultHotlinkPlaceholder()
+			break
+		}
+		// #nosec G304 - path comes from the server's own configuration
+		data, err := os.ReadFile(placeholderPath)
+		if err != nil {
+			logger.Error("failed to read hotlink placeholder", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+		config.Placeholder = data
+		config.PlaceholderType = mime.TypeByExtension(filepath.Ext(placeholderPath))
+		if config.PlaceholderType == "" {
+			config.PlaceholderType = http.DetectContentType(data)
+		}
+	default:
+		logger.Warn("ignoring invalid HOTLINK_BLOCK_RESPONSE, answering 403",
+			slog.String("value", response),
+		)
+	}
+
+	return config
+}
+
 // getEnv gets an environment variable with a default value.
 func getEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
@@ -233,3 +626,20 @@ func getEnv(key, defaultValue string) string {
 	}
 	return value
 }
+
+// getEnvInt gets an integer environment variable or returns a default value.
+func getEnvInt(logger *slog.Logger, key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		logger.Warn("ignoring invalid integer environment variable",
+			slog.String("key", key),
+			slog.String("value", value),
+		)
+		return defaultValue
+	}
+	return parsed
+}
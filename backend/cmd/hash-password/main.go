@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"os"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
 )
 
 func main() {
@@ -20,13 +20,18 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Generate bcrypt hash with cost of 12
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err := services.ValidatePassword(password); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Generate an argon2id hash with the default parameters
+	hash, err := services.HashPassword(password)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error generating hash: %v\n", err)
 		os.Exit(1)
 	}
 
 	// Output just the hash (no newline for easy piping)
-	fmt.Print(string(hash))
+	fmt.Print(hash)
 }
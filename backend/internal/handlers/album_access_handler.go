@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+)
+
+// AlbumAccessHandler serves albums to visitors and unlocks password-protected albums.
+type AlbumAccessHandler struct {
+	albumService  *services.AlbumService
+	accessService *services.AlbumAccessService
+	mediaSigner   *services.MediaSigner
+	secureCookies bool
+	logger        *slog.Logger
+}
+
+// NewAlbumAccessHandler creates a new album access handler.
+func NewAlbumAccessHandler(
+	albumService *services.AlbumService,
+	accessService *services.AlbumAccessService,
+	logger *slog.Logger,
+) *AlbumAccessHandler {
+	return &AlbumAccessHandler{
+		albumService:  albumService,
+		accessService: accessService,
+		logger:        logger,
+	}
+}
+
+// SetMediaSigner makes GetAlbum return signed photo URLs, which pass hotlink
+// protection without a Referer.
+func (h *AlbumAccessHandler) SetMediaSigner(signer *services.MediaSigner) {
+	h.mediaSigner = signer
+}
+
+// SetSecureCookies marks album access cookies Secure, so that they are only
+// sent over HTTPS.
+func (h *AlbumAccessHandler) SetSecureCookies(secure bool) {
+	h.secureCookies = secure
+}
+
+// RequestAccess unlocks an album, either with the password of a
+// password-protected album or with a share link token for an album of any
+// visibility. On success it returns an access token for the album and also
+// sets it as a cookie, so the album's images can be loaded with it.
+func (h *AlbumAccessHandler) RequestAccess(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Password   string `json:"password"`
+		ShareToken string `json:"share_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.Password == "" && req.ShareToken == "") {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	album, ok := visibleAlbum(w, r, h.albumService, h.logger)
+	if !ok {
+		return
+	}
+
+	var (
+		token          string
+		expiresAt      time.Time
+		allowDownloads = album.AllowDownloads
+		err            error
+	)
+	if req.ShareToken != "" {
+		var link *models.ShareLink
+		token, expiresAt, link, err = h.accessService.GrantShareAccess(album, req.ShareToken, clientIP(r))
+		if err == nil {
+			allowDownloads = allowDownloads || link.AllowDownloads
+		}
+	} else {
+		// Albums that are not protected are reported as missing, so slugs of
+		// unlisted albums cannot be confirmed by guessing
+		if !album.IsProtected() {
+			http.Error(w, "Album not found", http.StatusNotFound)
+			return
+		}
+		var albumPassword *models.AlbumPassword
+		token, expiresAt, albumPassword, err = h.accessService.GrantAccess(album, req.Password, clientIP(r))
+		if err == nil && albumPassword != nil {
+			h.logger.Info("album access granted",
+				slog.String("album_id", album.ID),
+				slog.String("password_id", albumPassword.ID),
+				slog.String("password_name", albumPassword.Name),
+			)
+		}
+	}
+	if err != nil {
+		var tooMany *services.TooManyAttemptsError
+		if errors.As(err, &tooMany) {
+			h.logger.Warn("album access rate limited",
+				slog.String("album_id", album.ID),
+				slog.String("retry_after", tooMany.RetryAfter.String()),
+			)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(tooMany.RetryAfter.Seconds()))))
+			http.Error(w, "Too many attempts", http.StatusTooManyRequests)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidAlbumPassword) {
+			http.Error(w, "Invalid password", http.StatusUnauthorized)
+			return
+		}
+		if errors.Is(err, services.ErrInvalidShareLink) {
+			// Same answer as a missing album, for the same reason
+			http.Error(w, "Album not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("failed to grant album access", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     albumAccessCookie(album.ID),
+		Value:    token,
+		Path:     "/",
+		Expires:  expiresAt,
+		MaxAge:   int(time.Until(expiresAt).Seconds()),
+		HttpOnly: true,
+		Secure:   h.secureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"token":           token,
+		"expires_at":      expiresAt,
+		"album_id":        album.ID,
+		"allow_downloads": allowDownloads,
+	})
+}
+
+// GetAlbum returns an album as visitors see it. Password-protected albums are
+// only returned in full with a valid access token, either as a Bearer token or
+// in the album's access cookie, and drafts only with a token from a preview
+// link. A token from a share link that allows downloads also unlocks the
+// album's originals.
+func (h *AlbumAccessHandler) GetAlbum(w http.ResponseWriter, r *http.Request) {
+	album, ok := visibleAlbum(w, r, h.albumService, h.logger)
+	if !ok {
+		return
+	}
+
+	grant, err := h.accessService.ValidateToken(album, albumAccessToken(r, album.ID))
+	if err != nil {
+		if album.IsDraft() {
+			http.Error(w, "Album not found", http.StatusNotFound)
+			return
+		}
+		if album.IsProtected() {
+			http.Error(w, "Album password required", http.StatusUnauthorized)
+			return
+		}
+		respondJSON(w, http.StatusOK, h.signed(album.ToPublic(true)))
+		return
+	}
+
+	if grant.AllowDownloads {
+		album.AllowDownloads = true
+	}
+	w.Header().Set("Cache-Control", "private, no-store")
+	respondJSON(w, http.StatusOK, h.signed(album.ToUnlocked(true)))
+}
+
+// signed signs the album's photo URLs when signed URLs are enabled.
+func (h *AlbumAccessHandler) signed(album models.PublicAlbum) models.PublicAlbum {
+	if h.mediaSigner != nil {
+		h.mediaSigner.SignAlbum(&album, time.Now())
+	}
+	return album
+}
+
+// visibleAlbum looks up the album in the URL, answering 404 for albums that do
+// not exist or have expired.
+func visibleAlbum(w http.ResponseWriter, r *http.Request, albumService *services.AlbumService, logger *slog.Logger) (*models.Album, bool) {
+	album, err := albumService.GetBySlug(chi.URLParam(r, "slug"))
+	if err != nil {
+		if err.Error() == "album not found" {
+			http.Error(w, "Album not found", http.StatusNotFound)
+			return nil, false
+		}
+		logger.Error("failed to get album", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	if album.IsWithdrawn(time.Now()) {
+		http.Error(w, "Album not found", http.StatusNotFound)
+		return nil, false
+	}
+
+	return album, true
+}
+
+// StartRateLimitCleanup starts a goroutine to periodically forget ended rate limit windows.
+func (h *AlbumAccessHandler) StartRateLimitCleanup() {
+	go func() {
+		ticker := time.NewTicker(15 * time.Minute)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			h.accessService.CleanupRateLimits()
+		}
+	}()
+}
+
+// albumAccessCookie returns the name of the cookie holding an album's access token.
+func albumAccessCookie(albumID string) string {
+	return "album_access_" + albumID
+}
+
+// albumAccessToken returns the album access token presented with a request.
+func albumAccessToken(r *http.Request, albumID string) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	if cookie, err := r.Cookie(albumAccessCookie(albumID)); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// clientIP returns the IP address of the connecting client.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
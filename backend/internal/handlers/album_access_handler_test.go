@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// setupAlbumAccessRouter serves the public album routes over a data directory
+// holding one album of each visibility.
+func setupAlbumAccessRouter(t *testing.T) (http.Handler, map[string]*models.Album, *services.ShareLinkService) {
+	fileService, err := services.NewFileService(t.TempDir())
+	require.NoError(t, err)
+	albumService := services.NewAlbumService(fileService)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("letmein"), bcrypt.MinCost)
+	require.NoError(t, err)
+	expired := time.Now().Add(-time.Hour)
+
+	albums := map[string]*models.Album{
+		"public":    {Title: "Public", Slug: "public", Visibility: "public"},
+		"unlisted":  {Title: "Unlisted", Slug: "unlisted", Visibility: "unlisted"},
+		"protected": {Title: "Protected", Slug: "protected", Visibility: "password_protected", PasswordHash: string(hash)},
+		"expired":   {Title: "Expired", Slug: "expired", Visibility: "password_protected", PasswordHash: string(hash), ExpirationDate: &expired},
+		"draft":     {Title: "Draft", Slug: "draft", Visibility: "draft", PasswordHash: string(hash)},
+	}
+	for _, album := range albums {
+		require.NoError(t, albumService.Create(album))
+		require.NoError(t, albumService.AddPhoto(album.ID, &models.Photo{
+			FilenameOriginal: "IMG_0001.jpg",
+			URLOriginal:      "/uploads/originals/1.jpg",
+			URLDisplay:       "/uploads/display/1.webp",
+		}))
+	}
+
+	accessService, err := services.NewAlbumAccessService(nil, time.Hour)
+	require.NoError(t, err)
+	shareLinkService := services.NewShareLinkService(fileService)
+	accessService.SetShareLinkService(shareLinkService)
+	handler := NewAlbumAccessHandler(albumService, accessService, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	r := chi.NewRouter()
+	r.Get("/api/albums/{slug}", handler.GetAlbum)
+	r.Post("/api/albums/{slug}/access", handler.RequestAccess)
+	return r, albums, shareLinkService
+}
+
+func requestAlbumAccess(router http.Handler, slug, password string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/api/albums/"+slug+"/access", strings.NewReader(`{"password":"`+password+`"}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAlbumAccessHandler_RequestAccess(t *testing.T) {
+	router, albums, _ := setupAlbumAccessRouter(t)
+
+	w := requestAlbumAccess(router, "protected", "wrong")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = requestAlbumAccess(router, "protected", "letmein")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.NotEmpty(t, resp.Token)
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "album_access_"+albums["protected"].ID, cookies[0].Name)
+	assert.Equal(t, resp.Token, cookies[0].Value)
+	assert.True(t, cookies[0].HttpOnly)
+
+	// Albums without a password, missing and expired albums all look the same
+	for _, slug := range []string{"public", "unlisted", "missing", "expired"} {
+		w = requestAlbumAccess(router, slug, "letmein")
+		assert.Equal(t, http.StatusNotFound, w.Code, slug)
+	}
+}
+
+func TestAlbumAccessHandler_RequestAccess_RateLimited(t *testing.T) {
+	router, _, _ := setupAlbumAccessRouter(t)
+
+	var w *httptest.ResponseRecorder
+	for i := 0; i < 20; i++ {
+		w = requestAlbumAccess(router, "protected", "wrong")
+		if w.Code == http.StatusTooManyRequests {
+			break
+		}
+	}
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+}
+
+func TestAlbumAccessHandler_GetAlbum(t *testing.T) {
+	router, _, _ := setupAlbumAccessRouter(t)
+
+	getAlbum := func(slug string, modify func(*http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/albums/"+slug, nil)
+		if modify != nil {
+			modify(req)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Public and unlisted albums need no token, and are sanitized
+	w := getAlbum("unlisted", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "filename_original")
+
+	// Protected albums need a token
+	w = getAlbum("protected", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = getAlbum("protected", func(r *http.Request) { r.Header.Set("Authorization", "Bearer forged.token") })
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	access := requestAlbumAccess(router, "protected", "letmein")
+	require.Equal(t, http.StatusOK, access.Code)
+	var resp struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(access.Body.Bytes(), &resp))
+
+	// With the token as a Bearer token or as the cookie
+	for name, modify := range map[string]func(*http.Request){
+		"bearer": func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+resp.Token) },
+		"cookie": func(r *http.Request) { r.AddCookie(access.Result().Cookies()[0]) },
+	} {
+		w = getAlbum("protected", modify)
+		require.Equal(t, http.StatusOK, w.Code, name)
+
+		var album models.PublicAlbum
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &album))
+		assert.Len(t, album.Photos, 1, name)
+		assert.NotContains(t, w.Body.String(), "password_hash", name)
+	}
+
+	// Expired and missing albums
+	assert.Equal(t, http.StatusNotFound, getAlbum("expired", nil).Code)
+	assert.Equal(t, http.StatusNotFound, getAlbum("missing", nil).Code)
+}
+
+func TestAlbumAccessHandler_ShareLink(t *testing.T) {
+	router, albums, shareLinks := setupAlbumAccessRouter(t)
+
+	redeem := func(slug, shareToken string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/albums/"+slug+"/access", strings.NewReader(`{"share_token":"`+shareToken+`"}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	link := &models.ShareLink{Label: "Bride's family", ExpiresAt: time.Now().Add(24 * time.Hour), AllowDownloads: true, MaxViews: 1}
+	shareToken, err := shareLinks.Create(albums["protected"].ID, link)
+	require.NoError(t, err)
+
+	// Wrong tokens and tokens for another album look like missing albums
+	assert.Equal(t, http.StatusNotFound, redeem("protected", "wrong").Code)
+	assert.Equal(t, http.StatusNotFound, redeem("public", shareToken).Code)
+
+	w := redeem("protected", shareToken)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Token          string `json:"token"`
+		AllowDownloads bool   `json:"allow_downloads"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.AllowDownloads)
+
+	// The link was used up
+	assert.Equal(t, http.StatusNotFound, redeem("protected", shareToken).Code)
+
+	// Its token still opens the album, with downloads
+	req := httptest.NewRequest("GET", "/api/albums/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+resp.Token)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var album models.PublicAlbum
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &album))
+	require.Len(t, album.Photos, 1)
+	assert.Equal(t, "/uploads/originals/1.jpg", album.Photos[0].URLOriginal)
+
+	stored, err := shareLinks.Get(link.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stored.Views)
+	assert.NotNil(t, stored.FirstUsedAt)
+}
+
+func TestAlbumAccessHandler_Draft(t *testing.T) {
+	router, albums, shareLinks := setupAlbumAccessRouter(t)
+
+	do := func(method, url, body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Drafts look missing, and their password does not open them
+	assert.Equal(t, http.StatusNotFound, do("GET", "/api/albums/draft", "", "").Code)
+	assert.Equal(t, http.StatusNotFound, requestAlbumAccess(router, "draft", "letmein").Code)
+
+	// Nor do share links, only preview links
+	shareToken, err := shareLinks.Create(albums["draft"].ID, &models.ShareLink{ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, do("POST", "/api/albums/draft/access", `{"share_token":"`+shareToken+`"}`, "").Code)
+
+	previewToken, err := shareLinks.Create(albums["draft"].ID, &models.ShareLink{ExpiresAt: time.Now().Add(time.Hour), Preview: true})
+	require.NoError(t, err)
+	w := do("POST", "/api/albums/draft/access", `{"share_token":"`+previewToken+`"}`, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	w = do("GET", "/api/albums/draft", "", resp.Token)
+	require.Equal(t, http.StatusOK, w.Code)
+	var album models.PublicAlbum
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &album))
+	assert.Equal(t, "draft", album.Visibility)
+	assert.Len(t, album.Photos, 1)
+}
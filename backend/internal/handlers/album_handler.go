@@ -2,13 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/middleware"
 	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
 	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // AlbumHandler handles album-related HTTP requests.
@@ -76,6 +77,8 @@ func (h *AlbumHandler) Create(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	middleware.AuditAlbum(r.Context(), album.ID)
+	middleware.AuditChange(r.Context(), nil, albumAudit(&album))
 
 	respondJSON(w, http.StatusCreated, album)
 }
@@ -90,11 +93,13 @@ func (h *AlbumHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before, _ := h.albumService.GetByID(id)
 	if err := h.albumService.Update(id, &updates); err != nil {
 		h.logger.Error("failed to update album", slog.String("error", err.Error()))
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	h.auditAlbumChange(r, before)
 
 	respondJSON(w, http.StatusOK, updates)
 }
@@ -131,6 +136,7 @@ func (h *AlbumHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	middleware.AuditChange(r.Context(), albumAudit(album), nil)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -164,6 +170,7 @@ func (h *AlbumHandler) UploadPhotos(w http.ResponseWriter, r *http.Request) {
 	// Process each file
 	uploadedPhotos := []models.Photo{}
 	errors := []string{}
+	failureStatus := http.StatusInternalServerError
 
 	for _, fileHeader := range files {
 		photo, err := h.imageService.ProcessUpload(fileHeader)
@@ -172,7 +179,11 @@ func (h *AlbumHandler) UploadPhotos(w http.ResponseWriter, r *http.Request) {
 				slog.String("filename", fileHeader.Filename),
 				slog.String("error", err.Error()),
 			)
-			errors = append(errors, fileHeader.Filename+": "+err.Error())
+			status, message := uploadError(err)
+			if len(errors) == 0 {
+				failureStatus = status
+			}
+			errors = append(errors, fileHeader.Filename+": "+message)
 			continue
 		}
 
@@ -182,19 +193,47 @@ func (h *AlbumHandler) UploadPhotos(w http.ResponseWriter, r *http.Request) {
 				slog.String("filename", fileHeader.Filename),
 				slog.String("error", err.Error()),
 			)
-			errors = append(errors, fileHeader.Filename+": "+err.Error())
+			errors = append(errors, fileHeader.Filename+": failed to add photo to album")
 			continue
 		}
 
 		uploadedPhotos = append(uploadedPhotos, *photo)
 	}
 
+	// When nothing could be uploaded, report why in the status code
+	if len(uploadedPhotos) == 0 {
+		respondJSON(w, failureStatus, map[string]interface{}{
+			"error":    errors[0],
+			"uploaded": uploadedPhotos,
+			"errors":   errors,
+		})
+		return
+	}
+
 	respondJSON(w, http.StatusOK, map[string]interface{}{
 		"uploaded": uploadedPhotos,
 		"errors":   errors,
 	})
 }
 
+// uploadError maps an image processing error to an HTTP status and the
+// message for the client: 413 for files over a size or pixel limit, 422 for
+// files that cannot be read as a supported image and 507 when the disk is too
+// full, each with its reason. Anything else failed on the server, so it is a
+// 500 whose details are only logged.
+func uploadError(err error) (int, string) {
+	switch {
+	case errors.Is(err, services.ErrImageTooLarge):
+		return http.StatusRequestEntityTooLarge, err.Error()
+	case errors.Is(err, services.ErrImageUnprocessable):
+		return http.StatusUnprocessableEntity, err.Error()
+	case errors.Is(err, services.ErrInsufficientStorage):
+		return http.StatusInsufficientStorage, err.Error()
+	default:
+		return http.StatusInternalServerError, "failed to process image"
+	}
+}
+
 // DeletePhoto deletes a photo from an album.
 func (h *AlbumHandler) DeletePhoto(w http.ResponseWriter, r *http.Request) {
 	albumID := chi.URLParam(r, "id")
@@ -235,6 +274,7 @@ func (h *AlbumHandler) DeletePhoto(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	middleware.AuditChange(r.Context(), photoAudit(photo), nil)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -268,6 +308,7 @@ func (h *AlbumHandler) DeleteAllPhotos(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	h.auditAlbumChange(r, album)
 
 	// Return result
 	response := map[string]interface{}{
@@ -304,8 +345,13 @@ func (h *AlbumHandler) SetPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := services.ValidateAlbumPassword(req.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Hash password
-	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hash, err := services.HashPassword(req.Password)
 	if err != nil {
 		h.logger.Error("failed to hash password", slog.String("error", err.Error()))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
@@ -313,14 +359,16 @@ func (h *AlbumHandler) SetPassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update album
+	before := *album
 	album.Visibility = "password_protected"
-	album.PasswordHash = string(hash)
+	album.PasswordHash = hash
 
 	if err := h.albumService.Update(albumID, album); err != nil {
 		h.logger.Error("failed to update album", slog.String("error", err.Error()))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	h.auditAlbumChange(r, &before)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -337,6 +385,7 @@ func (h *AlbumHandler) RemovePassword(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Update album
+	before := *album
 	album.Visibility = "public"
 	album.PasswordHash = ""
 
@@ -345,6 +394,7 @@ func (h *AlbumHandler) RemovePassword(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	h.auditAlbumChange(r, &before)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -361,11 +411,13 @@ func (h *AlbumHandler) SetCoverPhoto(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before, _ := h.albumService.GetByID(albumID)
 	if err := h.albumService.SetCoverPhoto(albumID, req.PhotoID); err != nil {
 		h.logger.Error("failed to set cover photo", slog.String("error", err.Error()))
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	h.auditAlbumChange(r, before)
 
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -396,6 +448,346 @@ func (h *AlbumHandler) ReorderPhotos(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// SetFocalPoint sets a photo's focal point and regenerates its cropped versions.
+func (h *AlbumHandler) SetFocalPoint(w http.ResponseWriter, r *http.Request) {
+	albumID := chi.URLParam(r, "id")
+	photoID := chi.URLParam(r, "photoId")
+
+	var focal models.FocalPoint
+	if err := json.NewDecoder(r.Body).Decode(&focal); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := focal.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.updateFocalPoint(w, albumID, photoID, &focal)
+}
+
+// ClearFocalPoint removes a photo's focal point so its crops fall back to smart cropping.
+func (h *AlbumHandler) ClearFocalPoint(w http.ResponseWriter, r *http.Request) {
+	albumID := chi.URLParam(r, "id")
+	photoID := chi.URLParam(r, "photoId")
+
+	h.updateFocalPoint(w, albumID, photoID, nil)
+}
+
+// updateFocalPoint stores a focal point (nil to clear) and regenerates only the cropped tiers.
+func (h *AlbumHandler) updateFocalPoint(w http.ResponseWriter, albumID, photoID string, focal *models.FocalPoint) {
+	album, err := h.albumService.GetByID(albumID)
+	if err != nil {
+		http.Error(w, "Album not found", http.StatusNotFound)
+		return
+	}
+
+	photo := findPhoto(album, photoID)
+	if photo == nil {
+		http.Error(w, "Photo not found", http.StatusNotFound)
+		return
+	}
+
+	photo.FocalPoint = focal
+
+	// The new crops replace the current ones only once the photo is saved
+	staged, err := h.imageService.StageCrops(photo)
+	if err != nil {
+		h.logger.Error("failed to regenerate cropped versions",
+			slog.String("photo_id", photoID),
+			slog.String("error", err.Error()),
+		)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.albumService.UpdatePhoto(albumID, photoID, photo); err != nil {
+		h.imageService.AbortDerivatives(staged)
+		h.logger.Error("failed to update photo", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.imageService.CommitDerivatives(staged); err != nil {
+		h.logger.Error("failed to replace cropped versions",
+			slog.String("photo_id", photoID),
+			slog.String("error", err.Error()),
+		)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, photo)
+}
+
+// EditPhoto applies a non-destructive edit stack to a photo and re-renders its
+// derivatives from the untouched original.
+func (h *AlbumHandler) EditPhoto(w http.ResponseWriter, r *http.Request) {
+	albumID := chi.URLParam(r, "id")
+	photoID := chi.URLParam(r, "photoId")
+
+	var edits models.PhotoEdits
+	if err := json.NewDecoder(r.Body).Decode(&edits); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := edits.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if edits.IsEmpty() {
+		h.updateEdits(w, albumID, photoID, nil)
+		return
+	}
+
+	h.updateEdits(w, albumID, photoID, &edits)
+}
+
+// RevertPhotoEdits discards a photo's edit stack and re-renders its derivatives
+// from the original.
+func (h *AlbumHandler) RevertPhotoEdits(w http.ResponseWriter, r *http.Request) {
+	albumID := chi.URLParam(r, "id")
+	photoID := chi.URLParam(r, "photoId")
+
+	h.updateEdits(w, albumID, photoID, nil)
+}
+
+// updateEdits stores an edit stack (nil to revert) and re-renders all derivatives.
+// The focal point is cleared because it no longer matches the new geometry.
+func (h *AlbumHandler) updateEdits(w http.ResponseWriter, albumID, photoID string, edits *models.PhotoEdits) {
+	album, err := h.albumService.GetByID(albumID)
+	if err != nil {
+		http.Error(w, "Album not found", http.StatusNotFound)
+		return
+	}
+
+	photo := findPhoto(album, photoID)
+	if photo == nil {
+		http.Error(w, "Photo not found", http.StatusNotFound)
+		return
+	}
+
+	photo.Edits = edits
+	photo.FocalPoint = nil
+
+	// The new derivatives replace the current ones only once the photo is saved
+	staged, err := h.imageService.StageDerivatives(photo)
+	if err != nil {
+		if errors.Is(err, services.ErrUnsupportedEdit) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		h.logger.Error("failed to render edited photo",
+			slog.String("photo_id", photoID),
+			slog.String("error", err.Error()),
+		)
+		http.Error(w, "Failed to apply edits", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.albumService.UpdatePhoto(albumID, photoID, photo); err != nil {
+		h.imageService.AbortDerivatives(staged)
+		h.logger.Error("failed to update photo", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.imageService.CommitDerivatives(staged); err != nil {
+		h.logger.Error("failed to replace derivatives of edited photo",
+			slog.String("photo_id", photoID),
+			slog.String("error", err.Error()),
+		)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, photo)
+}
+
+// ReplacePhotoFile swaps a photo's image for a newly uploaded file while keeping
+// its ID, caption, alt text, order and cover status. The previous file is kept
+// in the photo's version history.
+func (h *AlbumHandler) ReplacePhotoFile(w http.ResponseWriter, r *http.Request) {
+	albumID := chi.URLParam(r, "id")
+	photoID := chi.URLParam(r, "photoId")
+
+	album, err := h.albumService.GetByID(albumID)
+	if err != nil {
+		http.Error(w, "Album not found", http.StatusNotFound)
+		return
+	}
+
+	photo := findPhoto(album, photoID)
+	if photo == nil {
+		http.Error(w, "Photo not found", http.StatusNotFound)
+		return
+	}
+
+	// Parse multipart form (max 500 MB)
+	if err := r.ParseMultipartForm(500 << 20); err != nil {
+		http.Error(w, "Failed to parse form", http.StatusBadRequest)
+		return
+	}
+
+	files := r.MultipartForm.File["photo"]
+	if len(files) != 1 {
+		http.Error(w, "Exactly one file must be uploaded in the \"photo\" field", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := h.imageService.ReplaceFile(photo, files[0])
+	if err != nil {
+		h.logger.Error("failed to process replacement file",
+			slog.String("photo_id", photoID),
+			slog.String("filename", files[0].Filename),
+			slog.String("error", err.Error()),
+		)
+		status, message := uploadError(err)
+		http.Error(w, message, status)
+		return
+	}
+
+	h.savePhotoReplacement(w, albumID, photo, updated)
+}
+
+// RestorePhotoVersion makes a previous file of a photo current again.
+func (h *AlbumHandler) RestorePhotoVersion(w http.ResponseWriter, r *http.Request) {
+	albumID := chi.URLParam(r, "id")
+	photoID := chi.URLParam(r, "photoId")
+	versionID := chi.URLParam(r, "versionId")
+
+	album, err := h.albumService.GetByID(albumID)
+	if err != nil {
+		http.Error(w, "Album not found", http.StatusNotFound)
+		return
+	}
+
+	photo := findPhoto(album, photoID)
+	if photo == nil {
+		http.Error(w, "Photo not found", http.StatusNotFound)
+		return
+	}
+
+	updated, err := h.imageService.RestoreVersion(photo, versionID)
+	if err != nil {
+		if errors.Is(err, services.ErrVersionNotFound) {
+			http.Error(w, "Version not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("failed to restore photo version",
+			slog.String("photo_id", photoID),
+			slog.String("version_id", versionID),
+			slog.String("error", err.Error()),
+		)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.savePhotoReplacement(w, albumID, photo, updated)
+}
+
+// DeletePhotoVersion permanently removes a previous file from a photo's version history.
+func (h *AlbumHandler) DeletePhotoVersion(w http.ResponseWriter, r *http.Request) {
+	albumID := chi.URLParam(r, "id")
+	photoID := chi.URLParam(r, "photoId")
+	versionID := chi.URLParam(r, "versionId")
+
+	album, err := h.albumService.GetByID(albumID)
+	if err != nil {
+		http.Error(w, "Album not found", http.StatusNotFound)
+		return
+	}
+
+	photo := findPhoto(album, photoID)
+	if photo == nil {
+		http.Error(w, "Photo not found", http.StatusNotFound)
+		return
+	}
+
+	updated, err := h.imageService.DeleteVersion(photo, versionID)
+	if err != nil {
+		if errors.Is(err, services.ErrVersionNotFound) {
+			http.Error(w, "Version not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("failed to delete photo version",
+			slog.String("photo_id", photoID),
+			slog.String("version_id", versionID),
+			slog.String("error", err.Error()),
+		)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.savePhotoReplacement(w, albumID, photo, updated)
+}
+
+// savePhotoReplacement saves an updated photo and then removes the files it
+// superseded. If saving fails, the files created for the update are removed instead.
+func (h *AlbumHandler) savePhotoReplacement(w http.ResponseWriter, albumID string, previous, updated *models.Photo) {
+	if err := h.albumService.UpdatePhoto(albumID, previous.ID, updated); err != nil {
+		h.imageService.AbortReplacement(previous, updated)
+		h.logger.Error("failed to update photo", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := h.imageService.CommitReplacement(previous, updated); err != nil {
+		h.logger.Warn("failed to remove replaced photo files",
+			slog.String("photo_id", previous.ID),
+			slog.String("error", err.Error()),
+		)
+	}
+
+	respondJSON(w, http.StatusOK, updated)
+}
+
+// auditAlbumChange records how a request changed an album in its audit entry,
+// from the album before the change.
+func (h *AlbumHandler) auditAlbumChange(r *http.Request, before *models.Album) {
+	if before == nil {
+		return
+	}
+	after, err := h.albumService.GetByID(before.ID)
+	if err != nil {
+		return
+	}
+	middleware.AuditChange(r.Context(), albumAudit(before), albumAudit(after))
+}
+
+// albumAuditSummary is an album in the audit log, with the number of its
+// photos instead of the photos.
+type albumAuditSummary struct {
+	models.Album
+	Photos int `json:"photos"`
+}
+
+func albumAudit(album *models.Album) *albumAuditSummary {
+	return &albumAuditSummary{Album: *album, Photos: len(album.Photos)}
+}
+
+// photoAudit summarizes a photo for the audit log.
+func photoAudit(photo *models.Photo) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                photo.ID,
+		"filename_original": photo.FilenameOriginal,
+		"caption":           photo.Caption,
+	}
+}
+
+// findPhoto returns a pointer to the photo with the given ID inside album, or nil.
+func findPhoto(album *models.Album, photoID string) *models.Photo {
+	for i := range album.Photos {
+		if album.Photos[i].ID == photoID {
+			return &album.Photos[i]
+		}
+	}
+	return nil
+}
+
 // respondJSON writes a JSON response.
 func respondJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/middleware"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+)
+
+// AlbumPasswordHandler handles the admin endpoints for named album passwords.
+type AlbumPasswordHandler struct {
+	albumService    *services.AlbumService
+	passwordService *services.AlbumPasswordService
+	logger          *slog.Logger
+}
+
+// NewAlbumPasswordHandler creates a new album password handler.
+func NewAlbumPasswordHandler(
+	albumService *services.AlbumService,
+	passwordService *services.AlbumPasswordService,
+	logger *slog.Logger,
+) *AlbumPasswordHandler {
+	return &AlbumPasswordHandler{
+		albumService:    albumService,
+		passwordService: passwordService,
+		logger:          logger,
+	}
+}
+
+// Create adds a named password to an album. It opens the album while the album
+// is password-protected, alongside the album's main password.
+func (h *AlbumPasswordHandler) Create(w http.ResponseWriter, r *http.Request) {
+	albumID := chi.URLParam(r, "id")
+	if _, err := h.albumService.GetByID(albumID); err != nil {
+		if err.Error() == "album not found" {
+			http.Error(w, "Album not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("failed to get album", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	var req struct {
+		Name      string     `json:"name"`
+		Password  string     `json:"password"`
+		ExpiresAt *time.Time `json:"expires_at"`
+		MaxUses   int        `json:"max_uses"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	albumPassword := &models.AlbumPassword{
+		Name:      req.Name,
+		ExpiresAt: req.ExpiresAt,
+		MaxUses:   req.MaxUses,
+	}
+	if err := h.passwordService.Create(albumID, albumPassword, req.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	middleware.AuditChange(r.Context(), nil, albumPassword)
+
+	h.logger.Info("album password created",
+		slog.String("album_id", albumID),
+		slog.String("password_id", albumPassword.ID),
+	)
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"password": albumPassword.Sanitized(),
+	})
+}
+
+// List returns an album's named passwords with their access logs.
+func (h *AlbumPasswordHandler) List(w http.ResponseWriter, r *http.Request) {
+	albumID := chi.URLParam(r, "id")
+	if _, err := h.albumService.GetByID(albumID); err != nil {
+		if err.Error() == "album not found" {
+			http.Error(w, "Album not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("failed to get album", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	passwords, err := h.passwordService.ListByAlbum(albumID)
+	if err != nil {
+		h.logger.Error("failed to list album passwords", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	for i := range passwords {
+		passwords[i] = passwords[i].Sanitized()
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"passwords": passwords,
+	})
+}
+
+// Delete removes a named password. Access already granted with it ends as well.
+func (h *AlbumPasswordHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	albumID := chi.URLParam(r, "id")
+	passwordID := chi.URLParam(r, "passwordId")
+
+	before, _ := h.passwordService.Get(passwordID)
+	if err := h.passwordService.Delete(albumID, passwordID); err != nil {
+		if err.Error() == "album password not found" {
+			http.Error(w, "Album password not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("failed to delete album password", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if before != nil {
+		middleware.AuditChange(r.Context(), before, nil)
+	}
+
+	h.logger.Info("album password deleted",
+		slog.String("album_id", albumID),
+		slog.String("password_id", passwordID),
+	)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/middleware"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+)
+
+// APITokenHandler handles the admin endpoints for the signed-in user's API
+// tokens.
+type APITokenHandler struct {
+	tokenService *services.APITokenService
+	logger       *slog.Logger
+}
+
+// NewAPITokenHandler creates a new API token handler.
+func NewAPITokenHandler(tokenService *services.APITokenService, logger *slog.Logger) *APITokenHandler {
+	return &APITokenHandler{
+		tokenService: tokenService,
+		logger:       logger,
+	}
+}
+
+// List returns the signed-in user's API tokens, newest first.
+func (h *APITokenHandler) List(w http.ResponseWriter, r *http.Request) {
+	tokens, err := h.tokenService.List(middleware.GetSession(r.Context()).UserID)
+	if err != nil {
+		h.logger.Error("failed to list API tokens", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	for i := range tokens {
+		tokens[i] = tokens[i].Sanitized()
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"api_tokens": tokens,
+	})
+}
+
+// Create adds an API token with a name, scopes and an optional expiry. The
+// token is in the response and cannot be shown again.
+func (h *APITokenHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name      string         `json:"name"`
+		Scopes    []models.Scope `json:"scopes"`
+		ExpiresAt *time.Time     `json:"expires_at"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	session := middleware.GetSession(r.Context())
+	token := &models.APIToken{
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	}
+	secret, err := h.tokenService.Create(session.UserID, token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.logger.Info("API token created",
+		slog.String("token_id", token.ID),
+		slog.String("name", token.Name),
+		slog.Any("scopes", token.Scopes),
+		slog.String("username", session.Username),
+	)
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"api_token": token.Sanitized(),
+		"token":     secret,
+	})
+}
+
+// Revoke deletes one of the signed-in user's API tokens.
+func (h *APITokenHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetSession(r.Context())
+	tokenID := chi.URLParam(r, "tokenId")
+
+	if err := h.tokenService.Revoke(session.UserID, tokenID); err != nil {
+		if err.Error() == "API token not found" {
+			http.Error(w, "API token not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("failed to revoke API token", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("API token revoked",
+		slog.String("token_id", tokenID),
+		slog.String("username", session.Username),
+	)
+
+	w.WriteHeader(http.StatusNoContent)
+}
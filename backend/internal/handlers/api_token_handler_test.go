@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/middleware"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupAPITokenRouter mounts the token endpoints for browser sessions, and
+// stand-in endpoints for reading and writing albums, behind the auth
+// middleware as in the server.
+func setupAPITokenRouter(t *testing.T) (http.Handler, *services.UserService) {
+	fileService, err := services.NewFileService(t.TempDir())
+	require.NoError(t, err)
+	users := services.NewUserService(fileService)
+	hash, err := services.HashPassword("owner-pass")
+	require.NoError(t, err)
+	_, err = users.Migrate(models.AdminConfig{Username: "owner", PasswordHash: hash})
+	require.NoError(t, err)
+
+	authService := services.NewAuthService(users, time.Hour)
+	tokenService := services.NewAPITokenService(fileService)
+	authService.SetAPITokenService(tokenService)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := NewAPITokenHandler(tokenService, logger)
+	authHandler := NewAuthHandler(authService, logger)
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	r := chi.NewRouter()
+	r.Post("/login", authHandler.Login)
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Auth(authService, logger))
+		r.Get("/auth/check", authHandler.Check)
+		r.With(middleware.RequireScope(models.ScopeAlbumsRead, logger)).Get("/albums", ok)
+		r.With(middleware.RequireRole(models.RoleEditor, logger), middleware.RequireScope(models.ScopeAlbumsWrite, logger)).Post("/albums", ok)
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireBrowserSession(logger))
+			r.Get("/tokens", handler.List)
+			r.Post("/tokens", handler.Create)
+			r.Delete("/tokens/{tokenId}", handler.Revoke)
+		})
+	})
+	return r, users
+}
+
+func bearerRequest(router http.Handler, method, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAPITokenHandler_Scopes(t *testing.T) {
+	router, users := setupAPITokenRouter(t)
+	cookie := sessionCookie(t, postLogin(router, "owner", "owner-pass"))
+
+	assert.Equal(t, http.StatusBadRequest, postJSON(router, "/tokens", `{"name":"bad","scopes":["everything"]}`, cookie).Code)
+	assert.Equal(t, http.StatusBadRequest, postJSON(router, "/tokens", `{"name":"old","scopes":["upload"],"expires_at":"2020-01-01T00:00:00Z"}`, cookie).Code)
+
+	w := postJSON(router, "/tokens", `{"name":"Lightroom","scopes":["albums:read"]}`, cookie)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created struct {
+		APIToken models.APIToken `json:"api_token"`
+		Token    string          `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.True(t, strings.HasPrefix(created.Token, created.APIToken.Prefix))
+	assert.Empty(t, created.APIToken.TokenHash)
+
+	// The token works for its scopes only, and never for account management
+	assert.Equal(t, http.StatusNoContent, bearerRequest(router, "GET", "/albums", created.Token).Code)
+	assert.Contains(t, bearerRequest(router, "GET", "/auth/check", created.Token).Body.String(), `"username":"owner"`)
+	assert.Equal(t, http.StatusForbidden, bearerRequest(router, "POST", "/albums", created.Token).Code)
+	assert.Equal(t, http.StatusForbidden, bearerRequest(router, "GET", "/tokens", created.Token).Code)
+	assert.Equal(t, http.StatusUnauthorized, bearerRequest(router, "GET", "/albums", "nsf_unknown").Code)
+
+	// A token can do no more than its user's role
+	w = postJSON(router, "/tokens", `{"name":"Script","scopes":["albums:read","albums:write"]}`, cookie)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var writer struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &writer))
+	assert.Equal(t, http.StatusNoContent, bearerRequest(router, "POST", "/albums", writer.Token).Code)
+
+	viewer := &models.User{Username: "viewer", Role: models.RoleViewer}
+	require.NoError(t, users.Create(viewer, "viewer-pass"))
+	viewerCookie := sessionCookie(t, postLogin(router, "viewer", "viewer-pass"))
+	w = postJSON(router, "/tokens", `{"name":"Script","scopes":["albums:write"]}`, viewerCookie)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &writer))
+	assert.Equal(t, http.StatusForbidden, bearerRequest(router, "POST", "/albums", writer.Token).Code)
+
+	// Tokens are listed per user, and revoking one stops it working
+	req := httptest.NewRequest("GET", "/tokens", nil)
+	req.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		APITokens []models.APIToken `json:"api_tokens"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list.APITokens, 2)
+
+	req = httptest.NewRequest("DELETE", "/tokens/"+created.APIToken.ID, nil)
+	req.AddCookie(viewerCookie)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code, "users cannot revoke each other's tokens")
+
+	req = httptest.NewRequest("DELETE", "/tokens/"+created.APIToken.ID, nil)
+	req.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, http.StatusUnauthorized, bearerRequest(router, "GET", "/albums", created.Token).Code)
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+)
+
+// AuditHandler handles the audit log endpoint and its retention.
+type AuditHandler struct {
+	auditService *services.AuditService
+	logger       *slog.Logger
+}
+
+// NewAuditHandler creates a new audit handler.
+func NewAuditHandler(auditService *services.AuditService, logger *slog.Logger) *AuditHandler {
+	return &AuditHandler{
+		auditService: auditService,
+		logger:       logger,
+	}
+}
+
+// List returns the newest audit entries that match the query's filters
+// (actor, action, album_id, photo_id, request_id, and since and until as
+// RFC 3339 times), newest first, up to limit. With format=jsonl it downloads
+// every matching entry as JSON Lines instead, oldest first.
+func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := services.AuditFilter{
+		Actor:     query.Get("actor"),
+		Action:    query.Get("action"),
+		AlbumID:   query.Get("album_id"),
+		PhotoID:   query.Get("photo_id"),
+		RequestID: query.Get("request_id"),
+	}
+	for name, t := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := query.Get(name); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, name+" must be an RFC 3339 time", http.StatusBadRequest)
+				return
+			}
+			*t = parsed
+		}
+	}
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > services.MaxAuditQueryLimit {
+			http.Error(w, "limit must be between 1 and "+strconv.Itoa(services.MaxAuditQueryLimit), http.StatusBadRequest)
+			return
+		}
+		filter.Limit = limit
+	}
+
+	switch query.Get("format") {
+	case "", "json":
+		entries, err := h.auditService.Query(filter)
+		if err != nil {
+			h.logger.Error("failed to read audit log", slog.String("error", err.Error()))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"entries": entries,
+		})
+
+	case "jsonl":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="audit.jsonl"`)
+		// Entries are streamed, so the response has usually started by the
+		// time anything fails
+		if err := h.auditService.Export(w, filter); err != nil {
+			h.logger.Error("failed to export audit log", slog.String("error", err.Error()))
+		}
+
+	default:
+		http.Error(w, "Format must be json or jsonl", http.StatusBadRequest)
+	}
+}
+
+// StartPruning starts a goroutine that applies the audit retention settings
+// right away and then hourly.
+func (h *AuditHandler) StartPruning() {
+	go func() {
+		h.prune()
+
+		ticker := time.NewTicker(1 * time.Hour)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			h.prune()
+		}
+	}()
+}
+
+func (h *AuditHandler) prune() {
+	removed, err := h.auditService.Prune()
+	if err != nil {
+		h.logger.Warn("failed to prune audit log", slog.String("error", err.Error()))
+		return
+	}
+	if removed > 0 {
+		h.logger.Info("pruned audit log", slog.Int("removed", removed))
+	}
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/middleware"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type auditTestEnv struct {
+	router       http.Handler
+	ownerCookie  *http.Cookie
+	viewerCookie *http.Cookie
+}
+
+// setupAuditRouter mounts album, config and audit endpoints behind the audit
+// middleware, and signs in an owner and a viewer.
+func setupAuditRouter(t *testing.T) *auditTestEnv {
+	fileService, err := services.NewFileService(t.TempDir())
+	require.NoError(t, err)
+	users := services.NewUserService(fileService)
+	hash, err := services.HashPassword("owner-pass")
+	require.NoError(t, err)
+	_, err = users.Migrate(models.AdminConfig{Username: "owner", PasswordHash: hash})
+	require.NoError(t, err)
+	require.NoError(t, users.Create(&models.User{Username: "viewer", Role: models.RoleViewer}, "viewer-pass"))
+	configService := services.NewSiteConfigService(fileService)
+	require.NoError(t, configService.Update(&models.SiteConfig{
+		Site:    models.SiteInfo{Title: "Old title", Language: "en"},
+		Storage: models.StorageConfig{MaxDiskUsagePercent: 80, MaxImageSizeMB: 50},
+	}))
+
+	authService := services.NewAuthService(users, time.Hour)
+	auditService := services.NewAuditService(fileService, services.DefaultAuditConfig())
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	albumHandler := NewAlbumHandler(services.NewAlbumService(fileService), nil, logger)
+	configHandler := NewConfigHandler(configService, logger)
+	auditHandler := NewAuditHandler(auditService, logger)
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Auth(authService, logger))
+			r.Use(middleware.Audit(auditService, logger))
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireRole(models.RoleEditor, logger))
+				r.Post("/albums", albumHandler.Create)
+				r.Put("/albums/{id}", albumHandler.Update)
+				r.Post("/albums/{id}/set-password", albumHandler.SetPassword)
+				r.Delete("/albums/{id}/password", albumHandler.RemovePassword)
+				r.Delete("/albums/{id}", albumHandler.Delete)
+				r.Put("/config", configHandler.Update)
+			})
+			r.With(middleware.RequireRole(models.RoleOwner, logger)).Get("/audit", auditHandler.List)
+		})
+	})
+
+	login := func(username, password string) *http.Cookie {
+		token, err := authService.Authenticate(username, password)
+		require.NoError(t, err)
+		return &http.Cookie{Name: "photoadmin_session", Value: token}
+	}
+	return &auditTestEnv{
+		router:       r,
+		ownerCookie:  login("owner", "owner-pass"),
+		viewerCookie: login("viewer", "viewer-pass"),
+	}
+}
+
+func (env *auditTestEnv) do(method, url, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	return w
+}
+
+func (env *auditTestEnv) entries(t *testing.T, query string) []models.AuditEntry {
+	w := env.do("GET", "/api/admin/audit"+query, "", env.ownerCookie)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Entries []models.AuditEntry `json:"entries"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response.Entries
+}
+
+func TestAuditHandler(t *testing.T) {
+	env := setupAuditRouter(t)
+
+	w := env.do("POST", "/api/admin/albums", `{"title":"Wedding","slug":"wedding","visibility":"public"}`, env.ownerCookie)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var album models.Album
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &album))
+	requestID := w.Header().Get("X-Request-ID")
+
+	require.Equal(t, http.StatusNoContent, env.do("POST", "/api/admin/albums/"+album.ID+"/set-password", `{"password":"secret-pass"}`, env.ownerCookie).Code)
+	require.Equal(t, http.StatusNoContent, env.do("DELETE", "/api/admin/albums/"+album.ID+"/password", "", env.ownerCookie).Code)
+	require.Equal(t, http.StatusOK, env.do("PUT", "/api/admin/config", `{"site":{"title":"New title","language":"en"},"storage":{"max_disk_usage_percent":80,"max_image_size_mb":50}}`, env.ownerCookie).Code)
+	require.Equal(t, http.StatusForbidden, env.do("DELETE", "/api/admin/albums/"+album.ID, "", env.viewerCookie).Code)
+	require.Equal(t, http.StatusNoContent, env.do("DELETE", "/api/admin/albums/"+album.ID, "", env.ownerCookie).Code)
+
+	// Reads are not recorded
+	entries := env.entries(t, "")
+	require.Len(t, entries, 6)
+	assert.Equal(t, []string{
+		"DELETE /api/admin/albums/{id}",
+		"DELETE /api/admin/albums/{id}",
+		"PUT /api/admin/config",
+		"DELETE /api/admin/albums/{id}/password",
+		"POST /api/admin/albums/{id}/set-password",
+		"POST /api/admin/albums",
+	}, []string{entries[0].Action, entries[1].Action, entries[2].Action, entries[3].Action, entries[4].Action, entries[5].Action})
+
+	// Who deleted the album, and what it was
+	deleted := entries[0]
+	assert.Equal(t, "owner", deleted.Username)
+	assert.Equal(t, models.RoleOwner, deleted.Role)
+	assert.Equal(t, album.ID, deleted.AlbumID)
+	assert.Equal(t, http.StatusNoContent, deleted.Status)
+	assert.Equal(t, "Wedding", deleted.Before["title"])
+	assert.Empty(t, deleted.After)
+
+	// Denied attempts are recorded too
+	assert.Equal(t, "viewer", entries[1].Username)
+	assert.Equal(t, http.StatusForbidden, entries[1].Status)
+	assert.Empty(t, entries[1].Before)
+
+	assert.Equal(t, map[string]interface{}{"site.title": "Old title"}, withoutTimestamps(entries[2].Before))
+	assert.Equal(t, map[string]interface{}{"site.title": "New title"}, withoutTimestamps(entries[2].After))
+
+	// Password hashes are redacted
+	assert.Equal(t, "[redacted]", entries[3].Before["password_hash"])
+	assert.Equal(t, "public", entries[3].After["visibility"])
+	assert.NotContains(t, entries[4].After["password_hash"], "argon2")
+
+	created := entries[5]
+	assert.Equal(t, album.ID, created.AlbumID)
+	assert.Equal(t, requestID, created.RequestID)
+	assert.Equal(t, "Wedding", created.After["title"])
+
+	// Filters
+	assert.Len(t, env.entries(t, "?actor=viewer"), 1)
+	assert.Len(t, env.entries(t, "?action=password"), 2)
+	assert.Len(t, env.entries(t, "?album_id="+album.ID+"&limit=2"), 2)
+	assert.Len(t, env.entries(t, "?request_id="+requestID), 1)
+	assert.Empty(t, env.entries(t, "?since="+time.Now().Add(time.Hour).UTC().Format(time.RFC3339)))
+	assert.Equal(t, http.StatusBadRequest, env.do("GET", "/api/admin/audit?since=yesterday", "", env.ownerCookie).Code)
+	assert.Equal(t, http.StatusBadRequest, env.do("GET", "/api/admin/audit?limit=0", "", env.ownerCookie).Code)
+	assert.Equal(t, http.StatusBadRequest, env.do("GET", "/api/admin/audit?format=csv", "", env.ownerCookie).Code)
+
+	// Export, oldest first
+	w = env.do("GET", "/api/admin/audit?format=jsonl&action=albums", "", env.ownerCookie)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "audit.jsonl")
+	var exported []string
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var entry models.AuditEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		exported = append(exported, entry.Action)
+	}
+	assert.Len(t, exported, 5)
+	assert.Equal(t, "POST /api/admin/albums", exported[0])
+
+	// Only owners can read the log
+	assert.Equal(t, http.StatusForbidden, env.do("GET", "/api/admin/audit", "", env.viewerCookie).Code)
+}
+
+// withoutTimestamps drops the fields that change with every update.
+func withoutTimestamps(fields map[string]interface{}) map[string]interface{} {
+	delete(fields, "last_updated")
+	return fields
+}
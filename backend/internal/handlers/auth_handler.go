@@ -1,18 +1,36 @@
 package handlers
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/njoubert/nielsshootsfilm/backend/internal/middleware"
 	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
 )
 
+// csrfCookie holds the session's CSRF token for the admin frontend to send
+// back in the X-CSRF-Token header. Unlike the session cookie, scripts can read
+// it, which other sites cannot.
+const csrfCookie = "photoadmin_csrf"
+
+// oidcStateCookie ties a single sign-on login to the browser that started it,
+// so that a callback cannot sign someone else in.
+const oidcStateCookie = "photoadmin_oidc_state"
+
 // AuthHandler handles authentication requests.
 type AuthHandler struct {
-	authService *services.AuthService
-	logger      *slog.Logger
+	authService   *services.AuthService
+	loginGuard    *services.LoginGuard
+	secureCookies bool
+	frontendURL   string
+	logger        *slog.Logger
 }
 
 // NewAuthHandler creates a new auth handler.
@@ -23,11 +41,33 @@ func NewAuthHandler(authService *services.AuthService, logger *slog.Logger) *Aut
 	}
 }
 
-// Login handles login requests.
+// SetLoginGuard enables login rate limits, backoff and lockout.
+func (h *AuthHandler) SetLoginGuard(loginGuard *services.LoginGuard) {
+	h.loginGuard = loginGuard
+}
+
+// SetSecureCookies marks the session and CSRF cookies Secure, so that they
+// are only sent over HTTPS.
+func (h *AuthHandler) SetSecureCookies(secure bool) {
+	h.secureCookies = secure
+}
+
+// SetFrontendURL sets the base URL of the admin frontend, such as
+// "https://example.com", that single sign-on sends users back to. Empty, the
+// default, means the server's own origin.
+func (h *AuthHandler) SetFrontendURL(frontendURL string) {
+	h.frontendURL = strings.TrimSuffix(frontendURL, "/")
+}
+
+// Login handles login requests. Users with two-factor authentication log in
+// in two stages: the username and password are answered with a challenge, and
+// the username, challenge and a TOTP or recovery code then start the session.
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Username string `json:"username"`
-		Password string `json:"password"`
+		Username  string `json:"username"`
+		Password  string `json:"password"`
+		Challenge string `json:"challenge"`
+		Code      string `json:"code"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -35,35 +75,236 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := clientIP(r)
+	if h.loginGuard != nil {
+		if err := h.loginGuard.Check(req.Username, ip); err != nil {
+			h.loginRejected(w, req.Username, ip, err)
+			return
+		}
+	}
+
 	// Authenticate
-	sessionID, err := h.authService.Authenticate(req.Username, req.Password)
+	var sessionID string
+	var err error
+	if req.Challenge != "" {
+		sessionID, err = h.authService.CompleteTwoFactor(req.Username, req.Challenge, req.Code)
+	} else {
+		sessionID, err = h.authService.Authenticate(req.Username, req.Password)
+	}
+	var twoFactor *services.TwoFactorRequiredError
+	if errors.As(err, &twoFactor) {
+		// Failures are kept until the second factor is through
+		if h.loginGuard != nil {
+			h.loginGuard.Release(req.Username, ip)
+		}
+		respondJSON(w, http.StatusOK, map[string]interface{}{
+			"message":             "Two-factor code required",
+			"two_factor_required": true,
+			"challenge":           twoFactor.Challenge,
+		})
+		return
+	}
 	if err != nil {
+		if !errors.Is(err, services.ErrInvalidCredentials) {
+			if h.loginGuard != nil {
+				h.loginGuard.Release(req.Username, ip)
+			}
+			h.logger.Error("login failed", slog.String("error", err.Error()))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
 		h.logger.Warn("login failed",
 			slog.String("username", req.Username),
-			slog.String("error", err.Error()),
+			slog.String("client_ip", ip),
+			slog.Bool("two_factor", req.Challenge != ""),
 		)
+		if h.loginGuard != nil {
+			locked, err := h.loginGuard.RecordFailure(req.Username, ip)
+			if err != nil {
+				h.logger.Error("failed to record login failure", slog.String("error", err.Error()))
+			}
+			if locked {
+				h.logger.Warn("account locked after failed logins",
+					slog.String("username", req.Username),
+					slog.String("client_ip", ip),
+				)
+			}
+		}
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
 
-	// Set session cookie
+	if h.loginGuard != nil {
+		if err := h.loginGuard.RecordSuccess(req.Username, ip); err != nil {
+			h.logger.Warn("failed to clear login failures", slog.String("error", err.Error()))
+		}
+	}
+
+	session, err := h.startSession(w, r, sessionID)
+	if err != nil {
+		h.logger.Error("failed to record new session", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("user logged in",
+		slog.String("username", session.Username),
+		slog.String("role", string(session.Role)),
+	)
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message":    "Login successful",
+		"user":       sessionUser(session),
+		"csrf_token": session.CSRFToken,
+	})
+}
+
+// OIDCStatus reports whether single sign-on is enabled, for the login page.
+func (h *AuthHandler) OIDCStatus(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"enabled": h.authService.OIDCEnabled(),
+	})
+}
+
+// OIDCLogin starts a single sign-on login by sending the browser to the
+// identity provider.
+func (h *AuthHandler) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if !h.authService.OIDCEnabled() {
+		http.Error(w, "Single sign-on is not enabled", http.StatusNotFound)
+		return
+	}
+
+	authURL, state, err := h.authService.StartOIDCLogin(r.Context())
+	if err != nil {
+		h.logger.Error("failed to start single sign-on", slog.String("error", err.Error()))
+		http.Error(w, "Single sign-on is unavailable", http.StatusBadGateway)
+		return
+	}
+
+	// Lax, as the identity provider sends the browser back from another site
 	http.SetCookie(w, &http.Cookie{
-		Name:     "photoadmin_session",
-		Value:    sessionID,
-		Path:     "/",
-		MaxAge:   24 * 60 * 60, // 24 hours
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/admin/oidc",
+		MaxAge:   10 * 60, // As long as the login waits for the identity provider
 		HttpOnly: true,
-		Secure:   false, // Set to true in production with HTTPS
-		SameSite: http.SameSiteStrictMode,
+		Secure:   h.secureCookies,
+		SameSite: http.SameSiteLaxMode,
 	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback completes a single sign-on login when the identity provider
+// sends the browser back, and sends it on to the admin frontend. Failures go
+// to the login page with an sso_error of "denied" for users without a role
+// mapping and "failed" otherwise.
+func (h *AuthHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if !h.authService.OIDCEnabled() {
+		http.Error(w, "Single sign-on is not enabled", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	state := query.Get("state")
+	cookie, err := r.Cookie(oidcStateCookie)
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    "",
+		Path:     "/api/admin/oidc",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   h.secureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		h.logger.Warn("single sign-on callback without matching state", slog.String("client_ip", clientIP(r)))
+		h.redirectSSOError(w, r, "failed")
+		return
+	}
+	if providerError := query.Get("error"); providerError != "" {
+		h.logger.Warn("single sign-on refused by identity provider",
+			slog.String("error", providerError),
+			slog.String("client_ip", clientIP(r)),
+		)
+		h.redirectSSOError(w, r, "denied")
+		return
+	}
+
+	sessionID, err := h.authService.CompleteOIDCLogin(r.Context(), state, query.Get("code"))
+	if err != nil {
+		h.logger.Warn("single sign-on failed",
+			slog.String("error", err.Error()),
+			slog.String("client_ip", clientIP(r)),
+		)
+		if errors.Is(err, services.ErrOIDCNotAllowed) {
+			h.redirectSSOError(w, r, "denied")
+		} else {
+			h.redirectSSOError(w, r, "failed")
+		}
+		return
+	}
+
+	session, err := h.startSession(w, r, sessionID)
+	if err != nil {
+		h.logger.Error("failed to record new session", slog.String("error", err.Error()))
+		h.redirectSSOError(w, r, "failed")
+		return
+	}
 
 	h.logger.Info("user logged in",
-		slog.String("username", req.Username),
+		slog.String("username", session.Username),
+		slog.String("role", string(session.Role)),
+		slog.Bool("sso", true),
 	)
 
-	respondJSON(w, http.StatusOK, map[string]string{
-		"message": "Login successful",
-	})
+	http.Redirect(w, r, h.frontendURL+"/admin", http.StatusFound)
+}
+
+// redirectSSOError sends a browser back to the login page after a failed
+// single sign-on.
+func (h *AuthHandler) redirectSSOError(w http.ResponseWriter, r *http.Request, reason string) {
+	http.Redirect(w, r, h.frontendURL+"/admin/login?sso_error="+reason, http.StatusFound)
+}
+
+// loginRejected answers a login attempt that the login guard turned away.
+func (h *AuthHandler) loginRejected(w http.ResponseWriter, username, ip string, err error) {
+	var retryAfter time.Duration
+	var tooMany *services.TooManyAttemptsError
+	var locked *services.AccountLockedError
+	switch {
+	case errors.As(err, &tooMany):
+		retryAfter = tooMany.RetryAfter
+	case errors.As(err, &locked):
+		retryAfter = locked.RetryAfter
+	default:
+		h.logger.Error("failed to check login attempt", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Warn("login rate limited",
+		slog.String("username", username),
+		slog.String("client_ip", ip),
+		slog.String("reason", err.Error()),
+	)
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	http.Error(w, "Too many attempts", http.StatusTooManyRequests)
+}
+
+// Check reports that the request is authenticated, and as whom. For browser
+// sessions it also returns the CSRF token, and sets its cookie again.
+func (h *AuthHandler) Check(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetSession(r.Context())
+	response := map[string]interface{}{
+		"authenticated": true,
+		"user":          sessionUser(session),
+	}
+	if !session.IsAPIToken() {
+		h.setCSRFCookie(w, session)
+		response["csrf_token"] = session.CSRFToken
+	}
+	respondJSON(w, http.StatusOK, response)
 }
 
 // Logout handles logout requests.
@@ -80,7 +321,15 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		Path:     "/",
 		MaxAge:   -1,
 		HttpOnly: true,
-		Secure:   false,
+		Secure:   h.secureCookies,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Secure:   h.secureCookies,
 		SameSite: http.SameSiteStrictMode,
 	})
 
@@ -89,7 +338,7 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// ChangePassword handles password change requests.
+// ChangePassword changes the signed-in user's password.
 func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 	var req struct {
 		OldPassword string `json:"old_password"`
@@ -101,28 +350,78 @@ func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.authService.ChangePassword(req.OldPassword, req.NewPassword); err != nil {
+	session := middleware.GetSession(r.Context())
+	if err := h.authService.ChangePassword(session, req.OldPassword, req.NewPassword); err != nil {
 		h.logger.Error("password change failed", slog.String("error", err.Error()))
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	h.logger.Info("password changed")
+	h.logger.Info("password changed", slog.String("username", session.Username))
 
 	respondJSON(w, http.StatusOK, map[string]string{
 		"message": "Password changed successfully",
 	})
 }
 
-// StartSessionCleanup starts a goroutine to periodically clean up expired sessions.
+// StartSessionCleanup starts a goroutine to periodically clean up expired
+// sessions and login rate limit windows.
 func (h *AuthHandler) StartSessionCleanup() {
 	go func() {
 		ticker := time.NewTicker(1 * time.Hour)
 		defer ticker.Stop()
 
 		for range ticker.C {
-			h.authService.CleanupExpiredSessions()
+			if h.loginGuard != nil {
+				h.loginGuard.CleanupRateLimits()
+			}
+			if err := h.authService.CleanupExpiredSessions(); err != nil {
+				h.logger.Warn("failed to clean up expired sessions", slog.String("error", err.Error()))
+				continue
+			}
 			h.logger.Debug("cleaned up expired sessions")
 		}
 	}()
 }
+
+// setCSRFCookie sets the cookie with the session's CSRF token.
+func (h *AuthHandler) setCSRFCookie(w http.ResponseWriter, session *services.Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookie,
+		Value:    session.CSRFToken,
+		Path:     "/",
+		MaxAge:   24 * 60 * 60, // Like the session cookie
+		Secure:   h.secureCookies,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// startSession sets the cookies of a new session, records the client that
+// signed in and returns the session.
+func (h *AuthHandler) startSession(w http.ResponseWriter, r *http.Request, sessionID string) (*services.Session, error) {
+	session, err := h.authService.RecordSessionClient(sessionID, clientIP(r), r.UserAgent())
+	if err != nil {
+		return nil, err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "photoadmin_session",
+		Value:    sessionID,
+		Path:     "/",
+		MaxAge:   24 * 60 * 60, // 24 hours
+		HttpOnly: true,
+		Secure:   h.secureCookies,
+		SameSite: http.SameSiteStrictMode,
+	})
+	h.setCSRFCookie(w, session)
+	return session, nil
+}
+
+// sessionUser describes the user of a session for API responses.
+func sessionUser(session *services.Session) map[string]string {
+	return map[string]string{
+		"id":       session.UserID,
+		"username": session.Username,
+		"role":     string(session.Role),
+	}
+}
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/middleware"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services/oidctest"
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupAuthRouter(t *testing.T, config services.LoginGuardConfig) (http.Handler, *services.AuthService) {
+	fileService, err := services.NewFileService(t.TempDir())
+	require.NoError(t, err)
+	users := services.NewUserService(fileService)
+	hash, err := services.HashPassword("owner-pass")
+	require.NoError(t, err)
+	_, err = users.Migrate(models.AdminConfig{Username: "owner", PasswordHash: hash})
+	require.NoError(t, err)
+
+	authService := services.NewAuthService(users, time.Hour)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	authHandler := NewAuthHandler(authService, logger)
+	loginGuard := services.NewLoginGuard(fileService, config)
+	authHandler.SetLoginGuard(loginGuard)
+	failureHandler := NewLoginFailureHandler(loginGuard, logger)
+	twoFactorHandler := NewTwoFactorHandler(users, authService, "Test", logger)
+	sessionHandler := NewSessionHandler(authService, logger)
+
+	r := chi.NewRouter()
+	r.Post("/login", authHandler.Login)
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Auth(authService, logger))
+		r.Get("/two-factor", twoFactorHandler.Status)
+		r.Post("/two-factor/enroll", twoFactorHandler.Enroll)
+		r.Post("/two-factor/verify", twoFactorHandler.Verify)
+		r.Post("/two-factor/disable", twoFactorHandler.Disable)
+		r.Get("/sessions", sessionHandler.List)
+		r.Post("/sessions/revoke-others", sessionHandler.RevokeOthers)
+		r.Delete("/sessions/{sessionId}", sessionHandler.Revoke)
+	})
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Auth(authService, logger))
+		r.Use(middleware.RequireRole(models.RoleOwner, logger))
+		r.Get("/login-failures", failureHandler.List)
+		r.Delete("/login-failures/{kind}/{key}", failureHandler.Clear)
+	})
+	return r, authService
+}
+
+func postLogin(router http.Handler, username, password string) *httptest.ResponseRecorder {
+	body := `{"username":"` + username + `","password":"` + password + `"}`
+	return postJSON(router, "/login", body, nil)
+}
+
+func postJSON(router http.Handler, path, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(body))
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func sessionCookie(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "photoadmin_session" {
+			return cookie
+		}
+	}
+	t.Fatal("no session cookie")
+	return nil
+}
+
+func TestAuthHandler_LoginLockout(t *testing.T) {
+	config := services.DefaultLoginGuardConfig()
+	config.FreeFailures = 10
+	config.LockoutFailures = 3
+	router, authService := setupAuthRouter(t, config)
+
+	w := postLogin(router, "owner", "owner-pass")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"role":"owner"`)
+	ownerCookie := sessionCookie(t, w)
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, postLogin(router, "owner", "wrong").Code)
+	}
+
+	// Locked, even with the right password
+	w = postLogin(router, "owner", "owner-pass")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1800", w.Header().Get("Retry-After"))
+
+	// The owner's existing session can see and clear the lockout
+	req := httptest.NewRequest("GET", "/login-failures", nil)
+	req.AddCookie(ownerCookie)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"locked_until"`)
+
+	req = httptest.NewRequest("DELETE", "/login-failures/username/owner", nil)
+	req.AddCookie(ownerCookie)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	assert.Equal(t, http.StatusOK, postLogin(router, "owner", "owner-pass").Code)
+	_, err := authService.ValidateSession(ownerCookie.Value)
+	assert.NoError(t, err)
+}
+
+func TestAuthHandler_TwoFactorLogin(t *testing.T) {
+	router, _ := setupAuthRouter(t, services.DefaultLoginGuardConfig())
+
+	w := postLogin(router, "owner", "owner-pass")
+	require.Equal(t, http.StatusOK, w.Code)
+	cookie := sessionCookie(t, w)
+
+	// Enroll, with a QR code of the provisioning URI
+	w = postJSON(router, "/two-factor/enroll", "", cookie)
+	require.Equal(t, http.StatusOK, w.Code)
+	var enrollment struct {
+		Secret          string `json:"secret"`
+		ProvisioningURI string `json:"provisioning_uri"`
+		QRCode          string `json:"qr_code"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &enrollment))
+	assert.True(t, strings.HasPrefix(enrollment.ProvisioningURI, "otpauth://totp/Test:owner?"))
+	assert.True(t, strings.HasPrefix(enrollment.QRCode, "data:image/png;base64,"))
+
+	assert.Equal(t, http.StatusBadRequest, postJSON(router, "/two-factor/verify", `{"code":"123"}`, cookie).Code)
+	code, err := totp.GenerateCode(enrollment.Secret, time.Now())
+	require.NoError(t, err)
+	w = postJSON(router, "/two-factor/verify", `{"code":"`+code+`"}`, cookie)
+	require.Equal(t, http.StatusOK, w.Code)
+	var verified struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &verified))
+	require.NotEmpty(t, verified.RecoveryCodes)
+
+	// The password alone now only gets a challenge
+	w = postLogin(router, "owner", "owner-pass")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Result().Cookies())
+	var stage struct {
+		TwoFactorRequired bool   `json:"two_factor_required"`
+		Challenge         string `json:"challenge"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stage))
+	assert.True(t, stage.TwoFactorRequired)
+
+	secondStage := func(code string) *httptest.ResponseRecorder {
+		return postJSON(router, "/login", `{"username":"owner","challenge":"`+stage.Challenge+`","code":"`+code+`"}`, nil)
+	}
+
+	// The second stage takes a TOTP or recovery code
+	assert.Equal(t, http.StatusUnauthorized, secondStage("000000").Code)
+	w = secondStage(verified.RecoveryCodes[0])
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"role":"owner"`)
+	cookie = sessionCookie(t, w)
+
+	req := httptest.NewRequest("GET", "/two-factor", nil)
+	req.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), `"enabled":true`)
+
+	// Disabling needs the password
+	assert.Equal(t, http.StatusBadRequest, postJSON(router, "/two-factor/disable", `{"password":"wrong"}`, cookie).Code)
+	assert.Equal(t, http.StatusOK, postJSON(router, "/two-factor/disable", `{"password":"owner-pass"}`, cookie).Code)
+	w = postLogin(router, "owner", "owner-pass")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "challenge")
+}
+
+func TestAuthHandler_OIDCLogin(t *testing.T) {
+	idp, err := oidctest.New()
+	require.NoError(t, err)
+	defer idp.Close()
+
+	fileService, err := services.NewFileService(t.TempDir())
+	require.NoError(t, err)
+	users := services.NewUserService(fileService)
+	authService := services.NewAuthService(users, time.Hour)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	authHandler := NewAuthHandler(authService, logger)
+	authHandler.SetFrontendURL("http://localhost:5173/")
+
+	r := chi.NewRouter()
+	r.Get("/api/admin/oidc", authHandler.OIDCStatus)
+	r.Get("/api/admin/oidc/login", authHandler.OIDCLogin)
+	r.Get("/api/admin/oidc/callback", authHandler.OIDCCallback)
+	r.With(middleware.Auth(authService, logger)).Get("/api/admin/sessions", NewSessionHandler(authService, logger).List)
+
+	get := func(path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	// signIn starts a login and signs in at the IdP, returning the state
+	// cookie and the callback path.
+	signIn := func(claims map[string]interface{}) (*http.Cookie, string) {
+		w := get("/api/admin/oidc/login")
+		require.Equal(t, http.StatusFound, w.Code)
+		var stateCookie *http.Cookie
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == "photoadmin_oidc_state" {
+				stateCookie = cookie
+			}
+		}
+		require.NotNil(t, stateCookie)
+		assert.Equal(t, http.SameSiteLaxMode, stateCookie.SameSite)
+		callback, err := idp.Authorize(w.Header().Get("Location"), claims)
+		require.NoError(t, err)
+		u, err := url.Parse(callback)
+		require.NoError(t, err)
+		return stateCookie, u.RequestURI()
+	}
+
+	// Disabled until configured
+	assert.Contains(t, get("/api/admin/oidc").Body.String(), `"enabled":false`)
+	assert.Equal(t, http.StatusNotFound, get("/api/admin/oidc/login").Code)
+
+	oidc, err := services.NewOIDCService(services.OIDCConfig{
+		IssuerURL:    idp.Issuer(),
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  "http://localhost:8080/api/admin/oidc/callback",
+		GroupRoles:   map[string]models.Role{"photo-team": models.RoleOwner},
+	})
+	require.NoError(t, err)
+	authService.SetOIDCService(oidc)
+	assert.Contains(t, get("/api/admin/oidc").Body.String(), `"enabled":true`)
+
+	team := map[string]interface{}{"sub": "1", "email": "sam@example.com", "email_verified": true, "groups": "photo-team"}
+
+	// The callback needs the state cookie of the browser that started it
+	_, callback := signIn(team)
+	w := get(callback)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "http://localhost:5173/admin/login?sso_error=failed", w.Header().Get("Location"))
+
+	// Users without a role mapping are denied
+	stateCookie, callback := signIn(map[string]interface{}{"sub": "2", "email": "guest@example.com", "email_verified": true})
+	w = get(callback, stateCookie)
+	assert.Equal(t, "http://localhost:5173/admin/login?sso_error=denied", w.Header().Get("Location"))
+
+	// So are users who refused at the identity provider
+	stateCookie, _ = signIn(team)
+	w = get("/api/admin/oidc/callback?error=access_denied&state="+url.QueryEscape(stateCookie.Value), stateCookie)
+	assert.Equal(t, "http://localhost:5173/admin/login?sso_error=denied", w.Header().Get("Location"))
+
+	stateCookie, callback = signIn(team)
+	w = get(callback, stateCookie)
+	require.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "http://localhost:5173/admin", w.Header().Get("Location"))
+	cookie := sessionCookie(t, w)
+	w = get("/api/admin/sessions", cookie)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"username":"sam@example.com"`)
+
+	// The state cookie is single-use
+	w = get(callback, stateCookie)
+	assert.Equal(t, "http://localhost:5173/admin/login?sso_error=failed", w.Header().Get("Location"))
+}
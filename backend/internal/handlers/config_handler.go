@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"net/http"
 
+	"github.com/njoubert/nielsshootsfilm/backend/internal/middleware"
 	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
 	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
 )
@@ -54,11 +55,24 @@ func (h *ConfigHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Pixel limits are optional; zero means the built-in default
+	if config.Storage.MaxImageMegapixels < 0 || config.Storage.MaxImageMegapixels > 1000 {
+		http.Error(w, "max_image_megapixels must be between 1 and 1000", http.StatusBadRequest)
+		return
+	}
+
+	if config.Storage.MaxImageDimension != 0 && (config.Storage.MaxImageDimension < 1000 || config.Storage.MaxImageDimension > 65000) {
+		http.Error(w, "max_image_dimension must be between 1000 and 65000", http.StatusBadRequest)
+		return
+	}
+
+	before, _ := h.configService.Get()
 	if err := h.configService.Update(&config); err != nil {
 		h.logger.Error("failed to update config", slog.String("error", err.Error()))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	h.auditChange(r, before)
 
 	respondJSON(w, http.StatusOK, config)
 }
@@ -73,11 +87,24 @@ func (h *ConfigHandler) SetMainPortfolioAlbum(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	before, _ := h.configService.Get()
 	if err := h.configService.SetMainPortfolioAlbum(req.AlbumID); err != nil {
 		h.logger.Error("failed to set main portfolio album", slog.String("error", err.Error()))
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	h.auditChange(r, before)
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// auditChange records how a request changed the site configuration in its
+// audit entry, from the configuration before the change.
+func (h *ConfigHandler) auditChange(r *http.Request, before *models.SiteConfig) {
+	if before == nil {
+		return
+	}
+	if after, err := h.configService.Get(); err == nil {
+		middleware.AuditChange(r.Context(), before, after)
+	}
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/middleware"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type csrfTestEnv struct {
+	router    http.Handler
+	albumID   string
+	cookie    *http.Cookie
+	csrfToken string
+	apiToken  string
+}
+
+// setupCSRFRouter mounts the upload endpoints behind the same CSRF protection
+// as the server, and signs in the owner.
+func setupCSRFRouter(t *testing.T) *csrfTestEnv {
+	fileService, err := services.NewFileService(t.TempDir())
+	require.NoError(t, err)
+	users := services.NewUserService(fileService)
+	hash, err := services.HashPassword("owner-pass")
+	require.NoError(t, err)
+	_, err = users.Migrate(models.AdminConfig{Username: "owner", PasswordHash: hash})
+	require.NoError(t, err)
+	album := &models.Album{Title: "Wedding", Slug: "wedding", Visibility: "public"}
+	albumService := services.NewAlbumService(fileService)
+	require.NoError(t, albumService.Create(album))
+
+	authService := services.NewAuthService(users, time.Hour)
+	tokenService := services.NewAPITokenService(fileService)
+	authService.SetAPITokenService(tokenService)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	authHandler := NewAuthHandler(authService, logger)
+	// Uploads are rejected or answered before any image is processed
+	albumHandler := NewAlbumHandler(albumService, nil, logger)
+
+	r := chi.NewRouter()
+	r.Route("/api/admin", func(r chi.Router) {
+		r.Use(middleware.CheckOrigin([]string{"http://localhost:5173"}, logger))
+		r.Post("/login", authHandler.Login)
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.Auth(authService, logger))
+			r.Use(middleware.CSRF(logger))
+			r.Get("/auth/check", authHandler.Check)
+			r.Group(func(r chi.Router) {
+				r.Use(middleware.RequireScope(models.ScopeUpload, logger))
+				r.Post("/albums/{id}/photos/upload", albumHandler.UploadPhotos)
+				r.Put("/albums/{id}/photos/{photoId}/file", albumHandler.ReplacePhotoFile)
+			})
+		})
+	})
+
+	w := postJSON(r, "/api/admin/login", `{"username":"owner","password":"owner-pass"}`, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	var login struct {
+		CSRFToken string `json:"csrf_token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &login))
+	require.NotEmpty(t, login.CSRFToken)
+	var csrfCookieValue string
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == csrfCookie {
+			csrfCookieValue = cookie.Value
+			assert.False(t, cookie.HttpOnly, "the frontend reads the CSRF cookie")
+		}
+	}
+	assert.Equal(t, login.CSRFToken, csrfCookieValue)
+
+	owners, err := users.GetAll()
+	require.NoError(t, err)
+	apiToken, err := tokenService.Create(owners[0].ID, &models.APIToken{Name: "Lightroom", Scopes: []models.Scope{models.ScopeUpload}})
+	require.NoError(t, err)
+
+	return &csrfTestEnv{
+		router:    r,
+		albumID:   album.ID,
+		cookie:    sessionCookie(t, w),
+		csrfToken: login.CSRFToken,
+		apiToken:  apiToken,
+	}
+}
+
+// uploadRequest builds a multipart upload without files, which the upload
+// endpoints answer with 400 once past the CSRF checks.
+func uploadRequest(t *testing.T, method, path string) *http.Request {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	require.NoError(t, writer.WriteField("note", "no files"))
+	require.NoError(t, writer.Close())
+	req := httptest.NewRequest(method, path, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestCSRF_UploadEndpoints(t *testing.T) {
+	env := setupCSRFRouter(t)
+
+	endpoints := map[string]struct {
+		method, path string
+		passed       int // Status once past the CSRF checks
+	}{
+		"upload":  {"POST", "/api/admin/albums/" + env.albumID + "/photos/upload", http.StatusBadRequest},
+		"replace": {"PUT", "/api/admin/albums/" + env.albumID + "/photos/missing/file", http.StatusNotFound},
+	}
+
+	cases := []struct {
+		name    string
+		prepare func(r *http.Request)
+		allowed bool
+	}{
+		{"session without token", func(r *http.Request) {
+			r.AddCookie(env.cookie)
+		}, false},
+		{"session with wrong token", func(r *http.Request) {
+			r.AddCookie(env.cookie)
+			r.Header.Set(middleware.CSRFHeader, "wrong")
+		}, false},
+		{"session with token", func(r *http.Request) {
+			r.AddCookie(env.cookie)
+			r.Header.Set(middleware.CSRFHeader, env.csrfToken)
+		}, true},
+		{"session with token from a trusted origin", func(r *http.Request) {
+			r.AddCookie(env.cookie)
+			r.Header.Set(middleware.CSRFHeader, env.csrfToken)
+			r.Header.Set("Origin", "http://localhost:5173")
+		}, true},
+		{"session with token from the server's own origin", func(r *http.Request) {
+			r.AddCookie(env.cookie)
+			r.Header.Set(middleware.CSRFHeader, env.csrfToken)
+			r.Header.Set("Referer", "http://"+r.Host+"/admin/albums")
+		}, true},
+		{"session with token from another site", func(r *http.Request) {
+			r.AddCookie(env.cookie)
+			r.Header.Set(middleware.CSRFHeader, env.csrfToken)
+			r.Header.Set("Origin", "https://evil.example")
+		}, false},
+		{"session with token and another site's referer", func(r *http.Request) {
+			r.AddCookie(env.cookie)
+			r.Header.Set(middleware.CSRFHeader, env.csrfToken)
+			r.Header.Set("Referer", "https://evil.example/page")
+		}, false},
+		{"bearer token", func(r *http.Request) {
+			r.Header.Set("Authorization", "Bearer "+env.apiToken)
+			r.Header.Set("Origin", "https://evil.example")
+		}, true},
+	}
+
+	for endpointName, endpoint := range endpoints {
+		for _, tc := range cases {
+			t.Run(endpointName+"/"+tc.name, func(t *testing.T) {
+				req := uploadRequest(t, endpoint.method, endpoint.path)
+				tc.prepare(req)
+				w := httptest.NewRecorder()
+				env.router.ServeHTTP(w, req)
+
+				if tc.allowed {
+					assert.Equal(t, endpoint.passed, w.Code, w.Body.String())
+				} else {
+					assert.Equal(t, http.StatusForbidden, w.Code)
+				}
+			})
+		}
+	}
+}
+
+func TestCSRF_SafeMethodsAndLogin(t *testing.T) {
+	env := setupCSRFRouter(t)
+
+	// Reads need no CSRF token, and the check returns it
+	req := httptest.NewRequest("GET", "/api/admin/auth/check", nil)
+	req.AddCookie(env.cookie)
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), env.csrfToken)
+
+	// Logins from other sites are rejected
+	req = httptest.NewRequest("POST", "/api/admin/login", bytes.NewBufferString(`{"username":"owner","password":"owner-pass"}`))
+	req.Header.Set("Origin", "https://evil.example")
+	w = httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+)
+
+// archiveRetryAfter is how long visitors are asked to wait while another
+// download is building the archive they asked for.
+const archiveRetryAfter = 30 * time.Second
+
+// DownloadHandler serves albums to visitors as ZIP archives.
+type DownloadHandler struct {
+	albumService   *services.AlbumService
+	accessService  *services.AlbumAccessService
+	archiveService *services.ArchiveService
+	logger         *slog.Logger
+}
+
+// NewDownloadHandler creates a new download handler.
+func NewDownloadHandler(
+	albumService *services.AlbumService,
+	accessService *services.AlbumAccessService,
+	archiveService *services.ArchiveService,
+	logger *slog.Logger,
+) *DownloadHandler {
+	return &DownloadHandler{
+		albumService:   albumService,
+		accessService:  accessService,
+		archiveService: archiveService,
+		logger:         logger,
+	}
+}
+
+// DownloadAlbum serves an album's photos as a ZIP archive at the tier in the
+// tier query parameter: original (the default) or large. Albums are
+// downloadable when the album or the visitor's share link allows downloads,
+// and password-protected albums and drafts need an access token as for
+// viewing them.
+//
+// The first download of an archive is streamed as it is built; once it is
+// cached, downloads support Range requests and can be resumed. Downloads that
+// arrive while the archive is being built get a 503 with Retry-After.
+func (h *DownloadHandler) DownloadAlbum(w http.ResponseWriter, r *http.Request) {
+	album, ok := visibleAlbum(w, r, h.albumService, h.logger)
+	if !ok {
+		return
+	}
+
+	grant, err := h.accessService.ValidateToken(album, albumAccessToken(r, album.ID))
+	if err != nil {
+		grant = nil
+	}
+	if album.IsDraft() && grant == nil {
+		http.Error(w, "Album not found", http.StatusNotFound)
+		return
+	}
+	if album.IsProtected() && grant == nil {
+		http.Error(w, "Album password required", http.StatusUnauthorized)
+		return
+	}
+	if !album.AllowDownloads && (grant == nil || !grant.AllowDownloads) {
+		http.Error(w, "Downloads are not allowed for this album", http.StatusForbidden)
+		return
+	}
+
+	archive, err := h.archiveService.Prepare(album, r.URL.Query().Get("tier"))
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownArchiveTier) {
+			http.Error(w, "Unknown download tier", http.StatusBadRequest)
+			return
+		}
+		h.logger.Error("failed to prepare album download", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	filename := unsafeFilenameChars.ReplaceAllString(album.Slug, "-")
+	if strings.Trim(filename, "-") == "" {
+		filename = "album"
+	}
+	filename += "-" + archive.Tier + ".zip"
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("ETag", `"`+archive.Key+`"`)
+	w.Header().Set("Cache-Control", "private, no-cache")
+
+	// Large albums take longer than the server's write timeout
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	if file, err := h.archiveService.Open(archive); err == nil {
+		defer func() { _ = file.Close() }()
+		if info, err := file.Stat(); err == nil {
+			h.recordDownload(r, grant)
+			http.ServeContent(w, r, filename, info.ModTime(), file)
+			return
+		}
+	}
+
+	// Not cached yet: stream the whole archive, even for Range requests
+	err = h.archiveService.Write(w, archive)
+	if errors.Is(err, services.ErrArchiveBuilding) {
+		w.Header().Del("Content-Disposition")
+		w.Header().Del("ETag")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Retry-After", strconv.Itoa(int(archiveRetryAfter.Seconds())))
+		http.Error(w, "Download is being prepared, please try again shortly", http.StatusServiceUnavailable)
+		return
+	}
+	h.recordDownload(r, grant)
+	if err != nil {
+		h.logger.Warn("album download interrupted",
+			slog.String("album_id", album.ID),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// recordDownload counts a full download in the access log of the share link
+// behind a grant.
+func (h *DownloadHandler) recordDownload(r *http.Request, grant *services.AlbumGrant) {
+	if !isFullDownload(r) {
+		return
+	}
+	if err := h.accessService.RecordShareDownload(grant); err != nil {
+		h.logger.Warn("failed to record share link download", slog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// blockingRecorder records a response but holds its first write until
+// release is closed, keeping a streamed download in progress.
+type blockingRecorder struct {
+	*httptest.ResponseRecorder
+	started chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (b *blockingRecorder) Write(p []byte) (int, error) {
+	b.once.Do(func() {
+		close(b.started)
+		<-b.release
+	})
+	return b.ResponseRecorder.Write(p)
+}
+
+type downloadTestEnv struct {
+	router        http.Handler
+	accessService *services.AlbumAccessService
+	shareLinks    *services.ShareLinkService
+	albums        map[string]*models.Album
+}
+
+// setupDownloadRouter creates albums of each kind, each with one photo whose
+// original exists in a temporary upload directory.
+func setupDownloadRouter(t *testing.T) *downloadTestEnv {
+	uploadDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(uploadDir, "originals"), 0750))
+
+	fileService, err := services.NewFileService(t.TempDir())
+	require.NoError(t, err)
+	albumService := services.NewAlbumService(fileService)
+	configService := services.NewSiteConfigService(fileService)
+	archiveService, err := services.NewArchiveService(uploadDir, t.TempDir(), albumService, configService, nil, 0, 0)
+	require.NoError(t, err)
+	albumService.SetPublisher(archiveService)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("letmein"), bcrypt.MinCost)
+	require.NoError(t, err)
+	expired := time.Now().Add(-time.Hour)
+
+	albums := map[string]*models.Album{
+		"public":      {Title: "Public", Visibility: "public", AllowDownloads: true},
+		"nodownloads": {Title: "No Downloads", Visibility: "public"},
+		"protected":   {Title: "Protected", Visibility: "password_protected", PasswordHash: string(hash), AllowDownloads: true},
+		"expired":     {Title: "Expired", Visibility: "public", ExpirationDate: &expired, AllowDownloads: true},
+		"draft":       {Title: "Draft", Visibility: "draft", AllowDownloads: true},
+	}
+	for name, album := range albums {
+		require.NoError(t, albumService.Create(album))
+		photo := &models.Photo{
+			FilenameOriginal: name + ".jpg",
+			URLOriginal:      "/uploads/originals/" + name + ".jpg",
+		}
+		require.NoError(t, albumService.AddPhoto(album.ID, photo))
+		require.NoError(t, os.WriteFile(filepath.Join(uploadDir, "originals", name+".jpg"), []byte("original "+name), 0600))
+	}
+
+	accessService, err := services.NewAlbumAccessService(nil, time.Hour)
+	require.NoError(t, err)
+	shareLinks := services.NewShareLinkService(fileService)
+	accessService.SetShareLinkService(shareLinks)
+
+	handler := NewDownloadHandler(albumService, accessService, archiveService, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	r := chi.NewRouter()
+	r.Get("/api/albums/{slug}/download", handler.DownloadAlbum)
+
+	return &downloadTestEnv{
+		router:        r,
+		accessService: accessService,
+		shareLinks:    shareLinks,
+		albums:        albums,
+	}
+}
+
+func (env *downloadTestEnv) download(name, query string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/api/albums/"+env.albums[name].Slug+"/download"+query, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestDownloadHandler_Policies(t *testing.T) {
+	env := setupDownloadRouter(t)
+
+	tests := []struct {
+		album string
+		query string
+		want  int
+	}{
+		{album: "public", want: http.StatusOK},
+		{album: "public", query: "?tier=original", want: http.StatusOK},
+		{album: "public", query: "?tier=large", want: http.StatusBadRequest}, // no renderer
+		{album: "nodownloads", want: http.StatusForbidden},
+		{album: "protected", want: http.StatusUnauthorized},
+		{album: "expired", want: http.StatusNotFound},
+		{album: "draft", want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.album+tt.query, func(t *testing.T) {
+			w := env.download(tt.album, tt.query, nil)
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+
+	// A password token opens the protected album
+	token, _, _, err := env.accessService.GrantAccess(env.albums["protected"], "letmein", "192.0.2.1")
+	require.NoError(t, err)
+	w := env.download("protected", "", http.Header{"Authorization": {"Bearer " + token}})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// A share link that allows downloads opens an album that does not, and
+	// counts the download
+	link := &models.ShareLink{ExpiresAt: time.Now().Add(time.Hour), AllowDownloads: true}
+	shareToken, err := env.shareLinks.Create(env.albums["nodownloads"].ID, link)
+	require.NoError(t, err)
+	token, _, _, err = env.accessService.GrantShareAccess(env.albums["nodownloads"], shareToken, "192.0.2.1")
+	require.NoError(t, err)
+	w = env.download("nodownloads", "", http.Header{"Authorization": {"Bearer " + token}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	stored, err := env.shareLinks.Get(link.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stored.Downloads)
+}
+
+func TestDownloadHandler_StreamThenResume(t *testing.T) {
+	env := setupDownloadRouter(t)
+
+	// The first download is streamed as the archive is built
+	w := env.download("public", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/zip", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "-original.zip")
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	full := w.Body.Bytes()
+	reader, err := zip.NewReader(bytes.NewReader(full), int64(len(full)))
+	require.NoError(t, err)
+	names := []string{}
+	for _, file := range reader.File {
+		names = append(names, file.Name)
+	}
+	assert.Equal(t, []string{"001-public.jpg", "manifest.json", "CREDITS.txt"}, names)
+
+	// Later downloads come from the cache and can resume
+	w = env.download("public", "", http.Header{"Range": {"bytes=10-"}, "If-Range": {etag}})
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, full[10:], w.Body.Bytes())
+
+	w = env.download("public", "", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+}
+
+func TestDownloadHandler_BuildInProgress(t *testing.T) {
+	env := setupDownloadRouter(t)
+
+	// The first download builds the archive and stalls partway through
+	first := &blockingRecorder{
+		ResponseRecorder: httptest.NewRecorder(),
+		started:          make(chan struct{}),
+		release:          make(chan struct{}),
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req := httptest.NewRequest("GET", "/api/albums/"+env.albums["public"].Slug+"/download", nil)
+		env.router.ServeHTTP(first, req)
+	}()
+	<-first.started
+
+	// Meanwhile other downloads are asked to come back instead of building it again
+	w := env.download("public", "", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+	assert.Empty(t, w.Header().Get("Content-Disposition"))
+
+	close(first.release)
+	<-done
+	assert.Equal(t, http.StatusOK, first.Code)
+
+	// Once built, downloads come from the cache
+	w = env.download("public", "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, first.Body.Bytes(), w.Body.Bytes())
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/middleware"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+)
+
+// LoginFailureHandler handles the admin endpoints for failed logins and
+// lockouts.
+type LoginFailureHandler struct {
+	loginGuard *services.LoginGuard
+	logger     *slog.Logger
+}
+
+// NewLoginFailureHandler creates a new login failure handler.
+func NewLoginFailureHandler(loginGuard *services.LoginGuard, logger *slog.Logger) *LoginFailureHandler {
+	return &LoginFailureHandler{
+		loginGuard: loginGuard,
+		logger:     logger,
+	}
+}
+
+// List returns the usernames and IPs with recent failed logins, locked
+// usernames first.
+func (h *LoginFailureHandler) List(w http.ResponseWriter, r *http.Request) {
+	failures, err := h.loginGuard.List()
+	if err != nil {
+		h.logger.Error("failed to list login failures", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"login_failures": failures,
+	})
+}
+
+// Clear forgets the failed logins of a username or IP, unlocking it.
+func (h *LoginFailureHandler) Clear(w http.ResponseWriter, r *http.Request) {
+	kind := chi.URLParam(r, "kind")
+	// Keys can be IPv6 addresses, which clients may escape
+	key, err := url.PathUnescape(chi.URLParam(r, "key"))
+	if err != nil {
+		http.Error(w, "Invalid key", http.StatusBadRequest)
+		return
+	}
+	if kind != models.LoginFailureUsername && kind != models.LoginFailureIP {
+		http.Error(w, "Kind must be username or ip", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.loginGuard.Clear(kind, key); err != nil {
+		if errors.Is(err, services.ErrLoginFailureNotFound) {
+			http.Error(w, "Login failure record not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("failed to clear login failures", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("login failures cleared",
+		slog.String("kind", kind),
+		slog.String("key", key),
+		slog.String("by", middleware.GetSession(r.Context()).Username),
+	)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+)
+
+// MediaHandler serves uploaded photo files, deciding access by the album each
+// file belongs to.
+type MediaHandler struct {
+	mediaIndex    *services.MediaIndex
+	accessService *services.AlbumAccessService
+	authService   *services.AuthService
+	uploadDir     string
+	privateCache  bool
+	logger        *slog.Logger
+}
+
+// NewMediaHandler creates a new media handler serving files from uploadDir.
+func NewMediaHandler(
+	mediaIndex *services.MediaIndex,
+	accessService *services.AlbumAccessService,
+	authService *services.AuthService,
+	uploadDir string,
+	logger *slog.Logger,
+) *MediaHandler {
+	return &MediaHandler{
+		mediaIndex:    mediaIndex,
+		accessService: accessService,
+		authService:   authService,
+		uploadDir:     uploadDir,
+		logger:        logger,
+	}
+}
+
+// SetHotlinkProtection tells the handler whether hotlink protection runs in
+// front of it. Files are then never marked as publicly cacheable, since a
+// shared cache would hand them to any site regardless of Referer.
+func (h *MediaHandler) SetHotlinkProtection(enabled bool) {
+	h.privateCache = enabled
+}
+
+// ServeMedia serves a file under /uploads. Visitors get files of public and
+// unlisted albums, of password-protected albums with a valid access token and
+// of drafts with a token from a preview link; originals only when the album or the visitor's share link allows downloads;
+// nothing from expired albums, and never previous versions. Signed-in admins
+// get every file of every album.
+//
+// Every denied request gets the same 404 as a file that does not exist, so
+// the response never reveals whether a file is there.
+func (h *MediaHandler) ServeMedia(w http.ResponseWriter, r *http.Request) {
+	urlPath := "/uploads/" + chi.URLParam(r, "*")
+	if path.Clean(urlPath) != urlPath {
+		h.notFound(w)
+		return
+	}
+
+	entry, ok := h.mediaIndex.Lookup(urlPath)
+	if !ok {
+		h.notFound(w)
+		return
+	}
+
+	admin := h.isAdmin(r)
+	var grant *services.AlbumGrant
+	if !admin {
+		if grant, ok = h.visitorGrant(r, entry); !ok {
+			h.notFound(w)
+			return
+		}
+	}
+
+	// #nosec G304 - path is a known upload URL from the media index, cleaned above
+	file, err := os.Open(filepath.Join(h.uploadDir, filepath.FromSlash(urlPath[len("/uploads/"):])))
+	if err != nil {
+		h.notFound(w)
+		return
+	}
+	defer func() { _ = file.Close() }()
+
+	info, err := file.Stat()
+	if err != nil || info.IsDir() {
+		h.notFound(w)
+		return
+	}
+
+	if admin || grant != nil || entry.Album.IsProtected() || h.privateCache {
+		w.Header().Set("Cache-Control", "private, max-age=3600")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+	}
+
+	if entry.Kind == services.MediaOriginal && isFullDownload(r) {
+		if err := h.accessService.RecordShareDownload(grant); err != nil {
+			h.logger.Warn("failed to record share link download", slog.String("error", err.Error()))
+		}
+	}
+
+	http.ServeContent(w, r, info.Name(), info.ModTime(), file)
+}
+
+// visitorGrant decides whether a visitor without an admin session may fetch a
+// file, returning what the visitor's album access token grants, if any.
+func (h *MediaHandler) visitorGrant(r *http.Request, entry services.MediaEntry) (*services.AlbumGrant, bool) {
+	album := entry.Album
+
+	if album.IsWithdrawn(time.Now()) || entry.Kind == services.MediaVersion {
+		return nil, false
+	}
+
+	grant, err := h.accessService.ValidateToken(album, albumAccessToken(r, album.ID))
+	if err != nil {
+		grant = nil
+	}
+
+	if (album.IsProtected() || album.IsDraft()) && grant == nil {
+		return nil, false
+	}
+	if entry.Kind == services.MediaOriginal && !album.AllowDownloads && (grant == nil || !grant.AllowDownloads) {
+		return nil, false
+	}
+
+	return grant, true
+}
+
+// isFullDownload reports whether a request downloads a whole file, so
+// resumed, partial and revalidating requests are not counted as downloads.
+func isFullDownload(r *http.Request) bool {
+	if r.Method != http.MethodGet || r.Header.Get("If-None-Match") != "" || r.Header.Get("If-Modified-Since") != "" {
+		return false
+	}
+	rangeHeader := r.Header.Get("Range")
+	return rangeHeader == "" || rangeHeader == "bytes=0-"
+}
+
+// isAdmin reports whether the request carries a valid admin session.
+func (h *MediaHandler) isAdmin(r *http.Request) bool {
+	cookie, err := r.Cookie("photoadmin_session")
+	if err != nil {
+		return false
+	}
+	_, err = h.authService.ValidateSession(cookie.Value)
+	return err == nil
+}
+
+func (h *MediaHandler) notFound(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "no-store")
+	http.Error(w, "Not found", http.StatusNotFound)
+}
@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type mediaTestEnv struct {
+	router        http.Handler
+	handler       *MediaHandler
+	albumService  *services.AlbumService
+	accessService *services.AlbumAccessService
+	shareLinks    *services.ShareLinkService
+	adminSession  string
+	albums        map[string]*models.Album
+}
+
+// setupMediaRouter creates albums of each kind, each with one photo whose files
+// (named after the album) exist in a temporary upload directory.
+func setupMediaRouter(t *testing.T) *mediaTestEnv {
+	uploadDir := t.TempDir()
+	for _, dir := range []string{"originals", "display", "versions"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(uploadDir, dir), 0750))
+	}
+
+	fileService, err := services.NewFileService(t.TempDir())
+	require.NoError(t, err)
+	albumService := services.NewAlbumService(fileService)
+	mediaIndex := services.NewMediaIndex(albumService)
+	albumService.SetPublisher(mediaIndex)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("letmein"), bcrypt.MinCost)
+	require.NoError(t, err)
+	expired := time.Now().Add(-time.Hour)
+
+	albums := map[string]*models.Album{
+		"public":      {Title: "Public", Visibility: "public", AllowDownloads: true},
+		"nodownloads": {Title: "No Downloads", Visibility: "public"},
+		"unlisted":    {Title: "Unlisted", Visibility: "unlisted", AllowDownloads: true},
+		"protected":   {Title: "Protected", Visibility: "password_protected", PasswordHash: string(hash), AllowDownloads: true},
+		"expired":     {Title: "Expired", Visibility: "public", ExpirationDate: &expired},
+		"draft":       {Title: "Draft", Visibility: "draft"},
+	}
+	for name, album := range albums {
+		require.NoError(t, albumService.Create(album))
+		photo := &models.Photo{
+			URLOriginal: "/uploads/originals/" + name + ".jpg",
+			URLDisplay:  "/uploads/display/" + name + ".webp",
+			Versions:    []models.PhotoVersion{{ID: "v1", URLOriginal: "/uploads/versions/" + name + ".jpg"}},
+		}
+		require.NoError(t, albumService.AddPhoto(album.ID, photo))
+		for _, url := range []string{photo.URLOriginal, photo.URLDisplay, photo.Versions[0].URLOriginal} {
+			require.NoError(t, os.WriteFile(filepath.Join(uploadDir, filepath.FromSlash(url[len("/uploads/"):])), []byte(url), 0600))
+		}
+	}
+
+	// A file on disk that belongs to no photo
+	require.NoError(t, os.WriteFile(filepath.Join(uploadDir, "display", "orphan.webp"), []byte("orphan"), 0600))
+
+	accessService, err := services.NewAlbumAccessService(nil, time.Hour)
+	require.NoError(t, err)
+	shareLinks := services.NewShareLinkService(fileService)
+	accessService.SetShareLinkService(shareLinks)
+
+	adminHash, err := bcrypt.GenerateFromPassword([]byte("admin-password"), bcrypt.MinCost)
+	require.NoError(t, err)
+	users := services.NewUserService(fileService)
+	_, err = users.Migrate(models.AdminConfig{Username: "admin", PasswordHash: string(adminHash)})
+	require.NoError(t, err)
+	authService := services.NewAuthService(users, time.Hour)
+	adminSession, err := authService.Authenticate("admin", "admin-password")
+	require.NoError(t, err)
+
+	handler := NewMediaHandler(mediaIndex, accessService, authService, uploadDir, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	r := chi.NewRouter()
+	r.Get("/uploads/*", handler.ServeMedia)
+
+	return &mediaTestEnv{
+		router:        r,
+		handler:       handler,
+		albumService:  albumService,
+		accessService: accessService,
+		shareLinks:    shareLinks,
+		adminSession:  adminSession,
+		albums:        albums,
+	}
+}
+
+func (env *mediaTestEnv) get(url string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", url, nil)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestMediaHandler_Visitors(t *testing.T) {
+	env := setupMediaRouter(t)
+
+	tests := []struct {
+		url  string
+		want int
+	}{
+		{url: "/uploads/display/public.webp", want: http.StatusOK},
+		{url: "/uploads/originals/public.jpg", want: http.StatusOK},
+		{url: "/uploads/display/unlisted.webp", want: http.StatusOK},
+		{url: "/uploads/display/nodownloads.webp", want: http.StatusOK},
+		{url: "/uploads/originals/nodownloads.jpg", want: http.StatusNotFound},
+		{url: "/uploads/display/protected.webp", want: http.StatusNotFound},
+		{url: "/uploads/originals/protected.jpg", want: http.StatusNotFound},
+		{url: "/uploads/display/expired.webp", want: http.StatusNotFound},
+		{url: "/uploads/display/draft.webp", want: http.StatusNotFound},
+		{url: "/uploads/versions/public.jpg", want: http.StatusNotFound},
+		{url: "/uploads/display/orphan.webp", want: http.StatusNotFound},
+		{url: "/uploads/display/missing.webp", want: http.StatusNotFound},
+		{url: "/uploads/display/../originals/nodownloads.jpg", want: http.StatusNotFound},
+	}
+
+	var deniedBody string
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			w := env.get(tt.url)
+			assert.Equal(t, tt.want, w.Code)
+
+			if tt.want == http.StatusOK {
+				assert.Equal(t, tt.url, w.Body.String())
+				return
+			}
+			// Denied and missing files are indistinguishable
+			if deniedBody == "" {
+				deniedBody = w.Body.String()
+			}
+			assert.Equal(t, deniedBody, w.Body.String())
+		})
+	}
+}
+
+func TestMediaHandler_CacheControl(t *testing.T) {
+	env := setupMediaRouter(t)
+
+	w := env.get("/uploads/display/public.webp")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "public, max-age=3600", w.Header().Get("Cache-Control"))
+
+	// Behind hotlink protection shared caches must not keep public files
+	env.handler.SetHotlinkProtection(true)
+	w = env.get("/uploads/display/public.webp")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "private, max-age=3600", w.Header().Get("Cache-Control"))
+}
+
+func TestMediaHandler_ProtectedWithToken(t *testing.T) {
+	env := setupMediaRouter(t)
+	album := env.albums["protected"]
+
+	stored, err := env.albumService.GetByID(album.ID)
+	require.NoError(t, err)
+	token, _, _, err := env.accessService.GrantAccess(stored, "letmein", "192.0.2.1")
+	require.NoError(t, err)
+	cookie := &http.Cookie{Name: albumAccessCookie(album.ID), Value: token}
+
+	w := env.get("/uploads/display/protected.webp", cookie)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "private, max-age=3600", w.Header().Get("Cache-Control"))
+	assert.Equal(t, http.StatusOK, env.get("/uploads/originals/protected.jpg", cookie).Code)
+
+	// The token does not open other albums or previous versions
+	assert.Equal(t, http.StatusNotFound, env.get("/uploads/versions/protected.jpg", cookie).Code)
+	otherCookie := &http.Cookie{Name: albumAccessCookie(env.albums["expired"].ID), Value: token}
+	assert.Equal(t, http.StatusNotFound, env.get("/uploads/display/expired.webp", otherCookie).Code)
+
+	// Changing the password locks the files again
+	stored.PasswordHash = "$2a$04$changedchangedchangedchangedchangedchangedchangedchang"
+	require.NoError(t, env.albumService.Update(stored.ID, stored))
+	assert.Equal(t, http.StatusNotFound, env.get("/uploads/display/protected.webp", cookie).Code)
+}
+
+func TestMediaHandler_ShareLinkDownloads(t *testing.T) {
+	env := setupMediaRouter(t)
+	album := env.albums["nodownloads"]
+
+	link := &models.ShareLink{ExpiresAt: time.Now().Add(time.Hour), AllowDownloads: true}
+	shareToken, err := env.shareLinks.Create(album.ID, link)
+	require.NoError(t, err)
+	token, _, _, err := env.accessService.GrantShareAccess(album, shareToken, "192.0.2.1")
+	require.NoError(t, err)
+	cookie := &http.Cookie{Name: albumAccessCookie(album.ID), Value: token}
+
+	// The link allows downloads the album does not
+	w := env.get("/uploads/originals/nodownloads.jpg", cookie)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "private, max-age=3600", w.Header().Get("Cache-Control"))
+	assert.Equal(t, http.StatusOK, env.get("/uploads/display/nodownloads.webp", cookie).Code)
+
+	// Only whole downloads of originals are counted
+	req := httptest.NewRequest("GET", "/uploads/originals/nodownloads.jpg", nil)
+	req.AddCookie(cookie)
+	req.Header.Set("Range", "bytes=10-")
+	env.router.ServeHTTP(httptest.NewRecorder(), req)
+
+	stored, err := env.shareLinks.Get(link.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stored.Downloads)
+
+	// Revoking the link ends its downloads
+	_, err = env.shareLinks.Revoke(album.ID, link.ID)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, env.get("/uploads/originals/nodownloads.jpg", cookie).Code)
+}
+
+func TestMediaHandler_DraftPreview(t *testing.T) {
+	env := setupMediaRouter(t)
+	album := env.albums["draft"]
+
+	shareToken, err := env.shareLinks.Create(album.ID, &models.ShareLink{ExpiresAt: time.Now().Add(time.Hour), Preview: true})
+	require.NoError(t, err)
+	token, _, _, err := env.accessService.GrantShareAccess(album, shareToken, "192.0.2.1")
+	require.NoError(t, err)
+	cookie := &http.Cookie{Name: albumAccessCookie(album.ID), Value: token}
+
+	w := env.get("/uploads/display/draft.webp", cookie)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "private, max-age=3600", w.Header().Get("Cache-Control"))
+	assert.Equal(t, http.StatusNotFound, env.get("/uploads/originals/draft.jpg", cookie).Code, "downloads follow the album")
+}
+
+func TestMediaHandler_Admin(t *testing.T) {
+	env := setupMediaRouter(t)
+	session := &http.Cookie{Name: "photoadmin_session", Value: env.adminSession}
+
+	for _, url := range []string{
+		"/uploads/display/protected.webp",
+		"/uploads/originals/nodownloads.jpg",
+		"/uploads/display/expired.webp",
+		"/uploads/versions/public.jpg",
+	} {
+		assert.Equal(t, http.StatusOK, env.get(url, session).Code, url)
+	}
+
+	// Files of no photo are not served to admins either
+	assert.Equal(t, http.StatusNotFound, env.get("/uploads/display/orphan.webp", session).Code)
+}
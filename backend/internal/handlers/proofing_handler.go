@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+)
+
+// unsafeFilenameChars matches characters not used in export filenames.
+var unsafeFilenameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// ProofingHandler handles client selections on proofing albums: making and
+// submitting them for visitors with album access, and reviewing, exporting
+// and locking them for the admin.
+type ProofingHandler struct {
+	albumService     *services.AlbumService
+	accessService    *services.AlbumAccessService
+	selectionService *services.SelectionService
+	logger           *slog.Logger
+}
+
+// NewProofingHandler creates a new proofing handler.
+func NewProofingHandler(
+	albumService *services.AlbumService,
+	accessService *services.AlbumAccessService,
+	selectionService *services.SelectionService,
+	logger *slog.Logger,
+) *ProofingHandler {
+	return &ProofingHandler{
+		albumService:     albumService,
+		accessService:    accessService,
+		selectionService: selectionService,
+		logger:           logger,
+	}
+}
+
+// StartSelection returns the selection of the visitor's share link, creating
+// it on first use, or creates a new selection for a visitor who unlocked the
+// album with its password. Those are rate limited per client IP.
+func (h *ProofingHandler) StartSelection(w http.ResponseWriter, r *http.Request) {
+	album, grant, ok := h.proofingAccess(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		ClientName string `json:"client_name"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	selection, created, err := h.selectionService.Start(album.ID, grant.ShareLinkID, strings.TrimSpace(req.ClientName), clientIP(r))
+	if err != nil {
+		var tooMany *services.TooManyAttemptsError
+		if errors.As(err, &tooMany) {
+			h.logger.Warn("new selections rate limited",
+				slog.String("album_id", album.ID),
+				slog.String("retry_after", tooMany.RetryAfter.String()),
+			)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(tooMany.RetryAfter.Seconds()))))
+			http.Error(w, "Too many attempts", http.StatusTooManyRequests)
+			return
+		}
+		h.selectionError(w, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	w.Header().Set("Cache-Control", "private, no-store")
+	respondJSON(w, status, selection)
+}
+
+// GetSelection returns one of the visitor's selections.
+func (h *ProofingHandler) GetSelection(w http.ResponseWriter, r *http.Request) {
+	album, grant, ok := h.proofingAccess(w, r)
+	if !ok {
+		return
+	}
+
+	selection, ok := h.visitorSelection(w, r, album, grant)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, no-store")
+	respondJSON(w, http.StatusOK, selection)
+}
+
+// UpdateSelection saves the visitor's favourites and notes.
+func (h *ProofingHandler) UpdateSelection(w http.ResponseWriter, r *http.Request) {
+	album, grant, ok := h.proofingAccess(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		ClientName string            `json:"client_name"`
+		Favorites  []string          `json:"favorites"`
+		PhotoNotes map[string]string `json:"photo_notes"`
+		Note       string            `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if _, ok := h.visitorSelection(w, r, album, grant); !ok {
+		return
+	}
+
+	selection, err := h.selectionService.Update(album, chi.URLParam(r, "selectionId"), &models.Selection{
+		ClientName: strings.TrimSpace(req.ClientName),
+		Favorites:  req.Favorites,
+		PhotoNotes: req.PhotoNotes,
+		Note:       req.Note,
+	})
+	if err != nil {
+		h.selectionError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, selection)
+}
+
+// SubmitSelection submits the visitor's selection to the photographer.
+func (h *ProofingHandler) SubmitSelection(w http.ResponseWriter, r *http.Request) {
+	album, grant, ok := h.proofingAccess(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := h.visitorSelection(w, r, album, grant); !ok {
+		return
+	}
+
+	selection, err := h.selectionService.Submit(album, chi.URLParam(r, "selectionId"))
+	if err != nil {
+		h.selectionError(w, err)
+		return
+	}
+
+	h.logger.Info("selection submitted",
+		slog.String("album_id", album.ID),
+		slog.String("selection_id", selection.ID),
+		slog.Int("favorites", len(selection.Favorites)),
+	)
+
+	respondJSON(w, http.StatusOK, selection)
+}
+
+// ListSelections returns an album's selections for the admin.
+func (h *ProofingHandler) ListSelections(w http.ResponseWriter, r *http.Request) {
+	album, ok := h.adminAlbum(w, r)
+	if !ok {
+		return
+	}
+
+	selections, err := h.selectionService.ListByAlbum(album.ID)
+	if err != nil {
+		h.logger.Error("failed to list selections", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"selections": selections,
+	})
+}
+
+// ExportSelection downloads a selection's favourites, either as CSV
+// (format=csv, the default) or as a comma-separated list of filenames without
+// extensions, to paste into Lightroom's library filter (format=lightroom).
+func (h *ProofingHandler) ExportSelection(w http.ResponseWriter, r *http.Request) {
+	album, ok := h.adminAlbum(w, r)
+	if !ok {
+		return
+	}
+
+	selection, err := h.selectionService.Get(album.ID, chi.URLParam(r, "selectionId"))
+	if err != nil {
+		h.selectionError(w, err)
+		return
+	}
+
+	filename := "selection-" + album.Slug
+	if name := unsafeFilenameChars.ReplaceAllString(selection.ClientName, "-"); strings.Trim(name, "-") != "" {
+		filename += "-" + strings.Trim(name, "-")
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, filename))
+
+		writer := csv.NewWriter(w)
+		_ = writer.Write([]string{"order", "filename", "photo_id", "note"})
+		order := 0
+		for _, photoID := range selection.Favorites {
+			photo := findPhoto(album, photoID)
+			if photo == nil {
+				continue
+			}
+			order++
+			_ = writer.Write([]string{
+				strconv.Itoa(order),
+				csvCell(photo.FilenameOriginal),
+				photo.ID,
+				csvCell(selection.PhotoNotes[photoID]),
+			})
+		}
+		writer.Flush()
+
+	case "lightroom":
+		names := []string{}
+		for _, photoID := range selection.Favorites {
+			if photo := findPhoto(album, photoID); photo != nil {
+				// Without the extension, so raw files and their JPEGs both match
+				names = append(names, strings.TrimSuffix(photo.FilenameOriginal, path.Ext(photo.FilenameOriginal)))
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.txt"`, filename))
+		_, _ = w.Write([]byte(strings.Join(names, ", ") + "\n"))
+
+	default:
+		http.Error(w, "Format must be csv or lightroom", http.StatusBadRequest)
+	}
+}
+
+// LockSelection locks a selection against further changes by the client.
+func (h *ProofingHandler) LockSelection(w http.ResponseWriter, r *http.Request) {
+	h.setLocked(w, r, true)
+}
+
+// UnlockSelection lets the client change a locked selection again.
+func (h *ProofingHandler) UnlockSelection(w http.ResponseWriter, r *http.Request) {
+	h.setLocked(w, r, false)
+}
+
+func (h *ProofingHandler) setLocked(w http.ResponseWriter, r *http.Request, locked bool) {
+	selection, err := h.selectionService.SetLocked(chi.URLParam(r, "id"), chi.URLParam(r, "selectionId"), locked)
+	if err != nil {
+		h.selectionError(w, err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, selection)
+}
+
+// proofingAccess looks up the album in the URL and the visitor's access to it.
+// Albums that do not exist, have expired or are not in proofing all answer 404;
+// visitors without a valid password or share link token get 401.
+func (h *ProofingHandler) proofingAccess(w http.ResponseWriter, r *http.Request) (*models.Album, *services.AlbumGrant, bool) {
+	album, ok := visibleAlbum(w, r, h.albumService, h.logger)
+	if !ok {
+		return nil, nil, false
+	}
+	// Reviewers of a draft look at the album; they don't make selections
+	if album.Proofing == nil || !album.Proofing.Enabled || album.IsDraft() {
+		http.Error(w, "Album not found", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	grant, err := h.accessService.ValidateToken(album, albumAccessToken(r, album.ID))
+	if err != nil {
+		http.Error(w, "Album access required", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	return album, grant, true
+}
+
+// StartRateLimitCleanup starts a goroutine to periodically forget ended rate limit windows.
+func (h *ProofingHandler) StartRateLimitCleanup() {
+	go func() {
+		ticker := time.NewTicker(15 * time.Minute)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			h.selectionService.CleanupRateLimits()
+		}
+	}()
+}
+
+// visitorSelection looks up the selection in the URL. Selections made through
+// a share link are only reachable through that link, and password selections
+// only with a password token.
+func (h *ProofingHandler) visitorSelection(w http.ResponseWriter, r *http.Request, album *models.Album, grant *services.AlbumGrant) (*models.Selection, bool) {
+	selection, err := h.selectionService.Get(album.ID, chi.URLParam(r, "selectionId"))
+	if err == nil && selection.ShareLinkID != grant.ShareLinkID {
+		err = errors.New("selection not found")
+	}
+	if err != nil {
+		h.selectionError(w, err)
+		return nil, false
+	}
+	return selection, true
+}
+
+// adminAlbum looks up the album in the URL of an admin request.
+func (h *ProofingHandler) adminAlbum(w http.ResponseWriter, r *http.Request) (*models.Album, bool) {
+	album, err := h.albumService.GetByID(chi.URLParam(r, "id"))
+	if err != nil {
+		if err.Error() == "album not found" {
+			http.Error(w, "Album not found", http.StatusNotFound)
+			return nil, false
+		}
+		h.logger.Error("failed to get album", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return album, true
+}
+
+// csvCell keeps client text from being read as a formula by spreadsheet apps.
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func (h *ProofingHandler) selectionError(w http.ResponseWriter, err error) {
+	switch {
+	case err.Error() == "selection not found":
+		http.Error(w, "Selection not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrSelectionLocked):
+		http.Error(w, "Selection is locked", http.StatusConflict)
+	case errors.Is(err, services.ErrTooManySelections):
+		http.Error(w, "Album has too many selections", http.StatusConflict)
+	case errors.Is(err, services.ErrInvalidSelection):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		h.logger.Error("failed to handle selection", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type proofingTestEnv struct {
+	router        http.Handler
+	album         *models.Album
+	shareToken    string // Album access token from a share link
+	passwordToken string // Album access token from the album password
+}
+
+// setupProofingRouter serves the proofing routes for a password-protected
+// proofing album with two photos, and returns access tokens for it.
+func setupProofingRouter(t *testing.T) *proofingTestEnv {
+	fileService, err := services.NewFileService(t.TempDir())
+	require.NoError(t, err)
+	albumService := services.NewAlbumService(fileService)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("letmein"), bcrypt.MinCost)
+	require.NoError(t, err)
+	album := &models.Album{
+		Title:        "Wedding",
+		Slug:         "wedding",
+		Visibility:   "password_protected",
+		PasswordHash: string(hash),
+		Proofing:     &models.Proofing{Enabled: true, SelectionLimit: 1},
+	}
+	require.NoError(t, albumService.Create(album))
+	require.NoError(t, albumService.Create(&models.Album{Title: "Portfolio", Slug: "portfolio", Visibility: "public"}))
+	for _, name := range []string{"IMG_0001.CR3", "=HYPERLINK(1).jpg"} {
+		require.NoError(t, albumService.AddPhoto(album.ID, &models.Photo{FilenameOriginal: name}))
+	}
+	album, err = albumService.GetByID(album.ID)
+	require.NoError(t, err)
+
+	accessService, err := services.NewAlbumAccessService(nil, time.Hour)
+	require.NoError(t, err)
+	shareLinks := services.NewShareLinkService(fileService)
+	accessService.SetShareLinkService(shareLinks)
+
+	link := &models.ShareLink{ExpiresAt: time.Now().Add(time.Hour)}
+	shareLinkToken, err := shareLinks.Create(album.ID, link)
+	require.NoError(t, err)
+	shareToken, _, _, err := accessService.GrantShareAccess(album, shareLinkToken, "192.0.2.1")
+	require.NoError(t, err)
+	passwordToken, _, _, err := accessService.GrantAccess(album, "letmein", "192.0.2.1")
+	require.NoError(t, err)
+
+	handler := NewProofingHandler(albumService, accessService, services.NewSelectionService(fileService), slog.New(slog.NewTextHandler(io.Discard, nil)))
+	r := chi.NewRouter()
+	r.Post("/api/albums/{slug}/selections", handler.StartSelection)
+	r.Get("/api/albums/{slug}/selections/{selectionId}", handler.GetSelection)
+	r.Put("/api/albums/{slug}/selections/{selectionId}", handler.UpdateSelection)
+	r.Post("/api/albums/{slug}/selections/{selectionId}/submit", handler.SubmitSelection)
+	r.Get("/api/admin/albums/{id}/selections", handler.ListSelections)
+	r.Get("/api/admin/albums/{id}/selections/{selectionId}/export", handler.ExportSelection)
+	r.Post("/api/admin/albums/{id}/selections/{selectionId}/lock", handler.LockSelection)
+
+	return &proofingTestEnv{
+		router:        r,
+		album:         album,
+		shareToken:    shareToken,
+		passwordToken: passwordToken,
+	}
+}
+
+func (env *proofingTestEnv) do(method, url, token, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestProofingHandler_Selection(t *testing.T) {
+	env := setupProofingRouter(t)
+	photoID := env.album.Photos[0].ID
+
+	// Visitors need a token, and albums must be in proofing
+	assert.Equal(t, http.StatusUnauthorized, env.do("POST", "/api/albums/wedding/selections", "", "").Code)
+	assert.Equal(t, http.StatusNotFound, env.do("POST", "/api/albums/portfolio/selections", env.shareToken, "").Code)
+
+	w := env.do("POST", "/api/albums/wedding/selections", env.shareToken, `{"client_name":"Bride's family"}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var selection models.Selection
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &selection))
+
+	// The share link keeps its selection
+	assert.Equal(t, http.StatusOK, env.do("POST", "/api/albums/wedding/selections", env.shareToken, "").Code)
+
+	url := "/api/albums/wedding/selections/" + selection.ID
+	w = env.do("PUT", url, env.shareToken, `{"favorites":["`+photoID+`"],"note":"Black and white please"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// The limit is one photo
+	w = env.do("PUT", url, env.shareToken, `{"favorites":["`+photoID+`","`+env.album.Photos[1].ID+`"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Password visitors cannot reach the share link's selection
+	assert.Equal(t, http.StatusNotFound, env.do("GET", url, env.passwordToken, "").Code)
+
+	w = env.do("POST", url+"/submit", env.shareToken, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &selection))
+	assert.NotNil(t, selection.SubmittedAt)
+	assert.Equal(t, "Black and white please", selection.Note)
+
+	// Locked selections cannot be changed
+	adminURL := "/api/admin/albums/" + env.album.ID + "/selections/" + selection.ID
+	require.Equal(t, http.StatusOK, env.do("POST", adminURL+"/lock", "", "").Code)
+	assert.Equal(t, http.StatusConflict, env.do("PUT", url, env.shareToken, `{"favorites":[]}`).Code)
+	assert.Equal(t, http.StatusConflict, env.do("POST", url+"/submit", env.shareToken, "").Code)
+}
+
+func TestProofingHandler_StartRateLimited(t *testing.T) {
+	env := setupProofingRouter(t)
+
+	var w *httptest.ResponseRecorder
+	for i := 0; i <= 10; i++ {
+		w = env.do("POST", "/api/albums/wedding/selections", env.passwordToken, "")
+		if w.Code != http.StatusCreated {
+			break
+		}
+	}
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	// Share links get one selection each, so they are not limited
+	assert.Equal(t, http.StatusCreated, env.do("POST", "/api/albums/wedding/selections", env.shareToken, "").Code)
+}
+
+func TestProofingHandler_Admin(t *testing.T) {
+	env := setupProofingRouter(t)
+	photos := env.album.Photos
+
+	for _, token := range []string{env.shareToken, env.passwordToken} {
+		w := env.do("POST", "/api/albums/wedding/selections", token, "")
+		require.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	w := env.do("GET", "/api/admin/albums/"+env.album.ID+"/selections", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Selections []models.Selection `json:"selections"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Selections, 2)
+	selection := resp.Selections[1]
+
+	// The password visitor names themselves and picks the second photo
+	url := "/api/albums/wedding/selections/" + selection.ID
+	w = env.do("PUT", url, env.passwordToken, `{"client_name":"Anna & Ben","favorites":["`+photos[1].ID+`"],"photo_notes":{"`+photos[1].ID+`":"-remove the sign"}}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	exportURL := "/api/admin/albums/" + env.album.ID + "/selections/" + selection.ID + "/export"
+	w = env.do("GET", exportURL, "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), `filename="selection-wedding-Anna-Ben.csv"`)
+	assert.Equal(t, "order,filename,photo_id,note\n1,'=HYPERLINK(1).jpg,"+photos[1].ID+",'-remove the sign\n", w.Body.String())
+
+	w = env.do("GET", exportURL+"?format=lightroom", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "=HYPERLINK(1)\n", w.Body.String())
+
+	assert.Equal(t, http.StatusBadRequest, env.do("GET", exportURL+"?format=xml", "", "").Code)
+	assert.Equal(t, http.StatusNotFound, env.do("GET", "/api/admin/albums/"+env.album.ID+"/selections/missing/export", "", "").Code)
+}
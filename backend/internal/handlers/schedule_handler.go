@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+)
+
+// ScheduleHandler runs the album scheduler and shows its upcoming events to the admin.
+type ScheduleHandler struct {
+	scheduler *services.AlbumScheduler
+	logger    *slog.Logger
+}
+
+// NewScheduleHandler creates a new schedule handler.
+func NewScheduleHandler(scheduler *services.AlbumScheduler, logger *slog.Logger) *ScheduleHandler {
+	return &ScheduleHandler{
+		scheduler: scheduler,
+		logger:    logger,
+	}
+}
+
+// Upcoming lists pending publishes and expiries, soonest first.
+func (h *ScheduleHandler) Upcoming(w http.ResponseWriter, r *http.Request) {
+	entries, err := h.scheduler.Upcoming(time.Now())
+	if err != nil {
+		h.logger.Error("failed to list scheduled albums", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{"schedule": entries})
+}
+
+// StartScheduler starts a goroutine that applies scheduled publishing and
+// album expiry right away, catching up on anything due while the server was
+// down, and then every interval.
+func (h *ScheduleHandler) StartScheduler(interval time.Duration) {
+	go func() {
+		h.run(time.Now())
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			h.run(now)
+		}
+	}()
+}
+
+func (h *ScheduleHandler) run(now time.Time) {
+	report, err := h.scheduler.Run(now)
+	if err != nil {
+		h.logger.Error("album scheduler failed", slog.String("error", err.Error()))
+		return
+	}
+
+	h.logAlbums(report.Published, slog.LevelInfo, "published scheduled album")
+	h.logAlbums(report.Expired, slog.LevelInfo, "album expired")
+	h.logAlbums(report.Archived, slog.LevelInfo, "archived expired album")
+	h.logAlbums(report.Expiring, slog.LevelWarn, "album expires soon")
+}
+
+func (h *ScheduleHandler) logAlbums(albums []models.Album, level slog.Level, msg string) {
+	for i := range albums {
+		attrs := []slog.Attr{
+			slog.String("album_id", albums[i].ID),
+			slog.String("title", albums[i].Title),
+			slog.String("visibility", albums[i].Visibility),
+		}
+		if albums[i].ExpirationDate != nil {
+			attrs = append(attrs, slog.Time("expiration_date", *albums[i].ExpirationDate))
+		}
+		h.logger.LogAttrs(context.Background(), level, msg, attrs...)
+	}
+}
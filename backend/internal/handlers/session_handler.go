@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/middleware"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+)
+
+// SessionHandler handles the admin endpoints for the signed-in user's
+// sessions.
+type SessionHandler struct {
+	authService *services.AuthService
+	logger      *slog.Logger
+}
+
+// NewSessionHandler creates a new session handler.
+func NewSessionHandler(authService *services.AuthService, logger *slog.Logger) *SessionHandler {
+	return &SessionHandler{
+		authService: authService,
+		logger:      logger,
+	}
+}
+
+// sessionInfo is a session as listed to its user.
+type sessionInfo struct {
+	services.Session
+	Current bool `json:"current"` // Whether the request was made with this session
+}
+
+// List returns the signed-in user's sessions, most recently seen first.
+func (h *SessionHandler) List(w http.ResponseWriter, r *http.Request) {
+	current := middleware.GetSession(r.Context())
+	sessions, err := h.authService.ListUserSessions(current.UserID)
+	if err != nil {
+		h.logger.Error("failed to list sessions", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	infos := make([]sessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		infos = append(infos, sessionInfo{
+			Session: session.Sanitized(),
+			Current: session.ID == current.ID,
+		})
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"sessions": infos,
+	})
+}
+
+// Revoke signs out one of the signed-in user's sessions.
+func (h *SessionHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetSession(r.Context())
+	sessionID := chi.URLParam(r, "sessionId")
+
+	if err := h.authService.RevokeSession(session.UserID, sessionID); err != nil {
+		if err.Error() == "session not found" {
+			http.Error(w, "Session not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("failed to revoke session", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("session revoked",
+		slog.String("username", session.Username),
+		slog.Bool("current", sessionID == session.ID),
+	)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RevokeOthers signs out every session of the signed-in user but the one the
+// request was made with.
+func (h *SessionHandler) RevokeOthers(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetSession(r.Context())
+	h.authService.InvalidateUserSessions(session.UserID, session.ID)
+
+	h.logger.Info("other sessions revoked", slog.String("username", session.Username))
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sessionRequest(router http.Handler, method, path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// listSessions returns the sessions listed to the holder of cookie.
+func listSessions(t *testing.T, router http.Handler, cookie *http.Cookie) []map[string]interface{} {
+	w := sessionRequest(router, "GET", "/sessions", cookie)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Sessions []map[string]interface{} `json:"sessions"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Sessions
+}
+
+func TestSessionHandler(t *testing.T) {
+	router, _ := setupAuthRouter(t, services.DefaultLoginGuardConfig())
+
+	login := func(userAgent string) *http.Cookie {
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(`{"username":"owner","password":"owner-pass"}`))
+		req.Header.Set("User-Agent", userAgent)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return sessionCookie(t, w)
+	}
+	current := login("Firefox")
+	second := login("Safari")
+	third := login("curl")
+
+	sessions := listSessions(t, router, current)
+	require.Len(t, sessions, 3)
+	var currentID, otherID string
+	for _, session := range sessions {
+		assert.NotContains(t, session, "csrf_token")
+		assert.NotEmpty(t, session["ip_hash"])
+		assert.NotEmpty(t, session["last_seen_at"])
+		assert.NotEmpty(t, session["created_at"])
+		if session["current"] == true {
+			assert.Equal(t, "Firefox", session["user_agent"])
+			currentID = session["id"].(string)
+		} else if otherID == "" {
+			otherID = session["id"].(string)
+		}
+	}
+	require.NotEmpty(t, currentID)
+
+	// Revoking a session signs it out
+	assert.Equal(t, http.StatusNotFound, sessionRequest(router, "DELETE", "/sessions/unknown", current).Code)
+	assert.Equal(t, http.StatusNoContent, sessionRequest(router, "DELETE", "/sessions/"+otherID, current).Code)
+	assert.Len(t, listSessions(t, router, current), 2)
+
+	// Revoking the others leaves only the current session
+	assert.Equal(t, http.StatusNoContent, sessionRequest(router, "POST", "/sessions/revoke-others", current).Code)
+	for _, cookie := range []*http.Cookie{second, third} {
+		assert.Equal(t, http.StatusUnauthorized, sessionRequest(router, "GET", "/sessions", cookie).Code)
+	}
+	sessions = listSessions(t, router, current)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, currentID, sessions[0]["id"])
+}
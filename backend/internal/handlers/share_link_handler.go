@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/middleware"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+)
+
+// defaultPreviewLinkTTL is how long preview links last without an expiry.
+const defaultPreviewLinkTTL = 7 * 24 * time.Hour
+
+// ShareLinkHandler handles the admin endpoints for album share links.
+type ShareLinkHandler struct {
+	albumService     *services.AlbumService
+	shareLinkService *services.ShareLinkService
+	logger           *slog.Logger
+}
+
+// NewShareLinkHandler creates a new share link handler.
+func NewShareLinkHandler(
+	albumService *services.AlbumService,
+	shareLinkService *services.ShareLinkService,
+	logger *slog.Logger,
+) *ShareLinkHandler {
+	return &ShareLinkHandler{
+		albumService:     albumService,
+		shareLinkService: shareLinkService,
+		logger:           logger,
+	}
+}
+
+// Create creates a share link for an album. The response is the only time the
+// link's token is shown.
+func (h *ShareLinkHandler) Create(w http.ResponseWriter, r *http.Request) {
+	album, ok := h.album(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Label          string    `json:"label"`
+		ExpiresAt      time.Time `json:"expires_at"`
+		AllowDownloads bool      `json:"allow_downloads"`
+		MaxViews       int       `json:"max_views"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	link := &models.ShareLink{
+		Label:          req.Label,
+		ExpiresAt:      req.ExpiresAt,
+		AllowDownloads: req.AllowDownloads,
+		MaxViews:       req.MaxViews,
+	}
+	token, err := h.shareLinkService.Create(album.ID, link)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	middleware.AuditChange(r.Context(), nil, link)
+
+	h.logger.Info("share link created",
+		slog.String("album_id", album.ID),
+		slog.String("share_link_id", link.ID),
+	)
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"share_link": link.Sanitized(),
+		"token":      token,
+		"url":        "/albums/" + album.Slug + "?share=" + url.QueryEscape(token),
+	})
+}
+
+// CreatePreview creates a preview link, with which reviewers see a draft album
+// as it will appear without logging in. Links last a week unless expires_at
+// is given, and can be revoked like any share link.
+func (h *ShareLinkHandler) CreatePreview(w http.ResponseWriter, r *http.Request) {
+	album, ok := h.album(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Label     string    `json:"label"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if req.ExpiresAt.IsZero() {
+		req.ExpiresAt = time.Now().Add(defaultPreviewLinkTTL).UTC()
+	}
+
+	link := &models.ShareLink{
+		Label:     req.Label,
+		ExpiresAt: req.ExpiresAt,
+		Preview:   true,
+	}
+	token, err := h.shareLinkService.Create(album.ID, link)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	middleware.AuditChange(r.Context(), nil, link)
+
+	h.logger.Info("preview link created",
+		slog.String("album_id", album.ID),
+		slog.String("share_link_id", link.ID),
+	)
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"share_link": link.Sanitized(),
+		"token":      token,
+		"url":        "/albums/" + album.Slug + "?share=" + url.QueryEscape(token),
+	})
+}
+
+// List returns an album's share links with their access logs.
+func (h *ShareLinkHandler) List(w http.ResponseWriter, r *http.Request) {
+	album, ok := h.album(w, r)
+	if !ok {
+		return
+	}
+
+	links, err := h.shareLinkService.ListByAlbum(album.ID)
+	if err != nil {
+		h.logger.Error("failed to list share links", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	for i := range links {
+		links[i] = links[i].Sanitized()
+	}
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"share_links": links,
+	})
+}
+
+// Revoke revokes a share link. Access already granted through it ends as well.
+func (h *ShareLinkHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	albumID := chi.URLParam(r, "id")
+	shareID := chi.URLParam(r, "shareId")
+
+	before, _ := h.shareLinkService.Get(shareID)
+	link, err := h.shareLinkService.Revoke(albumID, shareID)
+	if err != nil {
+		if err.Error() == "share link not found" {
+			http.Error(w, "Share link not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("failed to revoke share link", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	if before != nil {
+		middleware.AuditChange(r.Context(), before, link)
+	}
+
+	h.logger.Info("share link revoked",
+		slog.String("album_id", albumID),
+		slog.String("share_link_id", link.ID),
+	)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// album looks up the album in the URL.
+func (h *ShareLinkHandler) album(w http.ResponseWriter, r *http.Request) (*models.Album, bool) {
+	album, err := h.albumService.GetByID(chi.URLParam(r, "id"))
+	if err != nil {
+		if err.Error() == "album not found" {
+			http.Error(w, "Album not found", http.StatusNotFound)
+			return nil, false
+		}
+		h.logger.Error("failed to get album", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return album, true
+}
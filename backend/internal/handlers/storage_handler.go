@@ -43,6 +43,7 @@ type StorageByType struct {
 	Originals  int64 `json:"originals_bytes"`
 	Display    int64 `json:"display_bytes"`
 	Thumbnails int64 `json:"thumbnails_bytes"`
+	Crops      int64 `json:"crops_bytes"`
 }
 
 // StorageWarning provides warning information if storage is getting full.
@@ -73,7 +74,7 @@ func (h *StorageHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	usedBytes := breakdown.Originals + breakdown.Display + breakdown.Thumbnails
+	usedBytes := breakdown.Originals + breakdown.Display + breakdown.Thumbnails + breakdown.Crops
 	usagePercent := (float64(totalBytes-availableBytes) / float64(totalBytes)) * 100
 
 	// Get config to determine max usage threshold
@@ -158,6 +159,14 @@ func (h *StorageHandler) calculateStorageBreakdown() (*StorageByType, error) {
 	}
 	breakdown.Thumbnails = size
 
+	// Calculate cropped tiers
+	cropsDir := filepath.Join(h.uploadDir, "crops")
+	size, err = calculateDirectorySize(cropsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate crops size: %w", err)
+	}
+	breakdown.Crops = size
+
 	return breakdown, nil
 }
 
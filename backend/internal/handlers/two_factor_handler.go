@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"image/png"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/middleware"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+)
+
+// qrCodeSize is the width and height of enrollment QR codes, in pixels.
+const qrCodeSize = 256
+
+// TwoFactorHandler handles the admin endpoints for TOTP two-factor
+// authentication. Users manage their own; owners can reset anyone's.
+type TwoFactorHandler struct {
+	userService *services.UserService
+	authService *services.AuthService
+	issuer      string
+	logger      *slog.Logger
+}
+
+// NewTwoFactorHandler creates a new two-factor handler. The issuer names the
+// site in authenticator apps.
+func NewTwoFactorHandler(userService *services.UserService, authService *services.AuthService, issuer string, logger *slog.Logger) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		userService: userService,
+		authService: authService,
+		issuer:      issuer,
+		logger:      logger,
+	}
+}
+
+// Status returns the signed-in user's two-factor status.
+func (h *TwoFactorHandler) Status(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetSession(r.Context())
+	status, err := h.userService.TwoFactorStatus(session.UserID)
+	if err != nil {
+		h.logger.Error("failed to get two-factor status", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"two_factor": status,
+	})
+}
+
+// Enroll starts two-factor enrollment for the signed-in user. It returns the
+// secret, its otpauth:// provisioning URI and a QR code PNG of the URI as a
+// data URL, for the user to add to their authenticator app.
+func (h *TwoFactorHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetSession(r.Context())
+	key, err := h.userService.StartTOTPEnrollment(session.UserID, h.issuer)
+	if err != nil {
+		h.logger.Warn("failed to start two-factor enrollment", slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	img, err := key.Image(qrCodeSize, qrCodeSize)
+	if err != nil {
+		h.logger.Error("failed to render QR code", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		h.logger.Error("failed to encode QR code", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"secret":           key.Secret(),
+		"provisioning_uri": key.URL(),
+		"qr_code":          "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	})
+}
+
+// Verify enables two-factor authentication for the signed-in user with a code
+// from their authenticator app. It returns the recovery codes, which are not
+// shown again, and signs out the user's other sessions.
+func (h *TwoFactorHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	session := middleware.GetSession(r.Context())
+	codes, err := h.userService.EnableTOTP(session.UserID, req.Code)
+	if err != nil {
+		if !errors.Is(err, services.ErrInvalidTOTPCode) {
+			h.logger.Warn("failed to enable two-factor authentication", slog.String("error", err.Error()))
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	h.authService.InvalidateUserSessions(session.UserID, session.ID)
+
+	h.logger.Info("two-factor authentication enabled", slog.String("username", session.Username))
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"message":        "Two-factor authentication enabled",
+		"recovery_codes": codes,
+	})
+}
+
+// Disable turns off two-factor authentication for the signed-in user, after
+// checking their password.
+func (h *TwoFactorHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	session := middleware.GetSession(r.Context())
+	if err := h.userService.VerifyPassword(session.UserID, req.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.userService.DisableTOTP(session.UserID); err != nil {
+		h.logger.Error("failed to disable two-factor authentication", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("two-factor authentication disabled", slog.String("username", session.Username))
+
+	respondJSON(w, http.StatusOK, map[string]string{
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// Reset turns off two-factor authentication for another user, for when they
+// have lost their authenticator and recovery codes.
+func (h *TwoFactorHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	session := middleware.GetSession(r.Context())
+	userID := chi.URLParam(r, "userId")
+
+	if err := h.userService.DisableTOTP(userID); err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		h.logger.Error("failed to reset two-factor authentication", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.logger.Info("two-factor authentication reset",
+		slog.String("user_id", userID),
+		slog.String("by", session.Username),
+	)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/middleware"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+)
+
+// UserHandler handles the admin endpoints for managing admin users.
+type UserHandler struct {
+	userService *services.UserService
+	authService *services.AuthService
+	logger      *slog.Logger
+}
+
+// NewUserHandler creates a new user handler.
+func NewUserHandler(userService *services.UserService, authService *services.AuthService, logger *slog.Logger) *UserHandler {
+	return &UserHandler{
+		userService: userService,
+		authService: authService,
+		logger:      logger,
+	}
+}
+
+// List returns every admin user.
+func (h *UserHandler) List(w http.ResponseWriter, r *http.Request) {
+	users, err := h.userService.GetAll()
+	if err != nil {
+		h.logger.Error("failed to list users", slog.String("error", err.Error()))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	sanitized := make([]models.User, len(users))
+	for i, user := range users {
+		sanitized[i] = user.Sanitized()
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"users": sanitized,
+	})
+}
+
+// Get returns an admin user.
+func (h *UserHandler) Get(w http.ResponseWriter, r *http.Request) {
+	user, err := h.userService.Get(chi.URLParam(r, "userId"))
+	if err != nil {
+		h.userError(w, "failed to get user", err)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"user": user.Sanitized(),
+	})
+}
+
+// Create adds an admin user with a username, password and role.
+func (h *UserHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Username string      `json:"username"`
+		Password string      `json:"password"`
+		Role     models.Role `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	user := &models.User{
+		Username: req.Username,
+		Role:     req.Role,
+	}
+	if err := h.userService.Create(user, req.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	middleware.AuditChange(r.Context(), nil, user)
+
+	h.logger.Info("user created",
+		slog.String("user_id", user.ID),
+		slog.String("username", user.Username),
+		slog.String("role", string(user.Role)),
+		slog.String("by", middleware.GetSession(r.Context()).Username),
+	)
+
+	respondJSON(w, http.StatusCreated, map[string]interface{}{
+		"user": user.Sanitized(),
+	})
+}
+
+// Update changes an admin user's username and role, and resets their password
+// when one is given. A password reset signs the user out everywhere else.
+func (h *UserHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "userId")
+
+	var req struct {
+		Username string      `json:"username"`
+		Role     models.Role `json:"role"`
+		Password string      `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Password != "" {
+		// Check the new password before changing anything else
+		if err := services.ValidatePassword(req.Password); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	before, _ := h.userService.Get(userID)
+	user := &models.User{
+		Username: req.Username,
+		Role:     req.Role,
+	}
+	if err := h.userService.Update(userID, user); err != nil {
+		h.userError(w, "failed to update user", err)
+		return
+	}
+
+	session := middleware.GetSession(r.Context())
+	if req.Password != "" {
+		if err := h.userService.SetPassword(userID, req.Password); err != nil {
+			h.userError(w, "failed to reset user password", err)
+			return
+		}
+		h.authService.InvalidateUserSessions(userID, session.ID)
+	}
+	if after, err := h.userService.Get(userID); err == nil && before != nil {
+		middleware.AuditChange(r.Context(), before, after)
+	}
+
+	h.logger.Info("user updated",
+		slog.String("user_id", userID),
+		slog.String("username", user.Username),
+		slog.String("role", string(user.Role)),
+		slog.Bool("password_reset", req.Password != ""),
+		slog.String("by", session.Username),
+	)
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"user": user.Sanitized(),
+	})
+}
+
+// Delete removes an admin user and signs them out. Users cannot delete
+// themselves.
+func (h *UserHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "userId")
+	session := middleware.GetSession(r.Context())
+	if userID == session.UserID {
+		http.Error(w, "You cannot delete your own account", http.StatusBadRequest)
+		return
+	}
+
+	before, _ := h.userService.Get(userID)
+	if err := h.userService.Delete(userID); err != nil {
+		h.userError(w, "failed to delete user", err)
+		return
+	}
+	if before != nil {
+		middleware.AuditChange(r.Context(), before, nil)
+	}
+	h.authService.InvalidateUserSessions(userID, "")
+
+	h.logger.Info("user deleted",
+		slog.String("user_id", userID),
+		slog.String("by", session.Username),
+	)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// userError responds to a user service error: 404 for unknown users, 409 when
+// the change would leave no owner, and 400 otherwise.
+func (h *UserHandler) userError(w http.ResponseWriter, msg string, err error) {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		http.Error(w, "User not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrLastOwner):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		h.logger.Warn(msg, slog.String("error", err.Error()))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
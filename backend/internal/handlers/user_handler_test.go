@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/middleware"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type userTestEnv struct {
+	router      http.Handler
+	users       *services.UserService
+	authService *services.AuthService
+}
+
+// setupUserRouter mounts the user endpoints for owners and an upload endpoint
+// for uploaders, behind the auth middleware as in the server.
+func setupUserRouter(t *testing.T) *userTestEnv {
+	fileService, err := services.NewFileService(t.TempDir())
+	require.NoError(t, err)
+	users := services.NewUserService(fileService)
+	hash, err := services.HashPassword("owner-pass")
+	require.NoError(t, err)
+	_, err = users.Migrate(models.AdminConfig{Username: "owner", PasswordHash: hash})
+	require.NoError(t, err)
+
+	authService := services.NewAuthService(users, time.Hour)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	handler := NewUserHandler(users, authService, logger)
+	authHandler := NewAuthHandler(authService, logger)
+
+	r := chi.NewRouter()
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Auth(authService, logger))
+		r.Get("/auth/check", authHandler.Check)
+		r.With(middleware.RequireRole(models.RoleUploader, logger)).Post("/upload", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.RequireRole(models.RoleOwner, logger))
+			r.Get("/users", handler.List)
+			r.Post("/users", handler.Create)
+			r.Put("/users/{userId}", handler.Update)
+			r.Delete("/users/{userId}", handler.Delete)
+		})
+	})
+
+	return &userTestEnv{router: r, users: users, authService: authService}
+}
+
+func (env *userTestEnv) login(t *testing.T, username, password string) *http.Cookie {
+	sessionID, err := env.authService.Authenticate(username, password)
+	require.NoError(t, err)
+	return &http.Cookie{Name: "photoadmin_session", Value: sessionID}
+}
+
+func (env *userTestEnv) do(method, url, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	env.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestUserHandler_CRUD(t *testing.T) {
+	env := setupUserRouter(t)
+	owner := env.login(t, "owner", "owner-pass")
+
+	w := env.do("POST", "/users", `{"username":"assistant","password":"assistant-pass","role":"uploader"}`, owner)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.NotContains(t, w.Body.String(), "password_hash")
+	var created struct {
+		User models.User `json:"user"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, models.RoleUploader, created.User.Role)
+
+	w = env.do("POST", "/users", `{"username":"assistant","password":"x","role":"viewer"}`, owner)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "duplicate username")
+
+	w = env.do("GET", "/users", "", owner)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "password_hash")
+	var list struct {
+		Users []models.User `json:"users"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list.Users, 2)
+
+	// Weak passwords are rejected before anything changes
+	w = env.do("PUT", "/users/"+created.User.ID, `{"username":"assistant","role":"editor","password":"short"}`, owner)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "at least 10 characters")
+	unchanged, err := env.users.Get(created.User.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleUploader, unchanged.Role)
+	w = env.do("POST", "/users", `{"username":"intern","password":"password123","role":"viewer"}`, owner)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// A password reset signs the user out
+	assistant := env.login(t, "assistant", "assistant-pass")
+	w = env.do("PUT", "/users/"+created.User.ID, `{"username":"assistant","role":"editor","password":"reset-pass"}`, owner)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusUnauthorized, env.do("GET", "/auth/check", "", assistant).Code)
+	assistant = env.login(t, "assistant", "reset-pass")
+	w = env.do("GET", "/auth/check", "", assistant)
+	assert.Contains(t, w.Body.String(), `"role":"editor"`)
+
+	// The last owner can neither demote nor delete themselves
+	ownerUser, err := env.users.GetAll()
+	require.NoError(t, err)
+	w = env.do("PUT", "/users/"+ownerUser[0].ID, `{"username":"owner","role":"editor"}`, owner)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = env.do("DELETE", "/users/"+ownerUser[0].ID, "", owner)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = env.do("DELETE", "/users/"+created.User.ID, "", owner)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, http.StatusUnauthorized, env.do("GET", "/auth/check", "", assistant).Code)
+	w = env.do("DELETE", "/users/"+created.User.ID, "", owner)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUserHandler_Roles(t *testing.T) {
+	env := setupUserRouter(t)
+	for _, user := range []models.User{
+		{Username: "uploader", Role: models.RoleUploader},
+		{Username: "viewer", Role: models.RoleViewer},
+	} {
+		require.NoError(t, env.users.Create(&user, user.Username+"-pass"))
+	}
+
+	tests := []struct {
+		username string
+		method   string
+		url      string
+		want     int
+	}{
+		{username: "", method: "GET", url: "/auth/check", want: http.StatusUnauthorized},
+		{username: "viewer", method: "GET", url: "/auth/check", want: http.StatusOK},
+		{username: "viewer", method: "POST", url: "/upload", want: http.StatusForbidden},
+		{username: "uploader", method: "POST", url: "/upload", want: http.StatusNoContent},
+		{username: "uploader", method: "GET", url: "/users", want: http.StatusForbidden},
+		{username: "owner", method: "POST", url: "/upload", want: http.StatusNoContent},
+		{username: "owner", method: "GET", url: "/users", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.username+" "+tt.method+" "+tt.url, func(t *testing.T) {
+			var cookie *http.Cookie
+			if tt.username != "" {
+				cookie = env.login(t, tt.username, tt.username+"-pass")
+			}
+			assert.Equal(t, tt.want, env.do(tt.method, tt.url, "", cookie).Code)
+		})
+	}
+}
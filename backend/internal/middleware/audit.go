@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+)
+
+const auditEntryKey contextKey = "audit_entry"
+
+// Audit middleware records every state-changing request in the audit log,
+// once it is answered, with its user, route, album and photo, and status.
+// Handlers add what they changed with AuditChange. It runs after Auth.
+func Audit(auditService *services.AuditService, logger *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isSafeMethod(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			entry := &models.AuditEntry{
+				RequestID: GetRequestID(r.Context()),
+				Path:      r.URL.Path,
+			}
+			if session := GetSession(r.Context()); session != nil {
+				entry.UserID = session.UserID
+				entry.Username = session.Username
+				entry.Role = session.Role
+				entry.TokenID = session.TokenID
+			}
+			ww := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), auditEntryKey, entry)))
+
+			// The route is known once the request has been routed
+			pattern := r.URL.Path
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				if routePattern := rctx.RoutePattern(); routePattern != "" {
+					pattern = routePattern
+				}
+				if entry.AlbumID == "" && strings.HasPrefix(pattern, "/api/admin/albums/{id}") {
+					entry.AlbumID = rctx.URLParam("id")
+				}
+				if entry.PhotoID == "" {
+					entry.PhotoID = rctx.URLParam("photoId")
+				}
+			}
+			entry.Action = r.Method + " " + pattern
+			entry.Status = ww.statusCode
+
+			if err := auditService.Record(entry); err != nil {
+				logger.Error("failed to record audit entry",
+					slog.String("error", err.Error()),
+					slog.String("action", entry.Action),
+					slog.String("request_id", entry.RequestID),
+				)
+			}
+		})
+	}
+}
+
+// AuditChange adds what a request changed to its audit entry: the fields of
+// before and after, summaries such as the model before and after the change,
+// that differ. Either may be nil, for creations and deletions.
+func AuditChange(ctx context.Context, before, after interface{}) {
+	entry, ok := ctx.Value(auditEntryKey).(*models.AuditEntry)
+	if !ok {
+		return
+	}
+	beforeChanged, afterChanged, err := services.AuditDiff(before, after)
+	if err != nil {
+		return
+	}
+	if len(beforeChanged) > 0 {
+		entry.Before = beforeChanged
+	}
+	if len(afterChanged) > 0 {
+		entry.After = afterChanged
+	}
+}
+
+// AuditAlbum sets the album of a request's audit entry, for requests whose
+// route does not name it, such as creating an album.
+func AuditAlbum(ctx context.Context, albumID string) {
+	if entry, ok := ctx.Value(auditEntryKey).(*models.AuditEntry); ok {
+		entry.AlbumID = albumID
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"log/slog"
 	"net/http"
+	"strings"
 
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
 	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
 )
 
@@ -12,10 +14,33 @@ type authContextKey string
 
 const sessionKey authContextKey = "session"
 
-// Auth middleware validates session and requires authentication.
+// Auth middleware validates session and requires authentication. Requests
+// are authenticated by the session cookie, or by an API token in an
+// "Authorization: Bearer" header.
 func Auth(authService *services.AuthService, logger *slog.Logger) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if header := r.Header.Get("Authorization"); header != "" {
+				token, ok := strings.CutPrefix(header, "Bearer ")
+				if !ok {
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+					return
+				}
+				session, err := authService.ValidateAPIToken(strings.TrimSpace(token))
+				if err != nil {
+					logger.Warn("invalid API token",
+						slog.String("error", err.Error()),
+						slog.String("path", r.URL.Path),
+						slog.String("request_id", GetRequestID(r.Context())),
+					)
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+					return
+				}
+				ctx := context.WithValue(r.Context(), sessionKey, session)
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
 			// Get session ID from cookie
 			cookie, err := r.Cookie("photoadmin_session")
 			if err != nil {
@@ -46,6 +71,89 @@ func Auth(authService *services.AuthService, logger *slog.Logger) func(next http
 	}
 }
 
+// RequireRole middleware rejects sessions whose role does not include role. It
+// runs after Auth.
+func RequireRole(role models.Role, logger *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session := GetSession(r.Context())
+			if session == nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if !session.Role.Includes(role) {
+				logger.Warn("permission denied",
+					slog.String("username", session.Username),
+					slog.String("role", string(session.Role)),
+					slog.String("required_role", string(role)),
+					slog.String("path", r.URL.Path),
+					slog.String("request_id", GetRequestID(r.Context())),
+				)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireScope middleware rejects API tokens without scope. Browser sessions
+// have every scope. It runs after Auth.
+func RequireScope(scope models.Scope, logger *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session := GetSession(r.Context())
+			if session == nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if !session.HasScope(scope) {
+				logger.Warn("API token scope denied",
+					slog.String("username", session.Username),
+					slog.String("token_id", session.TokenID),
+					slog.String("required_scope", string(scope)),
+					slog.String("path", r.URL.Path),
+					slog.String("request_id", GetRequestID(r.Context())),
+				)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireBrowserSession middleware rejects API tokens, for account and user
+// management that only a signed-in user may do. It runs after Auth.
+func RequireBrowserSession(logger *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session := GetSession(r.Context())
+			if session == nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			if session.IsAPIToken() {
+				logger.Warn("API token used for account management",
+					slog.String("username", session.Username),
+					slog.String("token_id", session.TokenID),
+					slog.String("path", r.URL.Path),
+					slog.String("request_id", GetRequestID(r.Context())),
+				)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // GetSession retrieves the session from context.
 func GetSession(ctx context.Context) *services.Session {
 	if session, ok := ctx.Value(sessionKey).(*services.Session); ok {
@@ -0,0 +1,117 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CSRFHeader is the request header that carries the session's CSRF token.
+const CSRFHeader = "X-CSRF-Token"
+
+// CheckOrigin middleware rejects state-changing requests from other sites. The
+// Origin header, or failing that the Referer, must be the server itself or one
+// of trustedOrigins (such as "https://example.com"). Requests with neither
+// header are let through for the CSRF token check. Requests with an
+// Authorization header are exempt, as browsers never add one by themselves.
+func CheckOrigin(trustedOrigins []string, logger *slog.Logger) func(next http.Handler) http.Handler {
+	trusted := make(map[string]bool, len(trustedOrigins))
+	for _, origin := range trustedOrigins {
+		trusted[normalizeOrigin(origin)] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isSafeMethod(r.Method) || r.Header.Get("Authorization") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				if referer := r.Header.Get("Referer"); referer != "" {
+					if u, err := url.Parse(referer); err == nil {
+						origin = u.Scheme + "://" + u.Host
+					}
+				}
+			}
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !trusted[normalizeOrigin(origin)] && !sameHost(origin, r.Host) {
+				logger.Warn("cross-origin request rejected",
+					slog.String("origin", origin),
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("request_id", GetRequestID(r.Context())),
+				)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CSRF middleware requires state-changing requests of browser sessions to
+// send the session's CSRF token in the X-CSRF-Token header. The token is
+// issued at login and readable by the admin frontend, but not by other sites.
+// API tokens are exempt. It runs after Auth.
+func CSRF(logger *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session := GetSession(r.Context())
+			if session == nil {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if isSafeMethod(r.Method) || session.IsAPIToken() {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			token := r.Header.Get(CSRFHeader)
+			if token == "" || session.CSRFToken == "" ||
+				subtle.ConstantTimeCompare([]byte(token), []byte(session.CSRFToken)) != 1 {
+				logger.Warn("invalid CSRF token",
+					slog.String("username", session.Username),
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.String("request_id", GetRequestID(r.Context())),
+				)
+				http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isSafeMethod reports whether requests with the method do not change state.
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// normalizeOrigin lowercases an origin and drops any trailing slash.
+func normalizeOrigin(origin string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(origin)), "/")
+}
+
+// sameHost reports whether origin names the host the request was sent to.
+func sameHost(origin, host string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return strings.EqualFold(u.Host, host)
+}
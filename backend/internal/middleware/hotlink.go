@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+)
+
+// defaultHotlinkPlaceholder is served to blocked requests in placeholder mode
+// when no placeholder image is configured.
+const defaultHotlinkPlaceholder = `<svg xmlns="http://www.w3.org/2000/svg" width="640" height="400" viewBox="0 0 640 400">` +
+	`<rect width="640" height="400" fill="#e5e5e5"/>` +
+	`<text x="320" y="205" font-family="sans-serif" font-size="24" fill="#737373" text-anchor="middle">Image not available</text>` +
+	`</svg>`
+
+// HotlinkConfig configures hotlink protection for uploaded media.
+type HotlinkConfig struct {
+	// AllowedHosts lists the hosts whose pages may embed media;
+	// "*.example.com" also allows every subdomain. The server's own host is
+	// always allowed.
+	AllowedHosts []string
+	// AllowEmptyReferer lets through requests with neither Origin nor
+	// Referer, such as direct visits and clients that hide the Referer.
+	AllowEmptyReferer bool
+	// Signer, when set, lets signed URLs through regardless of Referer.
+	Signer *services.MediaSigner
+	// Placeholder is served instead of a 403 when set.
+	Placeholder     []byte
+	PlaceholderType string
+}
+
+// DefaultHotlinkPlaceholder returns the built-in placeholder image.
+func DefaultHotlinkPlaceholder() ([]byte, string) {
+	return []byte(defaultHotlinkPlaceholder), "image/svg+xml"
+}
+
+// Hotlink middleware blocks requests for media embedded on other sites. The
+// Origin header is checked, or the Referer when there is no Origin, against
+// the allowed hosts.
+func Hotlink(config HotlinkConfig, logger *slog.Logger) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config.Signer != nil && r.URL.Query().Has("sig") && config.Signer.Verify(r.URL.Path, r.URL.Query(), time.Now()) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			source := r.Header.Get("Origin")
+			if source == "" {
+				source = r.Header.Get("Referer")
+			}
+			if source == "" && config.AllowEmptyReferer {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			sourceHost := ""
+			if u, err := url.Parse(source); err == nil {
+				sourceHost = strings.ToLower(u.Hostname())
+			}
+			if sourceHost != "" && (sourceHost == requestHost(r) || hostAllowed(sourceHost, config.AllowedHosts)) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			logger.Info("hotlink blocked",
+				slog.String("path", r.URL.Path),
+				slog.String("source_host", sourceHost),
+				slog.String("request_id", GetRequestID(r.Context())),
+			)
+
+			w.Header().Set("Cache-Control", "no-store")
+			if len(config.Placeholder) == 0 {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Content-Type", config.PlaceholderType)
+			_, _ = w.Write(config.Placeholder)
+		})
+	}
+}
+
+// requestHost returns the host the request was sent to, without its port.
+func requestHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	return strings.ToLower(host)
+}
+
+// hostAllowed reports whether host matches one of the allowed hosts.
+func hostAllowed(host string, allowed []string) bool {
+	for _, pattern := range allowed {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if host == suffix || strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
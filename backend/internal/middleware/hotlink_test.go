@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hotlinkRequest(config HotlinkConfig, target string, header http.Header) *httptest.ResponseRecorder {
+	handler := Hotlink(config, slog.New(slog.NewTextHandler(io.Discard, nil)))(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("image"))
+		}),
+	)
+	req := httptest.NewRequest("GET", target, nil)
+	req.Host = "photos.example.com:6180"
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestHotlink(t *testing.T) {
+	config := HotlinkConfig{
+		AllowedHosts:      []string{"example.com", "*.example.org"},
+		AllowEmptyReferer: true,
+	}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{name: "no referer", want: http.StatusOK},
+		{name: "allowed referer", header: http.Header{"Referer": {"https://example.com/albums/wedding"}}, want: http.StatusOK},
+		{name: "allowed subdomain", header: http.Header{"Referer": {"https://www.example.org/"}}, want: http.StatusOK},
+		{name: "wildcard domain itself", header: http.Header{"Referer": {"https://example.org/"}}, want: http.StatusOK},
+		{name: "own host", header: http.Header{"Referer": {"http://photos.example.com:6180/"}}, want: http.StatusOK},
+		{name: "other site", header: http.Header{"Referer": {"https://forum.test/thread/1"}}, want: http.StatusForbidden},
+		{name: "lookalike domain", header: http.Header{"Referer": {"https://notexample.com/"}}, want: http.StatusForbidden},
+		{name: "origin wins over referer", header: http.Header{"Origin": {"https://forum.test"}, "Referer": {"https://example.com/"}}, want: http.StatusForbidden},
+		{name: "opaque origin", header: http.Header{"Origin": {"null"}}, want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := hotlinkRequest(config, "/uploads/display/photo.webp", tt.header)
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+
+	config.AllowEmptyReferer = false
+	w := hotlinkRequest(config, "/uploads/display/photo.webp", nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestHotlink_SignedURLs(t *testing.T) {
+	signer, err := services.NewMediaSigner(bytes.Repeat([]byte("k"), 32), time.Hour)
+	require.NoError(t, err)
+	config := HotlinkConfig{AllowedHosts: []string{"example.com"}, Signer: signer}
+
+	signed := signer.SignURL("/uploads/display/photo.webp", time.Now())
+	w := hotlinkRequest(config, signed, http.Header{"Referer": {"https://forum.test/"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// A signature for another file does not help
+	other := signer.SignURL("/uploads/display/other.webp", time.Now())
+	w = hotlinkRequest(config, "/uploads/display/photo.webp"+other[len("/uploads/display/other.webp"):], http.Header{"Referer": {"https://forum.test/"}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestHotlink_Placeholder(t *testing.T) {
+	config := HotlinkConfig{AllowedHosts: []string{"example.com"}}
+	config.Placeholder, config.PlaceholderType = DefaultHotlinkPlaceholder()
+
+	w := hotlinkRequest(config, "/uploads/display/photo.webp", http.Header{"Referer": {"https://forum.test/"}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/svg+xml", w.Header().Get("Content-Type"))
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	assert.Contains(t, w.Body.String(), "<svg")
+}
@@ -1,6 +1,8 @@
 package models
 
 // AdminConfig represents the admin configuration stored in admin_config.json.
+// It holds the single account from before user accounts, which becomes the
+// first owner in users.json.
 type AdminConfig struct {
 	Username     string `json:"username"`
 	PasswordHash string `json:"password_hash"`
@@ -14,36 +14,96 @@ type Album struct {
 	Subtitle       string     `json:"subtitle,omitempty"`
 	Description    string     `json:"description,omitempty"`
 	CoverPhotoID   string     `json:"cover_photo_id,omitempty"`
-	Visibility     string     `json:"visibility"` // public, unlisted, password_protected
+	Visibility     string     `json:"visibility"` // public, unlisted, password_protected, draft
 	PasswordHash   string     `json:"password_hash,omitempty"`
 	ExpirationDate *time.Time `json:"expiration_date,omitempty"`
-	AllowDownloads bool       `json:"allow_downloads"`
-	Order          int        `json:"order"`
-	ThemeOverride  string     `json:"theme_override,omitempty"` // system, light, dark
-	CreatedAt      time.Time  `json:"created_at"`
-	UpdatedAt      time.Time  `json:"updated_at"`
-	AlbumStartDate *time.Time `json:"date_of_album_start,omitempty"`
-	AlbumEndDate   *time.Time `json:"date_of_album_end,omitempty"`
-	Photos         []Photo    `json:"photos"`
+	ExpiryAction   string     `json:"expiry_action,omitempty"` // hide (default) or archive
+	ArchivedAt     *time.Time `json:"archived_at,omitempty"`
+	ExpiryWarnedAt *time.Time `json:"expiry_warned_at,omitempty"`
+	// PublishAt schedules a change of visibility to PublishVisibility (public
+	// when empty). The scheduler applies it and clears both fields.
+	PublishAt         *time.Time `json:"publish_at,omitempty"`
+	PublishVisibility string     `json:"publish_visibility,omitempty"`
+	AllowDownloads    bool       `json:"allow_downloads"`
+	Order             int        `json:"order"`
+	ThemeOverride     string     `json:"theme_override,omitempty"` // system, light, dark
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	AlbumStartDate    *time.Time `json:"date_of_album_start,omitempty"`
+	AlbumEndDate      *time.Time `json:"date_of_album_end,omitempty"`
+	Proofing          *Proofing  `json:"proofing,omitempty"`
+	Photos            []Photo    `json:"photos"`
 }
 
 // Photo represents a single photo in an album.
 type Photo struct {
-	ID                string    `json:"id"`
-	FilenameOriginal  string    `json:"filename_original"`
-	URLOriginal       string    `json:"url_original"`
-	URLDisplay        string    `json:"url_display"`
-	URLThumbnail      string    `json:"url_thumbnail"`
-	Caption           string    `json:"caption,omitempty"`
-	AltText           string    `json:"alt_text,omitempty"`
-	Order             int       `json:"order"`
-	Width             int       `json:"width"`
-	Height            int       `json:"height"`
-	FileSizeOriginal  int64     `json:"file_size_original"`
-	FileSizeDisplay   int64     `json:"file_size_display"`
-	FileSizeThumbnail int64     `json:"file_size_thumbnail"`
-	EXIF              *EXIF     `json:"exif,omitempty"`
-	UploadedAt        time.Time `json:"uploaded_at"`
+	ID                string         `json:"id"`
+	FilenameOriginal  string         `json:"filename_original"`
+	URLOriginal       string         `json:"url_original"`
+	URLDisplay        string         `json:"url_display"`
+	URLThumbnail      string         `json:"url_thumbnail"`
+	Caption           string         `json:"caption,omitempty"`
+	AltText           string         `json:"alt_text,omitempty"`
+	Order             int            `json:"order"`
+	Width             int            `json:"width"`
+	Height            int            `json:"height"`
+	FrameCount        int            `json:"frame_count,omitempty"` // Animated GIF/WebP only
+	FileSizeOriginal  int64          `json:"file_size_original"`
+	FileSizeDisplay   int64          `json:"file_size_display"`
+	FileSizeThumbnail int64          `json:"file_size_thumbnail"`
+	FocalPoint        *FocalPoint    `json:"focal_point,omitempty"`
+	URLSquare         string         `json:"url_square,omitempty"`   // 1:1 crop
+	URLPortrait       string         `json:"url_portrait,omitempty"` // 4:5 crop
+	URLSocial         string         `json:"url_social,omitempty"`   // 16:9 crop
+	FileSizeSquare    int64          `json:"file_size_square,omitempty"`
+	FileSizePortrait  int64          `json:"file_size_portrait,omitempty"`
+	FileSizeSocial    int64          `json:"file_size_social,omitempty"`
+	Edits             *PhotoEdits    `json:"edits,omitempty"`
+	EXIF              *EXIF          `json:"exif,omitempty"`
+	UploadedAt        time.Time      `json:"uploaded_at"`
+	Versions          []PhotoVersion `json:"versions,omitempty"` // Previous files, oldest first
+}
+
+// PhotoVersion is a previous file of a photo, kept when the file is replaced
+// so it can be restored later.
+type PhotoVersion struct {
+	ID               string      `json:"id"`
+	FilenameOriginal string      `json:"filename_original"`
+	URLOriginal      string      `json:"url_original"`
+	Width            int         `json:"width"`
+	Height           int         `json:"height"`
+	FileSizeOriginal int64       `json:"file_size_original"`
+	Edits            *PhotoEdits `json:"edits,omitempty"`
+	FocalPoint       *FocalPoint `json:"focal_point,omitempty"`
+	EXIF             *EXIF       `json:"exif,omitempty"`
+	ReplacedAt       time.Time   `json:"replaced_at"`
+}
+
+// FocalPoint marks the subject of a photo so cropped versions keep it in frame.
+// Coordinates are relative to the image: (0, 0) is top-left, (1, 1) is bottom-right.
+type FocalPoint struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// PhotoEdits is a non-destructive edit stack. The original file is never modified;
+// derivatives are rendered from it with the edits applied in the order
+// rotate, flip, straighten, crop.
+type PhotoEdits struct {
+	Rotate         int       `json:"rotate,omitempty"` // Clockwise degrees: 0, 90, 180 or 270
+	FlipHorizontal bool      `json:"flip_horizontal,omitempty"`
+	FlipVertical   bool      `json:"flip_vertical,omitempty"`
+	Straighten     float64   `json:"straighten,omitempty"` // Fine rotation in degrees, -45 to 45
+	Crop           *CropRect `json:"crop,omitempty"`
+}
+
+// CropRect is a crop rectangle relative to the rotated and straightened image,
+// with all values between 0 and 1.
+type CropRect struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
 }
 
 // EXIF represents photo metadata.
@@ -70,14 +130,82 @@ func (a *Album) Validate() error {
 	if a.Slug == "" {
 		return errors.New("album slug is required")
 	}
-	if a.Visibility != "public" && a.Visibility != "unlisted" && a.Visibility != "password_protected" {
-		return errors.New("album visibility must be public, unlisted, or password_protected")
+	if !isVisibility(a.Visibility) && a.Visibility != "draft" {
+		return errors.New("album visibility must be public, unlisted, password_protected, or draft")
+	}
+	// Scheduled publishing takes an album live, so never to a draft
+	if a.PublishVisibility != "" && !isVisibility(a.PublishVisibility) {
+		return errors.New("publish visibility must be public, unlisted, or password_protected")
+	}
+	if a.PublishAt != nil && a.ExpirationDate != nil && !a.PublishAt.Before(*a.ExpirationDate) {
+		return errors.New("publish date must be before the expiration date")
+	}
+	if a.ExpiryAction != "" && a.ExpiryAction != "hide" && a.ExpiryAction != "archive" {
+		return errors.New("expiry action must be hide or archive")
 	}
 	// Note: We don't validate password_hash here because it may be set via a separate API call
 	// after album creation. The set-password endpoint handles password setting.
+	if a.Proofing != nil && a.Proofing.SelectionLimit < 0 {
+		return errors.New("proofing selection limit must be 0 (unlimited) or more")
+	}
 	return nil
 }
 
+// isVisibility reports whether visibility is one of the live visibilities.
+func isVisibility(visibility string) bool {
+	return visibility == "public" || visibility == "unlisted" || visibility == "password_protected"
+}
+
+// Validate checks that the focal point lies within the image.
+func (f *FocalPoint) Validate() error {
+	if f.X < 0 || f.X > 1 || f.Y < 0 || f.Y > 1 {
+		return errors.New("focal point coordinates must be between 0 and 1")
+	}
+	return nil
+}
+
+// Validate checks that the edit stack contains only supported operations.
+func (e *PhotoEdits) Validate() error {
+	if e.Rotate != 0 && e.Rotate != 90 && e.Rotate != 180 && e.Rotate != 270 {
+		return errors.New("rotate must be 0, 90, 180 or 270")
+	}
+	if e.Straighten < -45 || e.Straighten > 45 {
+		return errors.New("straighten angle must be between -45 and 45 degrees")
+	}
+	if e.Crop != nil {
+		if err := e.Crop.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsEmpty reports whether the edit stack leaves the image unchanged.
+func (e *PhotoEdits) IsEmpty() bool {
+	return e.Rotate == 0 && !e.FlipHorizontal && !e.FlipVertical && e.Straighten == 0 && e.Crop == nil
+}
+
+// Validate checks that the crop rectangle lies within the image.
+func (c *CropRect) Validate() error {
+	if c.Width <= 0 || c.Height <= 0 {
+		return errors.New("crop width and height must be greater than 0")
+	}
+	if c.X < 0 || c.Y < 0 || c.X+c.Width > 1 || c.Y+c.Height > 1 {
+		return errors.New("crop rectangle must lie within the image")
+	}
+	return nil
+}
+
+// Pixels converts the relative crop rectangle to pixel coordinates for an image
+// of the given size, guaranteeing at least a 1x1 area inside the image.
+func (c *CropRect) Pixels(width, height int) (left, top, cropWidth, cropHeight int) {
+	left = min(int(c.X*float64(width)), width-1)
+	top = min(int(c.Y*float64(height)), height-1)
+	cropWidth = max(1, min(int(c.Width*float64(width)), width-left))
+	cropHeight = max(1, min(int(c.Height*float64(height)), height-top))
+	return left, top, cropWidth, cropHeight
+}
+
 // ToJSON converts album to JSON bytes.
 func (a *Album) ToJSON() ([]byte, error) {
 	return json.Marshal(a)
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// AlbumPassword is one of several named guest passwords of a
+// password-protected album, stored in album_passwords.json. Each can expire or
+// run out of uses on its own, so one guest's access can end without changing
+// the password for everyone.
+type AlbumPassword struct {
+	ID           string     `json:"id"`
+	AlbumID      string     `json:"album_id"`
+	Name         string     `json:"name"`
+	PasswordHash string     `json:"password_hash,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	MaxUses      int        `json:"max_uses,omitempty"` // 0 for unlimited
+	CreatedAt    time.Time  `json:"created_at"`
+
+	// Access log
+	Uses        int        `json:"uses"`
+	FirstUsedAt *time.Time `json:"first_used_at,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+}
+
+// AlbumPasswordCollection represents the root album_passwords.json structure.
+type AlbumPasswordCollection struct {
+	AlbumPasswords []AlbumPassword `json:"album_passwords"`
+}
+
+// Validate checks the password's settings.
+func (p *AlbumPassword) Validate() error {
+	if p.Name == "" {
+		return errors.New("album password name is required")
+	}
+	if len(p.Name) > 200 {
+		return errors.New("album password name must be at most 200 characters")
+	}
+	if p.MaxUses < 0 {
+		return errors.New("max uses must be 0 (unlimited) or more")
+	}
+	return nil
+}
+
+// Sanitized returns a copy of the password without its hash, for API
+// responses.
+func (p AlbumPassword) Sanitized() AlbumPassword {
+	p.PasswordHash = ""
+	return p
+}
+
+// IsValid reports whether the password has not expired. Access already
+// granted with a password lasts as long as the password is valid.
+func (p *AlbumPassword) IsValid(now time.Time) bool {
+	return p.ExpiresAt == nil || p.ExpiresAt.After(now)
+}
+
+// CanUse reports whether the password can be entered again: it is valid and
+// has uses left.
+func (p *AlbumPassword) CanUse(now time.Time) bool {
+	return p.IsValid(now) && (p.MaxUses == 0 || p.Uses < p.MaxUses)
+}
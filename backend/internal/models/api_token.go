@@ -0,0 +1,89 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Scope is a permission of an API token. A token can only use its scopes, and
+// only as far as its user's role allows.
+type Scope string
+
+// API token scopes.
+const (
+	ScopeAlbumsRead  Scope = "albums:read"  // Read albums, shares, selections and the schedule
+	ScopeAlbumsWrite Scope = "albums:write" // Change, delete and share albums
+	ScopeUpload      Scope = "upload"       // Upload photos
+	ScopeConfig      Scope = "config"       // Read and change site settings and storage
+)
+
+// IsValid reports whether s is a known scope.
+func (s Scope) IsValid() bool {
+	switch s {
+	case ScopeAlbumsRead, ScopeAlbumsWrite, ScopeUpload, ScopeConfig:
+		return true
+	}
+	return false
+}
+
+// APIToken is a personal access token for scripts, stored in api_tokens.json.
+// Only the hash of the token is kept.
+type APIToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // Start of the token, to tell tokens apart
+	TokenHash  string     `json:"token_hash,omitempty"`
+	Scopes     []Scope    `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` // Never expires if nil
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// APITokenCollection represents the root api_tokens.json structure.
+type APITokenCollection struct {
+	Tokens []APIToken `json:"api_tokens"`
+}
+
+// Validate checks the token's name, scopes and expiry.
+func (t *APIToken) Validate() error {
+	if t.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(t.Name) > 100 {
+		return errors.New("name must be at most 100 characters")
+	}
+	if len(t.Scopes) == 0 {
+		return errors.New("at least one scope is required")
+	}
+	for _, scope := range t.Scopes {
+		if !scope.IsValid() {
+			return errors.New("scopes must be among: albums:read, albums:write, upload, config")
+		}
+	}
+	if t.ExpiresAt != nil && !t.ExpiresAt.After(time.Now()) {
+		return errors.New("expiry must be in the future")
+	}
+	return nil
+}
+
+// HasScope reports whether the token has the scope.
+func (t *APIToken) HasScope(scope Scope) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsExpired reports whether the token has expired at now.
+func (t *APIToken) IsExpired(now time.Time) bool {
+	return t.ExpiresAt != nil && !now.Before(*t.ExpiresAt)
+}
+
+// Sanitized returns a copy of the token without its hash, for API responses.
+func (t APIToken) Sanitized() APIToken {
+	t.TokenHash = ""
+	return t
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// AuditEntry records one state-changing admin request, appended to
+// audit.jsonl. Entries are never changed, only removed by the retention
+// settings.
+type AuditEntry struct {
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+
+	// The user who made the request, and the API token they used, if any
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+	Role     Role   `json:"role"`
+	TokenID  string `json:"token_id,omitempty"`
+
+	Action  string `json:"action"` // Method and route, e.g. "DELETE /api/admin/albums/{id}"
+	Path    string `json:"path"`
+	AlbumID string `json:"album_id,omitempty"`
+	PhotoID string `json:"photo_id,omitempty"`
+	Status  int    `json:"status"`
+
+	// The fields the request changed, before and after, for the requests
+	// that describe their change. Hashes and secrets are redacted.
+	Before map[string]interface{} `json:"before,omitempty"`
+	After  map[string]interface{} `json:"after,omitempty"`
+}
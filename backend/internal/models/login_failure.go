@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Kinds of login failure records.
+const (
+	LoginFailureUsername = "username"
+	LoginFailureIP       = "ip"
+)
+
+// LoginFailure records the recent failed admin logins for one username or one
+// client IP, stored in login_failures.json. It is removed on a successful
+// login, when an owner clears it, or once it has been quiet for a while.
+type LoginFailure struct {
+	Kind          string     `json:"kind"` // "username" or "ip"
+	Key           string     `json:"key"`  // Lowercased username, or IP address
+	Failures      int        `json:"failures"`
+	FirstFailedAt time.Time  `json:"first_failed_at"`
+	LastFailedAt  time.Time  `json:"last_failed_at"`
+	LastIP        string     `json:"last_ip,omitempty"` // For usernames
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+}
+
+// LoginFailureCollection represents the root login_failures.json structure.
+type LoginFailureCollection struct {
+	LoginFailures []LoginFailure `json:"login_failures"`
+}
+
+// IsLocked reports whether the record locks its username at now.
+func (f *LoginFailure) IsLocked(now time.Time) bool {
+	return f.LockedUntil != nil && f.LockedUntil.After(now)
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Proofing lets clients with access to an album pick their favourite photos,
+// for editing or prints, and submit the selection.
+type Proofing struct {
+	Enabled        bool `json:"enabled"`
+	SelectionLimit int  `json:"selection_limit,omitempty"` // 0 for unlimited
+}
+
+// Selection is one client's pick of photos from a proofing album, stored in
+// selections.json. Selections made through a share link belong to that link;
+// others are only reachable by their ID.
+type Selection struct {
+	ID          string            `json:"id"`
+	AlbumID     string            `json:"album_id"`
+	ShareLinkID string            `json:"share_link_id,omitempty"`
+	ClientName  string            `json:"client_name,omitempty"`
+	Favorites   []string          `json:"favorites"`             // Photo IDs, in the order picked
+	PhotoNotes  map[string]string `json:"photo_notes,omitempty"` // Photo ID to note
+	Note        string            `json:"note,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	SubmittedAt *time.Time        `json:"submitted_at,omitempty"`
+	LockedAt    *time.Time        `json:"locked_at,omitempty"`
+}
+
+// SelectionCollection represents the root selections.json structure.
+type SelectionCollection struct {
+	Selections []Selection `json:"selections"`
+}
+
+// IsLocked reports whether the admin has locked the selection against changes.
+func (s *Selection) IsLocked() bool {
+	return s.LockedAt != nil
+}
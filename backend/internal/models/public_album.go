@@ -0,0 +1,180 @@
+package models
+
+import "time"
+
+// PublicAlbumIndex represents the root of the published public/albums.json.
+type PublicAlbumIndex struct {
+	LastUpdated   time.Time     `json:"last_updated"`
+	MainAlbumSlug string        `json:"main_album_slug,omitempty"`
+	Albums        []PublicAlbum `json:"albums"`
+}
+
+// PublicAlbum is the subset of an album that may be published to visitors.
+// It never carries the password hash, and photos are only included for albums
+// whose contents are visible without a password.
+type PublicAlbum struct {
+	ID             string        `json:"id"`
+	Slug           string        `json:"slug"`
+	Title          string        `json:"title"`
+	Subtitle       string        `json:"subtitle,omitempty"`
+	Description    string        `json:"description,omitempty"`
+	Visibility     string        `json:"visibility"`
+	ExpirationDate *time.Time    `json:"expiration_date,omitempty"`
+	AllowDownloads bool          `json:"allow_downloads"`
+	Order          int           `json:"order"`
+	ThemeOverride  string        `json:"theme_override,omitempty"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+	AlbumStartDate *time.Time    `json:"date_of_album_start,omitempty"`
+	AlbumEndDate   *time.Time    `json:"date_of_album_end,omitempty"`
+	Proofing       *Proofing     `json:"proofing,omitempty"`
+	PhotoCount     int           `json:"photo_count"`
+	CoverPhotoID   string        `json:"cover_photo_id,omitempty"`
+	CoverPhoto     *PublicPhoto  `json:"cover_photo,omitempty"`
+	Photos         []PublicPhoto `json:"photos"`
+}
+
+// PublicPhoto is the subset of a photo that may be published to visitors.
+// Upload filenames, edit stacks and version history stay in the backend, and
+// the original file is only linked when the album allows downloads.
+type PublicPhoto struct {
+	ID                string      `json:"id"`
+	URLOriginal       string      `json:"url_original,omitempty"`
+	URLDisplay        string      `json:"url_display"`
+	URLThumbnail      string      `json:"url_thumbnail"`
+	Caption           string      `json:"caption,omitempty"`
+	AltText           string      `json:"alt_text,omitempty"`
+	Order             int         `json:"order"`
+	Width             int         `json:"width"`
+	Height            int         `json:"height"`
+	FrameCount        int         `json:"frame_count,omitempty"`
+	FileSizeOriginal  int64       `json:"file_size_original,omitempty"`
+	FileSizeDisplay   int64       `json:"file_size_display"`
+	FileSizeThumbnail int64       `json:"file_size_thumbnail"`
+	FocalPoint        *FocalPoint `json:"focal_point,omitempty"`
+	URLSquare         string      `json:"url_square,omitempty"`
+	URLPortrait       string      `json:"url_portrait,omitempty"`
+	URLSocial         string      `json:"url_social,omitempty"`
+	FileSizeSquare    int64       `json:"file_size_square,omitempty"`
+	FileSizePortrait  int64       `json:"file_size_portrait,omitempty"`
+	FileSizeSocial    int64       `json:"file_size_social,omitempty"`
+	EXIF              *EXIF       `json:"exif,omitempty"`
+	UploadedAt        time.Time   `json:"uploaded_at"`
+}
+
+// IsExpired reports whether the album's expiration date has passed.
+func (a *Album) IsExpired(now time.Time) bool {
+	return a.ExpirationDate != nil && !a.ExpirationDate.After(now)
+}
+
+// IsWithdrawn reports whether the album is gone for visitors: expired, or
+// archived by the scheduler. This is checked live, so expired albums disappear
+// at their expiration date and not only when the scheduler next runs.
+func (a *Album) IsWithdrawn(now time.Time) bool {
+	return a.ArchivedAt != nil || a.IsExpired(now)
+}
+
+// IsDraft reports whether the album is a draft. Drafts are never published;
+// only preview links open them.
+func (a *Album) IsDraft() bool {
+	return a.Visibility == "draft"
+}
+
+// IsProtected reports whether the album's contents require a password.
+func (a *Album) IsProtected() bool {
+	return a.Visibility == "password_protected"
+}
+
+// ToPublic returns the album as it may be shown to visitors. Password-protected
+// albums are reduced to their title card: no photos and no cover.
+func (a *Album) ToPublic(withPhotos bool) PublicAlbum {
+	if a.IsProtected() {
+		return a.publicCard()
+	}
+	return a.ToUnlocked(withPhotos)
+}
+
+// ToUnlocked returns the album as shown to a visitor who has been granted
+// access, including the photos of a password-protected album.
+func (a *Album) ToUnlocked(withPhotos bool) PublicAlbum {
+	public := a.publicCard()
+
+	if len(a.Photos) > 0 {
+		public.CoverPhotoID = a.CoverPhotoID
+
+		// Fall back to the first photo, as the album cards do
+		cover := &a.Photos[0]
+		for i := range a.Photos {
+			if a.Photos[i].ID == a.CoverPhotoID {
+				cover = &a.Photos[i]
+				break
+			}
+		}
+		publicCover := cover.ToPublic(a.AllowDownloads)
+		public.CoverPhoto = &publicCover
+	}
+
+	if withPhotos {
+		for i := range a.Photos {
+			public.Photos = append(public.Photos, a.Photos[i].ToPublic(a.AllowDownloads))
+		}
+	}
+
+	return public
+}
+
+// publicCard returns the album's title card: its details without any photos.
+func (a *Album) publicCard() PublicAlbum {
+	return PublicAlbum{
+		ID:             a.ID,
+		Slug:           a.Slug,
+		Title:          a.Title,
+		Subtitle:       a.Subtitle,
+		Description:    a.Description,
+		Visibility:     a.Visibility,
+		ExpirationDate: a.ExpirationDate,
+		AllowDownloads: a.AllowDownloads,
+		Order:          a.Order,
+		ThemeOverride:  a.ThemeOverride,
+		CreatedAt:      a.CreatedAt,
+		UpdatedAt:      a.UpdatedAt,
+		AlbumStartDate: a.AlbumStartDate,
+		AlbumEndDate:   a.AlbumEndDate,
+		Proofing:       a.Proofing,
+		PhotoCount:     len(a.Photos),
+		Photos:         []PublicPhoto{},
+	}
+}
+
+// ToPublic returns the photo as it may be shown to visitors.
+func (p *Photo) ToPublic(allowDownloads bool) PublicPhoto {
+	public := PublicPhoto{
+		ID:                p.ID,
+		URLDisplay:        p.URLDisplay,
+		URLThumbnail:      p.URLThumbnail,
+		Caption:           p.Caption,
+		AltText:           p.AltText,
+		Order:             p.Order,
+		Width:             p.Width,
+		Height:            p.Height,
+		FrameCount:        p.FrameCount,
+		FileSizeDisplay:   p.FileSizeDisplay,
+		FileSizeThumbnail: p.FileSizeThumbnail,
+		FocalPoint:        p.FocalPoint,
+		URLSquare:         p.URLSquare,
+		URLPortrait:       p.URLPortrait,
+		URLSocial:         p.URLSocial,
+		FileSizeSquare:    p.FileSizeSquare,
+		FileSizePortrait:  p.FileSizePortrait,
+		FileSizeSocial:    p.FileSizeSocial,
+		EXIF:              p.EXIF,
+		UploadedAt:        p.UploadedAt,
+	}
+
+	if allowDownloads {
+		public.URLOriginal = p.URLOriginal
+		public.FileSizeOriginal = p.FileSizeOriginal
+	}
+
+	return public
+}
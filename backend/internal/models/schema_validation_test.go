@@ -42,6 +42,27 @@ func TestAlbumValidation(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "valid album with draft visibility",
+			album: Album{
+				Title:      "Test Album",
+				Slug:       "test-album",
+				Visibility: "draft",
+			},
+			wantErr: false,
+		},
+		{
+			name: "draft publish visibility",
+			album: Album{
+				Title:             "Test Album",
+				Slug:              "test-album",
+				Visibility:        "draft",
+				PublishAt:         timePtr(time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)),
+				PublishVisibility: "draft",
+			},
+			wantErr: true,
+			errMsg:  "publish visibility must be public, unlisted, or password_protected",
+		},
 		{
 			name: "missing title",
 			album: Album{
@@ -68,7 +89,7 @@ func TestAlbumValidation(t *testing.T) {
 				Visibility: "invalid",
 			},
 			wantErr: true,
-			errMsg:  "album visibility must be public, unlisted, or password_protected",
+			errMsg:  "album visibility must be public, unlisted, password_protected, or draft",
 		},
 		{
 			name: "empty visibility",
@@ -78,7 +99,41 @@ func TestAlbumValidation(t *testing.T) {
 				Visibility: "",
 			},
 			wantErr: true,
-			errMsg:  "album visibility must be public, unlisted, or password_protected",
+			errMsg:  "album visibility must be public, unlisted, password_protected, or draft",
+		},
+		{
+			name: "negative proofing selection limit",
+			album: Album{
+				Title:      "Test Album",
+				Slug:       "test-album",
+				Visibility: "public",
+				Proofing:   &Proofing{Enabled: true, SelectionLimit: -1},
+			},
+			wantErr: true,
+			errMsg:  "proofing selection limit must be 0 (unlimited) or more",
+		},
+		{
+			name: "publish date after expiration date",
+			album: Album{
+				Title:          "Test Album",
+				Slug:           "test-album",
+				Visibility:     "unlisted",
+				PublishAt:      timePtr(time.Date(2026, 6, 2, 0, 0, 0, 0, time.UTC)),
+				ExpirationDate: timePtr(time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)),
+			},
+			wantErr: true,
+			errMsg:  "publish date must be before the expiration date",
+		},
+		{
+			name: "invalid expiry action",
+			album: Album{
+				Title:        "Test Album",
+				Slug:         "test-album",
+				Visibility:   "public",
+				ExpiryAction: "delete",
+			},
+			wantErr: true,
+			errMsg:  "expiry action must be hide or archive",
 		},
 		// Note: We no longer validate password_hash during album validation
 		// because it can be set via a separate API call after creation
@@ -215,7 +270,7 @@ func TestAlbumWithEmptyVisibility(t *testing.T) {
 	if err == nil {
 		t.Error("Expected validation error for empty visibility, got nil")
 	}
-	if err != nil && err.Error() != "album visibility must be public, unlisted, or password_protected" {
+	if err != nil && err.Error() != "album visibility must be public, unlisted, password_protected, or draft" {
 		t.Errorf("Wrong error message: %q", err.Error())
 	}
 }
@@ -299,3 +354,75 @@ func TestPhotoJSONFields(t *testing.T) {
 		}
 	}
 }
+
+// TestFocalPointValidation tests focal point bounds checking.
+func TestFocalPointValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		focal   FocalPoint
+		wantErr bool
+	}{
+		{name: "center", focal: FocalPoint{X: 0.5, Y: 0.5}, wantErr: false},
+		{name: "top-left corner", focal: FocalPoint{X: 0, Y: 0}, wantErr: false},
+		{name: "bottom-right corner", focal: FocalPoint{X: 1, Y: 1}, wantErr: false},
+		{name: "negative x", focal: FocalPoint{X: -0.1, Y: 0.5}, wantErr: true},
+		{name: "y beyond image", focal: FocalPoint{X: 0.5, Y: 1.2}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.focal.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("FocalPoint.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestPhotoEditsValidation tests edit stack validation.
+func TestPhotoEditsValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		edits   PhotoEdits
+		wantErr bool
+	}{
+		{name: "empty", edits: PhotoEdits{}, wantErr: false},
+		{name: "quarter turn", edits: PhotoEdits{Rotate: 90}, wantErr: false},
+		{name: "arbitrary rotation", edits: PhotoEdits{Rotate: 45}, wantErr: true},
+		{name: "small straighten", edits: PhotoEdits{Straighten: -2.5}, wantErr: false},
+		{name: "straighten too far", edits: PhotoEdits{Straighten: 60}, wantErr: true},
+		{name: "valid crop", edits: PhotoEdits{Crop: &CropRect{X: 0.1, Y: 0.1, Width: 0.8, Height: 0.5}}, wantErr: false},
+		{name: "crop outside image", edits: PhotoEdits{Crop: &CropRect{X: 0.5, Y: 0, Width: 0.6, Height: 1}}, wantErr: true},
+		{name: "empty crop", edits: PhotoEdits{Crop: &CropRect{X: 0.5, Y: 0.5}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.edits.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("PhotoEdits.Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestCropRectPixels tests conversion of relative crops to pixel coordinates.
+func TestCropRectPixels(t *testing.T) {
+	crop := CropRect{X: 0.25, Y: 0.1, Width: 0.5, Height: 0.8}
+
+	left, top, width, height := crop.Pixels(2000, 1000)
+	if left != 500 || top != 100 || width != 1000 || height != 800 {
+		t.Errorf("Pixels() = (%d, %d, %d, %d), want (500, 100, 1000, 800)", left, top, width, height)
+	}
+
+	// Tiny crops never collapse below one pixel
+	tiny := CropRect{X: 0.999, Y: 0.999, Width: 0.0001, Height: 0.0001}
+	_, _, width, height = tiny.Pixels(100, 100)
+	if width != 1 || height != 1 {
+		t.Errorf("Pixels() size = (%d, %d), want (1, 1)", width, height)
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// ShareLink is a tokenised link giving one client access to one album, stored
+// in share_links.json. Only a hash of the token is stored; the token itself is
+// shown once, when the link is created. Preview links also open draft albums,
+// for reviewers to see them before they go live.
+type ShareLink struct {
+	ID             string     `json:"id"`
+	AlbumID        string     `json:"album_id"`
+	Label          string     `json:"label,omitempty"`
+	TokenHash      string     `json:"token_hash,omitempty"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	AllowDownloads bool       `json:"allow_downloads"`
+	MaxViews       int        `json:"max_views,omitempty"` // 0 for unlimited
+	CreatedAt      time.Time  `json:"created_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty"`
+	Preview        bool       `json:"preview,omitempty"`
+
+	// Access log
+	Views       int        `json:"views"`
+	Downloads   int        `json:"downloads"`
+	FirstUsedAt *time.Time `json:"first_used_at,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+}
+
+// ShareLinkCollection represents the root share_links.json structure.
+type ShareLinkCollection struct {
+	ShareLinks []ShareLink `json:"share_links"`
+}
+
+// Sanitized returns a copy of the link without its token hash, for API
+// responses.
+func (l ShareLink) Sanitized() ShareLink {
+	l.TokenHash = ""
+	return l
+}
+
+// Validate checks the link's settings.
+func (l *ShareLink) Validate() error {
+	if l.ExpiresAt.IsZero() {
+		return errors.New("share link expiry is required")
+	}
+	if l.MaxViews < 0 {
+		return errors.New("max views must be 0 (unlimited) or more")
+	}
+	if len(l.Label) > 200 {
+		return errors.New("share link label must be at most 200 characters")
+	}
+	return nil
+}
+
+// IsValid reports whether the link is neither revoked nor expired. Access
+// already granted through a link lasts as long as the link is valid.
+func (l *ShareLink) IsValid(now time.Time) bool {
+	return l.RevokedAt == nil && l.ExpiresAt.After(now)
+}
+
+// CanRedeem reports whether the link can be opened again: it is valid and has
+// views left.
+func (l *ShareLink) CanRedeem(now time.Time) bool {
+	return l.IsValid(now) && (l.MaxViews == 0 || l.Views < l.MaxViews)
+}
@@ -116,8 +116,10 @@ type FeaturesConfig struct {
 
 // StorageConfig contains storage and disk usage settings.
 type StorageConfig struct {
-	MaxDiskUsagePercent int `json:"max_disk_usage_percent"` // Maximum disk usage percentage (default 80)
-	MaxImageSizeMB      int `json:"max_image_size_mb"`      // Maximum individual image size in MB (default 50)
+	MaxDiskUsagePercent int `json:"max_disk_usage_percent"`         // Maximum disk usage percentage (default 80)
+	MaxImageSizeMB      int `json:"max_image_size_mb"`              // Maximum individual image size in MB (default 50)
+	MaxImageMegapixels  int `json:"max_image_megapixels,omitempty"` // Maximum pixel count in megapixels, all frames (default 150)
+	MaxImageDimension   int `json:"max_image_dimension,omitempty"`  // Maximum width or height in pixels (default 30000)
 }
 
 // Validate checks if the site config has required fields.
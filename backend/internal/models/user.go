@@ -0,0 +1,97 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"time"
+)
+
+// Role is an admin user's role. Each role can do everything the roles below it
+// can: viewers browse albums and settings, uploaders also add photos, editors
+// also change albums and site settings, and owners also manage users.
+type Role string
+
+// Admin user roles, from most to least privileged.
+const (
+	RoleOwner    Role = "owner"
+	RoleEditor   Role = "editor"
+	RoleUploader Role = "uploader"
+	RoleViewer   Role = "viewer"
+)
+
+// roleRanks orders the roles; a higher rank includes the lower ones.
+var roleRanks = map[Role]int{
+	RoleViewer:   1,
+	RoleUploader: 2,
+	RoleEditor:   3,
+	RoleOwner:    4,
+}
+
+// IsValid reports whether r is a known role.
+func (r Role) IsValid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+// Includes reports whether r can do everything other can.
+func (r Role) Includes(other Role) bool {
+	return r.IsValid() && roleRanks[r] >= roleRanks[other]
+}
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._@-]+$`)
+
+// User is an admin account, stored in users.json.
+type User struct {
+	ID           string     `json:"id"`
+	Username     string     `json:"username"`
+	PasswordHash string     `json:"password_hash,omitempty"`
+	Role         Role       `json:"role"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	LastLoginAt  *time.Time `json:"last_login_at,omitempty"`
+
+	// Two-factor authentication. TOTPSecret is set when enrollment starts and
+	// TOTPEnabled once a code from it has been verified. TOTPLastStep is the
+	// time step of the last accepted code, so that a code cannot be replayed.
+	TOTPSecret         string   `json:"totp_secret,omitempty"`
+	TOTPEnabled        bool     `json:"totp_enabled"`
+	TOTPLastStep       int64    `json:"totp_last_step,omitempty"`
+	RecoveryCodeHashes []string `json:"recovery_code_hashes,omitempty"` // Unused single-use recovery codes
+
+	// Single sign-on. Users linked to an OpenID Connect identity are found by
+	// its issuer and subject, and get their role from the identity provider.
+	OIDCIssuer  string `json:"oidc_issuer,omitempty"`
+	OIDCSubject string `json:"oidc_subject,omitempty"`
+}
+
+// UserCollection represents the root users.json structure.
+type UserCollection struct {
+	Users []User `json:"users"`
+}
+
+// Validate checks the user's username and role.
+func (u *User) Validate() error {
+	if u.Username == "" {
+		return errors.New("username is required")
+	}
+	if len(u.Username) > 100 {
+		return errors.New("username must be at most 100 characters")
+	}
+	if !usernamePattern.MatchString(u.Username) {
+		return errors.New("username may only contain letters, digits, '.', '_', '@' and '-'")
+	}
+	if !u.Role.IsValid() {
+		return errors.New("role must be one of: owner, editor, uploader, viewer")
+	}
+	return nil
+}
+
+// Sanitized returns a copy of the user without the password hash and
+// two-factor secrets, for API responses.
+func (u User) Sanitized() User {
+	u.PasswordHash = ""
+	u.TOTPSecret = ""
+	u.TOTPLastStep = 0
+	u.RecoveryCodeHashes = nil
+	return u
+}
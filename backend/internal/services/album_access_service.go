@@ -0,0 +1,284 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+)
+
+const (
+	// DefaultAlbumAccessTTL is how long an album access token stays valid.
+	DefaultAlbumAccessTTL = 2 * time.Hour
+
+	albumAccessWindow       = 15 * time.Minute
+	albumAccessIPLimit      = 10 // Password attempts per client IP per window
+	albumAccessAlbumLimit   = 30 // Password attempts per album per window, across all clients
+	minAlbumAccessSecretLen = 32
+)
+
+var (
+	// ErrInvalidAlbumPassword is returned when an album password does not match.
+	ErrInvalidAlbumPassword = errors.New("invalid album password")
+
+	// ErrInvalidAccessToken is returned for missing, forged, expired or revoked album access tokens.
+	ErrInvalidAccessToken = errors.New("invalid album access token")
+)
+
+// AlbumGrant describes the access an album access token gives.
+type AlbumGrant struct {
+	// ShareLinkID is set when access was granted through a share link.
+	ShareLinkID string
+	// PasswordID is set when access was granted with a named album password.
+	PasswordID string
+	// Preview is set when access was granted through a preview link.
+	Preview bool
+	// AllowDownloads lets the holder download originals even when the album
+	// does not allow downloads.
+	AllowDownloads bool
+}
+
+// AlbumAccessService verifies album passwords and share links and issues
+// signed, short-lived access tokens scoped to one album. Tokens carry the album
+// ID, expiry and either a fingerprint of the album's password hash, the named
+// password ID or the share link ID, signed with HMAC-SHA256, so changing the
+// password or deleting the named password or revoking the link revokes them.
+type AlbumAccessService struct {
+	secret       []byte
+	ttl          time.Duration
+	ipLimiter    *RateLimiter
+	albumLimiter *RateLimiter
+	shareLinks   *ShareLinkService
+	passwords    *AlbumPasswordService
+	albums       *AlbumService
+}
+
+// NewAlbumAccessService creates a new album access service. Without a secret a
+// random one is generated, and tokens do not survive a restart.
+func NewAlbumAccessService(secret []byte, ttl time.Duration) (*AlbumAccessService, error) {
+	if len(secret) == 0 {
+		secret = make([]byte, minAlbumAccessSecretLen)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate album access secret: %w", err)
+		}
+	}
+	if len(secret) < minAlbumAccessSecretLen {
+		return nil, fmt.Errorf("album access secret must be at least %d bytes", minAlbumAccessSecretLen)
+	}
+	if ttl <= 0 {
+		ttl = DefaultAlbumAccessTTL
+	}
+
+	return &AlbumAccessService{
+		secret:       secret,
+		ttl:          ttl,
+		ipLimiter:    NewRateLimiter(albumAccessIPLimit, albumAccessWindow),
+		albumLimiter: NewRateLimiter(albumAccessAlbumLimit, albumAccessWindow),
+	}, nil
+}
+
+// GrantAccess checks a password for a password-protected album and returns an
+// access token with its expiry. The album's main password is tried first, then
+// its named passwords; for a named password it is returned as well, and the
+// token expires with it at the latest. Attempts are rate limited per client IP
+// and per album; over the limit a TooManyAttemptsError is returned without
+// checking the password. Successful attempts count too, so that one album's
+// password or a share link buys no extra guesses at other albums.
+func (s *AlbumAccessService) GrantAccess(album *models.Album, password, clientIP string) (string, time.Time, *models.AlbumPassword, error) {
+	if err := s.ipLimiter.Allow(clientIP); err != nil {
+		return "", time.Time{}, nil, err
+	}
+	if err := s.albumLimiter.Allow(album.ID); err != nil {
+		return "", time.Time{}, nil, err
+	}
+
+	expiresAt := time.Now().Add(s.ttl).UTC()
+	if album.PasswordHash != "" {
+		if match, rehash := CheckPassword(album.PasswordHash, password); match {
+			passwordHash := album.PasswordHash
+			if rehash && s.albums != nil {
+				passwordHash = s.upgradePasswordHash(album, password)
+			}
+			return s.issueToken(album.ID, expiresAt, s.passwordFingerprint(passwordHash)), expiresAt, nil, nil
+		}
+	}
+
+	if s.passwords == nil {
+		return "", time.Time{}, nil, ErrInvalidAlbumPassword
+	}
+	albumPassword, err := s.passwords.Match(album.ID, password)
+	if err != nil {
+		return "", time.Time{}, nil, err
+	}
+
+	if albumPassword.ExpiresAt != nil && albumPassword.ExpiresAt.Before(expiresAt) {
+		expiresAt = albumPassword.ExpiresAt.UTC()
+	}
+	return s.issueToken(album.ID, expiresAt, "p:"+albumPassword.ID), expiresAt, albumPassword, nil
+}
+
+// upgradePasswordHash replaces the album's bcrypt or outdated password hash
+// and returns the hash in effect. Tokens issued for the old hash stop working,
+// so visitors enter the password once more. Failures keep the old hash.
+func (s *AlbumAccessService) upgradePasswordHash(album *models.Album, password string) string {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return album.PasswordHash
+	}
+	if err := s.albums.ReplacePasswordHash(album.ID, album.PasswordHash, hash); err != nil {
+		return album.PasswordHash
+	}
+	album.PasswordHash = hash
+	return hash
+}
+
+// SetAlbumService lets GrantAccess upgrade outdated album password hashes.
+func (s *AlbumAccessService) SetAlbumService(albums *AlbumService) {
+	s.albums = albums
+}
+
+// SetPasswordService enables named album passwords.
+func (s *AlbumAccessService) SetPasswordService(passwords *AlbumPasswordService) {
+	s.passwords = passwords
+}
+
+// SetShareLinkService enables access through share links.
+func (s *AlbumAccessService) SetShareLinkService(shareLinks *ShareLinkService) {
+	s.shareLinks = shareLinks
+}
+
+// GrantShareAccess redeems a share link of the album, of any visibility, and
+// returns an access token with its expiry and the link. Drafts only open with
+// preview links. The token expires with the link at the latest. Attempts are
+// rate limited per client IP.
+func (s *AlbumAccessService) GrantShareAccess(album *models.Album, shareToken, clientIP string) (string, time.Time, *models.ShareLink, error) {
+	if err := s.ipLimiter.Allow(clientIP); err != nil {
+		return "", time.Time{}, nil, err
+	}
+	if s.shareLinks == nil {
+		return "", time.Time{}, nil, ErrInvalidShareLink
+	}
+
+	link, err := s.shareLinks.Redeem(album.ID, shareToken, album.IsDraft())
+	if err != nil {
+		return "", time.Time{}, nil, err
+	}
+
+	expiresAt := time.Now().Add(s.ttl).UTC()
+	if link.ExpiresAt.Before(expiresAt) {
+		expiresAt = link.ExpiresAt.UTC()
+	}
+	return s.issueToken(album.ID, expiresAt, "s:"+link.ID), expiresAt, link, nil
+}
+
+// ValidateToken checks that a token was issued for the album and has not
+// expired, and that it was issued for the album's current password, a named
+// password or a share link that is still valid. It returns what the token grants.
+func (s *AlbumAccessService) ValidateToken(album *models.Album, token string) (*AlbumGrant, error) {
+	encodedPayload, encodedSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidAccessToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return nil, ErrInvalidAccessToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(encodedSig)
+	if err != nil || !hmac.Equal(sig, s.sign(payload)) {
+		return nil, ErrInvalidAccessToken
+	}
+
+	// payload: album ID, expiry (unix seconds), password fingerprint, "p:" and
+	// named password ID or "s:" and share link ID
+	fields := strings.Split(string(payload), "|")
+	if len(fields) != 3 || fields[0] != album.ID {
+		return nil, ErrInvalidAccessToken
+	}
+	expires, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || time.Now().Unix() >= expires {
+		return nil, ErrInvalidAccessToken
+	}
+
+	if shareLinkID, ok := strings.CutPrefix(fields[2], "s:"); ok {
+		return s.validateShareGrant(album, shareLinkID)
+	}
+	if passwordID, ok := strings.CutPrefix(fields[2], "p:"); ok {
+		return s.validatePasswordGrant(album, passwordID)
+	}
+
+	if album.IsDraft() || album.PasswordHash == "" || !hmac.Equal([]byte(fields[2]), []byte(s.passwordFingerprint(album.PasswordHash))) {
+		return nil, ErrInvalidAccessToken
+	}
+	return &AlbumGrant{}, nil
+}
+
+// RecordShareDownload counts a download of an original or album archive in the
+// access log of the share link behind a grant. Grants from passwords are not
+// logged.
+func (s *AlbumAccessService) RecordShareDownload(grant *AlbumGrant) error {
+	if grant == nil || grant.ShareLinkID == "" || s.shareLinks == nil {
+		return nil
+	}
+	return s.shareLinks.RecordDownload(grant.ShareLinkID)
+}
+
+// validateShareGrant checks that the share link behind a token is still valid.
+func (s *AlbumAccessService) validateShareGrant(album *models.Album, shareLinkID string) (*AlbumGrant, error) {
+	if s.shareLinks == nil {
+		return nil, ErrInvalidAccessToken
+	}
+	link, err := s.shareLinks.Get(shareLinkID)
+	if err != nil || link.AlbumID != album.ID || !link.IsValid(time.Now()) || (album.IsDraft() && !link.Preview) {
+		return nil, ErrInvalidAccessToken
+	}
+	return &AlbumGrant{ShareLinkID: link.ID, Preview: link.Preview, AllowDownloads: link.AllowDownloads}, nil
+}
+
+// validatePasswordGrant checks that the named password behind a token still
+// exists and has not expired. Running out of uses only stops new grants.
+func (s *AlbumAccessService) validatePasswordGrant(album *models.Album, passwordID string) (*AlbumGrant, error) {
+	if s.passwords == nil || album.IsDraft() {
+		return nil, ErrInvalidAccessToken
+	}
+	albumPassword, err := s.passwords.Get(passwordID)
+	if err != nil || albumPassword.AlbumID != album.ID || !albumPassword.IsValid(time.Now()) {
+		return nil, ErrInvalidAccessToken
+	}
+	return &AlbumGrant{PasswordID: albumPassword.ID}, nil
+}
+
+// CleanupRateLimits forgets rate limit windows that have ended.
+func (s *AlbumAccessService) CleanupRateLimits() {
+	s.ipLimiter.Cleanup()
+	s.albumLimiter.Cleanup()
+}
+
+// TTL returns how long issued tokens stay valid.
+func (s *AlbumAccessService) TTL() time.Duration {
+	return s.ttl
+}
+
+func (s *AlbumAccessService) issueToken(albumID string, expiresAt time.Time, credential string) string {
+	payload := []byte(fmt.Sprintf("%s|%d|%s", albumID, expiresAt.Unix(), credential))
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(s.sign(payload))
+}
+
+func (s *AlbumAccessService) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// passwordFingerprint identifies a password hash without revealing anything
+// about it; the token payload is readable by the visitor.
+func (s *AlbumAccessService) passwordFingerprint(passwordHash string) string {
+	return base64.RawURLEncoding.EncodeToString(s.sign([]byte("password:" + passwordHash))[:12])
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func protectedAlbum(t *testing.T, password string) *models.Album {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	return &models.Album{ID: "album-1", Slug: "wedding", Visibility: "password_protected", PasswordHash: string(hash)}
+}
+
+func assertInvalidToken(t *testing.T, service *AlbumAccessService, album *models.Album, token string) {
+	t.Helper()
+	grant, err := service.ValidateToken(album, token)
+	assert.ErrorIs(t, err, ErrInvalidAccessToken)
+	assert.Nil(t, grant)
+}
+
+func TestAlbumAccessService_GrantAccess(t *testing.T) {
+	service, err := NewAlbumAccessService(nil, time.Hour)
+	require.NoError(t, err)
+	album := protectedAlbum(t, "secret")
+
+	_, _, _, err = service.GrantAccess(album, "wrong", "192.0.2.1")
+	assert.ErrorIs(t, err, ErrInvalidAlbumPassword)
+
+	token, expiresAt, _, err := service.GrantAccess(album, "secret", "192.0.2.1")
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expiresAt, time.Minute)
+	grant, err := service.ValidateToken(album, token)
+	require.NoError(t, err)
+	assert.Empty(t, grant.ShareLinkID)
+
+	// The token is scoped to the album
+	other := *album
+	other.ID = "album-2"
+	assertInvalidToken(t, service, &other, token)
+
+	// Changing the password revokes the token
+	changed := protectedAlbum(t, "new secret")
+	assertInvalidToken(t, service, changed, token)
+
+	// Tokens from another secret are rejected
+	otherService, err := NewAlbumAccessService(bytes.Repeat([]byte("k"), 32), time.Hour)
+	require.NoError(t, err)
+	assertInvalidToken(t, otherService, album, token)
+
+	// Tampered tokens are rejected
+	payload, sig, _ := strings.Cut(token, ".")
+	assertInvalidToken(t, service, album, payload+"x."+sig)
+	assertInvalidToken(t, service, album, "")
+}
+
+func TestAlbumAccessService_ExpiredToken(t *testing.T) {
+	service, err := NewAlbumAccessService(nil, time.Hour)
+	require.NoError(t, err)
+	album := protectedAlbum(t, "secret")
+
+	token := service.issueToken(album.ID, time.Now().Add(-time.Second), service.passwordFingerprint(album.PasswordHash))
+	assertInvalidToken(t, service, album, token)
+}
+
+func TestAlbumAccessService_RateLimits(t *testing.T) {
+	service, err := NewAlbumAccessService(nil, time.Hour)
+	require.NoError(t, err)
+	album := protectedAlbum(t, "secret")
+
+	// Per client IP
+	for i := 0; i < albumAccessIPLimit; i++ {
+		_, _, _, err = service.GrantAccess(album, "wrong", "192.0.2.1")
+		require.ErrorIs(t, err, ErrInvalidAlbumPassword)
+	}
+	_, _, _, err = service.GrantAccess(album, "secret", "192.0.2.1")
+	var tooMany *TooManyAttemptsError
+	require.True(t, errors.As(err, &tooMany), "the correct password is not checked once limited")
+	assert.Greater(t, tooMany.RetryAfter, time.Duration(0))
+
+	// Per album, across clients
+	for i := albumAccessIPLimit; i < albumAccessAlbumLimit; i++ {
+		_, _, _, err = service.GrantAccess(album, "wrong", fmt.Sprintf("198.51.100.%d", i))
+		require.ErrorIs(t, err, ErrInvalidAlbumPassword)
+	}
+	_, _, _, err = service.GrantAccess(album, "secret", "203.0.113.1")
+	assert.True(t, errors.As(err, &tooMany), "the album should be limited for every client")
+
+	// Knowing one album's password buys no extra guesses at another
+	service, err = NewAlbumAccessService(nil, time.Hour)
+	require.NoError(t, err)
+	other := protectedAlbum(t, "other")
+	other.ID = "album-2"
+	for i := 0; i < albumAccessIPLimit-1; i++ {
+		_, _, _, err = service.GrantAccess(other, "wrong", "192.0.2.1")
+		require.ErrorIs(t, err, ErrInvalidAlbumPassword)
+	}
+	_, _, _, err = service.GrantAccess(album, "secret", "192.0.2.1")
+	require.NoError(t, err)
+	_, _, _, err = service.GrantAccess(other, "wrong", "192.0.2.1")
+	assert.True(t, errors.As(err, &tooMany), "a success does not clear the client's limit")
+}
+
+func TestAlbumAccessService_ShareLinks(t *testing.T) {
+	fileService, err := NewFileService(t.TempDir())
+	require.NoError(t, err)
+	shareLinks := NewShareLinkService(fileService)
+	service, err := NewAlbumAccessService(nil, time.Hour)
+	require.NoError(t, err)
+	service.SetShareLinkService(shareLinks)
+
+	// Share links work for albums of any visibility
+	album := &models.Album{ID: "album-1", Slug: "wedding", Visibility: "unlisted"}
+	link := &models.ShareLink{ExpiresAt: time.Now().Add(30 * time.Minute), AllowDownloads: true}
+	shareToken, err := shareLinks.Create(album.ID, link)
+	require.NoError(t, err)
+
+	_, _, _, err = service.GrantShareAccess(album, "wrong", "192.0.2.1")
+	assert.ErrorIs(t, err, ErrInvalidShareLink)
+
+	token, expiresAt, redeemed, err := service.GrantShareAccess(album, shareToken, "192.0.2.1")
+	require.NoError(t, err)
+	assert.Equal(t, link.ID, redeemed.ID)
+	assert.WithinDuration(t, link.ExpiresAt, expiresAt, time.Second, "the token expires with the link")
+
+	grant, err := service.ValidateToken(album, token)
+	require.NoError(t, err)
+	assert.Equal(t, link.ID, grant.ShareLinkID)
+	assert.True(t, grant.AllowDownloads)
+
+	require.NoError(t, service.RecordShareDownload(grant))
+	stored, err := shareLinks.Get(link.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stored.Downloads)
+
+	// The link only opens its own album
+	other := &models.Album{ID: "album-2", Slug: "other", Visibility: "public"}
+	_, _, _, err = service.GrantShareAccess(other, shareToken, "192.0.2.1")
+	assert.ErrorIs(t, err, ErrInvalidShareLink)
+	assertInvalidToken(t, service, other, token)
+
+	// Turning the album into a draft locks share links out, but not preview links
+	album.Visibility = "draft"
+	assertInvalidToken(t, service, album, token)
+	preview := &models.ShareLink{ExpiresAt: time.Now().Add(time.Hour), Preview: true}
+	previewToken, err := shareLinks.Create(album.ID, preview)
+	require.NoError(t, err)
+	token, _, _, err = service.GrantShareAccess(album, previewToken, "192.0.2.1")
+	require.NoError(t, err)
+	grant, err = service.ValidateToken(album, token)
+	require.NoError(t, err)
+	assert.True(t, grant.Preview)
+	album.Visibility = "unlisted"
+	token, _, _, err = service.GrantShareAccess(album, shareToken, "192.0.2.1")
+	require.NoError(t, err)
+
+	// Revoking the link revokes tokens issued through it
+	_, err = shareLinks.Revoke(album.ID, link.ID)
+	require.NoError(t, err)
+	assertInvalidToken(t, service, album, token)
+}
+
+func TestAlbumAccessService_NamedPasswords(t *testing.T) {
+	fileService, err := NewFileService(t.TempDir())
+	require.NoError(t, err)
+	passwords := NewAlbumPasswordService(fileService)
+	service, err := NewAlbumAccessService(nil, time.Hour)
+	require.NoError(t, err)
+	service.SetPasswordService(passwords)
+
+	album := protectedAlbum(t, "secret")
+	expiresAt := time.Now().Add(30 * time.Minute)
+	family := &models.AlbumPassword{Name: "Family", ExpiresAt: &expiresAt}
+	require.NoError(t, passwords.Create(album.ID, family, "family"))
+
+	// The main password still works and is not a named password
+	_, _, used, err := service.GrantAccess(album, "secret", "192.0.2.1")
+	require.NoError(t, err)
+	assert.Nil(t, used)
+
+	token, tokenExpiresAt, used, err := service.GrantAccess(album, "family", "192.0.2.1")
+	require.NoError(t, err)
+	require.NotNil(t, used)
+	assert.Equal(t, family.ID, used.ID)
+	assert.WithinDuration(t, expiresAt, tokenExpiresAt, time.Second, "the token expires with the password")
+
+	grant, err := service.ValidateToken(album, token)
+	require.NoError(t, err)
+	assert.Equal(t, family.ID, grant.PasswordID)
+
+	// Named passwords also work for albums without a main password
+	withoutMain := *album
+	withoutMain.PasswordHash = ""
+	_, _, _, err = service.GrantAccess(&withoutMain, "family", "192.0.2.1")
+	require.NoError(t, err)
+	_, err = service.ValidateToken(&withoutMain, token)
+	require.NoError(t, err)
+
+	// Changing the main password leaves named passwords alone
+	changed := protectedAlbum(t, "new secret")
+	_, err = service.ValidateToken(changed, token)
+	require.NoError(t, err)
+
+	// Drafts do not open with passwords
+	draft := *album
+	draft.Visibility = "draft"
+	assertInvalidToken(t, service, &draft, token)
+
+	// Deleting the password revokes tokens issued with it
+	require.NoError(t, passwords.Delete(album.ID, family.ID))
+	assertInvalidToken(t, service, album, token)
+	_, _, _, err = service.GrantAccess(album, "family", "192.0.2.1")
+	assert.ErrorIs(t, err, ErrInvalidAlbumPassword)
+}
+
+func TestNewAlbumAccessService_ShortSecret(t *testing.T) {
+	_, err := NewAlbumAccessService([]byte("too short"), time.Hour)
+	assert.Error(t, err)
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(2, time.Minute)
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	assert.NoError(t, limiter.Allow("a"))
+	assert.NoError(t, limiter.Allow("a"))
+	err := limiter.Allow("a")
+	var tooMany *TooManyAttemptsError
+	require.True(t, errors.As(err, &tooMany))
+	assert.Equal(t, time.Minute, tooMany.RetryAfter)
+
+	// Keys are independent
+	assert.NoError(t, limiter.Allow("b"))
+
+	// Reset clears a key
+	limiter.Reset("a")
+	assert.NoError(t, limiter.Allow("a"))
+
+	// Windows end
+	now = now.Add(time.Minute)
+	assert.NoError(t, limiter.Allow("b"))
+	limiter.Cleanup()
+	assert.Len(t, limiter.windows, 1, "only the window restarted for b is left")
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+)
+
+const albumPasswordsFile = "album_passwords.json"
+
+// AlbumPasswordService manages the named guest passwords of albums and their
+// access logs. The passwords are kept in memory after the first read, since
+// access tokens are checked against them on every media request.
+type AlbumPasswordService struct {
+	fileService *FileService
+	passwords   []models.AlbumPassword
+	mu          sync.Mutex
+}
+
+// NewAlbumPasswordService creates a new album password service.
+func NewAlbumPasswordService(fileService *FileService) *AlbumPasswordService {
+	return &AlbumPasswordService{
+		fileService: fileService,
+	}
+}
+
+// Create stores a new named password for an album. Only its hash is kept.
+func (s *AlbumPasswordService) Create(albumID string, albumPassword *models.AlbumPassword, password string) error {
+	if err := albumPassword.Validate(); err != nil {
+		return err
+	}
+	if password == "" {
+		return errors.New("password is required")
+	}
+	if err := ValidateAlbumPassword(password); err != nil {
+		return err
+	}
+	if albumPassword.ExpiresAt != nil && !albumPassword.ExpiresAt.After(time.Now()) {
+		return errors.New("album password expiry must be in the future")
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash album password: %w", err)
+	}
+
+	albumPassword.ID = uuid.New().String()
+	albumPassword.AlbumID = albumID
+	albumPassword.PasswordHash = hash
+	if albumPassword.ExpiresAt != nil {
+		expiresAt := albumPassword.ExpiresAt.UTC()
+		albumPassword.ExpiresAt = &expiresAt
+	}
+	albumPassword.CreatedAt = time.Now().UTC()
+	albumPassword.Uses = 0
+	albumPassword.FirstUsedAt = nil
+	albumPassword.LastUsedAt = nil
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	passwords, err := s.getAll()
+	if err != nil {
+		return err
+	}
+	return s.save(append(passwords, *albumPassword))
+}
+
+// ListByAlbum returns an album's passwords, including expired ones.
+func (s *AlbumPasswordService) ListByAlbum(albumID string) ([]models.AlbumPassword, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	passwords, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+
+	albumPasswords := []models.AlbumPassword{}
+	for _, password := range passwords {
+		if password.AlbumID == albumID {
+			albumPasswords = append(albumPasswords, password)
+		}
+	}
+	return albumPasswords, nil
+}
+
+// Get returns an album password by its ID.
+func (s *AlbumPasswordService) Get(id string) (*models.AlbumPassword, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	passwords, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+	for i := range passwords {
+		if passwords[i].ID == id {
+			return &passwords[i], nil
+		}
+	}
+	return nil, errors.New("album password not found")
+}
+
+// Delete removes an album's password. Access granted with it ends as well.
+func (s *AlbumPasswordService) Delete(albumID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	passwords, err := s.getAll()
+	if err != nil {
+		return err
+	}
+	for i := range passwords {
+		if passwords[i].ID == id && passwords[i].AlbumID == albumID {
+			return s.save(append(passwords[:i], passwords[i+1:]...))
+		}
+	}
+	return errors.New("album password not found")
+}
+
+// Match finds the album's usable password that matches password and counts a
+// use of it, upgrading its hash if outdated. It returns ErrInvalidAlbumPassword when none matches.
+// Passwords are checked without holding the service's lock, so guesses do not
+// hold up token checks against the stored passwords.
+func (s *AlbumPasswordService) Match(albumID, password string) (*models.AlbumPassword, error) {
+	s.mu.Lock()
+	passwords, err := s.getAll()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	for i := range passwords {
+		candidate := &passwords[i]
+		if candidate.AlbumID != albumID || !candidate.CanUse(now) {
+			continue
+		}
+		match, rehash := CheckPassword(candidate.PasswordHash, password)
+		if !match {
+			continue
+		}
+
+		newHash := ""
+		if rehash {
+			if newHash, err = HashPassword(password); err != nil {
+				return nil, fmt.Errorf("failed to hash album password: %w", err)
+			}
+		}
+		return s.recordUse(candidate, newHash, now)
+	}
+
+	return nil, ErrInvalidAlbumPassword
+}
+
+// recordUse counts a use of a password that matched, replacing its hash with
+// newHash when set. The password is checked again in case it was deleted or
+// used up while it was being matched.
+func (s *AlbumPasswordService) recordUse(matched *models.AlbumPassword, newHash string, now time.Time) (*models.AlbumPassword, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	passwords, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range passwords {
+		albumPassword := &passwords[i]
+		if albumPassword.ID != matched.ID {
+			continue
+		}
+		if !albumPassword.CanUse(now) {
+			return nil, ErrInvalidAlbumPassword
+		}
+
+		if newHash != "" {
+			albumPassword.PasswordHash = newHash
+		}
+		albumPassword.Uses++
+		if albumPassword.FirstUsedAt == nil {
+			albumPassword.FirstUsedAt = &now
+		}
+		albumPassword.LastUsedAt = &now
+
+		if err := s.save(passwords); err != nil {
+			return nil, err
+		}
+		return albumPassword, nil
+	}
+
+	return nil, ErrInvalidAlbumPassword
+}
+
+func (s *AlbumPasswordService) getAll() ([]models.AlbumPassword, error) {
+	if s.passwords == nil {
+		passwords := []models.AlbumPassword{}
+		if s.fileService.FileExists(albumPasswordsFile) {
+			var collection models.AlbumPasswordCollection
+			if err := s.fileService.ReadJSON(albumPasswordsFile, &collection); err != nil {
+				return nil, fmt.Errorf("failed to read album passwords: %w", err)
+			}
+			if collection.AlbumPasswords != nil {
+				passwords = collection.AlbumPasswords
+			}
+		}
+		s.passwords = passwords
+	}
+	return slices.Clone(s.passwords), nil
+}
+
+// save writes the album passwords to disk and keeps them as the in-memory
+// copy. Callers must hold s.mu.
+func (s *AlbumPasswordService) save(passwords []models.AlbumPassword) error {
+	collection := models.AlbumPasswordCollection{AlbumPasswords: passwords}
+	if err := s.fileService.WriteJSON(albumPasswordsFile, &collection); err != nil {
+		return fmt.Errorf("failed to write album passwords: %w", err)
+	}
+	s.passwords = slices.Clone(passwords)
+	return nil
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupAlbumPasswordService(t *testing.T) *AlbumPasswordService {
+	fileService, err := NewFileService(t.TempDir())
+	require.NoError(t, err)
+	return NewAlbumPasswordService(fileService)
+}
+
+func TestAlbumPasswordService_Create(t *testing.T) {
+	service := setupAlbumPasswordService(t)
+
+	err := service.Create("album-1", &models.AlbumPassword{}, "secret")
+	assert.Error(t, err, "name is required")
+
+	err = service.Create("album-1", &models.AlbumPassword{Name: "Family"}, "")
+	assert.Error(t, err, "password is required")
+
+	past := time.Now().Add(-time.Hour)
+	err = service.Create("album-1", &models.AlbumPassword{Name: "Family", ExpiresAt: &past}, "secret")
+	assert.Error(t, err, "expiry must be in the future")
+
+	err = service.Create("album-1", &models.AlbumPassword{Name: "Family", MaxUses: -1}, "secret")
+	assert.Error(t, err)
+
+	albumPassword := &models.AlbumPassword{Name: "Family", Uses: 3}
+	require.NoError(t, service.Create("album-1", albumPassword, "secret"))
+	assert.NotEmpty(t, albumPassword.ID)
+	assert.Equal(t, "album-1", albumPassword.AlbumID)
+	assert.Zero(t, albumPassword.Uses)
+	assert.NotEqual(t, "secret", albumPassword.PasswordHash, "only a hash of the password is stored")
+	assert.Empty(t, albumPassword.Sanitized().PasswordHash)
+	assert.NotEmpty(t, albumPassword.PasswordHash, "sanitizing returns a copy")
+
+	require.NoError(t, service.Create("album-2", &models.AlbumPassword{Name: "Friends"}, "secret"))
+
+	passwords, err := service.ListByAlbum("album-1")
+	require.NoError(t, err)
+	require.Len(t, passwords, 1)
+	assert.Equal(t, "Family", passwords[0].Name)
+
+	// Deleting only works through the password's own album
+	assert.Error(t, service.Delete("album-2", albumPassword.ID))
+	require.NoError(t, service.Delete("album-1", albumPassword.ID))
+	_, err = service.Get(albumPassword.ID)
+	assert.EqualError(t, err, "album password not found")
+}
+
+func TestAlbumPasswordService_Match(t *testing.T) {
+	service := setupAlbumPasswordService(t)
+
+	family := &models.AlbumPassword{Name: "Family", MaxUses: 2}
+	require.NoError(t, service.Create("album-1", family, "family"))
+	friends := &models.AlbumPassword{Name: "Friends"}
+	require.NoError(t, service.Create("album-1", friends, "friends"))
+
+	_, err := service.Match("album-1", "wrong")
+	assert.ErrorIs(t, err, ErrInvalidAlbumPassword)
+	_, err = service.Match("album-2", "family")
+	assert.ErrorIs(t, err, ErrInvalidAlbumPassword, "passwords only open their own album")
+
+	matched, err := service.Match("album-1", "friends")
+	require.NoError(t, err)
+	assert.Equal(t, friends.ID, matched.ID)
+
+	matched, err = service.Match("album-1", "family")
+	require.NoError(t, err)
+	assert.Equal(t, family.ID, matched.ID)
+	assert.Equal(t, 1, matched.Uses)
+	require.NotNil(t, matched.FirstUsedAt)
+	require.NotNil(t, matched.LastUsedAt)
+
+	_, err = service.Match("album-1", "family")
+	require.NoError(t, err)
+	_, err = service.Match("album-1", "family")
+	assert.ErrorIs(t, err, ErrInvalidAlbumPassword, "out of uses")
+
+	stored, err := service.Get(family.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stored.Uses)
+	assert.True(t, stored.IsValid(time.Now()), "running out of uses does not end access already granted")
+}
+
+func TestAlbumPasswordService_MatchConcurrently(t *testing.T) {
+	service := setupAlbumPasswordService(t)
+
+	albumPassword := &models.AlbumPassword{Name: "Family", MaxUses: 2}
+	require.NoError(t, service.Create("album-1", albumPassword, "family"))
+
+	// Guests entering the password at once cannot use it more than allowed
+	var wg sync.WaitGroup
+	var matched atomic.Int32
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := service.Match("album-1", "family"); err == nil {
+				matched.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(2), matched.Load())
+	stored, err := service.Get(albumPassword.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stored.Uses)
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+)
+
+// DefaultSchedulerInterval is how often scheduled publishing and expiry are applied.
+const DefaultSchedulerInterval = time.Minute
+
+// ScheduleReport lists the albums a scheduler run acted on.
+type ScheduleReport struct {
+	Published []models.Album // Scheduled visibility applied
+	Expired   []models.Album // Expired since the previous run
+	Archived  []models.Album // Archived on expiry
+	Expiring  []models.Album // Newly within the expiry warning window
+}
+
+// ScheduleEntry is an upcoming publish or expiry of an album.
+type ScheduleEntry struct {
+	AlbumID    string    `json:"album_id"`
+	Title      string    `json:"title"`
+	Event      string    `json:"event"`                // publish or expire
+	Visibility string    `json:"visibility,omitempty"` // Visibility after publishing
+	At         time.Time `json:"at"`
+	Soon       bool      `json:"soon,omitempty"` // Expiry within the warning window
+}
+
+// AlbumScheduler applies scheduled publishing and album expiry. Expired albums
+// are already hidden from visitors live; the scheduler republishes the public
+// dataset when they expire, archives them if asked to, and flags albums about
+// to expire.
+type AlbumScheduler struct {
+	albumService  *AlbumService
+	warningWindow time.Duration // Zero disables expiry warnings
+	lastRun       time.Time
+	mu            sync.Mutex
+}
+
+// NewAlbumScheduler creates an album scheduler that warns warningWindow before
+// albums expire.
+func NewAlbumScheduler(albumService *AlbumService, warningWindow time.Duration) *AlbumScheduler {
+	return &AlbumScheduler{
+		albumService:  albumService,
+		warningWindow: warningWindow,
+		lastRun:       time.Now(),
+	}
+}
+
+// Run applies everything that is due at now.
+func (s *AlbumScheduler) Run(now time.Time) (*ScheduleReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The changes are made under the album lock, so that they neither lose
+	// nor undo admin edits made at the same time
+	report := &ScheduleReport{}
+	err := s.albumService.Modify(func(albums []models.Album) (bool, error) {
+		changed := false
+		for i := range albums {
+			album := &albums[i]
+
+			if album.PublishAt != nil && !album.PublishAt.After(now) {
+				album.Visibility = album.PublishVisibility
+				if album.Visibility == "" {
+					album.Visibility = "public"
+				}
+				album.PublishAt = nil
+				album.PublishVisibility = ""
+				album.UpdatedAt = now.UTC()
+				report.Published = append(report.Published, *album)
+				changed = true
+			}
+
+			if album.IsExpired(now) {
+				if album.ExpirationDate.After(s.lastRun) {
+					report.Expired = append(report.Expired, *album)
+				}
+				if album.ExpiryAction == "archive" && album.ArchivedAt == nil {
+					archivedAt := now.UTC()
+					album.ArchivedAt = &archivedAt
+					report.Archived = append(report.Archived, *album)
+					changed = true
+				}
+				continue
+			}
+
+			if s.warningDue(album, now) {
+				warnedAt := now.UTC()
+				album.ExpiryWarnedAt = &warnedAt
+				report.Expiring = append(report.Expiring, *album)
+				changed = true
+			}
+		}
+		return changed, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Saving republishes; expiry alone changes nothing stored but still
+	// has to drop the albums from the public dataset.
+	changed := len(report.Published) > 0 || len(report.Archived) > 0 || len(report.Expiring) > 0
+	if !changed && len(report.Expired) > 0 {
+		if err := s.albumService.publish(); err != nil {
+			return nil, err
+		}
+	}
+
+	s.lastRun = now
+	return report, nil
+}
+
+// warningDue reports whether the album has entered the expiry warning window
+// without a warning for its current expiration date.
+func (s *AlbumScheduler) warningDue(album *models.Album, now time.Time) bool {
+	if s.warningWindow <= 0 || album.ExpirationDate == nil || album.ArchivedAt != nil {
+		return false
+	}
+	windowStart := album.ExpirationDate.Add(-s.warningWindow)
+	if now.Before(windowStart) {
+		return false
+	}
+	return album.ExpiryWarnedAt == nil || album.ExpiryWarnedAt.Before(windowStart)
+}
+
+// Upcoming returns the pending publishes and expiries of all albums, soonest first.
+func (s *AlbumScheduler) Upcoming(now time.Time) ([]ScheduleEntry, error) {
+	albums, err := s.albumService.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []ScheduleEntry{}
+	for i := range albums {
+		album := &albums[i]
+		if album.PublishAt != nil {
+			visibility := album.PublishVisibility
+			if visibility == "" {
+				visibility = "public"
+			}
+			entries = append(entries, ScheduleEntry{
+				AlbumID:    album.ID,
+				Title:      album.Title,
+				Event:      "publish",
+				Visibility: visibility,
+				At:         *album.PublishAt,
+			})
+		}
+		if album.ExpirationDate != nil && !album.IsWithdrawn(now) {
+			entries = append(entries, ScheduleEntry{
+				AlbumID: album.ID,
+				Title:   album.Title,
+				Event:   "expire",
+				At:      *album.ExpirationDate,
+				Soon:    s.warningWindow > 0 && !now.Before(album.ExpirationDate.Add(-s.warningWindow)),
+			})
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].At.Before(entries[j].At)
+	})
+	return entries, nil
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingPublisher counts how often the public dataset is rebuilt.
+type countingPublisher struct {
+	count int
+}
+
+func (p *countingPublisher) Publish() error {
+	p.count++
+	return nil
+}
+
+func setupAlbumScheduler(t *testing.T) (*AlbumScheduler, *AlbumService, *countingPublisher) {
+	fileService, err := NewFileService(t.TempDir())
+	require.NoError(t, err)
+	albumService := NewAlbumService(fileService)
+	publisher := &countingPublisher{}
+	albumService.SetPublisher(publisher)
+
+	return NewAlbumScheduler(albumService, 7*24*time.Hour), albumService, publisher
+}
+
+func TestAlbumScheduler_Publish(t *testing.T) {
+	scheduler, albumService, publisher := setupAlbumScheduler(t)
+	now := time.Now()
+
+	publishAt := now.Add(time.Hour)
+	album := &models.Album{Title: "Launch", Visibility: "unlisted", PublishAt: &publishAt}
+	require.NoError(t, albumService.Create(album))
+	publishAt = now.Add(2 * time.Hour)
+	later := &models.Album{Title: "Later", Visibility: "unlisted", PublishAt: &publishAt, PublishVisibility: "password_protected"}
+	require.NoError(t, albumService.Create(later))
+
+	report, err := scheduler.Run(now)
+	require.NoError(t, err)
+	assert.Empty(t, report.Published)
+
+	published := publisher.count
+	report, err = scheduler.Run(now.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, report.Published, 1)
+	assert.Equal(t, album.ID, report.Published[0].ID)
+	assert.Equal(t, published+1, publisher.count, "the public dataset is rebuilt")
+
+	album, err = albumService.GetByID(album.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "public", album.Visibility)
+	assert.Nil(t, album.PublishAt)
+
+	_, err = scheduler.Run(now.Add(3 * time.Hour))
+	require.NoError(t, err)
+	later, err = albumService.GetByID(later.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "password_protected", later.Visibility)
+	assert.Empty(t, later.PublishVisibility)
+}
+
+func TestAlbumScheduler_ConcurrentEdits(t *testing.T) {
+	scheduler, albumService, _ := setupAlbumScheduler(t)
+	now := time.Now()
+
+	publishAt := now.Add(-time.Minute)
+	album := &models.Album{Title: "Launch", Visibility: "unlisted", PublishAt: &publishAt}
+	require.NoError(t, albumService.Create(album))
+
+	// An edit prepared before the scheduler ran does not undo the publish
+	stale, err := albumService.GetByID(album.ID)
+	require.NoError(t, err)
+	_, err = scheduler.Run(now)
+	require.NoError(t, err)
+	stale.Title = "Launch day"
+	require.NoError(t, albumService.Update(album.ID, stale))
+	album, err = albumService.GetByID(album.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Launch day", album.Title)
+	assert.Equal(t, "public", album.Visibility)
+	assert.Nil(t, album.PublishAt)
+
+	// Edits made while the scheduler runs are not lost
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, albumService.AddPhoto(album.ID, &models.Photo{URLOriginal: "/uploads/originals/1.jpg"}))
+		}()
+		go func(i int) {
+			defer wg.Done()
+			_, err := scheduler.Run(now.Add(time.Duration(i) * time.Minute))
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+	album, err = albumService.GetByID(album.ID)
+	require.NoError(t, err)
+	assert.Len(t, album.Photos, 10)
+}
+
+func TestAlbumScheduler_Expiry(t *testing.T) {
+	scheduler, albumService, publisher := setupAlbumScheduler(t)
+	now := time.Now()
+
+	expiresAt := now.Add(time.Hour)
+	hidden := &models.Album{Title: "Hidden", Visibility: "public", ExpirationDate: &expiresAt}
+	require.NoError(t, albumService.Create(hidden))
+	archived := &models.Album{Title: "Archived", Visibility: "public", ExpirationDate: &expiresAt, ExpiryAction: "archive"}
+	require.NoError(t, albumService.Create(archived))
+
+	published := publisher.count
+	report, err := scheduler.Run(now.Add(2 * time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, report.Expired, 2)
+	require.Len(t, report.Archived, 1)
+	assert.Equal(t, archived.ID, report.Archived[0].ID)
+	assert.Equal(t, published+1, publisher.count)
+
+	archived, err = albumService.GetByID(archived.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, archived.ArchivedAt)
+	hidden, err = albumService.GetByID(hidden.ID)
+	require.NoError(t, err)
+	assert.Nil(t, hidden.ArchivedAt, "hidden albums come back if the expiration date is moved")
+
+	// Expiry is reported and published once
+	published = publisher.count
+	report, err = scheduler.Run(now.Add(3 * time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, report.Expired)
+	assert.Empty(t, report.Archived)
+	assert.Equal(t, published, publisher.count)
+
+	// Archived albums stay withdrawn after the expiration date is moved
+	later := now.Add(48 * time.Hour)
+	archived.ExpirationDate = &later
+	assert.True(t, archived.IsWithdrawn(now.Add(3*time.Hour)))
+}
+
+func TestAlbumScheduler_ExpiryWarnings(t *testing.T) {
+	scheduler, albumService, _ := setupAlbumScheduler(t)
+	now := time.Now()
+
+	expiresAt := now.Add(10 * 24 * time.Hour)
+	album := &models.Album{Title: "Client gallery", Visibility: "unlisted", ExpirationDate: &expiresAt}
+	require.NoError(t, albumService.Create(album))
+
+	report, err := scheduler.Run(now)
+	require.NoError(t, err)
+	assert.Empty(t, report.Expiring, "not yet within seven days")
+
+	report, err = scheduler.Run(now.Add(4 * 24 * time.Hour))
+	require.NoError(t, err)
+	require.Len(t, report.Expiring, 1)
+
+	report, err = scheduler.Run(now.Add(5 * 24 * time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, report.Expiring, "warned once")
+
+	// Moving the expiration date warns again for the new date
+	album, err = albumService.GetByID(album.ID)
+	require.NoError(t, err)
+	expiresAt = now.Add(20 * 24 * time.Hour)
+	album.ExpirationDate = &expiresAt
+	require.NoError(t, albumService.Update(album.ID, album))
+
+	report, err = scheduler.Run(now.Add(14 * 24 * time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, report.Expiring, 1)
+
+	entries, err := scheduler.Upcoming(now.Add(14 * 24 * time.Hour))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "expire", entries[0].Event)
+	assert.True(t, entries[0].Soon)
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,7 +15,10 @@ const albumsFile = "albums.json"
 
 // AlbumService handles album CRUD operations.
 type AlbumService struct {
-	fileService *FileService
+	fileService    *FileService
+	publisher      Publisher
+	onPublishError func(error)
+	mu             sync.Mutex // Serializes changes, so that none is lost
 }
 
 // NewAlbumService creates a new album service.
@@ -24,6 +28,19 @@ func NewAlbumService(fileService *FileService) *AlbumService {
 	}
 }
 
+// SetPublisher sets the publisher that rebuilds the public dataset after
+// every change to the albums.
+func (s *AlbumService) SetPublisher(publisher Publisher) {
+	s.publisher = publisher
+}
+
+// SetPublishErrorHandler sets the function told when republishing after a
+// change fails. The change is saved by then, so it still succeeds, and the
+// next change or restart publishes again.
+func (s *AlbumService) SetPublishErrorHandler(handler func(error)) {
+	s.onPublishError = handler
+}
+
 // GetAll returns all albums.
 func (s *AlbumService) GetAll() ([]models.Album, error) {
 	var collection models.AlbumCollection
@@ -79,257 +96,298 @@ func (s *AlbumService) Create(album *models.Album) error {
 	album.CreatedAt = time.Now().UTC()
 	album.UpdatedAt = time.Now().UTC()
 
-	// Get existing albums
-	albums, err := s.GetAll()
-	if err != nil {
-		return err
-	}
-
-	// Generate slug if not provided
-	if album.Slug == "" {
-		baseSlug := generateSlug(album.Title)
-		album.Slug = generateUniqueSlug(baseSlug, albums)
-	} else {
-		// If slug is provided, ensure it's unique
-		album.Slug = generateUniqueSlug(album.Slug, albums)
-	}
-
-	// Validate album
-	if err := album.Validate(); err != nil {
-		return fmt.Errorf("validation failed: %w", err)
-	}
-
-	// Add album to collection
-	albums = append(albums, *album)
+	return s.change(func(albums []models.Album) ([]models.Album, error) {
+		// Generate slug if not provided
+		if album.Slug == "" {
+			baseSlug := generateSlug(album.Title)
+			album.Slug = generateUniqueSlug(baseSlug, albums)
+		} else {
+			// If slug is provided, ensure it's unique
+			album.Slug = generateUniqueSlug(album.Slug, albums)
+		}
 
-	collection := models.AlbumCollection{Albums: albums}
-	if err := s.fileService.WriteJSON(albumsFile, &collection); err != nil {
-		return fmt.Errorf("failed to write albums: %w", err)
-	}
+		// Validate album
+		if err := album.Validate(); err != nil {
+			return nil, fmt.Errorf("validation failed: %w", err)
+		}
 
-	return nil
+		// Add album to collection
+		return append(albums, *album), nil
+	})
 }
 
-// Update updates an existing album.
+// Update updates an existing album. A publish time that has passed and that
+// the stored album no longer has was applied by the scheduler after the
+// update was prepared, so the update keeps the visibility it published.
 func (s *AlbumService) Update(id string, updates *models.Album) error {
-	albums, err := s.GetAll()
-	if err != nil {
-		return err
-	}
-
-	found := false
-	for i := range albums {
-		if albums[i].ID == id {
-			// Preserve ID and CreatedAt
-			updates.ID = albums[i].ID
-			updates.CreatedAt = albums[i].CreatedAt
-			updates.UpdatedAt = time.Now().UTC()
-
-			// Validate updates
-			if err := updates.Validate(); err != nil {
-				return fmt.Errorf("validation failed: %w", err)
+	return s.change(func(albums []models.Album) ([]models.Album, error) {
+		for i := range albums {
+			if albums[i].ID != id {
+				continue
 			}
-
-			// Check for duplicate slug (excluding current album)
-			for j := range albums {
-				if i != j && albums[j].Slug == updates.Slug {
-					return errors.New("album with this slug already exists")
-				}
+			if updates.PublishAt != nil && !updates.PublishAt.After(time.Now()) && albums[i].PublishAt == nil {
+				updates.PublishAt = nil
+				updates.PublishVisibility = ""
+				updates.Visibility = albums[i].Visibility
 			}
-
-			albums[i] = *updates
-			found = true
-			break
+			if err := replaceAlbum(albums, i, updates); err != nil {
+				return nil, err
+			}
+			return albums, nil
 		}
-	}
-
-	if !found {
-		return errors.New("album not found")
-	}
+		return nil, errors.New("album not found")
+	})
+}
 
-	collection := models.AlbumCollection{Albums: albums}
-	if err := s.fileService.WriteJSON(albumsFile, &collection); err != nil {
-		return fmt.Errorf("failed to write albums: %w", err)
-	}
+// Modify runs fn on all albums and saves them if it reports a change. It
+// holds the same lock as every other change, so changes made in the
+// background neither lose nor undo concurrent edits.
+func (s *AlbumService) Modify(fn func(albums []models.Album) (bool, error)) error {
+	return s.change(func(albums []models.Album) ([]models.Album, error) {
+		changed, err := fn(albums)
+		if err != nil || !changed {
+			return nil, err
+		}
+		return albums, nil
+	})
+}
 
-	return nil
+// ReplacePasswordHash swaps an album's password hash for an equivalent one, as
+// when upgrading the hashing scheme. The album is left alone if its hash is no
+// longer oldHash, and its update time is kept.
+func (s *AlbumService) ReplacePasswordHash(id, oldHash, newHash string) error {
+	return s.change(func(albums []models.Album) ([]models.Album, error) {
+		for i := range albums {
+			if albums[i].ID != id {
+				continue
+			}
+			if albums[i].PasswordHash != oldHash {
+				return nil, errors.New("album password has changed")
+			}
+			albums[i].PasswordHash = newHash
+			return albums, nil
+		}
+		return nil, errors.New("album not found")
+	})
 }
 
 // Delete deletes an album by ID.
 func (s *AlbumService) Delete(id string) error {
-	albums, err := s.GetAll()
-	if err != nil {
-		return err
-	}
-
-	found := false
-	newAlbums := make([]models.Album, 0, len(albums))
-
-	for _, album := range albums {
-		if album.ID == id {
-			found = true
-			// Skip this album (delete it)
-		} else {
-			newAlbums = append(newAlbums, album)
+	return s.change(func(albums []models.Album) ([]models.Album, error) {
+		found := false
+		newAlbums := make([]models.Album, 0, len(albums))
+
+		for _, album := range albums {
+			if album.ID == id {
+				found = true
+				// Skip this album (delete it)
+			} else {
+				newAlbums = append(newAlbums, album)
+			}
 		}
-	}
-
-	if !found {
-		return errors.New("album not found")
-	}
 
-	collection := models.AlbumCollection{Albums: newAlbums}
-	if err := s.fileService.WriteJSON(albumsFile, &collection); err != nil {
-		return fmt.Errorf("failed to write albums: %w", err)
-	}
-
-	return nil
+		if !found {
+			return nil, errors.New("album not found")
+		}
+		return newAlbums, nil
+	})
 }
 
 // AddPhoto adds a photo to an album.
 func (s *AlbumService) AddPhoto(albumID string, photo *models.Photo) error {
-	album, err := s.GetByID(albumID)
-	if err != nil {
-		return err
-	}
-
-	// Set photo ID and timestamp
-	photo.ID = uuid.New().String()
-	photo.UploadedAt = time.Now().UTC()
-
-	// Set order (append to end)
-	photo.Order = len(album.Photos) + 1
+	return s.updateAlbum(albumID, func(album *models.Album) error {
+		// Set photo ID and timestamp
+		photo.ID = uuid.New().String()
+		photo.UploadedAt = time.Now().UTC()
 
-	album.Photos = append(album.Photos, *photo)
+		// Set order (append to end)
+		photo.Order = len(album.Photos) + 1
 
-	return s.Update(albumID, album)
+		album.Photos = append(album.Photos, *photo)
+		return nil
+	})
 }
 
 // UpdatePhoto updates a photo in an album.
 func (s *AlbumService) UpdatePhoto(albumID, photoID string, updates *models.Photo) error {
-	album, err := s.GetByID(albumID)
-	if err != nil {
-		return err
-	}
-
-	found := false
-	for i := range album.Photos {
-		if album.Photos[i].ID == photoID {
-			// Preserve ID and UploadedAt
-			updates.ID = album.Photos[i].ID
-			updates.UploadedAt = album.Photos[i].UploadedAt
-
-			album.Photos[i] = *updates
-			found = true
-			break
+	return s.updateAlbum(albumID, func(album *models.Album) error {
+		for i := range album.Photos {
+			if album.Photos[i].ID == photoID {
+				// Preserve ID and UploadedAt
+				updates.ID = album.Photos[i].ID
+				updates.UploadedAt = album.Photos[i].UploadedAt
+
+				album.Photos[i] = *updates
+				return nil
+			}
 		}
-	}
-
-	if !found {
 		return errors.New("photo not found")
-	}
-
-	return s.Update(albumID, album)
+	})
 }
 
 // DeletePhoto deletes a photo from an album.
 func (s *AlbumService) DeletePhoto(albumID, photoID string) error {
-	album, err := s.GetByID(albumID)
-	if err != nil {
-		return err
-	}
-
-	found := false
-	newPhotos := make([]models.Photo, 0, len(album.Photos))
-
-	for _, photo := range album.Photos {
-		if photo.ID == photoID {
-			found = true
-			// Skip this photo (delete it)
-		} else {
-			newPhotos = append(newPhotos, photo)
+	return s.updateAlbum(albumID, func(album *models.Album) error {
+		found := false
+		newPhotos := make([]models.Photo, 0, len(album.Photos))
+
+		for _, photo := range album.Photos {
+			if photo.ID == photoID {
+				found = true
+				// Skip this photo (delete it)
+			} else {
+				newPhotos = append(newPhotos, photo)
+			}
 		}
-	}
-
-	if !found {
-		return errors.New("photo not found")
-	}
 
-	album.Photos = newPhotos
+		if !found {
+			return errors.New("photo not found")
+		}
 
-	return s.Update(albumID, album)
+		album.Photos = newPhotos
+		return nil
+	})
 }
 
 // DeleteAllPhotos deletes all photos from an album.
 func (s *AlbumService) DeleteAllPhotos(albumID string) error {
-	album, err := s.GetByID(albumID)
-	if err != nil {
-		return err
-	}
-
-	// Clear all photos
-	album.Photos = []models.Photo{}
-
-	return s.Update(albumID, album)
+	return s.updateAlbum(albumID, func(album *models.Album) error {
+		// Clear all photos
+		album.Photos = []models.Photo{}
+		return nil
+	})
 }
 
 // SetCoverPhoto sets the cover photo for an album.
 func (s *AlbumService) SetCoverPhoto(albumID, photoID string) error {
-	album, err := s.GetByID(albumID)
-	if err != nil {
-		return err
-	}
+	return s.updateAlbum(albumID, func(album *models.Album) error {
+		// Verify photo exists in album
+		for _, photo := range album.Photos {
+			if photo.ID == photoID {
+				album.CoverPhotoID = photoID
+				return nil
+			}
+		}
+		return errors.New("photo not found in album")
+	})
+}
 
-	// Verify photo exists in album
-	found := false
-	for _, photo := range album.Photos {
-		if photo.ID == photoID {
-			found = true
-			break
+// ReorderPhotos reorders photos in an album based on the provided photo IDs.
+func (s *AlbumService) ReorderPhotos(albumID string, photoIDs []string) error {
+	return s.updateAlbum(albumID, func(album *models.Album) error {
+		// Verify all photo IDs exist in the album
+		if len(photoIDs) != len(album.Photos) {
+			return errors.New("photo ID count does not match album photo count")
 		}
-	}
 
-	if !found {
-		return errors.New("photo not found in album")
-	}
+		photoMap := make(map[string]models.Photo)
+		for _, photo := range album.Photos {
+			photoMap[photo.ID] = photo
+		}
 
-	album.CoverPhotoID = photoID
+		// Build new photos array in the requested order
+		newPhotos := make([]models.Photo, 0, len(photoIDs))
+		for i, photoID := range photoIDs {
+			photo, exists := photoMap[photoID]
+			if !exists {
+				return fmt.Errorf("photo ID %s not found in album", photoID)
+			}
+			// Update the order field
+			photo.Order = i + 1
+			newPhotos = append(newPhotos, photo)
+		}
 
-	return s.Update(albumID, album)
+		album.Photos = newPhotos
+		return nil
+	})
 }
 
-// ReorderPhotos reorders photos in an album based on the provided photo IDs.
-func (s *AlbumService) ReorderPhotos(albumID string, photoIDs []string) error {
-	album, err := s.GetByID(albumID)
+// change runs fn on all albums under the lock and saves the albums it
+// returns. A nil result saves nothing.
+func (s *AlbumService) change(fn func(albums []models.Album) ([]models.Album, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	albums, err := s.GetAll()
 	if err != nil {
 		return err
 	}
-
-	// Verify all photo IDs exist in the album
-	if len(photoIDs) != len(album.Photos) {
-		return errors.New("photo ID count does not match album photo count")
+	albums, err = fn(albums)
+	if err != nil || albums == nil {
+		return err
 	}
+	return s.save(albums)
+}
+
+// updateAlbum runs fn on a copy of one album under the lock and stores the
+// result with the checks of Update.
+func (s *AlbumService) updateAlbum(id string, fn func(album *models.Album) error) error {
+	return s.change(func(albums []models.Album) ([]models.Album, error) {
+		for i := range albums {
+			if albums[i].ID != id {
+				continue
+			}
+			album := albums[i]
+			album.Photos = append([]models.Photo(nil), albums[i].Photos...)
+			if err := fn(&album); err != nil {
+				return nil, err
+			}
+			if err := replaceAlbum(albums, i, &album); err != nil {
+				return nil, err
+			}
+			return albums, nil
+		}
+		return nil, errors.New("album not found")
+	})
+}
 
-	photoMap := make(map[string]models.Photo)
-	for _, photo := range album.Photos {
-		photoMap[photo.ID] = photo
+// replaceAlbum replaces albums[i] with updates, keeping its ID and creation
+// time, after validating it and checking that its slug is unique.
+func replaceAlbum(albums []models.Album, i int, updates *models.Album) error {
+	// Preserve ID and CreatedAt
+	updates.ID = albums[i].ID
+	updates.CreatedAt = albums[i].CreatedAt
+	updates.UpdatedAt = time.Now().UTC()
+
+	// Validate updates
+	if err := updates.Validate(); err != nil {
+		return fmt.Errorf("validation failed: %w", err)
 	}
 
-	// Build new photos array in the requested order
-	newPhotos := make([]models.Photo, 0, len(photoIDs))
-	for i, photoID := range photoIDs {
-		photo, exists := photoMap[photoID]
-		if !exists {
-			return fmt.Errorf("photo ID %s not found in album", photoID)
+	// Check for duplicate slug (excluding current album)
+	for j := range albums {
+		if i != j && albums[j].Slug == updates.Slug {
+			return errors.New("album with this slug already exists")
 		}
-		// Update the order field
-		photo.Order = i + 1
-		newPhotos = append(newPhotos, photo)
 	}
 
-	album.Photos = newPhotos
+	albums[i] = *updates
+	return nil
+}
+
+// save writes the albums and republishes the public dataset. Only a failed
+// write fails the save; publishing failures go to the publish error handler.
+func (s *AlbumService) save(albums []models.Album) error {
+	collection := models.AlbumCollection{Albums: albums}
+	if err := s.fileService.WriteJSON(albumsFile, &collection); err != nil {
+		return fmt.Errorf("failed to write albums: %w", err)
+	}
+
+	if err := s.publish(); err != nil && s.onPublishError != nil {
+		s.onPublishError(err)
+	}
+	return nil
+}
 
-	return s.Update(albumID, album)
+// publish rebuilds the public dataset, if a publisher is set.
+func (s *AlbumService) publish() error {
+	if s.publisher != nil {
+		if err := s.publisher.Publish(); err != nil {
+			return fmt.Errorf("failed to publish albums: %w", err)
+		}
+	}
+
+	return nil
 }
 
 // generateSlug creates a URL-friendly slug from a title.
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -138,6 +139,29 @@ func TestAlbumService_Delete(t *testing.T) {
 	assert.Error(t, err)
 }
 
+type failingPublisher struct{}
+
+func (failingPublisher) Publish() error {
+	return errors.New("disk full")
+}
+
+func TestAlbumService_PublishFailure(t *testing.T) {
+	service, _ := setupAlbumService(t)
+	service.SetPublisher(failingPublisher{})
+	var publishErrors []error
+	service.SetPublishErrorHandler(func(err error) {
+		publishErrors = append(publishErrors, err)
+	})
+
+	// The change is saved and succeeds; the failure is only reported
+	album := &models.Album{Title: "Test Album", Visibility: "public"}
+	require.NoError(t, service.Create(album))
+	_, err := service.GetByID(album.ID)
+	require.NoError(t, err)
+	require.Len(t, publishErrors, 1)
+	assert.ErrorContains(t, publishErrors[0], "disk full")
+}
+
 func TestAlbumService_AddPhoto(t *testing.T) {
 	service, _ := setupAlbumService(t)
 
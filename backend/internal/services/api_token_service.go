@@ -0,0 +1,180 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+)
+
+const (
+	apiTokensFile = "api_tokens.json"
+	// apiTokenPrefix starts every API token, so that leaked tokens are easy to
+	// recognise.
+	apiTokenPrefix = "nsf_"
+	// apiTokenPrefixLength is how much of a token is kept to tell tokens apart.
+	apiTokenPrefixLength = len(apiTokenPrefix) + 8
+)
+
+// ErrInvalidAPIToken is returned for unknown, revoked and expired API tokens.
+var ErrInvalidAPIToken = errors.New("invalid API token")
+
+// APITokenService manages personal access tokens for scripts.
+type APITokenService struct {
+	fileService *FileService
+	mu          sync.Mutex
+}
+
+// NewAPITokenService creates a new API token service.
+func NewAPITokenService(fileService *FileService) *APITokenService {
+	return &APITokenService{
+		fileService: fileService,
+	}
+}
+
+// List returns a user's tokens, newest first.
+func (s *APITokenService) List(userID string) ([]models.APIToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+
+	owned := []models.APIToken{}
+	for _, token := range tokens {
+		if token.UserID == userID {
+			owned = append(owned, token)
+		}
+	}
+	sort.SliceStable(owned, func(i, j int) bool {
+		return owned[i].CreatedAt.After(owned[j].CreatedAt)
+	})
+	return owned, nil
+}
+
+// Create stores a new token for a user and returns the token itself, which is
+// not kept and cannot be shown again.
+func (s *APITokenService) Create(userID string, token *models.APIToken) (string, error) {
+	if err := token.Validate(); err != nil {
+		return "", err
+	}
+
+	secret, err := generateAPIToken()
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.getAll()
+	if err != nil {
+		return "", err
+	}
+
+	token.ID = uuid.New().String()
+	token.UserID = userID
+	token.Prefix = secret[:apiTokenPrefixLength]
+	token.TokenHash = hashSessionToken(secret)
+	token.CreatedAt = time.Now().UTC()
+	token.LastUsedAt = nil
+
+	if err := s.save(append(tokens, *token)); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// Revoke deletes one of a user's tokens.
+func (s *APITokenService) Revoke(userID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.getAll()
+	if err != nil {
+		return err
+	}
+	for i := range tokens {
+		if tokens[i].ID == id && tokens[i].UserID == userID {
+			return s.save(append(tokens[:i], tokens[i+1:]...))
+		}
+	}
+	return errors.New("API token not found")
+}
+
+// Authenticate returns the stored token for a token presented by a client and
+// records its use. It returns ErrInvalidAPIToken for unknown and expired
+// tokens.
+func (s *APITokenService) Authenticate(secret string) (*models.APIToken, error) {
+	if !strings.HasPrefix(secret, apiTokenPrefix) {
+		return nil, ErrInvalidAPIToken
+	}
+	hash := hashSessionToken(secret)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range tokens {
+		token := &tokens[i]
+		if token.TokenHash != hash {
+			continue
+		}
+		now := time.Now().UTC()
+		if token.IsExpired(now) {
+			return nil, ErrInvalidAPIToken
+		}
+		// Like session extensions, uses are only written once a minute
+		if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= sessionWriteInterval {
+			token.LastUsedAt = &now
+			if err := s.save(tokens); err != nil {
+				return nil, err
+			}
+		}
+		found := *token
+		return &found, nil
+	}
+	return nil, ErrInvalidAPIToken
+}
+
+func (s *APITokenService) getAll() ([]models.APIToken, error) {
+	if !s.fileService.FileExists(apiTokensFile) {
+		return []models.APIToken{}, nil
+	}
+
+	var collection models.APITokenCollection
+	if err := s.fileService.ReadJSON(apiTokensFile, &collection); err != nil {
+		return nil, fmt.Errorf("failed to read API tokens: %w", err)
+	}
+	return collection.Tokens, nil
+}
+
+func (s *APITokenService) save(tokens []models.APIToken) error {
+	collection := models.APITokenCollection{Tokens: tokens}
+	if err := s.fileService.WriteJSON(apiTokensFile, &collection); err != nil {
+		return fmt.Errorf("failed to write API tokens: %w", err)
+	}
+	return nil
+}
+
+// generateAPIToken generates a new API token.
+func generateAPIToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate API token: %w", err)
+	}
+	return apiTokenPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPITokenService_Authenticate(t *testing.T) {
+	dataDir := t.TempDir()
+	fileService, err := NewFileService(dataDir)
+	require.NoError(t, err)
+	service := NewAPITokenService(fileService)
+
+	token := &models.APIToken{Name: "Lightroom", Scopes: []models.Scope{models.ScopeUpload}}
+	secret, err := service.Create("user-1", token)
+	require.NoError(t, err)
+
+	// Only the token's hash is stored
+	data, err := os.ReadFile(filepath.Join(dataDir, apiTokensFile))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), secret)
+
+	found, err := service.Authenticate(secret)
+	require.NoError(t, err)
+	assert.Equal(t, token.ID, found.ID)
+	assert.True(t, found.HasScope(models.ScopeUpload))
+	assert.False(t, found.HasScope(models.ScopeConfig))
+	require.NotNil(t, found.LastUsedAt)
+
+	_, err = service.Authenticate(secret + "x")
+	assert.ErrorIs(t, err, ErrInvalidAPIToken)
+
+	// Expired tokens are rejected
+	expiresAt := time.Now().Add(time.Hour)
+	expiring := &models.APIToken{Name: "Short", Scopes: []models.Scope{models.ScopeAlbumsRead}, ExpiresAt: &expiresAt}
+	expiringSecret, err := service.Create("user-1", expiring)
+	require.NoError(t, err)
+	tokens, err := service.getAll()
+	require.NoError(t, err)
+	past := time.Now().Add(-time.Minute)
+	tokens[1].ExpiresAt = &past
+	require.NoError(t, service.save(tokens))
+	_, err = service.Authenticate(expiringSecret)
+	assert.ErrorIs(t, err, ErrInvalidAPIToken)
+
+	// Revoked tokens are gone
+	require.NoError(t, service.Revoke("user-1", token.ID))
+	_, err = service.Authenticate(secret)
+	assert.ErrorIs(t, err, ErrInvalidAPIToken)
+	assert.Error(t, service.Revoke("user-1", token.ID))
+}
@@ -0,0 +1,447 @@
+package services
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+)
+
+const (
+	// ArchiveTierOriginal downloads the photos' original files.
+	ArchiveTierOriginal = "original"
+	// ArchiveTierLarge downloads large JPEGs rendered with the photos' edits.
+	ArchiveTierLarge = "large"
+
+	// DefaultArchiveLargeSize is the longest side of large JPEGs in pixels.
+	DefaultArchiveLargeSize = 2048
+	// DefaultArchiveLargeQuality is the JPEG quality of large JPEGs.
+	DefaultArchiveLargeQuality = 90
+
+	archiveManifestFile = "manifest.json"
+	archiveCreditsFile  = "CREDITS.txt"
+	archivePartialExt   = ".partial"
+)
+
+var (
+	// ErrUnknownArchiveTier is returned for download tiers that do not exist or
+	// are not available on this server.
+	ErrUnknownArchiveTier = errors.New("unknown download tier")
+
+	// ErrArchiveBuilding is returned by Write while another download is
+	// building the same archive.
+	ErrArchiveBuilding = errors.New("archive is being built")
+)
+
+// JPEGRenderer renders photos as JPEGs for the large download tier.
+type JPEGRenderer interface {
+	RenderJPEG(photo *models.Photo, maxSize, quality int) ([]byte, error)
+}
+
+// Archive is the ZIP download of one album at one tier. Its key changes
+// whenever anything in the archive would.
+type Archive struct {
+	Album   *models.Album
+	Tier    string
+	Key     string
+	credits string
+}
+
+// archiveManifest is the manifest.json in every archive.
+type archiveManifest struct {
+	Album     string                 `json:"album"`
+	Slug      string                 `json:"slug"`
+	Tier      string                 `json:"tier"`
+	UpdatedAt time.Time              `json:"updated_at"`
+	Files     []archiveManifestEntry `json:"files"`
+}
+
+type archiveManifestEntry struct {
+	Name    string `json:"name"`
+	PhotoID string `json:"photo_id"`
+	Size    int64  `json:"size"`
+	SHA256  string `json:"sha256"`
+	Caption string `json:"caption,omitempty"`
+}
+
+// ArchiveService builds ZIP archives of albums for download. Archives are
+// streamed as they are built, with no temporary files, and written to the
+// cache directory at the same time; later downloads are served from the
+// cache, which also lets interrupted downloads resume. Archives are built
+// deterministically, so a cached archive has the same bytes as the stream it
+// was written from.
+type ArchiveService struct {
+	uploadDir     string
+	cacheDir      string
+	albumService  *AlbumService
+	configService *SiteConfigService
+	renderer      JPEGRenderer
+	largeSize     int
+	largeQuality  int
+
+	mu       sync.Mutex
+	building map[string]bool // cache files being written
+}
+
+// NewArchiveService creates a new archive service caching archives in
+// cacheDir. Without a renderer only the original tier is available.
+func NewArchiveService(
+	uploadDir, cacheDir string,
+	albumService *AlbumService,
+	configService *SiteConfigService,
+	renderer JPEGRenderer,
+	largeSize, largeQuality int,
+) (*ArchiveService, error) {
+	// #nosec G301 - 0755 is appropriate for the cache directory
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create download cache directory: %w", err)
+	}
+	if largeSize <= 0 {
+		largeSize = DefaultArchiveLargeSize
+	}
+	if largeQuality <= 0 || largeQuality > 100 {
+		largeQuality = DefaultArchiveLargeQuality
+	}
+
+	return &ArchiveService{
+		uploadDir:     uploadDir,
+		cacheDir:      cacheDir,
+		albumService:  albumService,
+		configService: configService,
+		renderer:      renderer,
+		largeSize:     largeSize,
+		largeQuality:  largeQuality,
+		building:      make(map[string]bool),
+	}, nil
+}
+
+// Prepare describes the archive of an album at a tier, the original tier when
+// empty.
+func (s *ArchiveService) Prepare(album *models.Album, tier string) (*Archive, error) {
+	if tier == "" {
+		tier = ArchiveTierOriginal
+	}
+	if !s.hasTier(tier) {
+		return nil, ErrUnknownArchiveTier
+	}
+
+	config, err := s.configService.Get()
+	if err != nil {
+		return nil, err
+	}
+	return s.prepare(album, tier, config)
+}
+
+// Open opens the cached archive. It returns an error satisfying
+// errors.Is(err, os.ErrNotExist) while the archive is not cached.
+func (s *ArchiveService) Open(archive *Archive) (*os.File, error) {
+	// #nosec G304 - the cache file name is built from the album ID, tier and key
+	return os.Open(filepath.Join(s.cacheDir, archiveCacheName(archive)))
+}
+
+// Write builds the archive into w and caches it. Each archive is built by one
+// download at a time: while another download is building it, Write writes
+// nothing and returns ErrArchiveBuilding, and the archive can be fetched from
+// the cache once it is done. Once caching, the archive is finished even if w
+// fails, so a client that lost its connection can resume from the cache; the
+// error from w is returned afterwards.
+func (s *ArchiveService) Write(w io.Writer, archive *Archive) error {
+	name := archiveCacheName(archive)
+	cache, err := s.startCaching(name)
+	if err != nil {
+		return err
+	}
+	if cache == nil {
+		return s.writeZip(w, archive)
+	}
+
+	out := &cachingWriter{client: w, cache: cache}
+	err = s.writeZip(out, archive)
+	s.finishCaching(name, cache, err == nil && out.cacheErr == nil)
+	if err != nil {
+		return err
+	}
+	return out.clientErr
+}
+
+// Publish removes cached archives that no longer match their album, so any
+// change to an album, or to the credits in the site config, invalidates its
+// downloads. Archives of deleted albums are removed as well.
+func (s *ArchiveService) Publish() error {
+	albums, err := s.albumService.GetAll()
+	if err != nil {
+		return err
+	}
+	config, err := s.configService.Get()
+	if err != nil {
+		return err
+	}
+
+	current := make(map[string]bool)
+	for i := range albums {
+		for _, tier := range []string{ArchiveTierOriginal, ArchiveTierLarge} {
+			if !s.hasTier(tier) {
+				continue
+			}
+			archive, err := s.prepare(&albums[i], tier, config)
+			if err != nil {
+				return err
+			}
+			current[archiveCacheName(archive)] = true
+		}
+	}
+
+	entries, err := os.ReadDir(s.cacheDir)
+	if err != nil {
+		return fmt.Errorf("failed to read download cache: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, entry := range entries {
+		name := entry.Name()
+		if current[name] || s.building[strings.TrimSuffix(name, archivePartialExt)] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.cacheDir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove cached archive %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (s *ArchiveService) hasTier(tier string) bool {
+	return tier == ArchiveTierOriginal || (tier == ArchiveTierLarge && s.renderer != nil)
+}
+
+func (s *ArchiveService) prepare(album *models.Album, tier string, config *models.SiteConfig) (*Archive, error) {
+	archive := &Archive{
+		Album:   album,
+		Tier:    tier,
+		credits: archiveCredits(album, config),
+	}
+
+	hash := sha256.New()
+	err := json.NewEncoder(hash).Encode(struct {
+		Album        *models.Album
+		Tier         string
+		LargeSize    int
+		LargeQuality int
+		Credits      string
+	}{album, tier, s.largeSize, s.largeQuality, archive.credits})
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash archive contents: %w", err)
+	}
+	archive.Key = hex.EncodeToString(hash.Sum(nil))
+	return archive, nil
+}
+
+// startCaching opens the partial cache file for an archive. It returns
+// ErrArchiveBuilding when the archive is being cached already, and no file
+// when the file cannot be created.
+func (s *ArchiveService) startCaching(name string) (*os.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.building[name] {
+		return nil, ErrArchiveBuilding
+	}
+	// #nosec G304 - the cache file name is built from the album ID, tier and key
+	file, err := os.OpenFile(filepath.Join(s.cacheDir, name+archivePartialExt), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, nil
+	}
+	s.building[name] = true
+	return file, nil
+}
+
+// finishCaching moves a complete archive into the cache, or drops it.
+func (s *ArchiveService) finishCaching(name string, cache *os.File, complete bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	partial := filepath.Join(s.cacheDir, name+archivePartialExt)
+	if err := cache.Close(); err != nil {
+		complete = false
+	}
+	if !complete || os.Rename(partial, filepath.Join(s.cacheDir, name)) != nil {
+		_ = os.Remove(partial)
+	}
+	delete(s.building, name)
+}
+
+// writeZip writes the archive: the album's photos in album order, then the
+// manifest with each file's size and SHA-256, then the credits. Photos are
+// stored without compression, as they do not compress further.
+func (s *ArchiveService) writeZip(w io.Writer, archive *Archive) error {
+	album := archive.Album
+	photos := make([]models.Photo, len(album.Photos))
+	copy(photos, album.Photos)
+	sort.SliceStable(photos, func(i, j int) bool {
+		return photos[i].Order < photos[j].Order
+	})
+
+	zw := zip.NewWriter(w)
+	manifest := archiveManifest{
+		Album:     album.Title,
+		Slug:      album.Slug,
+		Tier:      archive.Tier,
+		UpdatedAt: album.UpdatedAt.UTC(),
+		Files:     []archiveManifestEntry{},
+	}
+
+	for i := range photos {
+		photo := &photos[i]
+		name := archiveEntryName(i, photo, archive.Tier)
+		entry, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Store,
+			Modified: photo.UploadedAt.UTC(),
+		})
+		if err != nil {
+			return err
+		}
+
+		hash := sha256.New()
+		size, err := s.writePhoto(io.MultiWriter(entry, hash), photo, archive.Tier)
+		if err != nil {
+			return fmt.Errorf("failed to add photo %s: %w", photo.ID, err)
+		}
+		manifest.Files = append(manifest.Files, archiveManifestEntry{
+			Name:    name,
+			PhotoID: photo.ID,
+			Size:    size,
+			SHA256:  hex.EncodeToString(hash.Sum(nil)),
+			Caption: photo.Caption,
+		})
+	}
+
+	entry, err := zw.CreateHeader(&zip.FileHeader{Name: archiveManifestFile, Method: zip.Deflate, Modified: manifest.UpdatedAt})
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(manifest); err != nil {
+		return err
+	}
+
+	entry, err = zw.CreateHeader(&zip.FileHeader{Name: archiveCreditsFile, Method: zip.Deflate, Modified: manifest.UpdatedAt})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(entry, archive.credits); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// writePhoto writes one photo at the tier and returns its size.
+func (s *ArchiveService) writePhoto(w io.Writer, photo *models.Photo, tier string) (int64, error) {
+	if tier == ArchiveTierLarge {
+		data, err := s.renderer.RenderJPEG(photo, s.largeSize, s.largeQuality)
+		if err != nil {
+			return 0, err
+		}
+		n, err := w.Write(data)
+		return int64(n), err
+	}
+
+	filename := filepath.Base(photo.URLOriginal)
+	if err := ValidateFilename(filename); err != nil {
+		return 0, err
+	}
+	// #nosec G304 - File path is from controlled upload directory
+	file, err := os.Open(filepath.Join(s.uploadDir, "originals", filename))
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = file.Close() }()
+	return io.Copy(w, file)
+}
+
+// archiveCacheName is the file name of an archive in the cache.
+func archiveCacheName(archive *Archive) string {
+	return fmt.Sprintf("%s-%s-%s.zip", archive.Album.ID, archive.Tier, archive.Key[:32])
+}
+
+// archiveEntryName names a photo in the archive by its position and upload
+// filename, so names are unique and sort in album order. Large JPEGs get a
+// .jpg extension.
+func archiveEntryName(index int, photo *models.Photo, tier string) string {
+	name := path.Base(strings.ReplaceAll(photo.FilenameOriginal, `\`, "/"))
+	if name == "." || name == "/" || ValidateFilename(name) != nil {
+		name = photo.ID + path.Ext(photo.URLOriginal)
+	}
+	if tier == ArchiveTierLarge {
+		name = strings.TrimSuffix(name, path.Ext(name)) + ".jpg"
+	}
+	return fmt.Sprintf("%03d-%s", index+1, name)
+}
+
+// archiveCredits writes the credits file: the album, the photographer and how
+// to reach them.
+func archiveCredits(album *models.Album, config *models.SiteConfig) string {
+	var b strings.Builder
+	b.WriteString(album.Title + "\n")
+	if album.Subtitle != "" {
+		b.WriteString(album.Subtitle + "\n")
+	}
+
+	photographer := config.Owner.Name
+	if photographer == "" {
+		photographer = config.Site.Title
+	}
+	if photographer != "" {
+		fmt.Fprintf(&b, "\nPhotographs by %s\n", photographer)
+	}
+	if config.Site.Title != "" && config.Site.Title != photographer {
+		b.WriteString(config.Site.Title + "\n")
+	}
+	if config.Owner.Email != "" {
+		fmt.Fprintf(&b, "Contact: %s\n", config.Owner.Email)
+	}
+
+	b.WriteString("\nPlease credit the photographer when sharing these photos.\n")
+	return b.String()
+}
+
+// cachingWriter writes to a client and a cache file. A failing client is
+// dropped and the cache keeps being written, and the other way round; it only
+// fails once both have.
+type cachingWriter struct {
+	client    io.Writer
+	cache     io.Writer
+	clientErr error
+	cacheErr  error
+}
+
+func (c *cachingWriter) Write(p []byte) (int, error) {
+	if c.cacheErr == nil {
+		if _, err := c.cache.Write(p); err != nil {
+			c.cacheErr = err
+		}
+	}
+	if c.clientErr == nil {
+		if _, err := c.client.Write(p); err != nil {
+			c.clientErr = err
+		}
+	}
+	if c.clientErr != nil && c.cacheErr != nil {
+		return 0, c.clientErr
+	}
+	return len(p), nil
+}
@@ -0,0 +1,205 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRenderer renders every photo as a fixed "JPEG" naming the photo.
+type fakeRenderer struct {
+	renders int
+}
+
+func (r *fakeRenderer) RenderJPEG(photo *models.Photo, maxSize, quality int) ([]byte, error) {
+	r.renders++
+	return []byte("large " + photo.ID), nil
+}
+
+// failingWriter stands in for a client that hangs up.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func setupArchiveService(t *testing.T) (*ArchiveService, *AlbumService, *models.Album, *fakeRenderer) {
+	uploadDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(uploadDir, "originals"), 0750))
+
+	fileService, err := NewFileService(t.TempDir())
+	require.NoError(t, err)
+	albumService := NewAlbumService(fileService)
+	configService := NewSiteConfigService(fileService)
+	renderer := &fakeRenderer{}
+	service, err := NewArchiveService(uploadDir, t.TempDir(), albumService, configService, renderer, 0, 0)
+	require.NoError(t, err)
+	albumService.SetPublisher(service)
+
+	album := &models.Album{Title: "Wedding", Visibility: "public", AllowDownloads: true}
+	require.NoError(t, albumService.Create(album))
+	var photoIDs []string
+	for _, name := range []string{"first", "second"} {
+		photo := &models.Photo{
+			FilenameOriginal: "IMG_" + name + ".JPG",
+			URLOriginal:      "/uploads/originals/" + name + ".jpg",
+			Caption:          "The " + name,
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(uploadDir, "originals", name+".jpg"), []byte("original "+name), 0600))
+		require.NoError(t, albumService.AddPhoto(album.ID, photo))
+		photoIDs = append([]string{photo.ID}, photoIDs...)
+	}
+	require.NoError(t, albumService.ReorderPhotos(album.ID, photoIDs), "second comes first")
+
+	album, err = albumService.GetByID(album.ID)
+	require.NoError(t, err)
+	return service, albumService, album, renderer
+}
+
+func readArchive(t *testing.T, data []byte) map[string]string {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	files := make(map[string]string)
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(rc)
+		require.NoError(t, err)
+		require.NoError(t, rc.Close())
+		files[file.Name] = string(content)
+	}
+	return files
+}
+
+func TestArchiveService_Original(t *testing.T) {
+	service, _, album, _ := setupArchiveService(t)
+
+	archive, err := service.Prepare(album, "")
+	require.NoError(t, err)
+	assert.Equal(t, ArchiveTierOriginal, archive.Tier)
+
+	var buf bytes.Buffer
+	require.NoError(t, service.Write(&buf, archive))
+
+	files := readArchive(t, buf.Bytes())
+	assert.Equal(t, "original second", files["001-IMG_second.JPG"], "photos are in album order")
+	assert.Equal(t, "original first", files["002-IMG_first.JPG"])
+	assert.Contains(t, files[archiveCreditsFile], "Wedding")
+
+	var manifest archiveManifest
+	require.NoError(t, json.Unmarshal([]byte(files[archiveManifestFile]), &manifest))
+	require.Len(t, manifest.Files, 2)
+	assert.Equal(t, "001-IMG_second.JPG", manifest.Files[0].Name)
+	assert.Equal(t, int64(len("original second")), manifest.Files[0].Size)
+	assert.Len(t, manifest.Files[0].SHA256, 64)
+	assert.Equal(t, "The second", manifest.Files[0].Caption)
+
+	// The archive was cached with the same bytes
+	file, err := service.Open(archive)
+	require.NoError(t, err)
+	defer func() { _ = file.Close() }()
+	cached, err := io.ReadAll(file)
+	require.NoError(t, err)
+	assert.Equal(t, buf.Bytes(), cached)
+}
+
+func TestArchiveService_Large(t *testing.T) {
+	service, _, album, renderer := setupArchiveService(t)
+
+	_, err := service.Prepare(album, "huge")
+	assert.ErrorIs(t, err, ErrUnknownArchiveTier)
+
+	archive, err := service.Prepare(album, ArchiveTierLarge)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, service.Write(&buf, archive))
+	assert.Equal(t, 2, renderer.renders)
+
+	files := readArchive(t, buf.Bytes())
+	var first string
+	for _, photo := range album.Photos {
+		if photo.FilenameOriginal == "IMG_first.JPG" {
+			first = photo.ID
+		}
+	}
+	assert.Equal(t, "large "+first, files["002-IMG_first.jpg"], "large JPEGs get a .jpg extension")
+
+	// Without a renderer there is no large tier
+	service.renderer = nil
+	_, err = service.Prepare(album, ArchiveTierLarge)
+	assert.ErrorIs(t, err, ErrUnknownArchiveTier)
+}
+
+func TestArchiveService_CacheInvalidation(t *testing.T) {
+	service, albumService, album, _ := setupArchiveService(t)
+
+	archive, err := service.Prepare(album, ArchiveTierOriginal)
+	require.NoError(t, err)
+	require.NoError(t, service.Write(io.Discard, archive))
+	cachePath := filepath.Join(service.cacheDir, archiveCacheName(archive))
+	assert.FileExists(t, cachePath)
+
+	// Publishing without changes keeps the archive
+	require.NoError(t, service.Publish())
+	assert.FileExists(t, cachePath)
+
+	// Changing the album invalidates it
+	album.Title = "Wedding day"
+	require.NoError(t, albumService.Update(album.ID, album))
+	assert.NoFileExists(t, cachePath)
+
+	changed, err := service.Prepare(album, ArchiveTierOriginal)
+	require.NoError(t, err)
+	assert.NotEqual(t, archive.Key, changed.Key)
+}
+
+func TestArchiveService_ClientHangsUp(t *testing.T) {
+	service, _, album, _ := setupArchiveService(t)
+
+	archive, err := service.Prepare(album, ArchiveTierOriginal)
+	require.NoError(t, err)
+
+	err = service.Write(failingWriter{}, archive)
+	assert.Error(t, err)
+
+	// The archive was still finished, so the download can resume from the cache
+	file, err := service.Open(archive)
+	require.NoError(t, err)
+	defer func() { _ = file.Close() }()
+	cached, err := io.ReadAll(file)
+	require.NoError(t, err)
+	assert.Len(t, readArchive(t, cached), 4)
+}
+
+func TestArchiveService_OneBuildAtATime(t *testing.T) {
+	service, _, album, _ := setupArchiveService(t)
+
+	archive, err := service.Prepare(album, ArchiveTierOriginal)
+	require.NoError(t, err)
+
+	// Another download is building the archive
+	name := archiveCacheName(archive)
+	cache, err := service.startCaching(name)
+	require.NoError(t, err)
+	require.NotNil(t, cache)
+
+	var out bytes.Buffer
+	err = service.Write(&out, archive)
+	assert.ErrorIs(t, err, ErrArchiveBuilding)
+	assert.Zero(t, out.Len(), "nothing is written while the archive is being built")
+
+	// Once that build has ended the archive can be built again
+	service.finishCaching(name, cache, false)
+	require.NoError(t, service.Write(&out, archive))
+	assert.Len(t, readArchive(t, out.Bytes()), 4)
+}
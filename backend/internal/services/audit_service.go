@@ -0,0 +1,285 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+)
+
+const (
+	auditFile = "audit.jsonl"
+	// maxAuditValueLength bounds the values in change summaries; longer ones
+	// are shortened.
+	maxAuditValueLength = 200
+	// DefaultAuditQueryLimit and MaxAuditQueryLimit bound the entries a query
+	// returns.
+	DefaultAuditQueryLimit = 100
+	MaxAuditQueryLimit     = 1000
+)
+
+// AuditConfig configures how long audit entries are kept.
+type AuditConfig struct {
+	// Retention is how long entries are kept. Zero keeps them forever.
+	Retention time.Duration
+	// MaxEntries bounds the log; the oldest entries are dropped first. Zero
+	// for no limit.
+	MaxEntries int
+}
+
+// DefaultAuditConfig returns the default audit retention settings.
+func DefaultAuditConfig() AuditConfig {
+	return AuditConfig{
+		Retention:  365 * 24 * time.Hour,
+		MaxEntries: 100000,
+	}
+}
+
+// AuditFilter selects audit entries. Empty fields match every entry.
+type AuditFilter struct {
+	Actor     string // Username (any case) or user ID
+	Action    string // Part of the action, in any case, e.g. "DELETE" or "/config"
+	AlbumID   string
+	PhotoID   string
+	RequestID string
+	Since     time.Time // Inclusive
+	Until     time.Time // Exclusive
+	Limit     int       // For Query; zero for DefaultAuditQueryLimit
+}
+
+// AuditService keeps the append-only log of admin actions in audit.jsonl.
+type AuditService struct {
+	fileService *FileService
+	config      AuditConfig
+	onReadError func(error)
+}
+
+// NewAuditService creates a new audit service.
+func NewAuditService(fileService *FileService, config AuditConfig) *AuditService {
+	return &AuditService{
+		fileService: fileService,
+		config:      config,
+	}
+}
+
+// SetReadErrorHandler sets the function told about lines of the log that
+// cannot be read, such as one cut short by a crash. Such lines are skipped.
+func (s *AuditService) SetReadErrorHandler(handler func(error)) {
+	s.onReadError = handler
+}
+
+// Record appends an entry to the log, setting its ID and, if unset, its time.
+func (s *AuditService) Record(entry *models.AuditEntry) error {
+	entry.ID = uuid.New().String()
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+	return s.fileService.AppendJSONL(auditFile, entry)
+}
+
+// Query returns the newest entries that match filter, newest first.
+func (s *AuditService) Query(filter AuditFilter) ([]models.AuditEntry, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultAuditQueryLimit
+	}
+	limit = min(limit, MaxAuditQueryLimit)
+
+	entries := []models.AuditEntry{}
+	err := s.each(filter, func(entry *models.AuditEntry, _ []byte) error {
+		entries = append(entries, *entry)
+		if len(entries) > limit {
+			entries = entries[1:]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+// Export streams every entry that matches filter to w as JSON Lines, oldest
+// first. The filter's limit is ignored.
+func (s *AuditService) Export(w io.Writer, filter AuditFilter) error {
+	return s.each(filter, func(_ *models.AuditEntry, line []byte) error {
+		if _, err := w.Write(line); err != nil {
+			return err
+		}
+		_, err := w.Write([]byte{'\n'})
+		return err
+	})
+}
+
+// Prune removes the entries older than the retention period, and the oldest
+// ones over the maximum number, and returns how many were removed.
+func (s *AuditService) Prune() (int, error) {
+	excess := 0
+	if s.config.MaxEntries > 0 {
+		count := 0
+		if err := s.fileService.ReadJSONL(auditFile, func([]byte) error {
+			count++
+			return nil
+		}); err != nil {
+			return 0, err
+		}
+		excess = count - s.config.MaxEntries
+	}
+	if excess <= 0 && s.config.Retention <= 0 {
+		return 0, nil
+	}
+
+	cutoff := time.Now().Add(-s.config.Retention)
+	seen := 0
+	return s.fileService.FilterJSONL(auditFile, func(line []byte) bool {
+		seen++
+		if seen <= excess {
+			return false
+		}
+		if s.config.Retention <= 0 {
+			return true
+		}
+		var entry struct {
+			Time time.Time `json:"time"`
+		}
+		// Lines that cannot be read are kept rather than lost
+		return json.Unmarshal(line, &entry) != nil || !entry.Time.Before(cutoff)
+	})
+}
+
+// each calls fn with each entry that matches filter, oldest first, until fn
+// returns an error. Lines that cannot be read are skipped, as Prune keeps
+// them, and reported to the read error handler.
+func (s *AuditService) each(filter AuditFilter, fn func(entry *models.AuditEntry, line []byte) error) error {
+	lineNumber := 0
+	return s.fileService.ReadJSONLSnapshot(auditFile, func(line []byte) error {
+		lineNumber++
+		var entry models.AuditEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			if s.onReadError != nil {
+				s.onReadError(fmt.Errorf("failed to read audit entry on line %d: %w", lineNumber, err))
+			}
+			return nil
+		}
+		if filter.matches(&entry) {
+			return fn(&entry, line)
+		}
+		return nil
+	})
+}
+
+func (f *AuditFilter) matches(entry *models.AuditEntry) bool {
+	switch {
+	case f.Actor != "" && !strings.EqualFold(entry.Username, f.Actor) && entry.UserID != f.Actor:
+		return false
+	case f.Action != "" && !strings.Contains(strings.ToLower(entry.Action), strings.ToLower(f.Action)):
+		return false
+	case f.AlbumID != "" && entry.AlbumID != f.AlbumID:
+		return false
+	case f.PhotoID != "" && entry.PhotoID != f.PhotoID:
+		return false
+	case f.RequestID != "" && entry.RequestID != f.RequestID:
+		return false
+	case !f.Since.IsZero() && entry.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && !entry.Time.Before(f.Until):
+		return false
+	}
+	return true
+}
+
+// AuditDiff summarizes a change for the audit log: the fields of before and
+// after that differ, by their JSON names, with nested objects flattened to
+// dotted paths such as "site.title". Either may be nil, for creations and
+// deletions. Values of hashes and secrets are redacted, and long values
+// shortened.
+func AuditDiff(before, after interface{}) (map[string]interface{}, map[string]interface{}, error) {
+	beforeFields, err := auditFields(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	afterFields, err := auditFields(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	beforeChanged := make(map[string]interface{})
+	afterChanged := make(map[string]interface{})
+	for path, value := range beforeFields {
+		if other, ok := afterFields[path]; !ok || !reflect.DeepEqual(value, other) {
+			beforeChanged[path] = auditValue(path, value)
+		}
+	}
+	for path, value := range afterFields {
+		if other, ok := beforeFields[path]; !ok || !reflect.DeepEqual(value, other) {
+			afterChanged[path] = auditValue(path, value)
+		}
+	}
+	return beforeChanged, afterChanged, nil
+}
+
+// auditFields flattens v's JSON fields to dotted paths.
+func auditFields(v interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if v == nil {
+		return fields, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	object, ok := decoded.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("audit summaries must be JSON objects")
+	}
+	flattenAuditFields("", object, fields)
+	return fields, nil
+}
+
+func flattenAuditFields(prefix string, object map[string]interface{}, fields map[string]interface{}) {
+	for key, value := range object {
+		if nested, ok := value.(map[string]interface{}); ok && len(nested) > 0 {
+			flattenAuditFields(prefix+key+".", nested, fields)
+			continue
+		}
+		fields[prefix+key] = value
+	}
+}
+
+// auditValue redacts the values of hashes and secrets, keeping whether they
+// are set, and shortens long values.
+func auditValue(path string, value interface{}) interface{} {
+	name := path[strings.LastIndex(path, ".")+1:]
+	if strings.HasSuffix(name, "_hash") || strings.HasSuffix(name, "_hashes") || strings.Contains(name, "secret") {
+		if value == nil || value == "" {
+			return value
+		}
+		return "[redacted]"
+	}
+
+	switch v := value.(type) {
+	case string:
+		if runes := []rune(v); len(runes) > maxAuditValueLength {
+			return string(runes[:maxAuditValueLength]) + "…"
+		}
+	case []interface{}:
+		if data, err := json.Marshal(v); err != nil || len(data) > maxAuditValueLength {
+			return fmt.Sprintf("[%d items]", len(v))
+		}
+	}
+	return value
+}
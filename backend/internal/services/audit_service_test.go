@@ -0,0 +1,207 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupAuditService(t *testing.T, config AuditConfig) (*AuditService, *FileService) {
+	fileService, err := NewFileService(t.TempDir())
+	require.NoError(t, err)
+	return NewAuditService(fileService, config), fileService
+}
+
+// recordAudit records entries with the given actions, an hour apart and
+// ending now, for album-1 and then album-2 alternately.
+func recordAudit(t *testing.T, service *AuditService, actions ...string) {
+	start := time.Now().UTC().Add(-time.Duration(len(actions)-1) * time.Hour)
+	for i, action := range actions {
+		entry := &models.AuditEntry{
+			Time:     start.Add(time.Duration(i) * time.Hour),
+			UserID:   "user-1",
+			Username: "Owner",
+			Role:     models.RoleOwner,
+			Action:   action,
+			AlbumID:  []string{"album-1", "album-2"}[i%2],
+			Status:   200,
+		}
+		require.NoError(t, service.Record(entry))
+		require.NotEmpty(t, entry.ID)
+	}
+}
+
+func auditActions(entries []models.AuditEntry) []string {
+	actions := []string{}
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+	}
+	return actions
+}
+
+func TestAuditService_Query(t *testing.T) {
+	service, _ := setupAuditService(t, DefaultAuditConfig())
+
+	entries, err := service.Query(AuditFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, entries, "no log yet")
+
+	recordAudit(t, service,
+		"POST /api/admin/albums",
+		"PUT /api/admin/albums/{id}",
+		"DELETE /api/admin/albums/{id}",
+		"PUT /api/admin/config",
+	)
+
+	// Newest first
+	entries, err = service.Query(AuditFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"PUT /api/admin/config", "DELETE /api/admin/albums/{id}", "PUT /api/admin/albums/{id}", "POST /api/admin/albums"}, auditActions(entries))
+
+	entries, err = service.Query(AuditFilter{Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"PUT /api/admin/config", "DELETE /api/admin/albums/{id}"}, auditActions(entries))
+
+	entries, err = service.Query(AuditFilter{Action: "delete"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DELETE /api/admin/albums/{id}"}, auditActions(entries))
+
+	entries, err = service.Query(AuditFilter{AlbumID: "album-1"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DELETE /api/admin/albums/{id}", "POST /api/admin/albums"}, auditActions(entries))
+
+	entries, err = service.Query(AuditFilter{Actor: "owner"})
+	require.NoError(t, err)
+	assert.Len(t, entries, 4)
+	entries, err = service.Query(AuditFilter{Actor: "user-2"})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	// Since is inclusive, until exclusive
+	all, err := service.Query(AuditFilter{})
+	require.NoError(t, err)
+	entries, err = service.Query(AuditFilter{Since: all[2].Time, Until: all[0].Time})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DELETE /api/admin/albums/{id}", "PUT /api/admin/albums/{id}"}, auditActions(entries))
+}
+
+func TestAuditService_Export(t *testing.T) {
+	service, _ := setupAuditService(t, DefaultAuditConfig())
+	recordAudit(t, service, "POST /api/admin/albums", "PUT /api/admin/config", "DELETE /api/admin/albums/{id}")
+
+	// Oldest first, one entry per line
+	var out bytes.Buffer
+	require.NoError(t, service.Export(&out, AuditFilter{Action: "albums", Limit: 1}))
+	actions := []string{}
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var entry models.AuditEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+		actions = append(actions, entry.Action)
+	}
+	assert.Equal(t, []string{"POST /api/admin/albums", "DELETE /api/admin/albums/{id}"}, actions)
+}
+
+func TestAuditService_UnreadableLines(t *testing.T) {
+	service, fileService := setupAuditService(t, DefaultAuditConfig())
+	var readErrors []error
+	service.SetReadErrorHandler(func(err error) { readErrors = append(readErrors, err) })
+
+	// An append cut short by a crash, followed by later entries
+	recordAudit(t, service, "POST /api/admin/albums")
+	f, err := os.OpenFile(filepath.Join(fileService.dataDir, auditFile), os.O_WRONLY|os.O_APPEND, 0600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"id":"cut-short","action":"PUT /api/ad` + "\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	recordAudit(t, service, "PUT /api/admin/config")
+
+	entries, err := service.Query(AuditFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"PUT /api/admin/config", "POST /api/admin/albums"}, auditActions(entries))
+	require.Len(t, readErrors, 1)
+	assert.Contains(t, readErrors[0].Error(), "line 2")
+
+	var out bytes.Buffer
+	require.NoError(t, service.Export(&out, AuditFilter{}))
+	assert.Equal(t, 2, strings.Count(out.String(), "\n"))
+	assert.NotContains(t, out.String(), "cut-short")
+}
+
+func TestAuditService_Prune(t *testing.T) {
+	service, fileService := setupAuditService(t, AuditConfig{Retention: 210 * time.Minute, MaxEntries: 3})
+
+	removed, err := service.Prune()
+	require.NoError(t, err)
+	assert.Zero(t, removed, "no log yet")
+
+	// Entries from five hours ago to now, and a late one from long ago: four
+	// are over the maximum, and the late one is past the retention
+	recordAudit(t, service, "a", "b", "c", "d", "e", "f")
+	require.NoError(t, fileService.AppendJSONL(auditFile, &models.AuditEntry{Time: time.Now().Add(-100 * time.Hour), Action: "late"}))
+
+	removed, err = service.Prune()
+	require.NoError(t, err)
+	assert.Equal(t, 5, removed)
+	entries, err := service.Query(AuditFilter{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"f", "e"}, auditActions(entries))
+
+	// Nothing more to remove
+	removed, err = service.Prune()
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+
+	// Without limits everything is kept
+	service.config = AuditConfig{}
+	recordAudit(t, service, "g", "h", "i", "j")
+	removed, err = service.Prune()
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+}
+
+func TestAuditDiff(t *testing.T) {
+	before := &models.Album{ID: "album-1", Title: "Wedding", Visibility: "public", Proofing: &models.Proofing{}}
+	after := *before
+	after.Visibility = "password_protected"
+	after.PasswordHash = "$argon2id$v=19$m=19456,t=2,p=1$c2FsdA$a2V5"
+	after.Description = strings.Repeat("x", 300)
+
+	beforeChanged, afterChanged, err := AuditDiff(before, &after)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"visibility": "public"}, beforeChanged)
+	assert.Equal(t, "password_protected", afterChanged["visibility"])
+	assert.Equal(t, "[redacted]", afterChanged["password_hash"])
+	assert.Len(t, []rune(afterChanged["description"].(string)), maxAuditValueLength+1)
+	assert.Len(t, afterChanged, 3)
+
+	// Nested objects are flattened
+	beforeConfig := &models.SiteConfig{Site: models.SiteInfo{Title: "Old", Language: "en"}}
+	afterConfig := &models.SiteConfig{Site: models.SiteInfo{Title: "New", Language: "en"}}
+	beforeChanged, afterChanged, err = AuditDiff(beforeConfig, afterConfig)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"site.title": "Old"}, beforeChanged)
+	assert.Equal(t, map[string]interface{}{"site.title": "New"}, afterChanged)
+
+	// Deletions keep every field, with secrets redacted
+	user := &models.User{ID: "user-1", Username: "sam", PasswordHash: "hash", TOTPSecret: "secret", RecoveryCodeHashes: []string{"a"}}
+	beforeChanged, afterChanged, err = AuditDiff(user, nil)
+	require.NoError(t, err)
+	assert.Empty(t, afterChanged)
+	assert.Equal(t, "sam", beforeChanged["username"])
+	assert.Equal(t, "[redacted]", beforeChanged["password_hash"])
+	assert.Equal(t, "[redacted]", beforeChanged["totp_secret"])
+	assert.Equal(t, "[redacted]", beforeChanged["recovery_code_hashes"])
+
+	_, _, err = AuditDiff([]string{"not", "an", "object"}, nil)
+	assert.Error(t, err)
+}
@@ -1,184 +1,503 @@
 package services
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
 	"fmt"
-	"log/slog"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
-	"golang.org/x/crypto/bcrypt"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
 )
 
-// Session represents an authenticated session.
+// sessionWriteInterval is how far a session's expiry must move before the
+// extension is written to the session store, so that file-backed stores are
+// not rewritten on every request.
+const sessionWriteInterval = time.Minute
+
+// maxUserAgentLength is how much of a client's user agent is kept with its
+// session.
+const maxUserAgentLength = 256
+
+const (
+	// loginChallengeTTL is how long a user has to enter their two-factor code
+	// after their password.
+	loginChallengeTTL = 5 * time.Minute
+	// maxChallengeAttempts is how many wrong codes end a login challenge.
+	maxChallengeAttempts = 5
+)
+
+// TwoFactorRequiredError is returned by Authenticate when the password is
+// right but the user has two-factor authentication enabled. The login is
+// completed by CompleteTwoFactor with the challenge and a code.
+type TwoFactorRequiredError struct {
+	Challenge string
+}
+
+func (e *TwoFactorRequiredError) Error() string {
+	return "two-factor authentication required"
+}
+
+// loginChallenge is a login waiting for its second factor.
+type loginChallenge struct {
+	userID    string
+	expiresAt time.Time
+	attempts  int
+}
+
+// Session represents an authenticated session. Its ID is the hash of the
+// session token that the client holds. Its CSRF token must accompany the
+// session's state-changing requests. Requests made with an API token get a
+// session too, which is not stored and has the token's ID and scopes.
 type Session struct {
-	ID        string
-	Username  string
-	CreatedAt time.Time
-	ExpiresAt time.Time
+	ID         string      `json:"id"`
+	UserID     string      `json:"user_id"`
+	Username   string      `json:"username"`
+	Role       models.Role `json:"role"`
+	CSRFToken  string      `json:"csrf_token,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	ExpiresAt  time.Time   `json:"expires_at"`
+	LastSeenAt time.Time   `json:"last_seen_at"`         // Accurate to sessionWriteInterval
+	IPHash     string      `json:"ip_hash,omitempty"`    // Client IP at sign-in, hashed
+	UserAgent  string      `json:"user_agent,omitempty"` // Client user agent at sign-in
+
+	TokenID string         `json:"-"`
+	Scopes  []models.Scope `json:"-"`
+}
+
+// IsAPIToken reports whether the session comes from an API token.
+func (s *Session) IsAPIToken() bool {
+	return s.TokenID != ""
+}
+
+// HasScope reports whether the session may use the scope. Browser sessions
+// have every scope.
+func (s *Session) HasScope(scope models.Scope) bool {
+	if !s.IsAPIToken() {
+		return true
+	}
+	for _, sc := range s.Scopes {
+		if sc == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Sanitized returns a copy of the session without its CSRF token, for listing
+// a user's sessions.
+func (s Session) Sanitized() Session {
+	s.CSRFToken = ""
+	return s
 }
 
 // AuthService handles authentication and session management.
 type AuthService struct {
-	username     string
-	passwordHash string
-	sessions     map[string]*Session
-	mu           sync.RWMutex
-	sessionTTL   time.Duration
-	fileService  *FileService
-	configFile   string
+	users       *UserService
+	store       SessionStore
+	tokens      *APITokenService
+	oidc        *OIDCService
+	sessionTTL  time.Duration
+	maxLifetime time.Duration
+	mu          sync.Mutex
+	challenges  map[string]*loginChallenge // By challenge token hash
 }
 
-// NewAuthService creates a new auth service.
-func NewAuthService(username, passwordHash string, sessionTTL time.Duration) *AuthService { // pragma: allowlist secret
+// NewAuthService creates a new auth service for the accounts in users. Sessions
+// are kept in memory until SetSessionStore is called.
+func NewAuthService(users *UserService, sessionTTL time.Duration) *AuthService {
 	return &AuthService{
-		username:     username,
-		passwordHash: passwordHash, // pragma: allowlist secret
-		sessions:     make(map[string]*Session),
-		sessionTTL:   sessionTTL,
-		fileService:  nil,
-		configFile:   "",
+		users:      users,
+		store:      NewMemorySessionStore(),
+		sessionTTL: sessionTTL,
+		challenges: make(map[string]*loginChallenge),
 	}
 }
 
-// SetConfigPersistence configures the auth service to persist password changes to disk.
-func (s *AuthService) SetConfigPersistence(fileService *FileService, configFile string) {
-	s.fileService = fileService
-	s.configFile = configFile
+// SetSessionStore sets where sessions are kept.
+func (s *AuthService) SetSessionStore(store SessionStore) {
+	s.store = store
 }
 
-// Authenticate verifies credentials and creates a session.
+// SetAPITokenService enables authentication with API tokens.
+func (s *AuthService) SetAPITokenService(tokens *APITokenService) {
+	s.tokens = tokens
+}
+
+// SetOIDCService enables single sign-on with an OpenID Connect identity
+// provider.
+func (s *AuthService) SetOIDCService(oidc *OIDCService) {
+	s.oidc = oidc
+}
+
+// OIDCEnabled reports whether single sign-on is enabled.
+func (s *AuthService) OIDCEnabled() bool {
+	return s.oidc != nil
+}
+
+// SetMaxSessionLifetime limits how long a session lasts from sign-in, however
+// active it is. Zero, the default, lets sessions last as long as they are used
+// within the session TTL.
+func (s *AuthService) SetMaxSessionLifetime(maxLifetime time.Duration) {
+	s.maxLifetime = maxLifetime
+}
+
+// Authenticate verifies credentials and creates a session. It returns the
+// session token for the client; only its hash is stored. For users with
+// two-factor authentication it returns a TwoFactorRequiredError instead.
 func (s *AuthService) Authenticate(username, password string) (string, error) { // pragma: allowlist secret
-	// Debug logging
-	slog.Info("authenticate attempt",
-		slog.String("username", username),
-		slog.String("stored_username", s.username),
-		slog.Int("hash_length", len(s.passwordHash)),
-		slog.Int("password_length", len(password)),
-	)
+	user, err := s.users.Authenticate(username, password)
+	if err != nil {
+		return "", err
+	}
 
-	// Check username
-	if username != s.username {
-		slog.Warn("username mismatch")
-		return "", errors.New("invalid credentials")
+	if user.TOTPEnabled {
+		challenge, err := generateSessionID()
+		if err != nil {
+			return "", fmt.Errorf("failed to generate login challenge: %w", err)
+		}
+		s.mu.Lock()
+		s.challenges[hashSessionToken(challenge)] = &loginChallenge{
+			userID:    user.ID,
+			expiresAt: time.Now().Add(loginChallengeTTL),
+		}
+		s.mu.Unlock()
+		return "", &TwoFactorRequiredError{Challenge: challenge}
 	}
 
-	// Check password
-	if err := bcrypt.CompareHashAndPassword([]byte(s.passwordHash), []byte(password)); err != nil {
-		slog.Warn("password hash comparison failed", slog.String("error", err.Error()))
-		return "", errors.New("invalid credentials")
+	return s.createSession(user)
+}
+
+// CompleteTwoFactor finishes a login that Authenticate answered with a
+// TwoFactorRequiredError, given the same username, the challenge and a TOTP or
+// recovery code. It returns the session token. Unknown and expired challenges
+// and wrong codes give ErrInvalidCredentials; too many attempts end the
+// challenge. Attempts are counted before the code is checked, so attempts made
+// in parallel are limited as well.
+func (s *AuthService) CompleteTwoFactor(username, challenge, code string) (string, error) {
+	key := hashSessionToken(challenge)
+	s.mu.Lock()
+	pending, ok := s.challenges[key]
+	if ok && time.Now().After(pending.expiresAt) {
+		delete(s.challenges, key)
+		ok = false
+	}
+	// Attempts over the limit are turned away; the challenge itself ends once
+	// a wrong code has used it up
+	if ok && pending.attempts >= maxChallengeAttempts {
+		ok = false
+	}
+	if ok {
+		pending.attempts++
+	}
+	s.mu.Unlock()
+	if !ok {
+		return "", ErrInvalidCredentials
 	}
 
-	// Create session
-	sessionID, err := generateSessionID()
+	user, err := s.users.Get(pending.userID)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate session ID: %w", err)
+		if errors.Is(err, ErrUserNotFound) {
+			return "", ErrInvalidCredentials
+		}
+		return "", err
+	}
+	if !strings.EqualFold(user.Username, username) {
+		return "", ErrInvalidCredentials
 	}
 
-	session := &Session{
-		ID:        sessionID,
-		Username:  username,
-		CreatedAt: time.Now(),
-		ExpiresAt: time.Now().Add(s.sessionTTL),
+	user, err = s.users.VerifySecondFactor(user.ID, code)
+	if err != nil {
+		if errors.Is(err, ErrInvalidTOTPCode) {
+			s.mu.Lock()
+			if pending.attempts >= maxChallengeAttempts && s.challenges[key] == pending {
+				delete(s.challenges, key)
+			}
+			s.mu.Unlock()
+			return "", ErrInvalidCredentials
+		}
+		return "", err
 	}
 
+	// The challenge is single-use, also when codes for it are checked in parallel
 	s.mu.Lock()
-	s.sessions[sessionID] = session
+	current, ok := s.challenges[key]
+	if ok && current == pending {
+		delete(s.challenges, key)
+	}
 	s.mu.Unlock()
+	if !ok || current != pending {
+		return "", ErrInvalidCredentials
+	}
+	return s.createSession(user)
+}
 
-	return sessionID, nil
+// StartOIDCLogin begins a single sign-on login. It returns the identity
+// provider URL to send the user to and the state that must come back with the
+// callback.
+func (s *AuthService) StartOIDCLogin(ctx context.Context) (string, string, error) {
+	if s.oidc == nil {
+		return "", "", errors.New("single sign-on is not enabled")
+	}
+	return s.oidc.StartLogin(ctx)
 }
 
-// ValidateSession checks if a session is valid and extends it.
-func (s *AuthService) ValidateSession(sessionID string) (*Session, error) {
-	s.mu.RLock()
-	session, exists := s.sessions[sessionID]
-	s.mu.RUnlock()
+// CompleteOIDCLogin finishes a single sign-on login with the state and code
+// from the identity provider's callback, and returns the session token. Users
+// signed in by the identity provider skip local two-factor authentication.
+func (s *AuthService) CompleteOIDCLogin(ctx context.Context, state, code string) (string, error) {
+	if s.oidc == nil {
+		return "", errors.New("single sign-on is not enabled")
+	}
+	identity, err := s.oidc.CompleteLogin(ctx, state, code)
+	if err != nil {
+		return "", err
+	}
+	user, err := s.users.LoginOIDC(identity)
+	if err != nil {
+		return "", err
+	}
+	return s.createSession(user)
+}
+
+// createSession starts a session for a user and returns its token.
+func (s *AuthService) createSession(user *models.User) (string, error) {
+	token, err := generateSessionID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	csrfToken, err := generateSessionID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate CSRF token: %w", err)
+	}
+
+	now := time.Now()
+	session := &Session{
+		ID:         hashSessionToken(token),
+		UserID:     user.ID,
+		Username:   user.Username,
+		Role:       user.Role,
+		CSRFToken:  csrfToken,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+	session.ExpiresAt = s.expiry(session, now)
+
+	if err := s.store.Put(session); err != nil {
+		return "", err
+	}
 
-	if !exists {
-		return nil, errors.New("invalid session")
+	return token, nil
+}
+
+// ValidateSession checks if a session token is valid and extends its session.
+// The session's username and role are refreshed from the user store, so
+// changes to a user apply to their sessions straight away.
+func (s *AuthService) ValidateSession(token string) (*Session, error) {
+	session, err := s.store.Get(hashSessionToken(token))
+	if err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			return nil, errors.New("invalid session")
+		}
+		return nil, err
 	}
 
 	// Check if expired
-	if time.Now().After(session.ExpiresAt) {
-		s.mu.Lock()
-		delete(s.sessions, sessionID)
-		s.mu.Unlock()
+	now := time.Now()
+	if now.After(session.ExpiresAt) {
+		_ = s.store.Delete(session.ID)
 		return nil, errors.New("session expired")
 	}
 
+	user, err := s.users.Get(session.UserID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			_ = s.store.Delete(session.ID)
+			return nil, errors.New("invalid session")
+		}
+		return nil, err
+	}
+
+	// Sessions stored before CSRF tokens existed get one now
+	missingCSRF := session.CSRFToken == ""
+	if missingCSRF {
+		if session.CSRFToken, err = generateSessionID(); err != nil {
+			return nil, fmt.Errorf("failed to generate CSRF token: %w", err)
+		}
+	}
+
 	// Extend session
-	s.mu.Lock()
-	session.ExpiresAt = time.Now().Add(s.sessionTTL)
-	s.mu.Unlock()
+	expiresAt := s.expiry(session, now)
+	if missingCSRF || user.Username != session.Username || user.Role != session.Role ||
+		expiresAt.Sub(session.ExpiresAt) >= sessionWriteInterval ||
+		now.Sub(session.LastSeenAt) >= sessionWriteInterval {
+		session.Username = user.Username
+		session.Role = user.Role
+		session.ExpiresAt = expiresAt
+		session.LastSeenAt = now
+		if err := s.store.Put(session); err != nil {
+			return nil, err
+		}
+	}
 
 	return session, nil
 }
 
-// InvalidateSession removes a session (logout).
-func (s *AuthService) InvalidateSession(sessionID string) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// ValidateAPIToken checks an API token and returns a session for its user
+// with the token's scopes. The user's current role applies, so a token can
+// never do more than its user.
+func (s *AuthService) ValidateAPIToken(secret string) (*Session, error) {
+	if s.tokens == nil {
+		return nil, ErrInvalidAPIToken
+	}
+	token, err := s.tokens.Authenticate(secret)
+	if err != nil {
+		return nil, err
+	}
 
-	delete(s.sessions, sessionID)
-}
+	user, err := s.users.Get(token.UserID)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, ErrInvalidAPIToken
+		}
+		return nil, err
+	}
 
-// CleanupExpiredSessions removes expired sessions.
-func (s *AuthService) CleanupExpiredSessions() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	session := &Session{
+		UserID:    user.ID,
+		Username:  user.Username,
+		Role:      user.Role,
+		CreatedAt: token.CreatedAt,
+		TokenID:   token.ID,
+		Scopes:    token.Scopes,
+	}
+	if token.ExpiresAt != nil {
+		session.ExpiresAt = *token.ExpiresAt
+	}
+	return session, nil
+}
 
-	now := time.Now()
-	for id, session := range s.sessions {
-		if now.After(session.ExpiresAt) {
-			delete(s.sessions, id)
+// RecordSessionClient stores the client IP, hashed, and user agent that
+// signed in to a token's session, so that users can tell their sessions
+// apart. It returns the session.
+func (s *AuthService) RecordSessionClient(token, clientIP, userAgent string) (*Session, error) {
+	session, err := s.store.Get(hashSessionToken(token))
+	if err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			return nil, errors.New("invalid session")
 		}
+		return nil, err
 	}
+
+	session.IPHash = hashClientIP(clientIP)
+	// User agents are sent by the client, so keep them short
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	session.UserAgent = userAgent
+	if err := s.store.Put(session); err != nil {
+		return nil, err
+	}
+	return session, nil
 }
 
-// ChangePassword updates the admin password.
-func (s *AuthService) ChangePassword(oldPassword, newPassword string) error {
-	// Verify old password
-	if err := bcrypt.CompareHashAndPassword([]byte(s.passwordHash), []byte(oldPassword)); err != nil {
-		return errors.New("invalid current password")
+// ListUserSessions returns a user's unexpired sessions, most recently seen
+// first.
+func (s *AuthService) ListUserSessions(userID string) ([]Session, error) {
+	now := time.Now()
+	sessions, err := s.store.List(func(session *Session) bool {
+		return session.UserID == userID && now.Before(session.ExpiresAt)
+	})
+	if err != nil {
+		return nil, err
 	}
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
 
-	// Hash new password
-	newHash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+// RevokeSession signs out one of a user's sessions by its ID.
+func (s *AuthService) RevokeSession(userID, id string) error {
+	session, err := s.store.Get(id)
 	if err != nil {
-		return fmt.Errorf("failed to hash password: %w", err)
+		if errors.Is(err, ErrSessionNotFound) {
+			return errors.New("session not found")
+		}
+		return err
 	}
+	if session.UserID != userID {
+		return errors.New("session not found")
+	}
+	return s.store.Delete(id)
+}
 
-	s.passwordHash = string(newHash)
+// InvalidateSession removes the session of a session token (logout).
+func (s *AuthService) InvalidateSession(token string) {
+	_ = s.store.Delete(hashSessionToken(token))
+}
 
-	// Persist to disk if configured
-	if s.fileService != nil && s.configFile != "" {
-		// Import models package to use AdminConfig struct
-		config := struct {
-			Username     string `json:"username"`
-			PasswordHash string `json:"password_hash"` // pragma: allowlist secret
-		}{
-			Username:     s.username,
-			PasswordHash: s.passwordHash, // pragma: allowlist secret
-		}
+// InvalidateUserSessions removes every session of a user except the one with
+// ID keepSessionID, which may be empty.
+func (s *AuthService) InvalidateUserSessions(userID, keepSessionID string) {
+	_ = s.store.DeleteWhere(func(session *Session) bool {
+		return session.UserID == userID && session.ID != keepSessionID
+	})
+}
 
-		if err := s.fileService.WriteJSON(s.configFile, config); err != nil {
-			// Log the error but don't fail the password change
-			// The in-memory password is already updated
-			slog.Error("failed to persist password change to disk",
-				slog.String("error", err.Error()),
-				slog.String("file", s.configFile),
-			)
-			return fmt.Errorf("password changed in memory but failed to save to disk: %w", err)
+// CleanupExpiredSessions removes expired sessions, login challenges and
+// single sign-on logins.
+func (s *AuthService) CleanupExpiredSessions() error {
+	now := time.Now()
+	s.mu.Lock()
+	for key, pending := range s.challenges {
+		if now.After(pending.expiresAt) {
+			delete(s.challenges, key)
 		}
 	}
+	s.mu.Unlock()
+	if s.oidc != nil {
+		s.oidc.CleanupExpired()
+	}
+
+	return s.store.DeleteWhere(func(session *Session) bool {
+		return now.After(session.ExpiresAt)
+	})
+}
 
+// ChangePassword updates the password of the session's user. The user's other
+// sessions are signed out.
+func (s *AuthService) ChangePassword(session *Session, oldPassword, newPassword string) error {
+	if err := s.users.ChangePassword(session.UserID, oldPassword, newPassword); err != nil {
+		return err
+	}
+	s.InvalidateUserSessions(session.UserID, session.ID)
 	return nil
 }
 
-// generateSessionID generates a cryptographically secure session ID.
+// expiry returns when a session used at now expires: after the session TTL,
+// but no later than the maximum lifetime allows.
+func (s *AuthService) expiry(session *Session, now time.Time) time.Time {
+	expiresAt := now.Add(s.sessionTTL)
+	if s.maxLifetime > 0 {
+		if limit := session.CreatedAt.Add(s.maxLifetime); expiresAt.After(limit) {
+			expiresAt = limit
+		}
+	}
+	return expiresAt
+}
+
+// hashClientIP returns a short hash of a client IP, which tells sessions from
+// different addresses apart without storing the address.
+func hashClientIP(ip string) string {
+	return hashSessionToken(ip)[:16]
+}
+
+// generateSessionID generates a cryptographically secure session token.
 func generateSessionID() (string, error) {
 	b := make([]byte, 32)
 	if _, err := rand.Read(b); err != nil {
@@ -186,12 +505,3 @@ func generateSessionID() (string, error) {
 	}
 	return base64.URLEncoding.EncodeToString(b), nil
 }
-
-// HashPassword hashes a password using bcrypt.
-func HashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return "", err
-	}
-	return string(hash), nil
-}
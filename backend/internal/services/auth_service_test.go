@@ -1,20 +1,44 @@
 package services
 
 import (
-	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 func setupAuthService(t *testing.T) *AuthService {
-	// Create an auth service with a test password hash
+	// Create an auth service with an owner migrated from a test admin config
 	// Password is "test123"
 	// pragma: allowlist secret
 	testHash := "$2a$10$VPqUwu5tQ8xAsqdRFgzibeVQVewjXsBkKuhJClOVqpeGflWYwLZKm"
-	return NewAuthService("testuser", testHash, 24*time.Hour)
+	fileService, err := NewFileService(t.TempDir())
+	require.NoError(t, err)
+	users := NewUserService(fileService)
+	_, err = users.Migrate(models.AdminConfig{Username: "testuser", PasswordHash: testHash})
+	require.NoError(t, err)
+	return NewAuthService(users, 24*time.Hour)
+}
+
+// login authenticates as testuser and returns the session token and the
+// validated session.
+func login(t *testing.T, service *AuthService, password string) (string, *Session) {
+	token, err := service.Authenticate("testuser", password)
+	require.NoError(t, err)
+	session, err := service.ValidateSession(token)
+	require.NoError(t, err)
+	return token, session
+}
+
+// expireSession moves a session's expiry into the past.
+func expireSession(t *testing.T, service *AuthService, token string) {
+	session, err := service.store.Get(hashSessionToken(token))
+	require.NoError(t, err)
+	session.ExpiresAt = time.Now().Add(-1 * time.Hour)
+	require.NoError(t, service.store.Put(session))
 }
 
 func TestNewAuthService(t *testing.T) {
@@ -75,12 +99,7 @@ func TestAuthService_ValidateSession_Expired(t *testing.T) {
 	require.NoError(t, err)
 
 	// Manually expire the session by setting its time to the past
-	service.mu.Lock()
-	if sess, exists := service.sessions[sessionID]; exists {
-		sess.ExpiresAt = time.Now().Add(-1 * time.Hour)
-		service.sessions[sessionID] = sess
-	}
-	service.mu.Unlock()
+	expireSession(t, service, sessionID)
 
 	// Validate should fail
 	_, err = service.ValidateSession(sessionID)
@@ -109,11 +128,19 @@ func TestAuthService_InvalidateSession(t *testing.T) {
 
 func TestAuthService_ChangePassword(t *testing.T) {
 	service := setupAuthService(t)
+	token, session := login(t, service, "test123")
+	otherToken, _ := login(t, service, "test123")
 
 	// Change password
-	err := service.ChangePassword("test123", "newpassword456")
+	err := service.ChangePassword(session, "test123", "newpassword456")
 	require.NoError(t, err)
 
+	// The user's other sessions are signed out
+	_, err = service.ValidateSession(token)
+	assert.NoError(t, err)
+	_, err = service.ValidateSession(otherToken)
+	assert.Error(t, err)
+
 	// Old password should not work
 	_, err = service.Authenticate("testuser", "test123")
 	assert.Error(t, err)
@@ -124,10 +151,46 @@ func TestAuthService_ChangePassword(t *testing.T) {
 	assert.NotEmpty(t, sessionID)
 }
 
+func TestAuthService_ListAndRevokeSessions(t *testing.T) {
+	service := setupAuthService(t)
+	token, session := login(t, service, "test123")
+	otherToken, other := login(t, service, "test123")
+	expiredToken, _ := login(t, service, "test123")
+
+	recorded, err := service.RecordSessionClient(token, "192.0.2.1", "Firefox/130.0")
+	require.NoError(t, err)
+	assert.Equal(t, hashClientIP("192.0.2.1"), recorded.IPHash)
+	assert.NotContains(t, recorded.IPHash, "192.0.2.1")
+	assert.Equal(t, "Firefox/130.0", recorded.UserAgent)
+
+	// The listing has the client and last use of every session
+	expireSession(t, service, expiredToken)
+	sessions, err := service.ListUserSessions(session.UserID)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2, "expired sessions are not listed")
+	for _, listed := range sessions {
+		assert.False(t, listed.LastSeenAt.IsZero())
+		if listed.ID == session.ID {
+			assert.Equal(t, "Firefox/130.0", listed.UserAgent)
+		}
+	}
+	assert.Empty(t, sessions[0].Sanitized().CSRFToken)
+
+	// Sessions can only be revoked by their user
+	assert.EqualError(t, service.RevokeSession("someone-else", other.ID), "session not found")
+	assert.EqualError(t, service.RevokeSession(session.UserID, "unknown"), "session not found")
+	require.NoError(t, service.RevokeSession(session.UserID, other.ID))
+	_, err = service.ValidateSession(otherToken)
+	assert.Error(t, err)
+	_, err = service.ValidateSession(token)
+	assert.NoError(t, err)
+}
+
 func TestAuthService_ChangePassword_WrongOldPassword(t *testing.T) {
 	service := setupAuthService(t)
+	_, session := login(t, service, "test123")
 
-	err := service.ChangePassword("wrongpassword", "newpassword456")
+	err := service.ChangePassword(session, "wrongpassword", "newpassword456")
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "invalid current password")
 }
@@ -140,33 +203,30 @@ func TestAuthService_SessionCleanup(t *testing.T) {
 	require.NoError(t, err)
 
 	// Manually expire it
-	service.mu.Lock()
-	if sess, exists := service.sessions[sessionID]; exists {
-		sess.ExpiresAt = time.Now().Add(-1 * time.Hour)
-		service.sessions[sessionID] = sess
-	}
-	service.mu.Unlock()
+	expireSession(t, service, sessionID)
 
 	// Try to validate expired session (this will clean it up)
 	_, err = service.ValidateSession(sessionID)
 	assert.Error(t, err)
 
 	// Session should be gone after validation attempt
-	service.mu.RLock()
-	_, exists := service.sessions[sessionID]
-	service.mu.RUnlock()
-
-	assert.False(t, exists)
+	_, err = service.store.Get(hashSessionToken(sessionID))
+	assert.ErrorIs(t, err, ErrSessionNotFound)
 }
 
 func TestAuthService_HashPassword(t *testing.T) {
 	hash, err := HashPassword("mypassword")
 	require.NoError(t, err)
 	assert.NotEmpty(t, hash)
-	assert.Contains(t, hash, "$2a$")
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$"))
 
 	// Verify the hash works
-	service := NewAuthService("testuser", hash, 24*time.Hour)
+	fileService, err := NewFileService(t.TempDir())
+	require.NoError(t, err)
+	users := NewUserService(fileService)
+	_, err = users.Migrate(models.AdminConfig{Username: "testuser", PasswordHash: hash})
+	require.NoError(t, err)
+	service := NewAuthService(users, 24*time.Hour)
 	sessionID, err := service.Authenticate("testuser", "mypassword")
 	require.NoError(t, err)
 	assert.NotEmpty(t, sessionID)
@@ -201,50 +261,21 @@ func TestAuthService_MultipleActiveSessions(t *testing.T) {
 }
 
 func TestAuthService_ChangePassword_Persistence(t *testing.T) {
-	// Create a temporary directory for test data
-	tmpDir, err := os.MkdirTemp("", "auth_test_*")
+	fileService, err := NewFileService(t.TempDir())
 	require.NoError(t, err)
-	defer func() { _ = os.RemoveAll(tmpDir) }()
-
-	// Create a file service
-	fileService, err := NewFileService(tmpDir)
-	require.NoError(t, err)
-
-	// Write initial admin config
-	configFile := "admin_config.json"
 	initialHash := "$2a$10$VPqUwu5tQ8xAsqdRFgzibeVQVewjXsBkKuhJClOVqpeGflWYwLZKm" // test123 // pragma: allowlist secret
-	initialConfig := struct {
-		Username     string `json:"username"`
-		PasswordHash string `json:"password_hash"` // pragma: allowlist secret
-	}{
-		Username:     "testuser",
-		PasswordHash: initialHash, // pragma: allowlist secret
-	}
-	err = fileService.WriteJSON(configFile, initialConfig)
+	_, err = NewUserService(fileService).Migrate(models.AdminConfig{Username: "testuser", PasswordHash: initialHash})
 	require.NoError(t, err)
 
-	// Create auth service with persistence configured
-	service := NewAuthService("testuser", initialHash, 24*time.Hour)
-	service.SetConfigPersistence(fileService, configFile)
+	service := NewAuthService(NewUserService(fileService), 24*time.Hour)
+	_, session := login(t, service, "test123")
 
 	// Change password
-	err = service.ChangePassword("test123", "newpassword456")
-	require.NoError(t, err)
-
-	// Read the config file back
-	var savedConfig struct {
-		Username     string `json:"username"`
-		PasswordHash string `json:"password_hash"` // pragma: allowlist secret
-	}
-	err = fileService.ReadJSON(configFile, &savedConfig)
+	err = service.ChangePassword(session, "test123", "newpassword456")
 	require.NoError(t, err)
 
-	// Verify the hash was saved and is different from the original
-	assert.NotEqual(t, initialHash, savedConfig.PasswordHash)
-	assert.Equal(t, "testuser", savedConfig.Username)
-
-	// Verify we can create a new service with the saved hash and authenticate with new password
-	newService := NewAuthService(savedConfig.Username, savedConfig.PasswordHash, 24*time.Hour)
+	// A new service reading the same users.json uses the new password
+	newService := NewAuthService(NewUserService(fileService), 24*time.Hour)
 	sessionID, err := newService.Authenticate("testuser", "newpassword456")
 	require.NoError(t, err)
 	assert.NotEmpty(t, sessionID)
@@ -254,16 +285,30 @@ func TestAuthService_ChangePassword_Persistence(t *testing.T) {
 	assert.Error(t, err)
 }
 
-func TestAuthService_ChangePassword_PersistenceNotConfigured(t *testing.T) {
-	// Create a service without persistence configured
+func TestAuthService_UserChanges(t *testing.T) {
 	service := setupAuthService(t)
+	ownerToken, _ := login(t, service, "test123")
 
-	// Change password should still work (just won't persist)
-	err := service.ChangePassword("test123", "newpassword456")
+	editor := &models.User{Username: "assistant", Role: models.RoleEditor}
+	require.NoError(t, service.users.Create(editor, "assistant-pass"))
+	sessionID, err := service.Authenticate("assistant", "assistant-pass")
 	require.NoError(t, err)
+	session, err := service.ValidateSession(sessionID)
+	require.NoError(t, err)
+	assert.Equal(t, editor.ID, session.UserID)
+	assert.Equal(t, models.RoleEditor, session.Role)
 
-	// New password should work in memory
-	sessionID, err := service.Authenticate("testuser", "newpassword456")
+	// A role change applies to the existing session
+	update := &models.User{Username: "assistant", Role: models.RoleViewer}
+	require.NoError(t, service.users.Update(editor.ID, update))
+	session, err = service.ValidateSession(sessionID)
 	require.NoError(t, err)
-	assert.NotEmpty(t, sessionID)
+	assert.Equal(t, models.RoleViewer, session.Role)
+
+	// Deleting the user ends their session but not others'
+	require.NoError(t, service.users.Delete(editor.ID))
+	_, err = service.ValidateSession(sessionID)
+	assert.Error(t, err)
+	_, err = service.ValidateSession(ownerToken)
+	assert.NoError(t, err)
 }
@@ -1,15 +1,21 @@
 package services
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sync"
 	"time"
 )
 
+// maxJSONLLineSize bounds the lines of JSON Lines files.
+const maxJSONLLineSize = 1024 * 1024
+
 // FileService provides atomic file operations with locking and backups.
 type FileService struct {
 	dataDir    string
@@ -222,6 +228,138 @@ func (fs *FileService) Rollback(filename string) error {
 	return nil
 }
 
+// AppendJSONL appends v to a JSON Lines file as one line, creating the file
+// if needed. Appends are not backed up.
+func (fs *FileService) AppendJSONL(filename string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	lock := fs.getFileLock(filename)
+	lock.Lock()
+	defer lock.Unlock()
+
+	// #nosec G302 G304 - 0644 is appropriate for data files in the controlled data directory
+	f, err := os.OpenFile(filepath.Join(fs.dataDir, filename), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", filename, err)
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to append to %s: %w", filename, err)
+	}
+	return f.Close()
+}
+
+// ReadJSONL calls fn with each line of a JSON Lines file, in order, until fn
+// returns an error. A missing file has no lines. The line is only valid during
+// the call.
+func (fs *FileService) ReadJSONL(filename string, fn func(line []byte) error) error {
+	lock := fs.getFileLock(filename)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	// #nosec G304 - File path is from controlled data directory
+	f, err := os.Open(filepath.Join(fs.dataDir, filename))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", filename, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	return scanJSONL(f, fn)
+}
+
+// ReadJSONLSnapshot calls fn with each line of a JSON Lines file as it was when
+// the call started, like ReadJSONL, but without holding the file's lock while
+// fn runs, so a slow fn does not hold up appends. Lines appended after the
+// start are not read, and FilterJSONL replaces the file rather than changing
+// it.
+func (fs *FileService) ReadJSONLSnapshot(filename string, fn func(line []byte) error) error {
+	lock := fs.getFileLock(filename)
+	lock.RLock()
+	// #nosec G304 - File path is from controlled data directory
+	f, err := os.Open(filepath.Join(fs.dataDir, filename))
+	if errors.Is(err, os.ErrNotExist) {
+		lock.RUnlock()
+		return nil
+	}
+	if err != nil {
+		lock.RUnlock()
+		return fmt.Errorf("failed to read file %s: %w", filename, err)
+	}
+	info, err := f.Stat()
+	lock.RUnlock()
+	defer func() { _ = f.Close() }()
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", filename, err)
+	}
+
+	return scanJSONL(io.LimitReader(f, info.Size()), fn)
+}
+
+// FilterJSONL rewrites a JSON Lines file atomically with only the lines that
+// keep is called with and returns true. It returns the number of lines
+// removed, and leaves the file alone when there are none.
+func (fs *FileService) FilterJSONL(filename string, keep func(line []byte) bool) (int, error) {
+	lock := fs.getFileLock(filename)
+	lock.Lock()
+	defer lock.Unlock()
+
+	filePath := filepath.Join(fs.dataDir, filename)
+	// #nosec G304 - File path is from controlled data directory
+	f, err := os.Open(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read file %s: %w", filename, err)
+	}
+	var kept bytes.Buffer
+	removed := 0
+	err = scanJSONL(f, func(line []byte) error {
+		if keep(line) {
+			kept.Write(line)
+			kept.WriteByte('\n')
+		} else {
+			removed++
+		}
+		return nil
+	})
+	_ = f.Close()
+	if err != nil || removed == 0 {
+		return 0, err
+	}
+
+	tmpPath := filePath + ".tmp"
+	// #nosec G306 - 0644 is appropriate for data files
+	if err := os.WriteFile(tmpPath, kept.Bytes(), 0644); err != nil {
+		return 0, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		_ = os.Remove(tmpPath)
+		return 0, fmt.Errorf("failed to rename temporary file: %w", err)
+	}
+	return removed, nil
+}
+
+// scanJSONL calls fn with each non-empty line read from r.
+func scanJSONL(r io.Reader, fn func(line []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxJSONLLineSize)
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			if err := fn(line); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
 // FileExists checks if a file exists in the data directory.
 func (fs *FileService) FileExists(filename string) bool {
 	filePath := filepath.Join(fs.dataDir, filename)
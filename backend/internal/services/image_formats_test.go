@@ -0,0 +1,132 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/davidbyttow/govips/v2/vips"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// uploadFixture runs a file from testdata through ProcessUpload.
+func uploadFixture(t *testing.T, imageService *ImageService, name string) *models.Photo {
+	data, err := os.ReadFile(filepath.Join("testdata", name)) // #nosec G304 - test fixture
+	require.NoError(t, err)
+
+	photo, err := imageService.ProcessUpload(createUploadFileHeader(t, "photos", name, data))
+	require.NoError(t, err, "ProcessUpload should succeed for %s", name)
+	return photo
+}
+
+func TestImageService_ProcessUpload_Formats(t *testing.T) {
+	tests := []struct {
+		fixture    string
+		imageType  vips.ImageType
+		wantExt    string
+		wantWidth  int
+		wantHeight int
+		wantFrames int
+	}{
+		{fixture: "still.jpg", imageType: vips.ImageTypeJPEG, wantExt: ".jpg", wantWidth: 96, wantHeight: 64},
+		{fixture: "still.png", imageType: vips.ImageTypePNG, wantExt: ".png", wantWidth: 96, wantHeight: 64},
+		{fixture: "still.webp", imageType: vips.ImageTypeWEBP, wantExt: ".webp", wantWidth: 150, wantHeight: 100},
+		{fixture: "still.heic", imageType: vips.ImageTypeHEIF, wantExt: ".heic", wantWidth: 1440, wantHeight: 960},
+		{fixture: "animated.gif", imageType: vips.ImageTypeGIF, wantExt: ".gif", wantWidth: 60, wantHeight: 40, wantFrames: 3},
+		{fixture: "animated.webp", imageType: vips.ImageTypeWEBP, wantExt: ".webp", wantWidth: 990, wantHeight: 1050, wantFrames: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			if !vips.IsTypeSupported(tt.imageType) {
+				t.Skipf("libvips was built without support for %s", tt.fixture)
+			}
+
+			tmpDir := t.TempDir()
+			imageService, err := NewImageService(tmpDir, nil)
+			require.NoError(t, err, "NewImageService should succeed")
+
+			photo := uploadFixture(t, imageService, tt.fixture)
+
+			assert.True(t, strings.HasSuffix(photo.URLOriginal, tt.wantExt), "original should be stored as %s, got %s", tt.wantExt, photo.URLOriginal)
+			assert.Equal(t, tt.wantWidth, photo.Width)
+			assert.Equal(t, tt.wantHeight, photo.Height, "height should be a single frame")
+			assert.Equal(t, tt.wantFrames, photo.FrameCount)
+
+			assert.FileExists(t, uploadPath(tmpDir, photo.URLOriginal))
+			assert.FileExists(t, uploadPath(tmpDir, photo.URLDisplay))
+			assert.FileExists(t, uploadPath(tmpDir, photo.URLThumbnail))
+			assert.FileExists(t, uploadPath(tmpDir, photo.URLSquare))
+			assert.FileExists(t, uploadPath(tmpDir, photo.URLPortrait))
+			assert.FileExists(t, uploadPath(tmpDir, photo.URLSocial))
+		})
+	}
+}
+
+func TestImageService_RenderDerivatives_Animated(t *testing.T) {
+	tmpDir := t.TempDir()
+	imageService, err := NewImageService(tmpDir, nil)
+	require.NoError(t, err, "NewImageService should succeed")
+
+	photo := uploadFixture(t, imageService, "animated.gif")
+
+	// Quarter turns and crops apply to every frame
+	photo.Edits = &models.PhotoEdits{
+		Rotate:       90,
+		FlipVertical: true,
+		Crop:         &models.CropRect{X: 0, Y: 0, Width: 1, Height: 0.5},
+	}
+	err = imageService.RenderDerivatives(photo)
+	require.NoError(t, err, "RenderDerivatives should succeed")
+	assert.Equal(t, 40, photo.Width)
+	assert.Equal(t, 30, photo.Height)
+	assert.Equal(t, 3, photo.FrameCount, "edits should keep every frame")
+
+	// Straightening is not available for animations
+	photo.Edits = &models.PhotoEdits{Straighten: 2}
+	err = imageService.RenderDerivatives(photo)
+	assert.ErrorIs(t, err, ErrUnsupportedEdit, "straightening an animated image should fail")
+}
+
+func TestImageService_ExtractEXIF_HEIF(t *testing.T) {
+	imageService := &ImageService{}
+
+	// HEIF without an EXIF item
+	data, err := os.ReadFile(filepath.Join("testdata", "still.heic")) // #nosec G304 - test fixture
+	require.NoError(t, err)
+	_, err = imageService.extractEXIFFromBytes(data)
+	assert.Error(t, err)
+
+	// The EXIF item is located by its header inside the container
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x00\x00\x00\x00\x00")
+	container := append([]byte("\x00\x00\x00\x18ftypheic\x00\x00\x00\x00mif1heic"), []byte("\x00\x00\x00\x06Exif\x00\x00")...)
+	container = append(container, tiff...)
+	exifData, err := imageService.extractEXIFFromBytes(container)
+	require.NoError(t, err, "EXIF inside a HEIF container should be decoded")
+	assert.NotNil(t, exifData)
+}
+
+func TestImageService_ProcessUpload_HEIFUnsupported(t *testing.T) {
+	if vips.IsTypeSupported(vips.ImageTypeHEIF) {
+		t.Skip("libvips was built with HEIF support")
+	}
+
+	tmpDir := t.TempDir()
+	imageService, err := NewImageService(tmpDir, nil)
+	require.NoError(t, err, "NewImageService should succeed")
+
+	data, err := os.ReadFile(filepath.Join("testdata", "still.heic")) // #nosec G304 - test fixture
+	require.NoError(t, err)
+
+	_, err = imageService.ProcessUpload(createUploadFileHeader(t, "photos", "still.heic", data))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "cannot decode HEIF")
+
+	// Nothing is left behind
+	entries, err := os.ReadDir(filepath.Join(tmpDir, "originals"))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
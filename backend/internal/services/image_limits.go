@@ -0,0 +1,211 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // Register GIF for header decoding
+	_ "image/jpeg" // Register JPEG for header decoding
+	_ "image/png"  // Register PNG for header decoding
+	"runtime"
+	"sync"
+
+	"github.com/davidbyttow/govips/v2/vips"
+	_ "golang.org/x/image/tiff" // Register TIFF for header decoding
+	_ "golang.org/x/image/webp" // Register WebP for header decoding
+)
+
+const (
+	defaultMaxImageMegapixels = 150   // Covers 100MP medium format and stitched panoramas
+	defaultMaxImageDimension  = 30000 // Longest side in pixels
+	defaultVipsCacheMaxMemMB  = 50    // libvips operation cache memory
+	defaultVipsCacheMaxOps    = 100   // libvips operation cache entries
+)
+
+var (
+	// ErrImageTooLarge is returned for uploads over a file size, pixel count or dimension limit.
+	ErrImageTooLarge = errors.New("image too large")
+
+	// ErrImageUnprocessable is returned for uploads that are not a readable image of a supported type.
+	ErrImageUnprocessable = errors.New("unprocessable image")
+
+	// ErrUnsupportedEdit is returned for edits that cannot be applied to a
+	// photo, such as straightening an animated image.
+	ErrUnsupportedEdit = errors.New("unsupported edit")
+
+	// ErrInsufficientStorage is returned for uploads that would take the disk
+	// over its usage limit or minimum free space.
+	ErrInsufficientStorage = errors.New("insufficient storage")
+)
+
+// ProcessingLimits bounds the resources libvips may use. libvips is process-wide,
+// so the limits passed to the first ImageService created apply to all of them.
+type ProcessingLimits struct {
+	MaxConcurrentImages int // Images decoded or encoded at once (default: number of CPUs)
+	CacheMaxMemMB       int // Memory held by the libvips operation cache
+	CacheMaxOperations  int // Operations held by the libvips operation cache
+}
+
+// DefaultProcessingLimits returns the limits used by NewImageService.
+func DefaultProcessingLimits() ProcessingLimits {
+	return ProcessingLimits{
+		MaxConcurrentImages: runtime.NumCPU(),
+		CacheMaxMemMB:       defaultVipsCacheMaxMemMB,
+		CacheMaxOperations:  defaultVipsCacheMaxOps,
+	}
+}
+
+var (
+	vipsStartOnce sync.Once
+
+	// vipsSlots is the global semaphore on concurrent libvips work.
+	vipsSlots chan struct{}
+)
+
+// startVips starts libvips with the given cache limits and sizes the processing
+// semaphore. Only the first call has any effect.
+func startVips(limits ProcessingLimits) {
+	vipsStartOnce.Do(func() {
+		defaults := DefaultProcessingLimits()
+		if limits.MaxConcurrentImages <= 0 {
+			limits.MaxConcurrentImages = defaults.MaxConcurrentImages
+		}
+		if limits.CacheMaxMemMB <= 0 {
+			limits.CacheMaxMemMB = defaults.CacheMaxMemMB
+		}
+		if limits.CacheMaxOperations <= 0 {
+			limits.CacheMaxOperations = defaults.CacheMaxOperations
+		}
+
+		vips.Startup(&vips.Config{
+			// Each image is already processed on its own goroutine, so keep
+			// libvips' per-image thread pool small
+			ConcurrencyLevel: 1,
+			MaxCacheMem:      limits.CacheMaxMemMB * 1024 * 1024,
+			MaxCacheSize:     limits.CacheMaxOperations,
+		})
+
+		vipsSlots = make(chan struct{}, limits.MaxConcurrentImages)
+	})
+}
+
+// acquireVips blocks until a libvips processing slot is free and returns a
+// function that releases it.
+func acquireVips() func() {
+	vipsSlots <- struct{}{}
+	return func() { <-vipsSlots }
+}
+
+// pixelLimits returns the configured maximum pixel count and longest side.
+func (s *ImageService) pixelLimits() (int64, int) {
+	maxMegapixels := defaultMaxImageMegapixels
+	maxDimension := defaultMaxImageDimension
+	if s.configService != nil {
+		config, err := s.configService.Get()
+		if err == nil {
+			if config.Storage.MaxImageMegapixels > 0 {
+				maxMegapixels = config.Storage.MaxImageMegapixels
+			}
+			if config.Storage.MaxImageDimension > 0 {
+				maxDimension = config.Storage.MaxImageDimension
+			}
+		}
+	}
+	return int64(maxMegapixels) * 1000 * 1000, maxDimension
+}
+
+// checkPixelLimits rejects an image whose width, height or total pixel count
+// (across all frames) exceeds the configured limits.
+func (s *ImageService) checkPixelLimits(width, height, frames int) error {
+	maxPixels, maxDimension := s.pixelLimits()
+
+	if width > maxDimension || height > maxDimension {
+		return fmt.Errorf("%w: %dx%d exceeds the maximum of %d pixels per side",
+			ErrImageTooLarge, width, height, maxDimension)
+	}
+
+	pixels := int64(width) * int64(height) * int64(max(frames, 1))
+	if pixels > maxPixels {
+		return fmt.Errorf("%w: %dx%d (%.1f megapixels) exceeds the maximum of %d megapixels",
+			ErrImageTooLarge, width, height, float64(pixels)/1e6, maxPixels/1e6)
+	}
+
+	return nil
+}
+
+// headerDimensions reads an image's dimensions from its header without decoding
+// any pixel data. For animations this is the size of a single frame.
+func headerDimensions(data []byte, contentType string) (int, int, error) {
+	if contentType == "image/heic" || contentType == "image/heif" {
+		return heifDimensions(data)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0, err
+	}
+	return config.Width, config.Height, nil
+}
+
+// heifDimensions returns the largest image size declared by the "ispe" (image
+// spatial extent) properties of a HEIC/HEIF file. Images split into tiles declare
+// the full size on the grid item, so the largest extent is the decoded size.
+// Only the item properties in the meta, iprp and ipco boxes are read, so image
+// data that happens to contain "ispe" is not mistaken for one.
+func heifDimensions(data []byte) (int, int, error) {
+	var width, height uint32
+	// meta is a full box: its boxes follow the version and flags (4 bytes)
+	if meta := heifBoxes(data, "meta"); len(meta) > 0 && len(meta[0]) >= 4 {
+		for _, iprp := range heifBoxes(meta[0][4:], "iprp") {
+			for _, ipco := range heifBoxes(iprp, "ipco") {
+				for _, ispe := range heifBoxes(ipco, "ispe") {
+					// ispe box: version/flags (4 bytes), width (4 bytes), height (4 bytes)
+					if len(ispe) < 12 {
+						continue
+					}
+					w := binary.BigEndian.Uint32(ispe[4:8])
+					h := binary.BigEndian.Uint32(ispe[8:12])
+					if uint64(w)*uint64(h) > uint64(width)*uint64(height) {
+						width, height = w, h
+					}
+				}
+			}
+		}
+	}
+
+	if width == 0 || height == 0 {
+		return 0, 0, errors.New("no image size found in HEIF header")
+	}
+	// #nosec G115 - HEIF dimensions are limited to 32 bits and checked against limits afterwards
+	return int(width), int(height), nil
+}
+
+// heifBoxes returns the contents of the boxes of the given type among the ISO
+// base media boxes that make up data, up to the first malformed box.
+func heifBoxes(data []byte, boxType string) [][]byte {
+	var contents [][]byte
+	for len(data) >= 8 {
+		// Box header: size (4 bytes), type (4 bytes), then a 64-bit size if
+		// the size is 1. A size of 0 extends to the end of the data.
+		size, header := uint64(binary.BigEndian.Uint32(data[:4])), uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return contents
+			}
+			size, header = binary.BigEndian.Uint64(data[8:16]), 16
+		}
+		if size < header || size > uint64(len(data)) {
+			return contents
+		}
+		if string(data[4:8]) == boxType {
+			contents = append(contents, data[header:size])
+		}
+		data = data[size:]
+	}
+	return contents
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pngBomb returns a tiny PNG that declares width x height pixels in its header.
+// Only the signature and IHDR chunk are present; decoding it in full would fail,
+// but a header-only check never gets that far.
+func pngBomb(width, height uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:4], width)
+	binary.BigEndian.PutUint32(ihdr[4:8], height)
+	ihdr[8] = 8 // bit depth
+	ihdr[9] = 2 // color type: RGB
+
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)))
+	chunk := append([]byte("IHDR"), ihdr...)
+	buf.Write(chunk)
+	_ = binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	return buf.Bytes()
+}
+
+func TestImageService_ProcessUpload_RejectsDecompressionBomb(t *testing.T) {
+	tmpDir := t.TempDir()
+	imageService, err := NewImageService(tmpDir, nil)
+	require.NoError(t, err, "NewImageService should succeed")
+
+	_, err = imageService.ProcessUpload(createUploadFileHeader(t, "photos", "bomb.png", pngBomb(50000, 50000)))
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+	assert.Contains(t, err.Error(), "50000x50000")
+
+	// Nothing is written for rejected uploads
+	entries, err := os.ReadDir(filepath.Join(tmpDir, "originals"))
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestImageService_ProcessUpload_RejectsUnreadableImage(t *testing.T) {
+	imageService, err := NewImageService(t.TempDir(), nil)
+	require.NoError(t, err, "NewImageService should succeed")
+
+	// A PNG signature followed by garbage
+	data := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0x42}, 600)...)
+	_, err = imageService.ProcessUpload(createUploadFileHeader(t, "photos", "broken.png", data))
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrImageUnprocessable)
+
+	// Unsupported types are unprocessable too
+	_, err = imageService.ProcessUpload(createUploadFileHeader(t, "photos", "notes.txt", bytes.Repeat([]byte("text "), 200)))
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrImageUnprocessable)
+}
+
+func TestImageService_CheckPixelLimits(t *testing.T) {
+	tmpDir := t.TempDir()
+	fileService, err := NewFileService(tmpDir)
+	require.NoError(t, err)
+	configService := NewSiteConfigService(fileService)
+
+	config := &models.SiteConfig{
+		Site: models.SiteInfo{Title: "Test", Language: "en"},
+		Storage: models.StorageConfig{
+			MaxDiskUsagePercent: 80,
+			MaxImageSizeMB:      50,
+			MaxImageMegapixels:  24,
+			MaxImageDimension:   8000,
+		},
+	}
+	require.NoError(t, configService.Update(config))
+
+	imageService, err := NewImageService(filepath.Join(tmpDir, "uploads"), configService)
+	require.NoError(t, err, "NewImageService should succeed")
+
+	tests := []struct {
+		name    string
+		width   int
+		height  int
+		frames  int
+		wantErr bool
+	}{
+		{name: "Within limits", width: 6000, height: 4000, frames: 1},
+		{name: "Too many pixels", width: 7000, height: 5000, frames: 1, wantErr: true},
+		{name: "Side too long", width: 9000, height: 1000, frames: 1, wantErr: true},
+		{name: "Small frames add up", width: 1000, height: 1000, frames: 30, wantErr: true},
+		{name: "Short animation", width: 1000, height: 1000, frames: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := imageService.checkPixelLimits(tt.width, tt.height, tt.frames)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrImageTooLarge)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestImageService_CheckPixelLimits_Defaults(t *testing.T) {
+	imageService := &ImageService{}
+
+	assert.NoError(t, imageService.checkPixelLimits(12000, 12000, 1), "144 megapixels is within the default")
+	assert.ErrorIs(t, imageService.checkPixelLimits(13000, 12000, 1), ErrImageTooLarge)
+	assert.ErrorIs(t, imageService.checkPixelLimits(31000, 100, 1), ErrImageTooLarge)
+}
+
+func TestHeaderDimensions(t *testing.T) {
+	tests := []struct {
+		fixture     string
+		contentType string
+		wantWidth   int
+		wantHeight  int
+	}{
+		{fixture: "still.jpg", contentType: "image/jpeg", wantWidth: 96, wantHeight: 64},
+		{fixture: "still.png", contentType: "image/png", wantWidth: 96, wantHeight: 64},
+		{fixture: "still.webp", contentType: "image/webp", wantWidth: 150, wantHeight: 100},
+		{fixture: "animated.webp", contentType: "image/webp", wantWidth: 990, wantHeight: 1050},
+		{fixture: "animated.gif", contentType: "image/gif", wantWidth: 60, wantHeight: 40},
+		{fixture: "still.heic", contentType: "image/heic", wantWidth: 1440, wantHeight: 960},
+		// Image data containing "ispe" with a huge size after it
+		{fixture: "ispe-in-payload.heic", contentType: "image/heic", wantWidth: 1440, wantHeight: 960},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.fixture)) // #nosec G304 - test fixture
+			require.NoError(t, err)
+
+			width, height, err := headerDimensions(data, tt.contentType)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantWidth, width)
+			assert.Equal(t, tt.wantHeight, height)
+		})
+	}
+
+	// HEIF without any size information
+	_, _, err := headerDimensions([]byte("\x00\x00\x00\x18ftypheic"), "image/heic")
+	assert.Error(t, err)
+}
+
+func TestAcquireVips(t *testing.T) {
+	startVips(DefaultProcessingLimits())
+
+	// Fill every slot; the next acquire must wait for a release
+	releases := make([]func(), cap(vipsSlots))
+	for i := range releases {
+		releases[i] = acquireVips()
+	}
+
+	acquired := make(chan func())
+	go func() { acquired <- acquireVips() }()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquireVips should block while all slots are taken")
+	default:
+	}
+
+	releases[0]()
+	release := <-acquired
+	release()
+	for _, r := range releases[1:] {
+		r()
+	}
+	assert.Empty(t, vipsSlots)
+}
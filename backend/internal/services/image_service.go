@@ -1,9 +1,11 @@
 package services
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"mime/multipart"
 	"net/http"
 	"os"
@@ -23,9 +25,25 @@ const (
 	thumbnailMaxSize = 800               // Thumbnail size
 	displayQuality   = 85                // Quality for display (JPEG/WebP)
 	thumbnailQuality = 80                // Quality for thumbnail (JPEG/WebP)
+	cropQuality      = 80                // Quality for cropped tiers (WebP)
 	minFreeSpace     = 500 * 1024 * 1024 // Minimum 500 MB free space required
+	stagingSuffix    = ".staging"        // Marks derivatives that are still being rendered
 )
 
+// cropTier describes a fixed-aspect derivative cropped around the photo's focal point.
+type cropTier struct {
+	name   string
+	width  int
+	height int
+}
+
+// cropTiers lists the cropped derivatives generated for every photo.
+var cropTiers = []cropTier{
+	{name: "square", width: 800, height: 800},
+	{name: "portrait", width: 800, height: 1000},
+	{name: "social", width: 1280, height: 720},
+}
+
 var allowedMimeTypes = map[string]bool{
 	"image/jpeg": true,
 	"image/png":  true,
@@ -36,22 +54,41 @@ var allowedMimeTypes = map[string]bool{
 	"image/heif": true,
 }
 
+// originalExtensions maps each allowed content type to the extension its original is stored with.
+var originalExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+	"image/gif":  ".gif",
+	"image/tiff": ".tiff",
+	"image/heic": ".heic",
+	"image/heif": ".heif",
+}
+
 // ImageService handles image upload and processing.
 type ImageService struct {
 	uploadDir     string
 	configService *SiteConfigService
 }
 
-// NewImageService creates a new image service.
+// NewImageService creates a new image service with the default processing limits.
 func NewImageService(uploadDir string, configService *SiteConfigService) (*ImageService, error) {
+	return NewImageServiceWithLimits(uploadDir, configService, DefaultProcessingLimits())
+}
+
+// NewImageServiceWithLimits creates a new image service, starting libvips with
+// the given limits if it is not running yet.
+func NewImageServiceWithLimits(uploadDir string, configService *SiteConfigService, limits ProcessingLimits) (*ImageService, error) {
 	// Initialize vips
-	vips.Startup(nil)
+	startVips(limits)
 
 	// Create upload directories
 	dirs := []string{
 		filepath.Join(uploadDir, "originals"),
 		filepath.Join(uploadDir, "display"),
 		filepath.Join(uploadDir, "thumbnails"),
+		filepath.Join(uploadDir, "crops"),
+		filepath.Join(uploadDir, "versions"),
 	}
 
 	for _, dir := range dirs {
@@ -108,20 +145,20 @@ func (s *ImageService) checkDiskSpace(estimatedSize int64) error {
 
 	// Check absolute minimum free space
 	if availableSpace < minFreeSpace {
-		return fmt.Errorf("insufficient disk space: %s available, minimum %s required",
-			formatBytes(availableSpace), formatBytes(minFreeSpace))
+		return fmt.Errorf("%w: %s available, minimum %s required",
+			ErrInsufficientStorage, formatBytes(availableSpace), formatBytes(minFreeSpace))
 	}
 
 	// Check if current usage already exceeds limit
 	if currentUsagePercent >= float64(effectiveMaxPercent) {
-		return fmt.Errorf("disk usage is at %.1f%%, exceeding the %d%% limit",
-			currentUsagePercent, effectiveMaxPercent)
+		return fmt.Errorf("%w: disk usage is at %.1f%%, exceeding the %d%% limit",
+			ErrInsufficientStorage, currentUsagePercent, effectiveMaxPercent)
 	}
 
 	// Check if upload would exceed the limit
 	if usagePercentAfterUpload >= float64(effectiveMaxPercent) {
-		return fmt.Errorf("upload would increase disk usage to %.1f%%, exceeding the %d%% limit (estimated %s needed)",
-			usagePercentAfterUpload, effectiveMaxPercent, formatBytes(estimatedTotal))
+		return fmt.Errorf("%w: upload would increase disk usage to %.1f%%, exceeding the %d%% limit (estimated %s needed)",
+			ErrInsufficientStorage, usagePercentAfterUpload, effectiveMaxPercent, formatBytes(estimatedTotal))
 	}
 
 	return nil
@@ -139,12 +176,12 @@ func (s *ImageService) ProcessUpload(fileHeader *multipart.FileHeader) (*models.
 	}
 	maxSizeBytes := int64(maxSizeMB) * 1024 * 1024
 	if fileHeader.Size > maxSizeBytes {
-		return nil, fmt.Errorf("file size %s exceeds maximum allowed %s (%dMB)", formatBytes(fileHeader.Size), formatBytes(maxSizeBytes), maxSizeMB)
+		return nil, fmt.Errorf("%w: file size %s exceeds maximum allowed %s (%dMB)", ErrImageTooLarge, formatBytes(fileHeader.Size), formatBytes(maxSizeBytes), maxSizeMB)
 	}
 
 	// Also check hard limit for safety
 	if fileHeader.Size > maxFileSize {
-		return nil, fmt.Errorf("file size %s exceeds absolute maximum %s", formatBytes(fileHeader.Size), formatBytes(maxFileSize))
+		return nil, fmt.Errorf("%w: file size %s exceeds absolute maximum %s", ErrImageTooLarge, formatBytes(fileHeader.Size), formatBytes(maxFileSize))
 	}
 
 	// Check disk space before processing
@@ -167,7 +204,12 @@ func (s *ImageService) ProcessUpload(fileHeader *multipart.FileHeader) (*models.
 
 	contentType := detectContentType(buffer, fileHeader.Filename)
 	if !allowedMimeTypes[contentType] {
-		return nil, fmt.Errorf("unsupported file type: %s", contentType)
+		return nil, fmt.Errorf("%w: unsupported file type: %s", ErrImageUnprocessable, contentType)
+	}
+
+	// HEIC/HEIF decoding depends on libvips being built with libheif
+	if (contentType == "image/heic" || contentType == "image/heif") && !vips.IsTypeSupported(vips.ImageTypeHEIF) {
+		return nil, fmt.Errorf("%w: unsupported file type: %s (this server cannot decode HEIF images)", ErrImageUnprocessable, contentType)
 	}
 
 	// Reset file pointer
@@ -184,35 +226,36 @@ func (s *ImageService) ProcessUpload(fileHeader *multipart.FileHeader) (*models.
 		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
-	// Load image with vips to get dimensions
-	img, err := vips.NewImageFromBuffer(fileBytes)
+	// Reject decompression bombs from the header alone, before libvips sees the file
+	headerWidth, headerHeight, err := headerDimensions(fileBytes, contentType)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode image with vips: %w", err)
+		return nil, fmt.Errorf("%w: failed to read image header: %v", ErrImageUnprocessable, err)
+	}
+	if err := s.checkPixelLimits(headerWidth, headerHeight, 1); err != nil {
+		return nil, err
+	}
+
+	release := acquireVips()
+	defer release()
+
+	// Load image with vips to get dimensions. Loading only reads the header;
+	// pixels are decoded when derivatives are generated.
+	img, err := loadImage(fileBytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrImageUnprocessable, err)
 	}
 	defer img.Close()
 
+	// Animations are decoded frame by frame, so count every frame
+	if err := s.checkPixelLimits(img.Width(), frameHeight(img), max(animationFrames(img), 1)); err != nil {
+		return nil, err
+	}
+
 	width := img.Width()
-	height := img.Height()
+	height := frameHeight(img)
 
-	// Determine original format from content type
-	originalExt := ""
-	switch contentType {
-	case "image/jpeg":
-		originalExt = ".jpg"
-	case "image/png":
-		originalExt = ".png"
-	case "image/webp":
-		originalExt = ".webp"
-	case "image/gif":
-		originalExt = ".gif"
-	case "image/tiff":
-		originalExt = ".tiff"
-	default:
-		originalExt = ".jpg"
-	}
-
-	// Save original
-	originalFilename := photoID + originalExt
+	// Save original, keeping its format's extension
+	originalFilename := photoID + originalExtensions[contentType]
 	originalPath := filepath.Join(s.uploadDir, "originals", originalFilename)
 
 	if err := os.WriteFile(originalPath, fileBytes, 0600); err != nil {
@@ -221,27 +264,13 @@ func (s *ImageService) ProcessUpload(fileHeader *multipart.FileHeader) (*models.
 
 	originalSize := int64(len(fileBytes))
 
-	// Generate display version (WebP)
-	displayFilename := photoID + "_display.webp"
-	displayPath := filepath.Join(s.uploadDir, "display", displayFilename)
-
-	displaySize, err := s.generateResizedVersion(fileBytes, displayPath, displayMaxSize, displayQuality)
+	// Generate display, thumbnail and cropped versions (WebP). There are no edits
+	// or focal point yet, so crops are smart-cropped.
+	derivatives, err := s.generateDerivatives(photoID, img, nil)
 	if err != nil {
 		// Clean up original
 		_ = os.Remove(originalPath)
-		return nil, fmt.Errorf("failed to generate display version: %w", err)
-	}
-
-	// Generate thumbnail (WebP)
-	thumbnailFilename := photoID + "_thumbnail.webp"
-	thumbnailPath := filepath.Join(s.uploadDir, "thumbnails", thumbnailFilename)
-
-	thumbnailSize, err := s.generateResizedVersion(fileBytes, thumbnailPath, thumbnailMaxSize, thumbnailQuality)
-	if err != nil {
-		// Clean up original and display
-		_ = os.Remove(originalPath)
-		_ = os.Remove(displayPath)
-		return nil, fmt.Errorf("failed to generate thumbnail: %w", err)
+		return nil, err
 	}
 
 	// Extract EXIF data (using original file bytes)
@@ -252,62 +281,663 @@ func (s *ImageService) ProcessUpload(fileHeader *multipart.FileHeader) (*models.
 	}
 
 	// Final disk space check after upload completes
-	totalSize := originalSize + displaySize + thumbnailSize
+	totalSize := originalSize + derivatives.totalSize()
 	if err := s.checkDiskSpace(totalSize); err != nil {
 		// Clean up all files
 		_ = os.Remove(originalPath)
-		_ = os.Remove(displayPath)
-		_ = os.Remove(thumbnailPath)
+		s.removeDerivatives(photoID)
 		return nil, fmt.Errorf("insufficient disk space after upload: %w", err)
 	}
 
 	// Create photo object
 	photo := &models.Photo{
-		FilenameOriginal:  fileHeader.Filename,
-		URLOriginal:       "/uploads/originals/" + originalFilename,
-		URLDisplay:        "/uploads/display/" + displayFilename,
-		URLThumbnail:      "/uploads/thumbnails/" + thumbnailFilename,
-		Width:             width,
-		Height:            height,
-		FileSizeOriginal:  originalSize,
-		FileSizeDisplay:   displaySize,
-		FileSizeThumbnail: thumbnailSize,
-		EXIF:              exifData,
+		FilenameOriginal: fileHeader.Filename,
+		URLOriginal:      "/uploads/originals/" + originalFilename,
+		Width:            width,
+		Height:           height,
+		FrameCount:       animationFrames(img),
+		FileSizeOriginal: originalSize,
+		EXIF:             exifData,
 	}
+	derivatives.applyTo(photo)
 
 	return photo, nil
 }
 
-// generateResizedVersion generates a resized WebP version of an image using libvips.
-func (s *ImageService) generateResizedVersion(imageBytes []byte, dstPath string, maxSize int, quality int) (int64, error) {
-	// Load image with vips
-	img, err := vips.NewImageFromBuffer(imageBytes)
+// RegenerateCrops rebuilds only the cropped tiers of a photo from its original,
+// with the photo's edits applied and centered on photo.FocalPoint (or
+// smart-cropped when it is nil). The photo's crop URLs and sizes are updated in place.
+// As with RenderDerivatives, a failed render leaves the current crops as they were.
+func (s *ImageService) RegenerateCrops(photo *models.Photo) error {
+	staged, err := s.StageCrops(photo)
+	if err != nil {
+		return err
+	}
+	return s.CommitDerivatives(staged)
+}
+
+// StageCrops renders a photo's cropped tiers as RegenerateCrops does, but
+// under temporary names like StageDerivatives, leaving the current crops in
+// place until CommitDerivatives.
+func (s *ImageService) StageCrops(photo *models.Photo) (*StagedDerivatives, error) {
+	release := acquireVips()
+	defer release()
+
+	photoID, img, err := s.loadEditedOriginal(photo)
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
+
+	stagedID := newStagedID(photoID)
+	crops, err := s.generateCrops(stagedID, img, photo.FocalPoint)
+	if err != nil {
+		s.removeCrops(stagedID)
+		return nil, fmt.Errorf("failed to generate cropped versions: %w", err)
+	}
+
+	derivatives := &derivativeSet{crops: crops}
+	derivatives.renamed(stagedID, photoID).applyCropsTo(photo)
+
+	return &StagedDerivatives{photoID: photoID, stagedID: stagedID, derivatives: derivatives}, nil
+}
+
+// StagedDerivatives are a photo's derivatives rendered under temporary names,
+// waiting to replace its current ones.
+type StagedDerivatives struct {
+	photoID     string
+	stagedID    string
+	derivatives *derivativeSet
+}
+
+// RenderDerivatives rebuilds every derivative (display, thumbnail and crops) of a
+// photo from its untouched original with photo.Edits applied. The original is
+// never modified. Dimensions, URLs and sizes on the photo are updated in place.
+// The new files only replace the current ones once all of them have been
+// written, so a failed render leaves the photo's existing derivatives as they
+// were.
+func (s *ImageService) RenderDerivatives(photo *models.Photo) error {
+	staged, err := s.StageDerivatives(photo)
+	if err != nil {
+		return err
+	}
+	return s.CommitDerivatives(staged)
+}
+
+// StageDerivatives renders a photo's derivatives as RenderDerivatives does,
+// but under temporary names, leaving the current files in place until
+// CommitDerivatives. The photo is updated in place as it will be once they are
+// committed. Edits that cannot be applied to the photo fail with
+// ErrUnsupportedEdit.
+func (s *ImageService) StageDerivatives(photo *models.Photo) (*StagedDerivatives, error) {
+	release := acquireVips()
+	defer release()
+
+	photoID, img, err := s.loadEditedOriginal(photo)
 	if err != nil {
-		return 0, fmt.Errorf("failed to load image: %w", err)
+		return nil, err
 	}
 	defer img.Close()
 
-	// Calculate scaling to fit within maxSize
+	stagedID := newStagedID(photoID)
+	derivatives, err := s.generateDerivatives(stagedID, img, photo.FocalPoint)
+	if err != nil {
+		s.removeDerivatives(stagedID)
+		return nil, err
+	}
+
+	photo.Width = img.Width()
+	photo.Height = frameHeight(img)
+	photo.FrameCount = animationFrames(img)
+	derivatives.renamed(stagedID, photoID).applyTo(photo)
+
+	return &StagedDerivatives{photoID: photoID, stagedID: stagedID, derivatives: derivatives}, nil
+}
+
+// CommitDerivatives moves staged derivatives over the photo's current ones.
+// On failure the staged files are removed.
+func (s *ImageService) CommitDerivatives(staged *StagedDerivatives) error {
+	if err := s.promoteStaged(staged.derivatives, staged.stagedID, staged.photoID); err != nil {
+		s.removeDerivatives(staged.stagedID)
+		return err
+	}
+	return nil
+}
+
+// AbortDerivatives removes staged derivatives that will not be used.
+func (s *ImageService) AbortDerivatives(staged *StagedDerivatives) {
+	s.removeDerivatives(staged.stagedID)
+}
+
+// newStagedID returns the temporary file stem for derivatives of a photo being
+// rendered. It is unique, so that renders of the same photo at the same time
+// do not mix.
+func newStagedID(photoID string) string {
+	return photoID + stagingSuffix + "-" + uuid.New().String()[:8]
+}
+
+// loadEditedOriginal loads a photo's original from disk and applies its edit stack.
+// It returns the file stem shared by all of the photo's derivatives.
+func (s *ImageService) loadEditedOriginal(photo *models.Photo) (string, *vips.ImageRef, error) {
+	originalFilename := filepath.Base(photo.URLOriginal)
+	if err := ValidateFilename(originalFilename); err != nil {
+		return "", nil, err
+	}
+
+	// #nosec G304 - File path is from controlled upload directory
+	fileBytes, err := os.ReadFile(filepath.Join(s.uploadDir, "originals", originalFilename))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read original: %w", err)
+	}
+
+	img, err := loadImage(fileBytes)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := applyEdits(img, photo.Edits); err != nil {
+		img.Close()
+		return "", nil, fmt.Errorf("failed to apply edits: %w", err)
+	}
+
+	photoID := strings.TrimSuffix(originalFilename, filepath.Ext(originalFilename))
+	return photoID, img, nil
+}
+
+// loadImage decodes an image with libvips. GIF and WebP are loaded with all of
+// their frames so animations carry through to the derivatives. The result is
+// converted to 8-bit sRGB, since derivatives are exported without a color profile.
+func loadImage(data []byte) (*vips.ImageRef, error) {
+	params := vips.NewImportParams()
+	switch http.DetectContentType(data) {
+	case "image/gif", "image/webp":
+		params.NumPages.Set(-1)
+	}
+
+	img, err := vips.LoadImageFromBuffer(data, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image with vips: %w", err)
+	}
+
+	if err := toSRGB(img); err != nil {
+		img.Close()
+		return nil, err
+	}
+
+	return img, nil
+}
+
+// toSRGB converts an image to 8-bit sRGB. Wide-gamut and high bit depth inputs
+// (such as Display P3 HEIC from phones, 16-bit PNG/TIFF or CMYK) would otherwise
+// render with shifted colors once their profile is stripped.
+func toSRGB(img *vips.ImageRef) error {
+	if img.HasICCProfile() {
+		if err := img.TransformICCProfile(vips.SRGBIEC6196621ICCProfilePath); err != nil {
+			return fmt.Errorf("failed to convert color profile: %w", err)
+		}
+	}
+
+	interpretation := img.Interpretation()
+	if img.BandFormat() != vips.BandFormatUchar ||
+		(interpretation != vips.InterpretationSRGB && interpretation != vips.InterpretationBW) {
+		if err := img.ToColorSpace(vips.InterpretationSRGB); err != nil {
+			return fmt.Errorf("failed to convert to sRGB: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// frameHeight returns the height of a single frame. Animated images are held by
+// libvips as a vertical strip of frames, so their Height() covers every frame.
+func frameHeight(img *vips.ImageRef) int {
+	return img.PageHeight()
+}
+
+// animationFrames returns the number of frames in an animated image, or 0 for
+// a still image.
+func animationFrames(img *vips.ImageRef) int {
+	if frames := img.Height() / img.PageHeight(); frames > 1 {
+		return frames
+	}
+	return 0
+}
+
+// applyEdits applies an edit stack to an image in a fixed order:
+// rotate, flip, straighten, then crop.
+func applyEdits(img *vips.ImageRef, edits *models.PhotoEdits) error {
+	if edits == nil {
+		return nil
+	}
+
+	animated := animationFrames(img) > 0
+	if animated && edits.Straighten != 0 {
+		return fmt.Errorf("%w: straightening is not supported for animated images", ErrUnsupportedEdit)
+	}
+
+	switch edits.Rotate {
+	case 90:
+		if err := img.Rotate(vips.Angle90); err != nil {
+			return fmt.Errorf("failed to rotate image: %w", err)
+		}
+	case 180:
+		if err := rotate180(img, animated); err != nil {
+			return fmt.Errorf("failed to rotate image: %w", err)
+		}
+	case 270:
+		if err := img.Rotate(vips.Angle270); err != nil {
+			return fmt.Errorf("failed to rotate image: %w", err)
+		}
+	}
+
+	if edits.FlipHorizontal {
+		if err := img.Flip(vips.DirectionHorizontal); err != nil {
+			return fmt.Errorf("failed to flip image: %w", err)
+		}
+	}
+	if edits.FlipVertical {
+		if animated {
+			// A vertical flip of the frame strip would also reverse the frame
+			// order, so flip each frame as a half turn plus a horizontal flip
+			if err := rotate180(img, animated); err != nil {
+				return fmt.Errorf("failed to flip image: %w", err)
+			}
+			if err := img.Flip(vips.DirectionHorizontal); err != nil {
+				return fmt.Errorf("failed to flip image: %w", err)
+			}
+		} else if err := img.Flip(vips.DirectionVertical); err != nil {
+			return fmt.Errorf("failed to flip image: %w", err)
+		}
+	}
+
+	if edits.Straighten != 0 {
+		if err := straighten(img, edits.Straighten); err != nil {
+			return err
+		}
+	}
+
+	if edits.Crop != nil {
+		left, top, width, height := edits.Crop.Pixels(img.Width(), frameHeight(img))
+		if err := img.ExtractArea(left, top, width, height); err != nil {
+			return fmt.Errorf("failed to crop image: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// rotate180 turns an image upside down. libvips only rotates animated images
+// frame by frame for quarter turns, so those take two of them.
+func rotate180(img *vips.ImageRef, animated bool) error {
+	if !animated {
+		return img.Rotate(vips.Angle180)
+	}
+	if err := img.Rotate(vips.Angle90); err != nil {
+		return err
+	}
+	return img.Rotate(vips.Angle90)
+}
+
+// straighten rotates an image by a small angle and trims the blank corners,
+// keeping the largest centered rectangle with the original aspect ratio.
+func straighten(img *vips.ImageRef, angle float64) error {
 	width := img.Width()
 	height := img.Height()
 
-	scale := 1.0
-	if width > maxSize || height > maxSize {
-		if width > height {
-			scale = float64(maxSize) / float64(width)
-		} else {
-			scale = float64(maxSize) / float64(height)
+	if err := img.Similarity(1.0, angle, &vips.ColorRGBA{}, 0, 0, 0, 0); err != nil {
+		return fmt.Errorf("failed to straighten image: %w", err)
+	}
+
+	cropWidth, cropHeight := straightenedSize(width, height, angle)
+	cropWidth = min(cropWidth, img.Width())
+	cropHeight = min(cropHeight, img.Height())
+	left := (img.Width() - cropWidth) / 2
+	top := (img.Height() - cropHeight) / 2
+
+	if err := img.ExtractArea(left, top, cropWidth, cropHeight); err != nil {
+		return fmt.Errorf("failed to trim straightened image: %w", err)
+	}
+
+	return nil
+}
+
+// straightenedSize returns the size of the largest rectangle with the same aspect
+// ratio as width x height that fits inside the image rotated by angle degrees.
+func straightenedSize(width, height int, angle float64) (int, int) {
+	theta := math.Abs(angle) * math.Pi / 180
+	sin, cos := math.Sin(theta), math.Cos(theta)
+	w, h := float64(width), float64(height)
+
+	scale := math.Min(w/(w*cos+h*sin), h/(w*sin+h*cos))
+
+	return int(math.Floor(w * scale)), int(math.Floor(h * scale))
+}
+
+// derivativeSet holds the results of rendering a photo's derivatives.
+type derivativeSet struct {
+	displayURL    string
+	displaySize   int64
+	thumbnailURL  string
+	thumbnailSize int64
+	crops         []cropResult
+}
+
+// totalSize returns the combined size of all derivatives in bytes.
+func (d *derivativeSet) totalSize() int64 {
+	total := d.displaySize + d.thumbnailSize
+	for _, crop := range d.crops {
+		total += crop.size
+	}
+	return total
+}
+
+// urls lists the URLs of the derivatives in the set.
+func (d *derivativeSet) urls() []string {
+	urls := []string{}
+	if d.displayURL != "" {
+		urls = append(urls, d.displayURL)
+	}
+	if d.thumbnailURL != "" {
+		urls = append(urls, d.thumbnailURL)
+	}
+	for _, crop := range d.crops {
+		urls = append(urls, crop.url)
+	}
+	return urls
+}
+
+// renamed returns a copy of the set whose file names have the stem from
+// replaced by to.
+func (d *derivativeSet) renamed(from, to string) *derivativeSet {
+	rename := func(url string) string {
+		if url == "" {
+			return ""
+		}
+		filename := filepath.Base(url)
+		return strings.TrimSuffix(url, filename) + strings.Replace(filename, from, to, 1)
+	}
+
+	renamed := *d
+	renamed.displayURL = rename(d.displayURL)
+	renamed.thumbnailURL = rename(d.thumbnailURL)
+	renamed.crops = make([]cropResult, len(d.crops))
+	for i, crop := range d.crops {
+		crop.url = rename(crop.url)
+		renamed.crops[i] = crop
+	}
+	return &renamed
+}
+
+// applyTo copies derivative URLs and sizes onto a photo.
+func (d *derivativeSet) applyTo(photo *models.Photo) {
+	photo.URLDisplay = d.displayURL
+	photo.FileSizeDisplay = d.displaySize
+	photo.URLThumbnail = d.thumbnailURL
+	photo.FileSizeThumbnail = d.thumbnailSize
+	d.applyCropsTo(photo)
+}
+
+// applyCropsTo copies cropped tier URLs and sizes onto a photo.
+func (d *derivativeSet) applyCropsTo(photo *models.Photo) {
+	for _, crop := range d.crops {
+		switch crop.tier {
+		case "square":
+			photo.URLSquare = crop.url
+			photo.FileSizeSquare = crop.size
+		case "portrait":
+			photo.URLPortrait = crop.url
+			photo.FileSizePortrait = crop.size
+		case "social":
+			photo.URLSocial = crop.url
+			photo.FileSizeSocial = crop.size
+		}
+	}
+}
+
+// generateDerivatives writes the display, thumbnail and cropped versions of an image.
+// On failure any files written so far are removed.
+func (s *ImageService) generateDerivatives(photoID string, img *vips.ImageRef, focal *models.FocalPoint) (*derivativeSet, error) {
+	// Generate display version (WebP)
+	displayFilename := photoID + "_display.webp"
+	displayPath := filepath.Join(s.uploadDir, "display", displayFilename)
+
+	displaySize, err := s.generateResizedVersion(img, displayPath, displayMaxSize, displayQuality)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate display version: %w", err)
+	}
+
+	// Generate thumbnail (WebP)
+	thumbnailFilename := photoID + "_thumbnail.webp"
+	thumbnailPath := filepath.Join(s.uploadDir, "thumbnails", thumbnailFilename)
+
+	thumbnailSize, err := s.generateResizedVersion(img, thumbnailPath, thumbnailMaxSize, thumbnailQuality)
+	if err != nil {
+		// Clean up display
+		_ = os.Remove(displayPath)
+		return nil, fmt.Errorf("failed to generate thumbnail: %w", err)
+	}
+
+	// Generate cropped tiers (WebP)
+	crops, err := s.generateCrops(photoID, img, focal)
+	if err != nil {
+		// Clean up display and thumbnail
+		_ = os.Remove(displayPath)
+		_ = os.Remove(thumbnailPath)
+		return nil, fmt.Errorf("failed to generate cropped versions: %w", err)
+	}
+
+	return &derivativeSet{
+		displayURL:    "/uploads/display/" + displayFilename,
+		displaySize:   displaySize,
+		thumbnailURL:  "/uploads/thumbnails/" + thumbnailFilename,
+		thumbnailSize: thumbnailSize,
+		crops:         crops,
+	}, nil
+}
+
+// promoteStaged renames the files of a derivative set rendered under stagedID
+// to their final names for photoID, replacing any existing files.
+func (s *ImageService) promoteStaged(staged *derivativeSet, stagedID, photoID string) error {
+	for _, url := range staged.urls() {
+		stagedFilename := filepath.Base(url)
+		filename := strings.Replace(stagedFilename, stagedID, photoID, 1)
+		dir := filepath.Join(s.uploadDir, filepath.Base(filepath.Dir(url)))
+
+		if err := os.Rename(filepath.Join(dir, stagedFilename), filepath.Join(dir, filename)); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", filename, err)
+		}
+	}
+	return nil
+}
+
+// removeDerivatives deletes the display, thumbnail and cropped versions for a photo,
+// ignoring missing files.
+func (s *ImageService) removeDerivatives(photoID string) {
+	_ = os.Remove(filepath.Join(s.uploadDir, "display", photoID+"_display.webp"))
+	_ = os.Remove(filepath.Join(s.uploadDir, "thumbnails", photoID+"_thumbnail.webp"))
+	s.removeCrops(photoID)
+}
+
+// cropResult is the outcome of generating a single crop tier.
+type cropResult struct {
+	tier string
+	url  string
+	size int64
+}
+
+// generateCrops writes every crop tier for a photo.
+func (s *ImageService) generateCrops(photoID string, img *vips.ImageRef, focal *models.FocalPoint) ([]cropResult, error) {
+	results := make([]cropResult, 0, len(cropTiers))
+
+	for _, tier := range cropTiers {
+		filename := photoID + "_" + tier.name + ".webp"
+		dstPath := filepath.Join(s.uploadDir, "crops", filename)
+
+		size, err := s.generateCroppedVersion(img, dstPath, tier, focal)
+		if err != nil {
+			return nil, fmt.Errorf("%s crop: %w", tier.name, err)
+		}
+
+		results = append(results, cropResult{
+			tier: tier.name,
+			url:  "/uploads/crops/" + filename,
+			size: size,
+		})
+	}
+
+	return results, nil
+}
+
+// removeCrops deletes every crop tier file for a photo, ignoring missing files.
+func (s *ImageService) removeCrops(photoID string) {
+	for _, tier := range cropTiers {
+		_ = os.Remove(filepath.Join(s.uploadDir, "crops", photoID+"_"+tier.name+".webp"))
+	}
+}
+
+// generateCroppedVersion generates a fixed-aspect WebP crop of an image using libvips.
+// Without a focal point, libvips' attention strategy picks the most salient region.
+// The source image is left untouched.
+func (s *ImageService) generateCroppedVersion(src *vips.ImageRef, dstPath string, tier cropTier, focal *models.FocalPoint) (int64, error) {
+	img, err := src.Copy()
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy image: %w", err)
+	}
+	defer img.Close()
+
+	// Attention-based smart crop does not understand frame strips, so animated
+	// images without a focal point are cropped around their center
+	if focal == nil && animationFrames(img) > 0 {
+		focal = &models.FocalPoint{X: 0.5, Y: 0.5}
+	}
+
+	if focal == nil {
+		if err := img.Thumbnail(tier.width, tier.height, vips.InterestingAttention); err != nil {
+			return 0, fmt.Errorf("failed to smart crop image: %w", err)
 		}
+
+		return exportWebP(img, dstPath, cropQuality)
+	}
+
+	// Scale so the image covers the target box, then cut the box out around the focal point
+	scale := math.Max(
+		float64(tier.width)/float64(img.Width()),
+		float64(tier.height)/float64(frameHeight(img)),
+	)
+	if err := resize(img, scale); err != nil {
+		return 0, fmt.Errorf("failed to resize image: %w", err)
+	}
+
+	// Rounding during resize can leave the image a pixel short of the target
+	cropWidth := min(tier.width, img.Width())
+	cropHeight := min(tier.height, frameHeight(img))
+	left, top := focalCropOrigin(img.Width(), frameHeight(img), cropWidth, cropHeight, focal)
+
+	if err := img.ExtractArea(left, top, cropWidth, cropHeight); err != nil {
+		return 0, fmt.Errorf("failed to crop image: %w", err)
+	}
+
+	return exportWebP(img, dstPath, cropQuality)
+}
+
+// focalCropOrigin returns the top-left corner of a cropWidth x cropHeight box
+// centered on the focal point as far as the image bounds allow.
+func focalCropOrigin(width, height, cropWidth, cropHeight int, focal *models.FocalPoint) (int, int) {
+	left := int(math.Round(focal.X*float64(width) - float64(cropWidth)/2))
+	top := int(math.Round(focal.Y*float64(height) - float64(cropHeight)/2))
+
+	left = max(0, min(left, width-cropWidth))
+	top = max(0, min(top, height-cropHeight))
+
+	return left, top
+}
+
+// generateResizedVersion generates a resized WebP version of an image using libvips.
+// The source image is left untouched.
+func (s *ImageService) generateResizedVersion(src *vips.ImageRef, dstPath string, maxSize int, quality int) (int64, error) {
+	img, err := src.Copy()
+	if err != nil {
+		return 0, fmt.Errorf("failed to copy image: %w", err)
 	}
+	defer img.Close()
 
 	// Resize if needed
-	if scale < 1.0 {
-		if err := img.Resize(scale, vips.KernelLanczos3); err != nil {
+	if scale := fitScale(img.Width(), frameHeight(img), maxSize); scale < 1.0 {
+		if err := resize(img, scale); err != nil {
 			return 0, fmt.Errorf("failed to resize image: %w", err)
 		}
 	}
 
-	// Export as WebP
+	return exportWebP(img, dstPath, quality)
+}
+
+// RenderJPEG renders a photo from its original with its edits applied as a
+// JPEG fitting within maxSize, for downloads. Animated images are rendered
+// from their first frame.
+func (s *ImageService) RenderJPEG(photo *models.Photo, maxSize, quality int) ([]byte, error) {
+	release := acquireVips()
+	defer release()
+
+	_, img, err := s.loadEditedOriginal(photo)
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
+
+	if animationFrames(img) > 0 {
+		if err := img.ExtractArea(0, 0, img.Width(), frameHeight(img)); err != nil {
+			return nil, fmt.Errorf("failed to extract first frame: %w", err)
+		}
+	}
+	if scale := fitScale(img.Width(), img.Height(), maxSize); scale < 1.0 {
+		if err := img.Resize(scale, vips.KernelLanczos3); err != nil {
+			return nil, fmt.Errorf("failed to resize image: %w", err)
+		}
+	}
+
+	ep := vips.NewJpegExportParams()
+	ep.Quality = quality
+	ep.StripMetadata = true
+	imageData, _, err := img.ExportJpeg(ep)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export jpeg: %w", err)
+	}
+	return imageData, nil
+}
+
+// fitScale returns the scale at which a width x height image fits within
+// maxSize on its longest side, or 1 if it already fits.
+func fitScale(width, height, maxSize int) float64 {
+	if width <= maxSize && height <= maxSize {
+		return 1.0
+	}
+	if width > height {
+		return float64(maxSize) / float64(width)
+	}
+	return float64(maxSize) / float64(height)
+}
+
+// resize scales an image with Lanczos3. Animated images are scaled so every frame
+// stays a whole number of pixels tall; otherwise libvips could no longer split
+// the strip back into frames and the animation would be lost on export.
+func resize(img *vips.ImageRef, scale float64) error {
+	frames := animationFrames(img)
+	if frames == 0 {
+		return img.Resize(scale, vips.KernelLanczos3)
+	}
+
+	pageHeight := max(1, int(math.Round(float64(frameHeight(img))*scale)))
+	vScale := float64(pageHeight) / float64(frameHeight(img))
+	if err := img.ResizeWithVScale(scale, vScale, vips.KernelLanczos3); err != nil {
+		return err
+	}
+
+	return img.SetPageHeight(img.Height() / frames)
+}
+
+// exportWebP encodes an image as lossy WebP and atomically writes it to dstPath,
+// so a regenerated derivative never replaces a good file with a partial one.
+func exportWebP(img *vips.ImageRef, dstPath string, quality int) (int64, error) {
 	ep := vips.NewWebpExportParams()
 	ep.Quality = quality
 	ep.Lossless = false
@@ -318,16 +948,33 @@ func (s *ImageService) generateResizedVersion(imageBytes []byte, dstPath string,
 		return 0, fmt.Errorf("failed to export webp: %w", err)
 	}
 
-	// Write to file
-	if err := os.WriteFile(dstPath, imageData, 0600); err != nil {
+	// Write to temporary file first
+	tmpPath := dstPath + ".tmp"
+	if err := os.WriteFile(tmpPath, imageData, 0600); err != nil {
 		return 0, fmt.Errorf("failed to write file: %w", err)
 	}
 
+	// Atomic rename
+	if err := os.Rename(tmpPath, dstPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return 0, fmt.Errorf("failed to rename file: %w", err)
+	}
+
 	return int64(len(imageData)), nil
 }
 
 // extractEXIFFromBytes extracts EXIF data from image bytes.
 func (s *ImageService) extractEXIFFromBytes(imageBytes []byte) (*models.EXIF, error) {
+	// HEIC/HEIF store EXIF as a separate item rather than a JPEG APP1 segment.
+	// The item starts with the same "Exif\0\0" header, which goexif can read from.
+	if len(imageBytes) >= 12 && string(imageBytes[4:8]) == "ftyp" {
+		start := bytes.Index(imageBytes, []byte("Exif\x00\x00"))
+		if start < 0 {
+			return nil, errors.New("no EXIF data found")
+		}
+		imageBytes = imageBytes[start:]
+	}
+
 	return s.extractEXIF(strings.NewReader(string(imageBytes)))
 }
 
@@ -406,8 +1053,28 @@ func (s *ImageService) extractEXIF(r io.Reader) (*models.EXIF, error) {
 	return exifData, nil
 }
 
-// DeletePhoto deletes all versions of a photo.
+// DeletePhoto deletes all versions of a photo, including its version history.
 func (s *ImageService) DeletePhoto(photo *models.Photo) error {
+	errors := s.deleteCurrentFiles(photo)
+
+	// Delete archived originals
+	for _, version := range photo.Versions {
+		versionPath := filepath.Join(s.uploadDir, "versions", filepath.Base(version.URLOriginal))
+		if err := os.Remove(versionPath); err != nil && !os.IsNotExist(err) {
+			errors = append(errors, fmt.Errorf("failed to delete version %s: %w", version.ID, err))
+		}
+	}
+
+	if len(errors) > 0 {
+		return fmt.Errorf("errors deleting photo: %v", errors)
+	}
+
+	return nil
+}
+
+// deleteCurrentFiles deletes the current original and derivatives of a photo,
+// leaving its version history alone.
+func (s *ImageService) deleteCurrentFiles(photo *models.Photo) []error {
 	errors := []error{}
 
 	// Extract filename from URL
@@ -433,11 +1100,18 @@ func (s *ImageService) DeletePhoto(photo *models.Photo) error {
 		errors = append(errors, fmt.Errorf("failed to delete thumbnail: %w", err))
 	}
 
-	if len(errors) > 0 {
-		return fmt.Errorf("errors deleting photo: %v", errors)
+	// Delete cropped tiers
+	for _, cropURL := range []string{photo.URLSquare, photo.URLPortrait, photo.URLSocial} {
+		if cropURL == "" {
+			continue
+		}
+		cropPath := filepath.Join(s.uploadDir, "crops", filepath.Base(cropURL))
+		if err := os.Remove(cropPath); err != nil && !os.IsNotExist(err) {
+			errors = append(errors, fmt.Errorf("failed to delete cropped version: %w", err))
+		}
 	}
 
-	return nil
+	return errors
 }
 
 // ValidateFilename checks for path traversal attacks.
@@ -475,6 +1149,8 @@ func detectContentType(data []byte, filename string) string {
 				if strings.HasSuffix(lowerFilename, ".heic") {
 					return "image/heic"
 				}
+				// Without a telling extension, treat it as generic HEIF
+				return "image/heif"
 			}
 		}
 	}
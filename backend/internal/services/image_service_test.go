@@ -1,6 +1,9 @@
 package services
 
 import (
+	"image"
+	"image/color"
+	"image/png"
 	"os"
 	"path/filepath"
 	"strings"
@@ -43,6 +46,12 @@ func TestDetectContentType(t *testing.T) {
 			filename: "photo.heic",
 			want:     "image/heic",
 		},
+		{
+			name:     "HEIF file with mif1 brand and no telling extension",
+			data:     []byte{0x00, 0x00, 0x00, 0x18, 0x66, 0x74, 0x79, 0x70, 0x6d, 0x69, 0x66, 0x31},
+			filename: "IMG_0001",
+			want:     "image/heif",
+		},
 		{
 			name:     "JPEG file",
 			data:     []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x10, 0x4A, 0x46, 0x49, 0x46, 0x00, 0x01},
@@ -368,6 +377,7 @@ func TestImageService_CheckDiskSpace_WithConfigAt10Percent(t *testing.T) {
 	if currentUsagePercent >= 10 {
 		assert.Error(t, err, "checkDiskSpace should fail when disk usage exceeds 10%")
 		assert.Contains(t, err.Error(), "disk usage is at", "error should mention disk usage")
+		assert.ErrorIs(t, err, ErrInsufficientStorage)
 	}
 }
 
@@ -410,3 +420,251 @@ func TestImageService_CheckDiskSpace_ErrorMessage(t *testing.T) {
 		)
 	}
 }
+
+// writeTestPNG writes a solid-color PNG of the given size and returns its bytes.
+func writeTestPNG(t *testing.T, path string, width, height int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x % 256), G: uint8(y % 256), B: 128, A: 255})
+		}
+	}
+
+	f, err := os.Create(path) // #nosec G304 - test file in temp directory
+	require.NoError(t, err)
+	require.NoError(t, png.Encode(f, img))
+	require.NoError(t, f.Close())
+
+	data, err := os.ReadFile(path) // #nosec G304 - test file in temp directory
+	require.NoError(t, err)
+	return data
+}
+
+func TestFocalCropOrigin(t *testing.T) {
+	tests := []struct {
+		name     string
+		width    int
+		height   int
+		cropW    int
+		cropH    int
+		focal    models.FocalPoint
+		wantLeft int
+		wantTop  int
+	}{
+		{
+			name:  "Centered focal point",
+			width: 1600, height: 800, cropW: 800, cropH: 800,
+			focal:    models.FocalPoint{X: 0.5, Y: 0.5},
+			wantLeft: 400, wantTop: 0,
+		},
+		{
+			name:  "Focal point near left edge clamps to zero",
+			width: 1600, height: 800, cropW: 800, cropH: 800,
+			focal:    models.FocalPoint{X: 0.05, Y: 0.5},
+			wantLeft: 0, wantTop: 0,
+		},
+		{
+			name:  "Focal point near right edge clamps to image bounds",
+			width: 1600, height: 800, cropW: 800, cropH: 800,
+			focal:    models.FocalPoint{X: 0.95, Y: 0.5},
+			wantLeft: 800, wantTop: 0,
+		},
+		{
+			name:  "Portrait image with focal point in upper third",
+			width: 800, height: 2400, cropW: 800, cropH: 1000,
+			focal:    models.FocalPoint{X: 0.5, Y: 0.33},
+			wantLeft: 0, wantTop: 292,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			left, top := focalCropOrigin(tt.width, tt.height, tt.cropW, tt.cropH, &tt.focal)
+			assert.Equal(t, tt.wantLeft, left)
+			assert.Equal(t, tt.wantTop, top)
+		})
+	}
+}
+
+func TestImageService_RegenerateCrops(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	imageService, err := NewImageService(tmpDir, nil)
+	require.NoError(t, err, "NewImageService should succeed")
+
+	writeTestPNG(t, filepath.Join(tmpDir, "originals", "test-photo.png"), 1200, 900)
+
+	photo := &models.Photo{
+		ID:          "test-photo",
+		URLOriginal: "/uploads/originals/test-photo.png",
+		FocalPoint:  &models.FocalPoint{X: 0.25, Y: 0.75},
+	}
+
+	err = imageService.RegenerateCrops(photo)
+	require.NoError(t, err, "RegenerateCrops should succeed")
+
+	assert.Equal(t, "/uploads/crops/test-photo_square.webp", photo.URLSquare)
+	assert.Equal(t, "/uploads/crops/test-photo_portrait.webp", photo.URLPortrait)
+	assert.Equal(t, "/uploads/crops/test-photo_social.webp", photo.URLSocial)
+	assert.Greater(t, photo.FileSizeSquare, int64(0))
+	assert.Greater(t, photo.FileSizePortrait, int64(0))
+	assert.Greater(t, photo.FileSizeSocial, int64(0))
+
+	for _, tier := range cropTiers {
+		assert.FileExists(t, filepath.Join(tmpDir, "crops", "test-photo_"+tier.name+".webp"))
+	}
+	staged, err := filepath.Glob(filepath.Join(tmpDir, "crops", "*"+stagingSuffix+"*"))
+	require.NoError(t, err)
+	assert.Empty(t, staged, "staged crops are moved into place")
+
+	// Deleting the photo removes the crops too
+	err = imageService.DeletePhoto(photo)
+	assert.NoError(t, err, "DeletePhoto should succeed")
+	for _, tier := range cropTiers {
+		assert.NoFileExists(t, filepath.Join(tmpDir, "crops", "test-photo_"+tier.name+".webp"))
+	}
+}
+
+func TestImageService_RegenerateCrops_MissingOriginal(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	imageService, err := NewImageService(tmpDir, nil)
+	require.NoError(t, err, "NewImageService should succeed")
+
+	photo := &models.Photo{
+		ID:          "missing",
+		URLOriginal: "/uploads/originals/missing.jpg",
+	}
+
+	err = imageService.RegenerateCrops(photo)
+	assert.Error(t, err, "RegenerateCrops should fail without an original")
+	assert.Empty(t, photo.URLSquare, "crop URLs should be left untouched on failure")
+}
+
+func TestStraightenedSize(t *testing.T) {
+	// No rotation keeps the full image
+	w, h := straightenedSize(1200, 800, 0)
+	assert.Equal(t, 1200, w)
+	assert.Equal(t, 800, h)
+
+	// Any rotation shrinks the image but keeps its aspect ratio
+	w, h = straightenedSize(1200, 800, 3)
+	assert.Less(t, w, 1200)
+	assert.Less(t, h, 800)
+	assert.InDelta(t, 1.5, float64(w)/float64(h), 0.01)
+
+	// Direction of rotation does not matter
+	w2, h2 := straightenedSize(1200, 800, -3)
+	assert.Equal(t, w, w2)
+	assert.Equal(t, h, h2)
+}
+
+func TestImageService_PromoteStaged(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	imageService, err := NewImageService(tmpDir, nil)
+	require.NoError(t, err, "NewImageService should succeed")
+
+	stagedID := "test-photo" + stagingSuffix
+	derivatives := &derivativeSet{
+		displayURL:   "/uploads/display/" + stagedID + "_display.webp",
+		thumbnailURL: "/uploads/thumbnails/" + stagedID + "_thumbnail.webp",
+		crops:        []cropResult{{tier: "square", url: "/uploads/crops/" + stagedID + "_square.webp"}},
+	}
+	files := map[string]string{
+		"display":    "_display.webp",
+		"thumbnails": "_thumbnail.webp",
+		"crops":      "_square.webp",
+	}
+	for dir, suffix := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, dir, "test-photo"+suffix), []byte("old"), 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(tmpDir, dir, stagedID+suffix), []byte("new"), 0600))
+	}
+
+	final := derivatives.renamed(stagedID, "test-photo")
+	assert.Equal(t, "/uploads/display/test-photo_display.webp", final.displayURL)
+	assert.Equal(t, "/uploads/thumbnails/test-photo_thumbnail.webp", final.thumbnailURL)
+	assert.Equal(t, "/uploads/crops/test-photo_square.webp", final.crops[0].url)
+	assert.Equal(t, "/uploads/crops/"+stagedID+"_square.webp", derivatives.crops[0].url, "renaming returns a copy")
+
+	err = imageService.promoteStaged(derivatives, stagedID, "test-photo")
+	require.NoError(t, err, "promoteStaged should succeed")
+
+	// The staged files replace the current ones
+	for dir, suffix := range files {
+		current, err := os.ReadFile(filepath.Join(tmpDir, dir, "test-photo"+suffix)) // #nosec G304 - test file in temp directory
+		require.NoError(t, err)
+		assert.Equal(t, "new", string(current))
+		assert.NoFileExists(t, filepath.Join(tmpDir, dir, stagedID+suffix))
+	}
+}
+
+func TestImageService_RenderDerivatives_MissingOriginal(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	imageService, err := NewImageService(tmpDir, nil)
+	require.NoError(t, err, "NewImageService should succeed")
+
+	displayPath := filepath.Join(tmpDir, "display", "test-photo_display.webp")
+	require.NoError(t, os.WriteFile(displayPath, []byte("current"), 0600))
+
+	photo := &models.Photo{
+		ID:          "test-photo",
+		URLOriginal: "/uploads/originals/test-photo.png",
+		URLDisplay:  "/uploads/display/test-photo_display.webp",
+	}
+
+	err = imageService.RenderDerivatives(photo)
+	require.Error(t, err, "RenderDerivatives should fail without an original")
+
+	// The current derivatives are left alone
+	current, err := os.ReadFile(displayPath) // #nosec G304 - test file in temp directory
+	require.NoError(t, err)
+	assert.Equal(t, "current", string(current))
+	assert.Equal(t, "/uploads/display/test-photo_display.webp", photo.URLDisplay)
+}
+
+func TestImageService_RenderDerivatives(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	imageService, err := NewImageService(tmpDir, nil)
+	require.NoError(t, err, "NewImageService should succeed")
+
+	originalPath := filepath.Join(tmpDir, "originals", "test-photo.png")
+	originalBytes := writeTestPNG(t, originalPath, 1200, 800)
+
+	photo := &models.Photo{
+		ID:          "test-photo",
+		URLOriginal: "/uploads/originals/test-photo.png",
+		Width:       1200,
+		Height:      800,
+		Edits: &models.PhotoEdits{
+			Rotate: 90,
+			Crop:   &models.CropRect{X: 0, Y: 0, Width: 1, Height: 0.5},
+		},
+	}
+
+	err = imageService.RenderDerivatives(photo)
+	require.NoError(t, err, "RenderDerivatives should succeed")
+
+	// Rotated 90 degrees then cropped to the top half
+	assert.Equal(t, 800, photo.Width)
+	assert.Equal(t, 600, photo.Height)
+	assert.Equal(t, "/uploads/display/test-photo_display.webp", photo.URLDisplay)
+	assert.Equal(t, "/uploads/thumbnails/test-photo_thumbnail.webp", photo.URLThumbnail)
+	assert.Equal(t, "/uploads/crops/test-photo_square.webp", photo.URLSquare)
+	assert.FileExists(t, filepath.Join(tmpDir, "display", "test-photo_display.webp"))
+	assert.FileExists(t, filepath.Join(tmpDir, "thumbnails", "test-photo_thumbnail.webp"))
+
+	// The original is never modified
+	current, err := os.ReadFile(originalPath) // #nosec G304 - test file in temp directory
+	require.NoError(t, err)
+	assert.Equal(t, originalBytes, current, "original file should be untouched")
+
+	// Reverting renders from the original again
+	photo.Edits = nil
+	err = imageService.RenderDerivatives(photo)
+	require.NoError(t, err, "RenderDerivatives should succeed after revert")
+	assert.Equal(t, 1200, photo.Width)
+	assert.Equal(t, 800, photo.Height)
+}
@@ -0,0 +1,238 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+)
+
+// ErrVersionNotFound is returned for versions a photo does not have.
+var ErrVersionNotFound = errors.New("version not found")
+
+// Replacing or restoring a photo's file is a two-phase operation so the album
+// JSON and the files on disk never disagree:
+//
+//  1. ReplaceFile / RestoreVersion write all new files and return an updated copy
+//     of the photo. Nothing that the current photo references is removed.
+//  2. After the updated photo has been saved, CommitReplacement removes the
+//     superseded files. If saving fails, AbortReplacement removes the new ones.
+
+// ReplaceFile processes a new file for an existing photo. The returned copy keeps
+// the photo's ID, caption, alt text and order, takes its dimensions, sizes and
+// EXIF from the new file, and records the current original in Versions.
+// Edits and the focal point are reset since they belong to the old file.
+func (s *ImageService) ReplaceFile(photo *models.Photo, fileHeader *multipart.FileHeader) (*models.Photo, error) {
+	processed, err := s.ProcessUpload(fileHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := s.archiveOriginal(photo)
+	if err != nil {
+		_ = s.DeletePhoto(processed)
+		return nil, err
+	}
+
+	updated := *photo
+	updated.FilenameOriginal = processed.FilenameOriginal
+	updated.URLOriginal = processed.URLOriginal
+	updated.Width = processed.Width
+	updated.Height = processed.Height
+	updated.FrameCount = processed.FrameCount
+	updated.FileSizeOriginal = processed.FileSizeOriginal
+	updated.EXIF = processed.EXIF
+	updated.Edits = nil
+	updated.FocalPoint = nil
+	updated.Versions = append(slices.Clone(photo.Versions), *version)
+	copyDerivatives(&updated, processed)
+
+	return &updated, nil
+}
+
+// RestoreVersion makes a file from the photo's version history current again,
+// re-rendering its derivatives with the edits and focal point it had. The
+// current original takes its place in the history.
+func (s *ImageService) RestoreVersion(photo *models.Photo, versionID string) (*models.Photo, error) {
+	index := slices.IndexFunc(photo.Versions, func(v models.PhotoVersion) bool {
+		return v.ID == versionID
+	})
+	if index < 0 {
+		return nil, ErrVersionNotFound
+	}
+	restored := photo.Versions[index]
+
+	versionFilename := filepath.Base(restored.URLOriginal)
+	if err := ValidateFilename(versionFilename); err != nil {
+		return nil, err
+	}
+
+	// Bring the archived original back alongside the current one
+	originalPath := filepath.Join(s.uploadDir, "originals", versionFilename)
+	if err := copyFile(filepath.Join(s.uploadDir, "versions", versionFilename), originalPath); err != nil {
+		return nil, fmt.Errorf("failed to restore original: %w", err)
+	}
+
+	version, err := s.archiveOriginal(photo)
+	if err != nil {
+		_ = os.Remove(originalPath)
+		return nil, err
+	}
+
+	updated := *photo
+	updated.FilenameOriginal = restored.FilenameOriginal
+	updated.URLOriginal = "/uploads/originals/" + versionFilename
+	updated.FileSizeOriginal = restored.FileSizeOriginal
+	updated.EXIF = restored.EXIF
+	updated.Edits = restored.Edits
+	updated.FocalPoint = restored.FocalPoint
+	updated.Versions = append(slices.Delete(slices.Clone(photo.Versions), index, index+1), *version)
+
+	if err := s.RenderDerivatives(&updated); err != nil {
+		s.removeDerivatives(strings.TrimSuffix(versionFilename, filepath.Ext(versionFilename)))
+		_ = os.Remove(originalPath)
+		_ = os.Remove(filepath.Join(s.uploadDir, "versions", filepath.Base(version.URLOriginal)))
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+// DeleteVersion returns a copy of the photo without the given version. The
+// archived file is removed by CommitReplacement once the copy has been saved.
+func (s *ImageService) DeleteVersion(photo *models.Photo, versionID string) (*models.Photo, error) {
+	index := slices.IndexFunc(photo.Versions, func(v models.PhotoVersion) bool {
+		return v.ID == versionID
+	})
+	if index < 0 {
+		return nil, ErrVersionNotFound
+	}
+
+	updated := *photo
+	updated.Versions = slices.Delete(slices.Clone(photo.Versions), index, index+1)
+	return &updated, nil
+}
+
+// CommitReplacement removes the files superseded by an update once the updated
+// photo has been saved: the previous original and derivatives (if the current
+// file changed) and any archived versions that were dropped.
+func (s *ImageService) CommitReplacement(previous, updated *models.Photo) error {
+	errors := []error{}
+
+	if previous.URLOriginal != updated.URLOriginal {
+		errors = append(errors, s.deleteCurrentFiles(previous)...)
+	}
+	errors = append(errors, s.deleteVersionsNotIn(previous.Versions, updated.Versions)...)
+
+	if len(errors) > 0 {
+		return fmt.Errorf("errors removing replaced files: %v", errors)
+	}
+	return nil
+}
+
+// AbortReplacement removes the files created for an update that could not be
+// saved, leaving the previous photo exactly as it was.
+func (s *ImageService) AbortReplacement(previous, updated *models.Photo) {
+	if previous.URLOriginal != updated.URLOriginal {
+		_ = s.deleteCurrentFiles(updated)
+	}
+	_ = s.deleteVersionsNotIn(updated.Versions, previous.Versions)
+}
+
+// archiveOriginal copies a photo's current original into the versions directory
+// and describes it as a version. The version ID is the original's file stem.
+func (s *ImageService) archiveOriginal(photo *models.Photo) (*models.PhotoVersion, error) {
+	originalFilename := filepath.Base(photo.URLOriginal)
+	if err := ValidateFilename(originalFilename); err != nil {
+		return nil, err
+	}
+
+	versionPath := filepath.Join(s.uploadDir, "versions", originalFilename)
+	if err := copyFile(filepath.Join(s.uploadDir, "originals", originalFilename), versionPath); err != nil {
+		return nil, fmt.Errorf("failed to archive original: %w", err)
+	}
+
+	return &models.PhotoVersion{
+		ID:               strings.TrimSuffix(originalFilename, filepath.Ext(originalFilename)),
+		FilenameOriginal: photo.FilenameOriginal,
+		URLOriginal:      "/uploads/versions/" + originalFilename,
+		Width:            photo.Width,
+		Height:           photo.Height,
+		FileSizeOriginal: photo.FileSizeOriginal,
+		Edits:            photo.Edits,
+		FocalPoint:       photo.FocalPoint,
+		EXIF:             photo.EXIF,
+		ReplacedAt:       time.Now().UTC(),
+	}, nil
+}
+
+// deleteVersionsNotIn removes the archived files of versions in from that are not in keep.
+func (s *ImageService) deleteVersionsNotIn(from, keep []models.PhotoVersion) []error {
+	errors := []error{}
+
+	for _, version := range from {
+		if slices.ContainsFunc(keep, func(v models.PhotoVersion) bool { return v.ID == version.ID }) {
+			continue
+		}
+		versionPath := filepath.Join(s.uploadDir, "versions", filepath.Base(version.URLOriginal))
+		if err := os.Remove(versionPath); err != nil && !os.IsNotExist(err) {
+			errors = append(errors, fmt.Errorf("failed to delete version %s: %w", version.ID, err))
+		}
+	}
+
+	return errors
+}
+
+// copyDerivatives copies derivative URLs and sizes from src onto dst.
+func copyDerivatives(dst, src *models.Photo) {
+	dst.URLDisplay = src.URLDisplay
+	dst.FileSizeDisplay = src.FileSizeDisplay
+	dst.URLThumbnail = src.URLThumbnail
+	dst.FileSizeThumbnail = src.FileSizeThumbnail
+	dst.URLSquare = src.URLSquare
+	dst.FileSizeSquare = src.FileSizeSquare
+	dst.URLPortrait = src.URLPortrait
+	dst.FileSizePortrait = src.FileSizePortrait
+	dst.URLSocial = src.URLSocial
+	dst.FileSizeSocial = src.FileSizeSocial
+}
+
+// copyFile copies src to dst via a temporary file, so dst is either complete or absent.
+func copyFile(src, dst string) error {
+	// #nosec G304 - File path is from controlled upload directory
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	tmpPath := dst + ".tmp"
+	// #nosec G304 - File path is from controlled upload directory
+	out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, dst); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createUploadFileHeader builds a real multipart.FileHeader holding data, as the
+// upload handlers would receive it.
+func createUploadFileHeader(t *testing.T, field, filename string, data []byte) *multipart.FileHeader {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile(field, filename)
+	require.NoError(t, err)
+	_, err = part.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest("POST", "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	require.NoError(t, req.ParseMultipartForm(32<<20))
+
+	return req.MultipartForm.File[field][0]
+}
+
+// uploadTestPhoto processes a generated PNG through ProcessUpload.
+func uploadTestPhoto(t *testing.T, imageService *ImageService, filename string, width, height int) *models.Photo {
+	data := writeTestPNG(t, filepath.Join(t.TempDir(), filename), width, height)
+	photo, err := imageService.ProcessUpload(createUploadFileHeader(t, "photos", filename, data))
+	require.NoError(t, err, "ProcessUpload should succeed")
+	photo.ID = "photo-1"
+	return photo
+}
+
+// uploadPath maps a /uploads URL to its path on disk.
+func uploadPath(uploadDir, url string) string {
+	return filepath.Join(uploadDir, filepath.FromSlash(url[len("/uploads/"):]))
+}
+
+func TestImageService_ReplaceFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	imageService, err := NewImageService(tmpDir, nil)
+	require.NoError(t, err, "NewImageService should succeed")
+
+	photo := uploadTestPhoto(t, imageService, "first-scan.png", 1200, 800)
+	photo.Caption = "Golden hour"
+	photo.AltText = "A field at sunset"
+	photo.Order = 3
+	photo.FocalPoint = &models.FocalPoint{X: 0.2, Y: 0.2}
+
+	replacement := writeTestPNG(t, filepath.Join(t.TempDir(), "rescan.png"), 900, 1350)
+	updated, err := imageService.ReplaceFile(photo, createUploadFileHeader(t, "photo", "rescan.png", replacement))
+	require.NoError(t, err, "ReplaceFile should succeed")
+
+	// Identity and metadata are kept
+	assert.Equal(t, photo.ID, updated.ID)
+	assert.Equal(t, "Golden hour", updated.Caption)
+	assert.Equal(t, "A field at sunset", updated.AltText)
+	assert.Equal(t, 3, updated.Order)
+
+	// File details come from the new upload
+	assert.Equal(t, "rescan.png", updated.FilenameOriginal)
+	assert.Equal(t, 900, updated.Width)
+	assert.Equal(t, 1350, updated.Height)
+	assert.NotEqual(t, photo.URLOriginal, updated.URLOriginal)
+	assert.NotEqual(t, photo.URLDisplay, updated.URLDisplay)
+	assert.Nil(t, updated.FocalPoint, "focal point belongs to the old file")
+
+	// The previous file is in the version history
+	require.Len(t, updated.Versions, 1)
+	assert.Equal(t, "first-scan.png", updated.Versions[0].FilenameOriginal)
+	assert.Equal(t, 1200, updated.Versions[0].Width)
+	assert.Equal(t, photo.FocalPoint, updated.Versions[0].FocalPoint)
+	assert.FileExists(t, uploadPath(tmpDir, updated.Versions[0].URLOriginal))
+	assert.Empty(t, photo.Versions, "the previous photo must not be modified")
+
+	// Nothing is removed until the replacement is committed
+	assert.FileExists(t, uploadPath(tmpDir, photo.URLOriginal))
+	require.NoError(t, imageService.CommitReplacement(photo, updated))
+	assert.NoFileExists(t, uploadPath(tmpDir, photo.URLOriginal))
+	assert.NoFileExists(t, uploadPath(tmpDir, photo.URLDisplay))
+	assert.NoFileExists(t, uploadPath(tmpDir, photo.URLSquare))
+	assert.FileExists(t, uploadPath(tmpDir, updated.URLOriginal))
+
+	// Restoring the version brings back the first scan and its focal point
+	restored, err := imageService.RestoreVersion(updated, updated.Versions[0].ID)
+	require.NoError(t, err, "RestoreVersion should succeed")
+	assert.Equal(t, "first-scan.png", restored.FilenameOriginal)
+	assert.Equal(t, 1200, restored.Width)
+	assert.Equal(t, 800, restored.Height)
+	assert.Equal(t, photo.FocalPoint, restored.FocalPoint)
+	require.Len(t, restored.Versions, 1)
+	assert.Equal(t, "rescan.png", restored.Versions[0].FilenameOriginal)
+
+	require.NoError(t, imageService.CommitReplacement(updated, restored))
+	assert.FileExists(t, uploadPath(tmpDir, restored.URLOriginal))
+	assert.FileExists(t, uploadPath(tmpDir, restored.URLDisplay))
+	assert.NoFileExists(t, uploadPath(tmpDir, updated.URLOriginal))
+	assert.NoFileExists(t, uploadPath(tmpDir, updated.Versions[0].URLOriginal))
+
+	// Deleting the photo removes its history too
+	require.NoError(t, imageService.DeletePhoto(restored))
+	assert.NoFileExists(t, uploadPath(tmpDir, restored.Versions[0].URLOriginal))
+}
+
+func TestImageService_AbortReplacement(t *testing.T) {
+	tmpDir := t.TempDir()
+	imageService, err := NewImageService(tmpDir, nil)
+	require.NoError(t, err, "NewImageService should succeed")
+
+	photo := uploadTestPhoto(t, imageService, "original.png", 640, 480)
+
+	replacement := writeTestPNG(t, filepath.Join(t.TempDir(), "new.png"), 480, 640)
+	updated, err := imageService.ReplaceFile(photo, createUploadFileHeader(t, "photo", "new.png", replacement))
+	require.NoError(t, err, "ReplaceFile should succeed")
+
+	imageService.AbortReplacement(photo, updated)
+
+	// The previous photo is untouched and the new files are gone
+	assert.FileExists(t, uploadPath(tmpDir, photo.URLOriginal))
+	assert.FileExists(t, uploadPath(tmpDir, photo.URLDisplay))
+	assert.NoFileExists(t, uploadPath(tmpDir, updated.URLOriginal))
+	assert.NoFileExists(t, uploadPath(tmpDir, updated.URLDisplay))
+	assert.NoFileExists(t, uploadPath(tmpDir, updated.Versions[0].URLOriginal))
+}
+
+func TestImageService_RestoreVersion_NotFound(t *testing.T) {
+	imageService, err := NewImageService(t.TempDir(), nil)
+	require.NoError(t, err, "NewImageService should succeed")
+
+	_, err = imageService.RestoreVersion(&models.Photo{ID: "photo-1"}, "missing")
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+
+	_, err = imageService.DeleteVersion(&models.Photo{ID: "photo-1"}, "missing")
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+}
+
+func TestCopyFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	src := filepath.Join(tmpDir, "src.bin")
+	dst := filepath.Join(tmpDir, "dst.bin")
+	require.NoError(t, os.WriteFile(src, []byte("original bytes"), 0600))
+
+	require.NoError(t, copyFile(src, dst))
+
+	data, err := os.ReadFile(dst) // #nosec G304 - test file in temp directory
+	require.NoError(t, err)
+	assert.Equal(t, "original bytes", string(data))
+	assert.NoFileExists(t, dst+".tmp")
+}
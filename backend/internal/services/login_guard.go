@@ -0,0 +1,419 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+)
+
+const (
+	loginFailuresFile = "login_failures.json"
+	// maxLoginFailures bounds login_failures.json when many usernames or IPs
+	// are tried; the records that have been quiet longest are dropped first.
+	maxLoginFailures = 1000
+	// pendingLoginTimeout is how long an attempt let through by Check counts
+	// as in progress if it is never recorded.
+	pendingLoginTimeout = time.Minute
+)
+
+// ErrLoginFailureNotFound is returned when clearing failures that are not
+// recorded.
+var ErrLoginFailureNotFound = errors.New("login failure record not found")
+
+// AccountLockedError is returned for logins to a username that is locked
+// after too many failed attempts.
+type AccountLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *AccountLockedError) Error() string {
+	return fmt.Sprintf("account locked, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// LoginGuardConfig configures login rate limits, backoff and lockout.
+type LoginGuardConfig struct {
+	// IPLimit is the number of login attempts allowed per client IP in each
+	// IPWindow, successful or not. Zero disables the limit.
+	IPLimit  int
+	IPWindow time.Duration
+	// FreeFailures is the number of failures per username or IP before
+	// backoff starts. After that, each failure doubles the wait before the next
+	// attempt, from BackoffBase up to BackoffMax.
+	FreeFailures int
+	BackoffBase  time.Duration
+	BackoffMax   time.Duration
+	// LockoutFailures is the number of failures that lock a username for
+	// LockoutDuration. Zero disables lockout.
+	LockoutFailures int
+	LockoutDuration time.Duration
+	// ResetAfter is how long without failures before they are forgotten.
+	ResetAfter time.Duration
+}
+
+// DefaultLoginGuardConfig returns the default login protection settings.
+func DefaultLoginGuardConfig() LoginGuardConfig {
+	return LoginGuardConfig{
+		IPLimit:         20,
+		IPWindow:        15 * time.Minute,
+		FreeFailures:    3,
+		BackoffBase:     time.Second,
+		BackoffMax:      5 * time.Minute,
+		LockoutFailures: 10,
+		LockoutDuration: 30 * time.Minute,
+		ResetAfter:      24 * time.Hour,
+	}
+}
+
+// LoginGuard protects admin logins against password guessing. Attempts are
+// rate limited per client IP, failures per username and per IP add an
+// exponential backoff, and too many failures lock the username for a while.
+// Failures are kept in login_failures.json, so a restart does not reset them.
+// Attempts in progress count as failures until they are recorded, so parallel
+// attempts cannot get past the backoff or lockout.
+type LoginGuard struct {
+	config      LoginGuardConfig
+	fileService *FileService
+	ipLimiter   *RateLimiter
+	mu          sync.Mutex
+	pending     map[string]pendingLogins
+	now         func() time.Time
+}
+
+// pendingLogins counts the attempts in progress for a username or IP.
+type pendingLogins struct {
+	attempts  int
+	startedAt time.Time
+}
+
+// NewLoginGuard creates a new login guard.
+func NewLoginGuard(fileService *FileService, config LoginGuardConfig) *LoginGuard {
+	g := &LoginGuard{
+		config:      config,
+		fileService: fileService,
+		pending:     make(map[string]pendingLogins),
+		now:         time.Now,
+	}
+	if config.IPLimit > 0 {
+		g.ipLimiter = NewRateLimiter(config.IPLimit, config.IPWindow)
+	}
+	return g
+}
+
+// Check records a login attempt and reports whether it may go ahead. It
+// returns a TooManyAttemptsError while the client IP is over its limit or the
+// username or IP is backing off, and an AccountLockedError while the username
+// is locked. An attempt that may go ahead is in progress until it is passed to
+// RecordFailure, RecordSuccess or Release.
+func (g *LoginGuard) Check(username, clientIP string) error {
+	if g.ipLimiter != nil {
+		if err := g.ipLimiter.Allow(clientIP); err != nil {
+			return err
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	failures, err := g.getAll()
+	if err != nil {
+		return err
+	}
+
+	now := g.now()
+	var wait time.Duration
+	for _, kind := range []string{models.LoginFailureUsername, models.LoginFailureIP} {
+		key := loginFailureKey(kind, username, clientIP)
+		if key == "" {
+			continue
+		}
+
+		count, lastFailedAt := 0, time.Time{}
+		if failure := findLoginFailure(failures, kind, key); failure != nil && !g.isStale(failure, now) {
+			if failure.IsLocked(now) {
+				return &AccountLockedError{RetryAfter: failure.LockedUntil.Sub(now)}
+			}
+			count, lastFailedAt = failure.Failures, failure.LastFailedAt
+		}
+		// Attempts in progress may all fail now
+		if pending := g.pendingAttempts(kind, key, now); pending > 0 {
+			count += pending
+			lastFailedAt = now
+			if kind == models.LoginFailureUsername && g.config.LockoutFailures > 0 && count >= g.config.LockoutFailures {
+				return &TooManyAttemptsError{RetryAfter: max(g.backoff(count), time.Second)}
+			}
+		}
+		if next := lastFailedAt.Add(g.backoff(count)); next.Sub(now) > wait {
+			wait = next.Sub(now)
+		}
+	}
+	if wait > 0 {
+		return &TooManyAttemptsError{RetryAfter: wait}
+	}
+
+	for _, kind := range []string{models.LoginFailureUsername, models.LoginFailureIP} {
+		if key := loginFailureKey(kind, username, clientIP); key != "" {
+			g.pending[pendingLoginKey(kind, key)] = pendingLogins{
+				attempts:  g.pendingAttempts(kind, key, now) + 1,
+				startedAt: now,
+			}
+		}
+	}
+	return nil
+}
+
+// Release ends an attempt let through by Check that neither failed nor
+// succeeded, such as one waiting for a second factor or one that ran into an
+// error.
+func (g *LoginGuard) Release(username, clientIP string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.release(username, clientIP)
+}
+
+// RecordFailure counts a failed login for the username and client IP. It
+// reports whether the failure locked the username.
+func (g *LoginGuard) RecordFailure(username, clientIP string) (bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.release(username, clientIP)
+	failures, err := g.getAll()
+	if err != nil {
+		return false, err
+	}
+
+	now := g.now().UTC()
+	locked := false
+	for _, kind := range []string{models.LoginFailureUsername, models.LoginFailureIP} {
+		key := loginFailureKey(kind, username, clientIP)
+		if key == "" {
+			continue
+		}
+
+		failure := findLoginFailure(failures, kind, key)
+		if failure == nil {
+			failures = append(failures, models.LoginFailure{Kind: kind, Key: key})
+			failure = &failures[len(failures)-1]
+		}
+		if failure.Failures == 0 || g.isStale(failure, now) {
+			*failure = models.LoginFailure{Kind: kind, Key: key, FirstFailedAt: now}
+		}
+
+		failure.Failures++
+		failure.LastFailedAt = now
+		if kind == models.LoginFailureUsername {
+			failure.LastIP = clientIP
+			if g.config.LockoutFailures > 0 && failure.Failures >= g.config.LockoutFailures {
+				lockedUntil := now.Add(g.config.LockoutDuration)
+				failure.LockedUntil = &lockedUntil
+				locked = true
+			}
+		}
+	}
+
+	return locked, g.save(g.prune(failures, now))
+}
+
+// RecordSuccess forgets the failures of the username and client IP after a
+// successful login.
+func (g *LoginGuard) RecordSuccess(username, clientIP string) error {
+	if g.ipLimiter != nil {
+		g.ipLimiter.Reset(clientIP)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.release(username, clientIP)
+	failures, err := g.getAll()
+	if err != nil {
+		return err
+	}
+
+	kept := failures[:0]
+	for _, failure := range failures {
+		if !g.matches(&failure, username, clientIP) {
+			kept = append(kept, failure)
+		}
+	}
+	if len(kept) == len(failures) {
+		return nil
+	}
+	return g.save(kept)
+}
+
+// List returns the current failure records, locked usernames first and then
+// the most recent.
+func (g *LoginGuard) List() ([]models.LoginFailure, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	failures, err := g.getAll()
+	if err != nil {
+		return nil, err
+	}
+
+	now := g.now()
+	current := g.prune(failures, now)
+	sort.SliceStable(current, func(i, j int) bool {
+		if current[i].IsLocked(now) != current[j].IsLocked(now) {
+			return current[i].IsLocked(now)
+		}
+		return current[i].LastFailedAt.After(current[j].LastFailedAt)
+	})
+	return current, nil
+}
+
+// Clear forgets the failures of a username or IP, which unlocks a locked
+// username and ends any backoff.
+func (g *LoginGuard) Clear(kind, key string) error {
+	if kind == models.LoginFailureIP && g.ipLimiter != nil {
+		g.ipLimiter.Reset(key)
+	}
+	key = loginFailureKey(kind, key, key)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	failures, err := g.getAll()
+	if err != nil {
+		return err
+	}
+	for i := range failures {
+		if failures[i].Kind == kind && failures[i].Key == key {
+			return g.save(append(failures[:i], failures[i+1:]...))
+		}
+	}
+	return ErrLoginFailureNotFound
+}
+
+// CleanupRateLimits forgets rate limit windows that have ended.
+func (g *LoginGuard) CleanupRateLimits() {
+	if g.ipLimiter != nil {
+		g.ipLimiter.Cleanup()
+	}
+}
+
+// pendingAttempts returns the number of attempts in progress for a username
+// or IP. Attempts that were never recorded stop counting after
+// pendingLoginTimeout.
+func (g *LoginGuard) pendingAttempts(kind, key string, now time.Time) int {
+	pending, ok := g.pending[pendingLoginKey(kind, key)]
+	if !ok || now.Sub(pending.startedAt) >= pendingLoginTimeout {
+		return 0
+	}
+	return pending.attempts
+}
+
+// release ends an attempt in progress for the username and client IP.
+func (g *LoginGuard) release(username, clientIP string) {
+	now := g.now()
+	for _, kind := range []string{models.LoginFailureUsername, models.LoginFailureIP} {
+		key := loginFailureKey(kind, username, clientIP)
+		if key == "" {
+			continue
+		}
+		pendingKey := pendingLoginKey(kind, key)
+		if attempts := g.pendingAttempts(kind, key, now); attempts > 1 {
+			pending := g.pending[pendingKey]
+			pending.attempts--
+			g.pending[pendingKey] = pending
+		} else {
+			delete(g.pending, pendingKey)
+		}
+	}
+}
+
+// backoff returns how long to wait after the given number of failures.
+func (g *LoginGuard) backoff(failures int) time.Duration {
+	if failures <= g.config.FreeFailures {
+		return 0
+	}
+	wait := g.config.BackoffBase
+	for i := g.config.FreeFailures + 1; i < failures && wait < g.config.BackoffMax; i++ {
+		wait *= 2
+	}
+	if wait > g.config.BackoffMax {
+		wait = g.config.BackoffMax
+	}
+	return wait
+}
+
+// isStale reports whether a record no longer counts: its lockout has ended, or
+// it has not been locked and has had no failures for ResetAfter.
+func (g *LoginGuard) isStale(failure *models.LoginFailure, now time.Time) bool {
+	if failure.LockedUntil != nil {
+		return !failure.IsLocked(now)
+	}
+	return now.Sub(failure.LastFailedAt) >= g.config.ResetAfter
+}
+
+// prune drops stale records and, over the limit, those quiet the longest.
+func (g *LoginGuard) prune(failures []models.LoginFailure, now time.Time) []models.LoginFailure {
+	current := make([]models.LoginFailure, 0, len(failures))
+	for _, failure := range failures {
+		if !g.isStale(&failure, now) {
+			current = append(current, failure)
+		}
+	}
+	if len(current) > maxLoginFailures {
+		sort.SliceStable(current, func(i, j int) bool {
+			return current[i].LastFailedAt.After(current[j].LastFailedAt)
+		})
+		current = current[:maxLoginFailures]
+	}
+	return current
+}
+
+func (g *LoginGuard) matches(failure *models.LoginFailure, username, clientIP string) bool {
+	key := loginFailureKey(failure.Kind, username, clientIP)
+	return key != "" && failure.Key == key
+}
+
+func (g *LoginGuard) getAll() ([]models.LoginFailure, error) {
+	if !g.fileService.FileExists(loginFailuresFile) {
+		return []models.LoginFailure{}, nil
+	}
+
+	var collection models.LoginFailureCollection
+	if err := g.fileService.ReadJSON(loginFailuresFile, &collection); err != nil {
+		return nil, fmt.Errorf("failed to read login failures: %w", err)
+	}
+	return collection.LoginFailures, nil
+}
+
+func (g *LoginGuard) save(failures []models.LoginFailure) error {
+	collection := models.LoginFailureCollection{LoginFailures: failures}
+	if err := g.fileService.WriteJSON(loginFailuresFile, &collection); err != nil {
+		return fmt.Errorf("failed to write login failures: %w", err)
+	}
+	return nil
+}
+
+// loginFailureKey returns the key of a record of the given kind: the
+// lowercased username, as usernames are case-insensitive, or the client IP.
+func loginFailureKey(kind, username, clientIP string) string {
+	if kind == models.LoginFailureUsername {
+		return strings.ToLower(username)
+	}
+	return clientIP
+}
+
+func pendingLoginKey(kind, key string) string {
+	return kind + ":" + key
+}
+
+func findLoginFailure(failures []models.LoginFailure, kind, key string) *models.LoginFailure {
+	for i := range failures {
+		if failures[i].Kind == kind && failures[i].Key == key {
+			return &failures[i]
+		}
+	}
+	return nil
+}
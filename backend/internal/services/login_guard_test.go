@@ -0,0 +1,173 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupLoginGuard(t *testing.T, config LoginGuardConfig) (*LoginGuard, *FileService, *time.Time) {
+	fileService, err := NewFileService(t.TempDir())
+	require.NoError(t, err)
+	guard := NewLoginGuard(fileService, config)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	guard.now = func() time.Time { return now }
+	if guard.ipLimiter != nil {
+		guard.ipLimiter.now = guard.now
+	}
+	return guard, fileService, &now
+}
+
+func TestLoginGuard_Backoff(t *testing.T) {
+	config := DefaultLoginGuardConfig()
+	config.LockoutFailures = 0
+	guard, _, now := setupLoginGuard(t, config)
+
+	// The first failures are free
+	for i := 0; i < config.FreeFailures; i++ {
+		require.NoError(t, guard.Check("admin", "192.0.2.1"))
+		_, err := guard.RecordFailure("admin", "192.0.2.1")
+		require.NoError(t, err)
+	}
+	require.NoError(t, guard.Check("admin", "192.0.2.1"))
+
+	// Then each failure doubles the wait
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		_, err := guard.RecordFailure("admin", "192.0.2.1")
+		require.NoError(t, err)
+
+		err = guard.Check("Admin", "198.51.100.7")
+		var tooMany *TooManyAttemptsError
+		require.ErrorAs(t, err, &tooMany, "usernames back off from any IP, case-insensitively")
+		assert.Equal(t, want, tooMany.RetryAfter)
+
+		*now = now.Add(want)
+		require.NoError(t, guard.Check("admin", "192.0.2.1"))
+	}
+
+	// Up to the maximum
+	for i := 0; i < 20; i++ {
+		_, err := guard.RecordFailure("admin", "192.0.2.1")
+		require.NoError(t, err)
+	}
+	err := guard.Check("someone-else", "192.0.2.1")
+	var tooMany *TooManyAttemptsError
+	require.ErrorAs(t, err, &tooMany, "the IP backs off for other usernames too")
+	assert.Equal(t, config.BackoffMax, tooMany.RetryAfter)
+
+	// A success forgets the failures
+	require.NoError(t, guard.RecordSuccess("admin", "192.0.2.1"))
+	assert.NoError(t, guard.Check("admin", "192.0.2.1"))
+}
+
+func TestLoginGuard_Lockout(t *testing.T) {
+	config := DefaultLoginGuardConfig()
+	config.BackoffBase = 0
+	config.LockoutFailures = 3
+	guard, fileService, now := setupLoginGuard(t, config)
+
+	for i := 1; i <= 3; i++ {
+		locked, err := guard.RecordFailure("admin", "192.0.2.1")
+		require.NoError(t, err)
+		assert.Equal(t, i == 3, locked)
+	}
+
+	err := guard.Check("admin", "198.51.100.7")
+	var lockedErr *AccountLockedError
+	require.ErrorAs(t, err, &lockedErr)
+	assert.Equal(t, config.LockoutDuration, lockedErr.RetryAfter)
+
+	// The lockout is persisted
+	restarted := NewLoginGuard(fileService, config)
+	restarted.now = guard.now
+	assert.ErrorAs(t, restarted.Check("admin", "198.51.100.7"), &lockedErr)
+
+	failures, err := guard.List()
+	require.NoError(t, err)
+	require.Len(t, failures, 2)
+	assert.Equal(t, models.LoginFailureUsername, failures[0].Kind, "locked usernames come first")
+	assert.Equal(t, "192.0.2.1", failures[0].LastIP)
+	assert.Equal(t, 3, failures[0].Failures)
+
+	// The owner can unlock it
+	require.NoError(t, guard.Clear(models.LoginFailureUsername, "ADMIN"))
+	assert.NoError(t, guard.Check("admin", "198.51.100.7"))
+	assert.Error(t, guard.Clear(models.LoginFailureUsername, "admin"))
+
+	// Lockouts end by themselves, and the count starts over
+	for i := 0; i < 3; i++ {
+		_, err := guard.RecordFailure("admin", "192.0.2.1")
+		require.NoError(t, err)
+	}
+	*now = now.Add(config.LockoutDuration)
+	assert.NoError(t, guard.Check("admin", "192.0.2.1"))
+	locked, err := guard.RecordFailure("admin", "192.0.2.1")
+	require.NoError(t, err)
+	assert.False(t, locked)
+}
+
+func TestLoginGuard_ParallelAttempts(t *testing.T) {
+	config := DefaultLoginGuardConfig()
+	config.BackoffBase = 0
+	config.LockoutFailures = 3
+	guard, _, now := setupLoginGuard(t, config)
+
+	// Attempts checked before any of them fail count towards the lockout
+	for i := 0; i < 3; i++ {
+		require.NoError(t, guard.Check("admin", "192.0.2.1"))
+	}
+	var tooMany *TooManyAttemptsError
+	require.ErrorAs(t, guard.Check("admin", "198.51.100.7"), &tooMany)
+	for i := 1; i <= 3; i++ {
+		locked, err := guard.RecordFailure("admin", "192.0.2.1")
+		require.NoError(t, err)
+		assert.Equal(t, i == 3, locked)
+	}
+
+	// Released attempts no longer count
+	require.NoError(t, guard.Clear(models.LoginFailureUsername, "admin"))
+	for i := 0; i < 3; i++ {
+		require.NoError(t, guard.Check("admin", "192.0.2.1"))
+	}
+	guard.Release("admin", "192.0.2.1")
+	require.NoError(t, guard.Check("admin", "192.0.2.1"))
+
+	// Nor do attempts that were never recorded, after a while
+	*now = now.Add(pendingLoginTimeout)
+	assert.NoError(t, guard.Check("admin", "192.0.2.1"))
+}
+
+func TestLoginGuard_IPLimit(t *testing.T) {
+	config := DefaultLoginGuardConfig()
+	config.IPLimit = 2
+	guard, _, now := setupLoginGuard(t, config)
+
+	assert.NoError(t, guard.Check("a", "192.0.2.1"))
+	assert.NoError(t, guard.Check("b", "192.0.2.1"))
+	var tooMany *TooManyAttemptsError
+	assert.ErrorAs(t, guard.Check("c", "192.0.2.1"), &tooMany)
+	assert.NoError(t, guard.Check("c", "198.51.100.7"))
+
+	*now = now.Add(config.IPWindow)
+	assert.NoError(t, guard.Check("c", "192.0.2.1"))
+}
+
+func TestLoginGuard_ResetAfterQuiet(t *testing.T) {
+	config := DefaultLoginGuardConfig()
+	guard, _, now := setupLoginGuard(t, config)
+
+	for i := 0; i < config.FreeFailures+1; i++ {
+		_, err := guard.RecordFailure("admin", "192.0.2.1")
+		require.NoError(t, err)
+	}
+	assert.Error(t, guard.Check("admin", "192.0.2.1"))
+
+	*now = now.Add(config.ResetAfter)
+	assert.NoError(t, guard.Check("admin", "192.0.2.1"))
+	failures, err := guard.List()
+	require.NoError(t, err)
+	assert.Empty(t, failures)
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+)
+
+// MediaKind identifies which file of a photo an upload URL points to.
+type MediaKind int
+
+const (
+	// MediaDerivative is a display, thumbnail or cropped version of a photo.
+	MediaDerivative MediaKind = iota
+	// MediaOriginal is the uploaded file of a photo.
+	MediaOriginal
+	// MediaVersion is a previous file of a photo, kept for restoring.
+	MediaVersion
+)
+
+// MediaEntry is the photo file behind an upload URL.
+type MediaEntry struct {
+	Album *models.Album
+	Kind  MediaKind
+}
+
+// MediaIndex maps upload URLs to the album and kind of file they belong to, so
+// access to each file can be decided by its album. It is rebuilt whenever the
+// albums change.
+type MediaIndex struct {
+	albumService *AlbumService
+	entries      map[string]MediaEntry
+	mu           sync.RWMutex
+}
+
+// NewMediaIndex creates a new media index over the albums.
+func NewMediaIndex(albumService *AlbumService) *MediaIndex {
+	return &MediaIndex{
+		albumService: albumService,
+	}
+}
+
+// Publish rebuilds the index from the current albums.
+func (m *MediaIndex) Publish() error {
+	albums, err := m.albumService.GetAll()
+	if err != nil {
+		return err
+	}
+
+	entries := make(map[string]MediaEntry)
+	for i := range albums {
+		album := &albums[i]
+		add := func(url string, kind MediaKind) {
+			if url != "" {
+				entries[url] = MediaEntry{Album: album, Kind: kind}
+			}
+		}
+
+		for j := range album.Photos {
+			photo := &album.Photos[j]
+			add(photo.URLOriginal, MediaOriginal)
+			add(photo.URLDisplay, MediaDerivative)
+			add(photo.URLThumbnail, MediaDerivative)
+			add(photo.URLSquare, MediaDerivative)
+			add(photo.URLPortrait, MediaDerivative)
+			add(photo.URLSocial, MediaDerivative)
+			for k := range photo.Versions {
+				add(photo.Versions[k].URLOriginal, MediaVersion)
+			}
+		}
+	}
+
+	m.mu.Lock()
+	m.entries = entries
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Lookup returns the photo file behind an upload URL.
+func (m *MediaIndex) Lookup(url string) (MediaEntry, bool) {
+	m.mu.RLock()
+	entries := m.entries
+	m.mu.RUnlock()
+
+	if entries == nil {
+		if err := m.Publish(); err != nil {
+			return MediaEntry{}, false
+		}
+		m.mu.RLock()
+		entries = m.entries
+		m.mu.RUnlock()
+	}
+
+	entry, ok := entries[url]
+	return entry, ok
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signedQuery(t *testing.T, signed string) (string, url.Values) {
+	u, err := url.Parse(signed)
+	require.NoError(t, err)
+	return u.Path, u.Query()
+}
+
+func TestMediaSigner(t *testing.T) {
+	signer, err := NewMediaSigner(bytes.Repeat([]byte("k"), 32), time.Hour)
+	require.NoError(t, err)
+	now := time.Now()
+
+	signed := signer.SignURL("/uploads/display/photo.webp", now)
+	path, query := signedQuery(t, signed)
+	assert.Equal(t, "/uploads/display/photo.webp", path)
+	assert.True(t, signer.Verify(path, query, now))
+	assert.True(t, signer.Verify(path, query, now.Add(29*time.Minute)), "valid for at least half the TTL")
+	assert.False(t, signer.Verify(path, query, now.Add(time.Hour+time.Second)), "expired")
+
+	// The same URL is handed out within a window, so browsers can cache it
+	assert.Equal(t, signed, signer.SignURL("/uploads/display/photo.webp", now.Truncate(30*time.Minute)))
+
+	// Signatures are bound to the path and expiry
+	assert.False(t, signer.Verify("/uploads/originals/photo.jpg", query, now))
+	tampered := url.Values{"exp": {query.Get("exp") + "0"}, "sig": {query.Get("sig")}}
+	assert.False(t, signer.Verify(path, tampered, now))
+	assert.False(t, signer.Verify(path, url.Values{}, now))
+
+	// Other secrets do not verify
+	other, err := NewMediaSigner(bytes.Repeat([]byte("x"), 32), time.Hour)
+	require.NoError(t, err)
+	assert.False(t, other.Verify(path, query, now))
+
+	_, err = NewMediaSigner([]byte("too short"), time.Hour)
+	assert.Error(t, err)
+}
+
+func TestMediaSigner_SignAlbum(t *testing.T) {
+	signer, err := NewMediaSigner(bytes.Repeat([]byte("k"), 32), time.Hour)
+	require.NoError(t, err)
+
+	album := models.PublicAlbum{
+		CoverPhoto: &models.PublicPhoto{URLDisplay: "/uploads/display/cover.webp"},
+		Photos: []models.PublicPhoto{{
+			URLDisplay:   "/uploads/display/photo.webp",
+			URLThumbnail: "/uploads/thumbnails/photo.webp",
+		}},
+	}
+	signer.SignAlbum(&album, time.Now())
+
+	assert.True(t, strings.HasPrefix(album.CoverPhoto.URLDisplay, "/uploads/display/cover.webp?exp="))
+	assert.Contains(t, album.Photos[0].URLThumbnail, "&sig=")
+	assert.Empty(t, album.Photos[0].URLOriginal, "missing URLs stay empty")
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// oidcLoginTTL is how long a user has to sign in at the identity provider.
+	oidcLoginTTL = 10 * time.Minute
+	// maxPendingOIDCLogins bounds the logins waiting for the identity
+	// provider; the oldest are dropped first.
+	maxPendingOIDCLogins = 1000
+	// oidcHTTPTimeout limits requests to the identity provider.
+	oidcHTTPTimeout = 10 * time.Second
+)
+
+var (
+	// ErrInvalidOIDCLogin is returned for unknown, expired and reused login
+	// states and for codes or ID tokens the identity provider did not issue.
+	ErrInvalidOIDCLogin = errors.New("invalid single sign-on login")
+
+	// ErrOIDCNotAllowed is returned for identities that no role mapping
+	// matches.
+	ErrOIDCNotAllowed = errors.New("single sign-on user is not allowed")
+)
+
+// OIDCConfig configures single sign-on with an OpenID Connect identity
+// provider.
+type OIDCConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback endpoint registered with the identity
+	// provider.
+	RedirectURL string
+	// Scopes are requested besides "openid".
+	Scopes []string
+	// EmailRoles and GroupRoles map verified emails (in lowercase) and groups
+	// to roles. A user gets the highest role of any that match; users that
+	// match none cannot sign in.
+	EmailRoles map[string]models.Role
+	GroupRoles map[string]models.Role
+	// GroupsClaim is the ID token claim holding the user's groups.
+	GroupsClaim string
+}
+
+// OIDCIdentity is a user signed in at the identity provider.
+type OIDCIdentity struct {
+	Issuer   string
+	Subject  string
+	Username string // Verified email
+	Role     models.Role
+}
+
+// oidcLogin is a login waiting for the identity provider.
+type oidcLogin struct {
+	verifier  string // PKCE code verifier
+	nonce     string
+	expiresAt time.Time
+}
+
+// OIDCService signs users in with the OpenID Connect authorization code flow
+// with PKCE. The provider is discovered on first use, and its signing keys are
+// fetched again when an ID token is signed with an unknown key, so that key
+// rotation needs no restart.
+type OIDCService struct {
+	config OIDCConfig
+	client *http.Client
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+	pending  map[string]*oidcLogin // By state hash
+}
+
+// NewOIDCService creates a new OIDC service. It does not contact the identity
+// provider.
+func NewOIDCService(config OIDCConfig) (*OIDCService, error) {
+	if config.IssuerURL == "" || config.ClientID == "" || config.RedirectURL == "" {
+		return nil, errors.New("OIDC issuer URL, client ID and redirect URL are required")
+	}
+	if len(config.EmailRoles) == 0 && len(config.GroupRoles) == 0 {
+		return nil, errors.New("OIDC needs at least one email or group role mapping")
+	}
+	for _, roles := range []map[string]models.Role{config.EmailRoles, config.GroupRoles} {
+		for key, role := range roles {
+			if !role.IsValid() {
+				return nil, fmt.Errorf("invalid role %q for %q", role, key)
+			}
+		}
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+
+	return &OIDCService{
+		config:  config,
+		client:  &http.Client{Timeout: oidcHTTPTimeout},
+		pending: make(map[string]*oidcLogin),
+	}, nil
+}
+
+// ParseRoleMap parses role mappings such as "alice@example.com=owner,
+// photo-team=editor". Keys are lowercased.
+func ParseRoleMap(value string) (map[string]models.Role, error) {
+	roles := make(map[string]models.Role)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, role, ok := strings.Cut(entry, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid role mapping %q, must be name=role", entry)
+		}
+		r := models.Role(strings.TrimSpace(role))
+		if !r.IsValid() {
+			return nil, fmt.Errorf("invalid role in mapping %q", entry)
+		}
+		roles[key] = r
+	}
+	return roles, nil
+}
+
+// StartLogin begins a login and returns the identity provider URL to send the
+// user to and the state, which the callback must bring back.
+func (s *OIDCService) StartLogin(ctx context.Context) (string, string, error) {
+	provider, err := s.getProvider(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := generateSessionID()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate OIDC state: %w", err)
+	}
+	nonce, err := generateSessionID()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to generate OIDC nonce: %w", err)
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	s.mu.Lock()
+	s.prunePending(time.Now())
+	s.pending[hashSessionToken(state)] = &oidcLogin{
+		verifier:  verifier,
+		nonce:     nonce,
+		expiresAt: time.Now().Add(oidcLoginTTL),
+	}
+	s.mu.Unlock()
+
+	url := s.oauth2Config(provider).AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), oidc.Nonce(nonce))
+	return url, state, nil
+}
+
+// CompleteLogin exchanges the code from the identity provider's callback for
+// an ID token, verifies it and returns the identity with its role. States are
+// single-use.
+func (s *OIDCService) CompleteLogin(ctx context.Context, state, code string) (*OIDCIdentity, error) {
+	key := hashSessionToken(state)
+	s.mu.Lock()
+	login, ok := s.pending[key]
+	delete(s.pending, key)
+	s.mu.Unlock()
+	if !ok || time.Now().After(login.expiresAt) {
+		return nil, ErrInvalidOIDCLogin
+	}
+
+	provider, err := s.getProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, s.client)
+	token, err := s.oauth2Config(provider).Exchange(ctx, code, oauth2.VerifierOption(login.verifier))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOIDCLogin, err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: no ID token", ErrInvalidOIDCLogin)
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: s.config.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOIDCLogin, err)
+	}
+	if idToken.Nonce != login.nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidOIDCLogin)
+	}
+
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidOIDCLogin, err)
+	}
+	return s.identity(idToken, claims)
+}
+
+// CleanupExpired removes logins that were not completed in time.
+func (s *OIDCService) CleanupExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prunePending(time.Now())
+}
+
+// identity maps a verified ID token to a user and their role.
+func (s *OIDCService) identity(idToken *oidc.IDToken, claims map[string]interface{}) (*OIDCIdentity, error) {
+	email, _ := claims["email"].(string)
+	email = strings.ToLower(strings.TrimSpace(email))
+	verified, _ := claims["email_verified"].(bool)
+
+	var role models.Role
+	grant := func(r models.Role) {
+		if !role.Includes(r) {
+			role = r
+		}
+	}
+	if email != "" && verified {
+		if r, ok := s.config.EmailRoles[email]; ok {
+			grant(r)
+		}
+	}
+	for _, group := range claimStrings(claims[s.config.GroupsClaim]) {
+		if r, ok := s.config.GroupRoles[strings.ToLower(group)]; ok {
+			grant(r)
+		}
+	}
+	if role == "" {
+		return nil, ErrOIDCNotAllowed
+	}
+
+	// The username links a first sign-in to a local user, so it only comes
+	// from a verified email: preferred usernames and unverified emails are
+	// chosen by the user at many providers
+	if email == "" || !verified {
+		return nil, fmt.Errorf("%w: no verified email", ErrInvalidOIDCLogin)
+	}
+
+	return &OIDCIdentity{
+		Issuer:   idToken.Issuer,
+		Subject:  idToken.Subject,
+		Username: email,
+		Role:     role,
+	}, nil
+}
+
+// getProvider discovers the identity provider on first use. Failures are not
+// kept, so an unreachable provider is tried again on the next login.
+func (s *OIDCService) getProvider(ctx context.Context) (*oidc.Provider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.provider != nil {
+		return s.provider, nil
+	}
+	// The provider keeps this context to fetch signing keys later, so it must
+	// outlive the request
+	providerCtx := oidc.ClientContext(context.WithoutCancel(ctx), s.client)
+	provider, err := oidc.NewProvider(providerCtx, s.config.IssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC provider: %w", err)
+	}
+	s.provider = provider
+	return provider, nil
+}
+
+func (s *OIDCService) oauth2Config(provider *oidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     s.config.ClientID,
+		ClientSecret: s.config.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  s.config.RedirectURL,
+		Scopes:       append([]string{oidc.ScopeOpenID}, s.config.Scopes...),
+	}
+}
+
+// prunePending drops expired logins, and the oldest ones over
+// maxPendingOIDCLogins. The caller holds s.mu.
+func (s *OIDCService) prunePending(now time.Time) {
+	for key, login := range s.pending {
+		if now.After(login.expiresAt) {
+			delete(s.pending, key)
+		}
+	}
+	for len(s.pending) >= maxPendingOIDCLogins {
+		var oldest string
+		for key, login := range s.pending {
+			if oldest == "" || login.expiresAt.Before(s.pending[oldest].expiresAt) {
+				oldest = key
+			}
+		}
+		delete(s.pending, oldest)
+	}
+}
+
+// claimStrings returns a claim holding a string or a list of strings.
+func claimStrings(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/services/oidctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupOIDC starts an identity provider and returns it with an OIDC service
+// that maps owner@example.com to owner and the photo-team group to editor.
+func setupOIDC(t *testing.T) (*oidctest.IdP, *OIDCService) {
+	idp, err := oidctest.New()
+	require.NoError(t, err)
+	t.Cleanup(idp.Close)
+
+	service, err := NewOIDCService(OIDCConfig{
+		IssuerURL:    idp.Issuer(),
+		ClientID:     oidctest.ClientID,
+		ClientSecret: oidctest.ClientSecret,
+		RedirectURL:  "http://localhost:8080/api/admin/oidc/callback",
+		Scopes:       []string{"email", "profile"},
+		EmailRoles:   map[string]models.Role{"owner@example.com": models.RoleOwner},
+		GroupRoles:   map[string]models.Role{"photo-team": models.RoleEditor, "viewers": models.RoleViewer},
+	})
+	require.NoError(t, err)
+	return idp, service
+}
+
+// signIn starts a login, signs in at the IdP with claims and returns the
+// state and code of the callback.
+func signIn(t *testing.T, idp *oidctest.IdP, start func(context.Context) (string, string, error), claims map[string]interface{}) (string, string) {
+	authURL, state, err := start(context.Background())
+	require.NoError(t, err)
+	callback, err := idp.Authorize(authURL, claims)
+	require.NoError(t, err)
+	u, err := url.Parse(callback)
+	require.NoError(t, err)
+	require.Equal(t, state, u.Query().Get("state"))
+	return state, u.Query().Get("code")
+}
+
+func TestNewOIDCService(t *testing.T) {
+	config := OIDCConfig{
+		IssuerURL:   "https://idp.example.com",
+		ClientID:    "photo-admin",
+		RedirectURL: "http://localhost:8080/api/admin/oidc/callback",
+	}
+	_, err := NewOIDCService(config)
+	assert.Error(t, err, "a role mapping is required")
+
+	config.GroupRoles = map[string]models.Role{"admins": "root"}
+	_, err = NewOIDCService(config)
+	assert.Error(t, err)
+
+	config.GroupRoles = map[string]models.Role{"admins": models.RoleOwner}
+	service, err := NewOIDCService(config)
+	require.NoError(t, err)
+	assert.Equal(t, "groups", service.config.GroupsClaim)
+
+	config.ClientID = ""
+	_, err = NewOIDCService(config)
+	assert.Error(t, err)
+}
+
+func TestParseRoleMap(t *testing.T) {
+	roles, err := ParseRoleMap(" Alice@Example.com=owner, photo-team = editor ,")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]models.Role{"alice@example.com": models.RoleOwner, "photo-team": models.RoleEditor}, roles)
+
+	roles, err = ParseRoleMap("")
+	require.NoError(t, err)
+	assert.Empty(t, roles)
+
+	for _, invalid := range []string{"alice@example.com", "=owner", "team=root"} {
+		_, err := ParseRoleMap(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestOIDCService_CompleteLogin(t *testing.T) {
+	idp, service := setupOIDC(t)
+
+	tests := []struct {
+		name     string
+		claims   map[string]interface{}
+		username string
+		role     models.Role
+		err      error
+	}{
+		{
+			name:     "verified email",
+			claims:   map[string]interface{}{"sub": "1", "email": "Owner@Example.com", "email_verified": true},
+			username: "owner@example.com",
+			role:     models.RoleOwner,
+		},
+		{
+			name:   "unverified email",
+			claims: map[string]interface{}{"sub": "2", "email": "owner@example.com", "email_verified": false},
+			err:    ErrOIDCNotAllowed,
+		},
+		{
+			name:     "group",
+			claims:   map[string]interface{}{"sub": "3", "email": "sam@example.com", "email_verified": true, "groups": []string{"other", "Photo-Team"}},
+			username: "sam@example.com",
+			role:     models.RoleEditor,
+		},
+		{
+			name:   "group without a verified email",
+			claims: map[string]interface{}{"sub": "3", "email": "admin@example.com", "email_verified": false, "groups": "photo-team"},
+			err:    ErrInvalidOIDCLogin,
+		},
+		{
+			name:   "preferred username only",
+			claims: map[string]interface{}{"sub": "3", "preferred_username": "admin", "groups": "photo-team"},
+			err:    ErrInvalidOIDCLogin,
+		},
+		{
+			name:     "highest role wins",
+			claims:   map[string]interface{}{"sub": "4", "email": "owner@example.com", "email_verified": true, "groups": []string{"viewers", "photo-team"}},
+			username: "owner@example.com",
+			role:     models.RoleOwner,
+		},
+		{
+			name:   "no match",
+			claims: map[string]interface{}{"sub": "5", "email": "guest@example.com", "email_verified": true, "groups": "guests"},
+			err:    ErrOIDCNotAllowed,
+		},
+		{
+			name:   "no username",
+			claims: map[string]interface{}{"sub": "6", "groups": "photo-team"},
+			err:    ErrInvalidOIDCLogin,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, code := signIn(t, idp, service.StartLogin, tt.claims)
+			identity, err := service.CompleteLogin(context.Background(), state, code)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, idp.Issuer(), identity.Issuer)
+			assert.Equal(t, tt.claims["sub"], identity.Subject)
+			assert.Equal(t, tt.username, identity.Username)
+			assert.Equal(t, tt.role, identity.Role)
+		})
+	}
+}
+
+func TestOIDCService_CompleteLogin_Invalid(t *testing.T) {
+	idp, service := setupOIDC(t)
+	claims := map[string]interface{}{"sub": "1", "email": "owner@example.com", "email_verified": true}
+
+	// States are single-use
+	state, code := signIn(t, idp, service.StartLogin, claims)
+	_, err := service.CompleteLogin(context.Background(), state, code)
+	require.NoError(t, err)
+	_, err = service.CompleteLogin(context.Background(), state, code)
+	assert.ErrorIs(t, err, ErrInvalidOIDCLogin)
+
+	// Unknown states
+	_, code = signIn(t, idp, service.StartLogin, claims)
+	_, err = service.CompleteLogin(context.Background(), "forged-state", code)
+	assert.ErrorIs(t, err, ErrInvalidOIDCLogin)
+
+	// Codes issued for another login fail the PKCE check
+	_, code = signIn(t, idp, service.StartLogin, claims)
+	otherState, _, err := service.StartLogin(context.Background())
+	require.NoError(t, err)
+	_, err = service.CompleteLogin(context.Background(), otherState, code)
+	assert.ErrorIs(t, err, ErrInvalidOIDCLogin)
+
+	// Expired logins
+	state, code = signIn(t, idp, service.StartLogin, claims)
+	service.mu.Lock()
+	for _, login := range service.pending {
+		login.expiresAt = login.expiresAt.Add(-2 * oidcLoginTTL)
+	}
+	service.mu.Unlock()
+	service.CleanupExpired()
+	_, err = service.CompleteLogin(context.Background(), state, code)
+	assert.ErrorIs(t, err, ErrInvalidOIDCLogin)
+}
+
+func TestOIDCService_KeyRotation(t *testing.T) {
+	idp, service := setupOIDC(t)
+	claims := map[string]interface{}{"sub": "1", "email": "owner@example.com", "email_verified": true}
+
+	state, code := signIn(t, idp, service.StartLogin, claims)
+	_, err := service.CompleteLogin(context.Background(), state, code)
+	require.NoError(t, err)
+
+	// Tokens signed with a new key are accepted after refetching the keys,
+	// without discovering the provider again
+	require.NoError(t, idp.RotateKey(false))
+	state, code = signIn(t, idp, service.StartLogin, claims)
+	_, err = service.CompleteLogin(context.Background(), state, code)
+	require.NoError(t, err)
+	assert.Equal(t, 1, idp.Discoveries)
+	assert.Equal(t, 2, idp.KeyFetches)
+}
+
+func TestAuthService_CompleteOIDCLogin(t *testing.T) {
+	idp, oidc := setupOIDC(t)
+	service := setupAuthService(t)
+	assert.False(t, service.OIDCEnabled())
+	service.SetOIDCService(oidc)
+	assert.True(t, service.OIDCEnabled())
+
+	// A new user is created with the mapped role
+	state, code := signIn(t, idp, service.StartOIDCLogin, map[string]interface{}{"sub": "sam-1", "email": "sam@example.com", "email_verified": true, "groups": "photo-team"})
+	token, err := service.CompleteOIDCLogin(context.Background(), state, code)
+	require.NoError(t, err)
+	session, err := service.ValidateSession(token)
+	require.NoError(t, err)
+	sam, err := service.users.Get(session.UserID)
+	require.NoError(t, err)
+	assert.Equal(t, "sam@example.com", sam.Username)
+	assert.Equal(t, models.RoleEditor, sam.Role)
+	assert.Equal(t, idp.Issuer(), sam.OIDCIssuer)
+	assert.Equal(t, "sam-1", sam.OIDCSubject)
+	assert.Empty(t, sam.PasswordHash)
+	assert.NotNil(t, sam.LastLoginAt)
+
+	// The role follows the identity provider, and the user is found by
+	// subject even after changing emails there
+	state, code = signIn(t, idp, service.StartOIDCLogin, map[string]interface{}{"sub": "sam-1", "email": "samuel@example.com", "email_verified": true, "groups": "viewers"})
+	token, err = service.CompleteOIDCLogin(context.Background(), state, code)
+	require.NoError(t, err)
+	session, err = service.ValidateSession(token)
+	require.NoError(t, err)
+	assert.Equal(t, sam.ID, session.UserID)
+	sam, err = service.users.Get(sam.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleViewer, sam.Role)
+
+	// Existing users are never linked by a name the user chose
+	state, code = signIn(t, idp, service.StartOIDCLogin, map[string]interface{}{"sub": "t-1", "preferred_username": "TestUser", "groups": "photo-team"})
+	_, err = service.CompleteOIDCLogin(context.Background(), state, code)
+	assert.ErrorIs(t, err, ErrInvalidOIDCLogin)
+	owner := userByName(t, service.users, "testuser")
+	assert.Empty(t, owner.OIDCSubject)
+	assert.Equal(t, models.RoleOwner, owner.Role)
+
+	// They are linked by verified email, but the last owner is not demoted
+	require.NoError(t, service.users.Create(&models.User{Username: "lee@example.com", Role: models.RoleOwner}, "Lee-password-1"))
+	require.NoError(t, service.users.Update(owner.ID, &models.User{Username: "testuser", Role: models.RoleViewer}))
+	lee := map[string]interface{}{"sub": "lee-1", "email": "Lee@Example.com", "email_verified": true, "groups": "photo-team"}
+	state, code = signIn(t, idp, service.StartOIDCLogin, lee)
+	_, err = service.CompleteOIDCLogin(context.Background(), state, code)
+	assert.ErrorIs(t, err, ErrLastOwner)
+	assert.Empty(t, userByName(t, service.users, "lee@example.com").OIDCSubject)
+
+	require.NoError(t, service.users.Update(owner.ID, &models.User{Username: "testuser", Role: models.RoleOwner}))
+	state, code = signIn(t, idp, service.StartOIDCLogin, lee)
+	_, err = service.CompleteOIDCLogin(context.Background(), state, code)
+	require.NoError(t, err)
+	linked := userByName(t, service.users, "lee@example.com")
+	assert.Equal(t, "lee-1", linked.OIDCSubject)
+	assert.Equal(t, models.RoleEditor, linked.Role)
+
+	// SSO users have no password to sign in with
+	_, err = service.Authenticate("sam@example.com", "")
+	assert.Error(t, err)
+}
+
+func userByName(t *testing.T, users *UserService, username string) *models.User {
+	all, err := users.GetAll()
+	require.NoError(t, err)
+	for i := range all {
+		if all[i].Username == username {
+			return &all[i]
+		}
+	}
+	t.Fatalf("no user %q", username)
+	return nil
+}
@@ -0,0 +1,266 @@
+// Package oidctest provides a local OpenID Connect identity provider for
+// tests of single sign-on.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// Test client credentials registered with every IdP.
+const (
+	ClientID     = "photo-admin"
+	ClientSecret = "test-client-secret" // pragma: allowlist secret
+)
+
+// authRequest is an authorization code waiting to be exchanged.
+type authRequest struct {
+	redirectURI string
+	challenge   string // PKCE code challenge (S256)
+	nonce       string
+	claims      map[string]interface{}
+}
+
+// IdP is an identity provider served by an httptest server. It supports
+// discovery, the authorization code flow with PKCE, and signing key rotation.
+// Users sign in with Authorize rather than a login page.
+type IdP struct {
+	server *httptest.Server
+
+	mu        sync.Mutex
+	keys      []jose.JSONWebKey // Published keys; the last one signs
+	codes     map[string]*authRequest
+	keyNumber int
+	// Discoveries and KeyFetches count requests to the discovery document and
+	// the key set.
+	Discoveries int
+	KeyFetches  int
+}
+
+// New starts an identity provider. Close it when done.
+func New() (*IdP, error) {
+	p := &IdP{codes: make(map[string]*authRequest)}
+	if err := p.RotateKey(true); err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /keys", p.keySet)
+	mux.HandleFunc("POST /token", p.token)
+	p.server = httptest.NewServer(mux)
+	return p, nil
+}
+
+// Issuer returns the IdP's issuer URL.
+func (p *IdP) Issuer() string {
+	return p.server.URL
+}
+
+// Close shuts the IdP down.
+func (p *IdP) Close() {
+	p.server.Close()
+}
+
+// RotateKey starts signing with a new key. With keepOld the previous keys
+// stay published, as during a planned rotation; otherwise they are dropped.
+func (p *IdP) RotateKey(keepOld bool) error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keyNumber++
+	jwk := jose.JSONWebKey{Key: key, KeyID: fmt.Sprintf("key-%d", p.keyNumber), Algorithm: string(jose.RS256), Use: "sig"}
+	if !keepOld {
+		p.keys = nil
+	}
+	p.keys = append(p.keys, jwk)
+	return nil
+}
+
+// Authorize signs a user with the claims in at the authorization URL that the
+// client sent the browser to, and returns the URL the IdP sends the browser
+// back to, with a code and the state. The claims go in the ID token besides
+// the standard ones; "sub" is required.
+func (p *IdP) Authorize(authURL string, claims map[string]interface{}) (string, error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	switch {
+	case u.Path != "/authorize":
+		return "", fmt.Errorf("unexpected authorization path %q", u.Path)
+	case query.Get("client_id") != ClientID:
+		return "", errors.New("unknown client")
+	case query.Get("response_type") != "code":
+		return "", errors.New("only the code flow is supported")
+	case query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "":
+		return "", errors.New("PKCE with S256 is required")
+	case query.Get("redirect_uri") == "" || query.Get("state") == "":
+		return "", errors.New("redirect URI and state are required")
+	}
+	if _, ok := claims["sub"]; !ok {
+		return "", errors.New("the sub claim is required")
+	}
+
+	code := base64.RawURLEncoding.EncodeToString(randomBytes())
+	p.mu.Lock()
+	p.codes[code] = &authRequest{
+		redirectURI: query.Get("redirect_uri"),
+		challenge:   query.Get("code_challenge"),
+		nonce:       query.Get("nonce"),
+		claims:      claims,
+	}
+	p.mu.Unlock()
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		return "", err
+	}
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirect.RawQuery = values.Encode()
+	return redirect.String(), nil
+}
+
+func (p *IdP) discovery(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	p.Discoveries++
+	p.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.Issuer(),
+		"authorization_endpoint":                p.Issuer() + "/authorize",
+		"token_endpoint":                        p.Issuer() + "/token",
+		"jwks_uri":                              p.Issuer() + "/keys",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *IdP) keySet(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	p.KeyFetches++
+	set := jose.JSONWebKeySet{}
+	for _, key := range p.keys {
+		set.Keys = append(set.Keys, key.Public())
+	}
+	p.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, set)
+}
+
+// token exchanges a code for an ID token, checking the client's credentials,
+// the redirect URI and the PKCE code verifier.
+func (p *IdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request")
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != ClientID || clientSecret != ClientSecret {
+		tokenError(w, "invalid_client")
+		return
+	}
+
+	code := r.PostForm.Get("code")
+	p.mu.Lock()
+	request, ok := p.codes[code]
+	delete(p.codes, code)
+	p.mu.Unlock()
+	if !ok || r.PostForm.Get("grant_type") != "authorization_code" ||
+		r.PostForm.Get("redirect_uri") != request.redirectURI {
+		tokenError(w, "invalid_grant")
+		return
+	}
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != request.challenge {
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss": p.Issuer(),
+		"aud": ClientID,
+		"iat": now.Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+	if request.nonce != "" {
+		claims["nonce"] = request.nonce
+	}
+	for name, value := range request.claims {
+		claims[name] = value
+	}
+	idToken, err := p.sign(claims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": base64.RawURLEncoding.EncodeToString(randomBytes()),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+// sign returns claims as a JWT signed with the current key.
+func (p *IdP) sign(claims map[string]interface{}) (string, error) {
+	p.mu.Lock()
+	key := p.keys[len(p.keys)-1]
+	p.mu.Unlock()
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		return "", err
+	}
+	return signed.CompactSerialize()
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func randomBytes() []byte {
+	b := make([]byte, 24)
+	_, _ = rand.Read(b)
+	return b
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+	// maxPasswordLength bounds the work of hashing a password.
+	maxPasswordLength = 256
+)
+
+// ErrWeakPassword is wrapped by the errors of passwords that the password
+// policy rejects.
+var ErrWeakPassword = errors.New("password too weak")
+
+// commonPasswords are rejected whatever the minimum length. Passwords are
+// compared in lowercase.
+var commonPasswords = map[string]bool{
+	"password": true, "password1": true, "password12": true, "password123": true, "password1234": true,
+	"passw0rd": true, "p@ssw0rd": true, "letmein": true, "letmein123": true, "welcome": true,
+	"welcome123": true, "qwerty": true, "qwerty123": true, "qwertyuiop": true, "asdfghjkl": true,
+	"123456": true, "1234567": true, "12345678": true, "123456789": true, "1234567890": true,
+	"12345678910": true, "0987654321": true, "iloveyou": true, "sunshine": true, "princess": true,
+	"football": true, "baseball": true, "dragon": true, "monkey": true, "trustno1": true,
+	"changeme": true, "changeme123": true, "admin": true, "admin123": true, "administrator": true,
+	"photography": true, "photographer": true, "photos": true, "wedding": true,
+}
+
+// PasswordConfig configures how passwords are hashed and which are accepted.
+type PasswordConfig struct {
+	// Argon2id parameters of new hashes. Hashes made with other parameters,
+	// and bcrypt hashes, are upgraded when their password is next used.
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	// MinLength is the minimum length of admin user passwords.
+	MinLength int
+	// AlbumMinLength is the minimum length of album passwords, which are
+	// shared with visitors and rate limited per album.
+	AlbumMinLength int
+}
+
+// DefaultPasswordConfig returns the default password settings: the argon2id
+// parameters recommended by OWASP.
+func DefaultPasswordConfig() PasswordConfig {
+	return PasswordConfig{
+		Memory:         19 * 1024,
+		Iterations:     2,
+		Parallelism:    1,
+		MinLength:      10,
+		AlbumMinLength: 6,
+	}
+}
+
+var passwordConfig atomic.Pointer[PasswordConfig]
+
+func init() {
+	config := DefaultPasswordConfig()
+	passwordConfig.Store(&config)
+}
+
+// SetPasswordConfig replaces the password settings, normally once at start.
+func SetPasswordConfig(config PasswordConfig) error {
+	if config.Memory < 8*uint32(config.Parallelism) || config.Iterations < 1 || config.Parallelism < 1 {
+		return errors.New("argon2 needs at least 1 iteration, 1 thread and 8 KiB of memory per thread")
+	}
+	if config.MinLength < 1 || config.AlbumMinLength < 1 {
+		return errors.New("minimum password lengths must be at least 1")
+	}
+	passwordConfig.Store(&config)
+	return nil
+}
+
+// ValidatePassword checks a new admin user password against the password
+// policy.
+func ValidatePassword(password string) error {
+	return checkPasswordStrength(password, passwordConfig.Load().MinLength)
+}
+
+// ValidateAlbumPassword checks a new album password against the password
+// policy.
+func ValidateAlbumPassword(password string) error {
+	return checkPasswordStrength(password, passwordConfig.Load().AlbumMinLength)
+}
+
+// checkPasswordStrength rejects passwords that are too short or too long,
+// repeat a single character, or are commonly used.
+func checkPasswordStrength(password string, minLength int) error {
+	length := len([]rune(password))
+	switch {
+	case length < minLength:
+		return fmt.Errorf("%w: must be at least %d characters", ErrWeakPassword, minLength)
+	case len(password) > maxPasswordLength:
+		return fmt.Errorf("%w: must be at most %d bytes", ErrWeakPassword, maxPasswordLength)
+	case strings.Count(password, string([]rune(password)[0])) == length:
+		return fmt.Errorf("%w: must not repeat a single character", ErrWeakPassword)
+	case commonPasswords[strings.ToLower(password)]:
+		return fmt.Errorf("%w: this password is too common", ErrWeakPassword)
+	}
+	return nil
+}
+
+// HashPassword hashes a password with argon2id, encoded in the PHC string
+// format with its parameters and salt.
+func HashPassword(password string) (string, error) {
+	config := passwordConfig.Load()
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, config.Iterations, config.Memory, config.Parallelism, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, config.Memory, config.Iterations, config.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// CheckPassword reports whether password matches an argon2id or bcrypt hash,
+// and, if it does, whether the hash should be replaced by HashPassword's
+// because it uses bcrypt or outdated parameters.
+func CheckPassword(hash, password string) (match, rehash bool) {
+	if !strings.HasPrefix(hash, "$argon2id$") {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil, true
+	}
+
+	params, salt, key, err := decodeArgon2Hash(hash)
+	if err != nil {
+		return false, false
+	}
+	computed := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return false, false
+	}
+
+	config := passwordConfig.Load()
+	return true, params.Memory != config.Memory || params.Iterations != config.Iterations ||
+		params.Parallelism != config.Parallelism || len(key) != argon2KeyLength
+}
+
+// decodeArgon2Hash parses an argon2id hash in the PHC string format.
+func decodeArgon2Hash(hash string) (*PasswordConfig, []byte, []byte, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	fields := strings.Split(hash, "$")
+	if len(fields) != 6 || fields[1] != "argon2id" {
+		return nil, nil, nil, errors.New("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(fields[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, errors.New("unsupported argon2 version")
+	}
+	var params PasswordConfig
+	if _, err := fmt.Sscanf(fields[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+	if params.Iterations < 1 || params.Parallelism < 1 {
+		return nil, nil, nil, errors.New("invalid argon2id parameters")
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(fields[4])
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(fields[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, errors.New("invalid argon2id key")
+	}
+	return &params, salt, key, nil
+}
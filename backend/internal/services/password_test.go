@@ -0,0 +1,160 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// setPasswordConfig changes the password settings for the rest of a test.
+func setPasswordConfig(t *testing.T, change func(*PasswordConfig)) {
+	config := DefaultPasswordConfig()
+	change(&config)
+	require.NoError(t, SetPasswordConfig(config))
+	t.Cleanup(func() {
+		require.NoError(t, SetPasswordConfig(DefaultPasswordConfig()))
+	})
+}
+
+func TestCheckPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse")
+	require.NoError(t, err)
+	match, rehash := CheckPassword(hash, "correct horse")
+	assert.True(t, match)
+	assert.False(t, rehash)
+	match, _ = CheckPassword(hash, "wrong horse")
+	assert.False(t, match)
+
+	// bcrypt hashes still verify, and are upgraded
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("correct horse"), bcrypt.MinCost)
+	require.NoError(t, err)
+	match, rehash = CheckPassword(string(bcryptHash), "correct horse")
+	assert.True(t, match)
+	assert.True(t, rehash)
+	match, _ = CheckPassword(string(bcryptHash), "wrong horse")
+	assert.False(t, match)
+
+	// Hashes with other parameters are upgraded
+	setPasswordConfig(t, func(config *PasswordConfig) { config.Iterations++ })
+	match, rehash = CheckPassword(hash, "correct horse")
+	assert.True(t, match)
+	assert.True(t, rehash)
+
+	for _, invalid := range []string{"", "$argon2id$", "$argon2id$v=19$m=8,t=0,p=1$c2FsdA$a2V5", strings.Replace(hash, "v=19", "v=16", 1)} {
+		match, _ = CheckPassword(invalid, "correct horse")
+		assert.False(t, match, invalid)
+	}
+}
+
+func TestSetPasswordConfig(t *testing.T) {
+	config := DefaultPasswordConfig()
+	config.Iterations = 0
+	assert.Error(t, SetPasswordConfig(config))
+	config = DefaultPasswordConfig()
+	config.MinLength = 0
+	assert.Error(t, SetPasswordConfig(config))
+}
+
+func TestValidatePassword(t *testing.T) {
+	for password, valid := range map[string]bool{
+		"correct horse":            true,
+		"short":                    false,
+		"aaaaaaaaaaaa":             false,
+		"Password123":              false,
+		"qwertyuiop":               false,
+		"é-photo-desk":             true,
+		strings.Repeat("ab", 129):  false,
+		"kodak portra 400 forever": true,
+	} {
+		err := ValidatePassword(password)
+		if valid {
+			assert.NoError(t, err, password)
+		} else {
+			assert.ErrorIs(t, err, ErrWeakPassword, password)
+		}
+	}
+
+	// Album passwords may be shorter
+	assert.NoError(t, ValidateAlbumPassword("family"))
+	assert.ErrorIs(t, ValidateAlbumPassword("fam"), ErrWeakPassword)
+
+	setPasswordConfig(t, func(config *PasswordConfig) { config.MinLength = 20 })
+	assert.ErrorIs(t, ValidatePassword("correct horse"), ErrWeakPassword)
+}
+
+func TestUserService_AuthenticateUpgradesHash(t *testing.T) {
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("owner-pass"), bcrypt.MinCost)
+	require.NoError(t, err)
+	fileService, err := NewFileService(t.TempDir())
+	require.NoError(t, err)
+	service := NewUserService(fileService)
+	_, err = service.Migrate(models.AdminConfig{Username: "admin", PasswordHash: string(bcryptHash)})
+	require.NoError(t, err)
+
+	_, err = service.Authenticate("admin", "wrong-pass")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	users, err := service.GetAll()
+	require.NoError(t, err)
+	assert.Equal(t, string(bcryptHash), users[0].PasswordHash, "wrong passwords change nothing")
+
+	_, err = service.Authenticate("admin", "owner-pass")
+	require.NoError(t, err)
+	users, err = service.GetAll()
+	require.NoError(t, err)
+	upgraded := users[0].PasswordHash
+	assert.True(t, strings.HasPrefix(upgraded, "$argon2id$"))
+
+	// Current hashes are kept
+	_, err = service.Authenticate("admin", "owner-pass")
+	require.NoError(t, err)
+	users, err = service.GetAll()
+	require.NoError(t, err)
+	assert.Equal(t, upgraded, users[0].PasswordHash)
+}
+
+func TestAlbumAccessService_UpgradesPasswordHash(t *testing.T) {
+	fileService, err := NewFileService(t.TempDir())
+	require.NoError(t, err)
+	albums := NewAlbumService(fileService)
+	album := protectedAlbum(t, "secret")
+	album.Title = "Wedding"
+	require.NoError(t, albums.Create(album))
+	bcryptHash := album.PasswordHash
+
+	passwords := NewAlbumPasswordService(fileService)
+	service, err := NewAlbumAccessService(nil, time.Hour)
+	require.NoError(t, err)
+	service.SetAlbumService(albums)
+	service.SetPasswordService(passwords)
+
+	// The album's password hash is upgraded, and the token issued for it
+	album, err = albums.GetByID(album.ID)
+	require.NoError(t, err)
+	token, _, _, err := service.GrantAccess(album, "secret", "192.0.2.1")
+	require.NoError(t, err)
+	stored, err := albums.GetByID(album.ID)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(stored.PasswordHash, "$argon2id$"))
+	_, err = service.ValidateToken(stored, token)
+	assert.NoError(t, err)
+	assert.ErrorContains(t, albums.ReplacePasswordHash(album.ID, bcryptHash, bcryptHash), "changed")
+
+	// So are named passwords'
+	setPasswordConfig(t, func(config *PasswordConfig) { config.Iterations++ })
+	family := &models.AlbumPassword{Name: "Family"}
+	require.NoError(t, passwords.Create(album.ID, family, "family"))
+	setPasswordConfig(t, func(config *PasswordConfig) {})
+	_, _, _, err = service.GrantAccess(stored, "family", "192.0.2.1")
+	require.NoError(t, err)
+	matched, err := passwords.Get(family.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, family.PasswordHash, matched.PasswordHash)
+	match, rehash := CheckPassword(matched.PasswordHash, "family")
+	assert.True(t, match)
+	assert.False(t, rehash)
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+)
+
+const (
+	publicSiteConfigFile = "site_config.json"
+	publicAlbumsFile     = "albums.json"
+	publicAlbumsDir      = "albums"
+)
+
+// publishableSlug matches slugs that are safe to use as a file name.
+var publishableSlug = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
+
+// Publisher rebuilds the public dataset after the admin data changes.
+type Publisher interface {
+	Publish() error
+}
+
+// Publishers runs several publishers in order, stopping at the first error.
+type Publishers []Publisher
+
+// Publish runs every publisher.
+func (p Publishers) Publish() error {
+	for _, publisher := range p {
+		if err := publisher.Publish(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PublishService writes the sanitized dataset the public site reads:
+//
+//	<publicDir>/site_config.json   the site configuration
+//	<publicDir>/albums.json        public, unexpired albums without their photos
+//	<publicDir>/albums/<slug>.json one file per public, unlisted or protected album
+//
+// Protected albums are published as a title card only, and admin-only fields
+// (password hashes, upload filenames, edit stacks, version history) never
+// leave the backend.
+type PublishService struct {
+	publicDir     string
+	albumService  *AlbumService
+	configService *SiteConfigService
+	mu            sync.Mutex
+}
+
+// NewPublishService creates a new publish service writing to publicDir.
+func NewPublishService(publicDir string, albumService *AlbumService, configService *SiteConfigService) (*PublishService, error) {
+	// #nosec G301 - 0755 is appropriate for the public data directory
+	if err := os.MkdirAll(filepath.Join(publicDir, publicAlbumsDir), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create public data directory: %w", err)
+	}
+
+	return &PublishService{
+		publicDir:     publicDir,
+		albumService:  albumService,
+		configService: configService,
+	}, nil
+}
+
+// Publish rewrites the public dataset from the current albums and site config.
+func (s *PublishService) Publish() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	albums, err := s.albumService.GetAll()
+	if err != nil {
+		return err
+	}
+	config, err := s.configService.Get()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	index := models.PublicAlbumIndex{
+		LastUpdated: now,
+		Albums:      []models.PublicAlbum{},
+	}
+	published := make(map[string]bool)
+
+	for i := range albums {
+		album := &albums[i]
+		if album.IsDraft() || album.IsWithdrawn(now) || !publishableSlug.MatchString(album.Slug) {
+			continue
+		}
+
+		if err := s.writeJSON(filepath.Join(publicAlbumsDir, album.Slug+".json"), album.ToPublic(true)); err != nil {
+			return err
+		}
+		published[album.Slug+".json"] = true
+
+		if album.Visibility == "public" {
+			index.Albums = append(index.Albums, album.ToPublic(false))
+		}
+		if album.ID == config.Portfolio.MainAlbumID && !album.IsProtected() {
+			index.MainAlbumSlug = album.Slug
+		}
+	}
+
+	sort.SliceStable(index.Albums, func(i, j int) bool {
+		return index.Albums[i].Order < index.Albums[j].Order
+	})
+
+	if err := s.writeJSON(publicSiteConfigFile, config); err != nil {
+		return err
+	}
+	if err := s.writeJSON(publicAlbumsFile, index); err != nil {
+		return err
+	}
+
+	return s.removeStale(published)
+}
+
+// removeStale deletes album files for albums that were deleted, renamed, expired
+// or made unavailable since the last publish.
+func (s *PublishService) removeStale(published map[string]bool) error {
+	dir := filepath.Join(s.publicDir, publicAlbumsDir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to list public albums: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || published[name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale public album %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// writeJSON writes a public file atomically. Unlike FileService it keeps no
+// backups, since anything in the public directory is served as is.
+func (s *PublishService) writeJSON(filename string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	filePath := filepath.Join(s.publicDir, filename)
+	tmpPath := filePath + ".tmp"
+	// #nosec G306 - 0644 is appropriate for public JSON files
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("failed to publish %s: %w", filename, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupPublishService(t *testing.T) (*AlbumService, *SiteConfigService, string) {
+	tmpDir := t.TempDir()
+	fileService, err := NewFileService(tmpDir)
+	require.NoError(t, err)
+
+	albumService := NewAlbumService(fileService)
+	configService := NewSiteConfigService(fileService)
+	publicDir := filepath.Join(tmpDir, "public")
+
+	publishService, err := NewPublishService(publicDir, albumService, configService)
+	require.NoError(t, err)
+	albumService.SetPublisher(publishService)
+	configService.SetPublisher(publishService)
+
+	return albumService, configService, publicDir
+}
+
+// readPublic reads a published file as generic JSON, so the test sees exactly
+// what a visitor would.
+func readPublic(t *testing.T, publicDir, name string) map[string]interface{} {
+	data, err := os.ReadFile(filepath.Join(publicDir, name)) // #nosec G304 - test file in temp directory
+	require.NoError(t, err)
+
+	var v map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &v))
+	return v
+}
+
+func createAlbumWithPhotos(t *testing.T, albumService *AlbumService, title, visibility string, allowDownloads bool) *models.Album {
+	album := &models.Album{
+		Title:          title,
+		Visibility:     visibility,
+		AllowDownloads: allowDownloads,
+		PasswordHash:   "$2a$10$abcdefghijklmnopqrstuv",
+	}
+	require.NoError(t, albumService.Create(album))
+
+	var photo *models.Photo
+	for _, name := range []string{"photo-1", "photo-2"} {
+		photo = &models.Photo{
+			FilenameOriginal: "IMG_0001.CR3",
+			URLOriginal:      "/uploads/originals/" + name + ".jpg",
+			URLDisplay:       "/uploads/display/" + name + ".webp",
+			URLThumbnail:     "/uploads/thumbnails/" + name + ".webp",
+			FileSizeOriginal: 1024,
+			Edits:            &models.PhotoEdits{Rotate: 90},
+			Versions:         []models.PhotoVersion{{ID: "v1", URLOriginal: "/uploads/versions/v1.jpg"}},
+		}
+		require.NoError(t, albumService.AddPhoto(album.ID, photo))
+	}
+	// The last photo is the cover
+	require.NoError(t, albumService.SetCoverPhoto(album.ID, photo.ID))
+
+	return album
+}
+
+func TestPublishService_Publish(t *testing.T) {
+	albumService, _, publicDir := setupPublishService(t)
+
+	createAlbumWithPhotos(t, albumService, "Public", "public", true)
+	createAlbumWithPhotos(t, albumService, "Unlisted", "unlisted", false)
+	createAlbumWithPhotos(t, albumService, "Protected", "password_protected", true)
+
+	// The index lists public albums only, without their photos
+	index := readPublic(t, publicDir, "albums.json")
+	albums := index["albums"].([]interface{})
+	require.Len(t, albums, 1)
+	summary := albums[0].(map[string]interface{})
+	assert.Equal(t, "public", summary["slug"])
+	assert.Empty(t, summary["photos"])
+	assert.EqualValues(t, 2, summary["photo_count"])
+	assert.Equal(t, "/uploads/display/photo-2.webp", summary["cover_photo"].(map[string]interface{})["url_display"])
+
+	// Public album: photos without admin-only fields
+	public := readPublic(t, publicDir, "albums/public.json")
+	assert.NotContains(t, public, "password_hash")
+	photos := public["photos"].([]interface{})
+	require.Len(t, photos, 2)
+	photo := photos[0].(map[string]interface{})
+	assert.Equal(t, "/uploads/originals/photo-1.jpg", photo["url_original"], "downloads are allowed")
+	assert.NotContains(t, photo, "filename_original")
+	assert.NotContains(t, photo, "edits")
+	assert.NotContains(t, photo, "versions")
+
+	// Unlisted album: reachable by slug, originals withheld
+	unlisted := readPublic(t, publicDir, "albums/unlisted.json")
+	photo = unlisted["photos"].([]interface{})[0].(map[string]interface{})
+	assert.NotContains(t, photo, "url_original")
+	assert.NotContains(t, photo, "file_size_original")
+
+	// Protected album: title card only
+	protected := readPublic(t, publicDir, "albums/protected.json")
+	assert.Equal(t, "Protected", protected["title"])
+	assert.NotContains(t, protected, "password_hash")
+	assert.NotContains(t, protected, "cover_photo")
+	assert.Empty(t, protected["photos"])
+}
+
+func TestPublishService_RemovesStaleAlbums(t *testing.T) {
+	albumService, _, publicDir := setupPublishService(t)
+
+	createAlbumWithPhotos(t, albumService, "Summer", "public", false)
+	album, err := albumService.GetBySlug("summer")
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(publicDir, "albums", "summer.json"))
+
+	// Renaming the slug removes the old file
+	album.Slug = "summer-2025"
+	require.NoError(t, albumService.Update(album.ID, album))
+	assert.NoFileExists(t, filepath.Join(publicDir, "albums", "summer.json"))
+	assert.FileExists(t, filepath.Join(publicDir, "albums", "summer-2025.json"))
+
+	// Expired albums are withdrawn
+	expired := time.Now().Add(-time.Hour)
+	album.ExpirationDate = &expired
+	require.NoError(t, albumService.Update(album.ID, album))
+	assert.NoFileExists(t, filepath.Join(publicDir, "albums", "summer-2025.json"))
+	assert.Empty(t, readPublic(t, publicDir, "albums.json")["albums"])
+
+	// Deleted albums are withdrawn
+	other := createAlbumWithPhotos(t, albumService, "Winter", "public", false)
+	require.NoError(t, albumService.Delete(other.ID))
+	assert.NoFileExists(t, filepath.Join(publicDir, "albums", "winter.json"))
+}
+
+func TestPublishService_MainAlbum(t *testing.T) {
+	albumService, configService, publicDir := setupPublishService(t)
+
+	created := createAlbumWithPhotos(t, albumService, "Portfolio", "unlisted", true)
+	require.NoError(t, configService.SetMainPortfolioAlbum(created.ID))
+	assert.Equal(t, "portfolio", readPublic(t, publicDir, "albums.json")["main_album_slug"])
+	config := readPublic(t, publicDir, "site_config.json")
+	assert.Equal(t, created.ID, config["portfolio"].(map[string]interface{})["main_album_id"])
+
+	// A protected album is never published as the portfolio
+	album, err := albumService.GetByID(created.ID)
+	require.NoError(t, err)
+	album.Visibility = "password_protected"
+	require.NoError(t, albumService.Update(album.ID, album))
+	assert.NotContains(t, readPublic(t, publicDir, "albums.json"), "main_album_slug")
+}
+
+func TestPublishService_Drafts(t *testing.T) {
+	albumService, configService, publicDir := setupPublishService(t)
+
+	created := createAlbumWithPhotos(t, albumService, "Upcoming", "public", true)
+	require.NoError(t, configService.SetMainPortfolioAlbum(created.ID))
+	assert.FileExists(t, filepath.Join(publicDir, "albums", "upcoming.json"))
+
+	// Drafts are never published, not even as the portfolio
+	album, err := albumService.GetByID(created.ID)
+	require.NoError(t, err)
+	album.Visibility = "draft"
+	require.NoError(t, albumService.Update(album.ID, album))
+	assert.NoFileExists(t, filepath.Join(publicDir, "albums", "upcoming.json"))
+	index := readPublic(t, publicDir, "albums.json")
+	assert.Empty(t, index["albums"])
+	assert.NotContains(t, index, "main_album_slug")
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TooManyAttemptsError is returned when a rate limit has been reached.
+type TooManyAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TooManyAttemptsError) Error() string {
+	return fmt.Sprintf("too many attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// RateLimiter allows a fixed number of attempts per key in each time window.
+type RateLimiter struct {
+	limit   int
+	window  time.Duration
+	windows map[string]*rateWindow
+	mu      sync.Mutex
+	now     func() time.Time
+}
+
+type rateWindow struct {
+	count int
+	start time.Time
+}
+
+// NewRateLimiter creates a rate limiter allowing limit attempts per key per window.
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:   limit,
+		window:  window,
+		windows: make(map[string]*rateWindow),
+		now:     time.Now,
+	}
+}
+
+// Allow records an attempt for key. Once the limit is reached it returns a
+// TooManyAttemptsError saying when the window ends.
+func (l *RateLimiter) Allow(key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	w, exists := l.windows[key]
+	if !exists || now.Sub(w.start) >= l.window {
+		w = &rateWindow{start: now}
+		l.windows[key] = w
+	}
+
+	if w.count >= l.limit {
+		return &TooManyAttemptsError{RetryAfter: w.start.Add(l.window).Sub(now)}
+	}
+
+	w.count++
+	return nil
+}
+
+// Reset forgets the attempts recorded for key.
+func (l *RateLimiter) Reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.windows, key)
+}
+
+// Cleanup removes windows that have ended.
+func (l *RateLimiter) Cleanup() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	for key, w := range l.windows {
+		if now.Sub(w.start) >= l.window {
+			delete(l.windows, key)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+)
+
+const (
+	selectionsFile = "selections.json"
+
+	maxSelectionNoteLen  = 2000
+	maxSelectionNameLen  = 200
+	maxSelectionPhotoLen = 500 // Per-photo note length
+
+	maxAlbumSelections    = 500 // Selections per album
+	selectionStartWindow  = 15 * time.Minute
+	selectionStartIPLimit = 10 // New selections per client IP per window
+)
+
+var (
+	// ErrInvalidSelection is wrapped by errors for selections a client may not save or submit.
+	ErrInvalidSelection = errors.New("invalid selection")
+
+	// ErrSelectionLocked is returned for changes to a selection the admin has locked.
+	ErrSelectionLocked = errors.New("selection is locked")
+
+	// ErrTooManySelections is returned for new selections on an album that has
+	// as many as it may hold.
+	ErrTooManySelections = errors.New("album has too many selections")
+)
+
+// SelectionService manages clients' photo selections on proofing albums.
+type SelectionService struct {
+	fileService *FileService
+	ipLimiter   *RateLimiter
+	mu          sync.Mutex
+}
+
+// NewSelectionService creates a new selection service.
+func NewSelectionService(fileService *FileService) *SelectionService {
+	return &SelectionService{
+		fileService: fileService,
+		ipLimiter:   NewRateLimiter(selectionStartIPLimit, selectionStartWindow),
+	}
+}
+
+// Start returns the selection of a share link, creating it on first use, or
+// creates a new selection for a visitor without a share link. It reports
+// whether the selection was created. Selections without a share link are rate
+// limited per client IP, returning a TooManyAttemptsError over the limit, and
+// an album holds at most maxAlbumSelections, after which ErrTooManySelections
+// is returned.
+func (s *SelectionService) Start(albumID, shareLinkID, clientName, clientIP string) (*models.Selection, bool, error) {
+	if len(clientName) > maxSelectionNameLen {
+		return nil, false, fmt.Errorf("%w: client name must be at most %d characters", ErrInvalidSelection, maxSelectionNameLen)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	selections, err := s.getAll()
+	if err != nil {
+		return nil, false, err
+	}
+
+	albumSelections := 0
+	for i := range selections {
+		if selections[i].AlbumID != albumID {
+			continue
+		}
+		if shareLinkID != "" && selections[i].ShareLinkID == shareLinkID {
+			return &selections[i], false, nil
+		}
+		albumSelections++
+	}
+	if albumSelections >= maxAlbumSelections {
+		return nil, false, ErrTooManySelections
+	}
+	// Share links get one selection each; other visitors could make any number
+	if shareLinkID == "" {
+		if err := s.ipLimiter.Allow(clientIP); err != nil {
+			return nil, false, err
+		}
+	}
+
+	now := time.Now().UTC()
+	selection := models.Selection{
+		ID:          uuid.New().String(),
+		AlbumID:     albumID,
+		ShareLinkID: shareLinkID,
+		ClientName:  clientName,
+		Favorites:   []string{},
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	selections = append(selections, selection)
+	if err := s.save(selections); err != nil {
+		return nil, false, err
+	}
+
+	return &selection, true, nil
+}
+
+// CleanupRateLimits forgets rate limit windows that have ended.
+func (s *SelectionService) CleanupRateLimits() {
+	s.ipLimiter.Cleanup()
+}
+
+// Get returns an album's selection by its ID.
+func (s *SelectionService) Get(albumID, id string) (*models.Selection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	selections, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+	for i := range selections {
+		if selections[i].ID == id && selections[i].AlbumID == albumID {
+			return &selections[i], nil
+		}
+	}
+	return nil, errors.New("selection not found")
+}
+
+// ListByAlbum returns an album's selections, oldest first.
+func (s *SelectionService) ListByAlbum(albumID string) ([]models.Selection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	selections, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+
+	albumSelections := []models.Selection{}
+	for _, selection := range selections {
+		if selection.AlbumID == albumID {
+			albumSelections = append(albumSelections, selection)
+		}
+	}
+	return albumSelections, nil
+}
+
+// Update replaces a selection's favourites and notes, and its client name when
+// one is given. Favourites must be photos of the album, within its selection
+// limit; repeated photos are dropped.
+func (s *SelectionService) Update(album *models.Album, id string, changes *models.Selection) (*models.Selection, error) {
+	favorites, err := selectionFavorites(album, changes.Favorites)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateSelectionNotes(album, changes); err != nil {
+		return nil, err
+	}
+
+	return s.update(album.ID, id, func(selection *models.Selection) error {
+		if changes.ClientName != "" {
+			selection.ClientName = changes.ClientName
+		}
+		selection.Favorites = favorites
+		selection.PhotoNotes = changes.PhotoNotes
+		selection.Note = changes.Note
+		return nil
+	})
+}
+
+// Submit marks a selection as submitted. A submitted selection may still be
+// changed and submitted again until the admin locks it.
+func (s *SelectionService) Submit(album *models.Album, id string) (*models.Selection, error) {
+	return s.update(album.ID, id, func(selection *models.Selection) error {
+		// Photos may have been removed, or the limit lowered, since the
+		// selection was saved
+		favorites := []string{}
+		for _, id := range selection.Favorites {
+			if findAlbumPhoto(album, id) != nil {
+				favorites = append(favorites, id)
+			}
+		}
+		if len(favorites) == 0 {
+			return fmt.Errorf("%w: pick at least one photo", ErrInvalidSelection)
+		}
+		if _, err := selectionFavorites(album, favorites); err != nil {
+			return err
+		}
+		selection.Favorites = favorites
+		now := time.Now().UTC()
+		selection.SubmittedAt = &now
+		return nil
+	})
+}
+
+// SetLocked locks or unlocks a selection. Locked selections cannot be changed
+// or submitted by the client.
+func (s *SelectionService) SetLocked(albumID, id string, locked bool) (*models.Selection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	selections, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range selections {
+		selection := &selections[i]
+		if selection.ID != id || selection.AlbumID != albumID {
+			continue
+		}
+		if locked && selection.LockedAt == nil {
+			now := time.Now().UTC()
+			selection.LockedAt = &now
+		} else if !locked {
+			selection.LockedAt = nil
+		}
+		if err := s.save(selections); err != nil {
+			return nil, err
+		}
+		return selection, nil
+	}
+
+	return nil, errors.New("selection not found")
+}
+
+// update applies a client's change to an unlocked selection and saves it.
+func (s *SelectionService) update(albumID, id string, fn func(*models.Selection) error) (*models.Selection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	selections, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range selections {
+		selection := &selections[i]
+		if selection.ID != id || selection.AlbumID != albumID {
+			continue
+		}
+		if selection.IsLocked() {
+			return nil, ErrSelectionLocked
+		}
+		if err := fn(selection); err != nil {
+			return nil, err
+		}
+		selection.UpdatedAt = time.Now().UTC()
+		if err := s.save(selections); err != nil {
+			return nil, err
+		}
+		return selection, nil
+	}
+
+	return nil, errors.New("selection not found")
+}
+
+func (s *SelectionService) getAll() ([]models.Selection, error) {
+	if !s.fileService.FileExists(selectionsFile) {
+		return []models.Selection{}, nil
+	}
+
+	var collection models.SelectionCollection
+	if err := s.fileService.ReadJSON(selectionsFile, &collection); err != nil {
+		return nil, fmt.Errorf("failed to read selections: %w", err)
+	}
+	return collection.Selections, nil
+}
+
+func (s *SelectionService) save(selections []models.Selection) error {
+	collection := models.SelectionCollection{Selections: selections}
+	if err := s.fileService.WriteJSON(selectionsFile, &collection); err != nil {
+		return fmt.Errorf("failed to write selections: %w", err)
+	}
+	return nil
+}
+
+// selectionFavorites checks picked photo IDs against the album and its
+// selection limit, dropping repeats.
+func selectionFavorites(album *models.Album, photoIDs []string) ([]string, error) {
+	inAlbum := make(map[string]bool, len(album.Photos))
+	for i := range album.Photos {
+		inAlbum[album.Photos[i].ID] = true
+	}
+
+	favorites := []string{}
+	picked := make(map[string]bool, len(photoIDs))
+	for _, id := range photoIDs {
+		if !inAlbum[id] {
+			return nil, fmt.Errorf("%w: photo %s is not in the album", ErrInvalidSelection, id)
+		}
+		if !picked[id] {
+			picked[id] = true
+			favorites = append(favorites, id)
+		}
+	}
+
+	if album.Proofing != nil && album.Proofing.SelectionLimit > 0 && len(favorites) > album.Proofing.SelectionLimit {
+		return nil, fmt.Errorf("%w: choose at most %d photos", ErrInvalidSelection, album.Proofing.SelectionLimit)
+	}
+	return favorites, nil
+}
+
+// validateSelectionNotes checks the lengths of a selection's name and notes,
+// and that photo notes are for photos of the album.
+func validateSelectionNotes(album *models.Album, selection *models.Selection) error {
+	if len(selection.ClientName) > maxSelectionNameLen {
+		return fmt.Errorf("%w: client name must be at most %d characters", ErrInvalidSelection, maxSelectionNameLen)
+	}
+	if len(selection.Note) > maxSelectionNoteLen {
+		return fmt.Errorf("%w: note must be at most %d characters", ErrInvalidSelection, maxSelectionNoteLen)
+	}
+	for photoID, note := range selection.PhotoNotes {
+		if findAlbumPhoto(album, photoID) == nil {
+			return fmt.Errorf("%w: photo %s is not in the album", ErrInvalidSelection, photoID)
+		}
+		if len(note) > maxSelectionPhotoLen {
+			return fmt.Errorf("%w: photo notes must be at most %d characters", ErrInvalidSelection, maxSelectionPhotoLen)
+		}
+	}
+	return nil
+}
+
+// findAlbumPhoto returns the album's photo with the given ID, or nil.
+func findAlbumPhoto(album *models.Album, photoID string) *models.Photo {
+	for i := range album.Photos {
+		if album.Photos[i].ID == photoID {
+			return &album.Photos[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupSelectionService(t *testing.T) (*SelectionService, *models.Album) {
+	fileService, err := NewFileService(t.TempDir())
+	require.NoError(t, err)
+
+	album := &models.Album{
+		ID:       "album-1",
+		Proofing: &models.Proofing{Enabled: true, SelectionLimit: 2},
+		Photos:   []models.Photo{{ID: "p1"}, {ID: "p2"}, {ID: "p3"}},
+	}
+	return NewSelectionService(fileService), album
+}
+
+func TestSelectionService_Start(t *testing.T) {
+	service, album := setupSelectionService(t)
+
+	// One selection per share link
+	first, created, err := service.Start(album.ID, "link-1", "Bride's family", "192.0.2.1")
+	require.NoError(t, err)
+	assert.True(t, created)
+	again, created, err := service.Start(album.ID, "link-1", "", "192.0.2.1")
+	require.NoError(t, err)
+	assert.False(t, created)
+	assert.Equal(t, first.ID, again.ID)
+
+	// A new selection for each password visitor
+	a, _, err := service.Start(album.ID, "", "Anna", "192.0.2.1")
+	require.NoError(t, err)
+	b, _, err := service.Start(album.ID, "", "Ben", "192.0.2.1")
+	require.NoError(t, err)
+	assert.NotEqual(t, a.ID, b.ID)
+
+	selections, err := service.ListByAlbum(album.ID)
+	require.NoError(t, err)
+	assert.Len(t, selections, 3)
+}
+
+func TestSelectionService_StartLimits(t *testing.T) {
+	service, album := setupSelectionService(t)
+
+	// New selections are rate limited per client IP
+	for i := 0; i < selectionStartIPLimit; i++ {
+		_, created, err := service.Start(album.ID, "", "", "192.0.2.1")
+		require.NoError(t, err)
+		assert.True(t, created)
+	}
+	_, _, err := service.Start(album.ID, "", "", "192.0.2.1")
+	var tooMany *TooManyAttemptsError
+	assert.ErrorAs(t, err, &tooMany)
+	_, _, err = service.Start(album.ID, "", "", "198.51.100.7")
+	assert.NoError(t, err)
+
+	// And an album holds a limited number of them
+	selections, err := service.getAll()
+	require.NoError(t, err)
+	for len(selections) < maxAlbumSelections {
+		selections = append(selections, models.Selection{ID: uuid.New().String(), AlbumID: album.ID})
+	}
+	require.NoError(t, service.save(selections))
+	_, _, err = service.Start(album.ID, "", "", "203.0.113.9")
+	assert.ErrorIs(t, err, ErrTooManySelections)
+	_, _, err = service.Start("album-2", "", "", "203.0.113.9")
+	assert.NoError(t, err, "other albums are not affected")
+}
+
+func TestSelectionService_Update(t *testing.T) {
+	service, album := setupSelectionService(t)
+	selection, _, err := service.Start(album.ID, "", "", "192.0.2.1")
+	require.NoError(t, err)
+
+	updated, err := service.Update(album, selection.ID, &models.Selection{
+		ClientName: "Anna",
+		Favorites:  []string{"p2", "p1", "p2"},
+		PhotoNotes: map[string]string{"p1": "Crop tighter"},
+		Note:       "For the album",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p2", "p1"}, updated.Favorites, "repeats are dropped")
+	assert.Equal(t, "Anna", updated.ClientName)
+
+	_, err = service.Update(album, selection.ID, &models.Selection{Favorites: []string{"p1", "p2", "p3"}})
+	assert.ErrorIs(t, err, ErrInvalidSelection, "over the limit")
+
+	_, err = service.Update(album, selection.ID, &models.Selection{Favorites: []string{"other"}})
+	assert.ErrorIs(t, err, ErrInvalidSelection, "photo of another album")
+
+	_, err = service.Update(album, selection.ID, &models.Selection{PhotoNotes: map[string]string{"other": "note"}})
+	assert.ErrorIs(t, err, ErrInvalidSelection, "note on a photo of another album")
+}
+
+func TestSelectionService_SubmitAndLock(t *testing.T) {
+	service, album := setupSelectionService(t)
+	selection, _, err := service.Start(album.ID, "", "", "192.0.2.1")
+	require.NoError(t, err)
+
+	_, err = service.Submit(album, selection.ID)
+	assert.ErrorIs(t, err, ErrInvalidSelection, "empty selections cannot be submitted")
+
+	_, err = service.Update(album, selection.ID, &models.Selection{Favorites: []string{"p1", "p3"}})
+	require.NoError(t, err)
+
+	// Photos removed since are left out
+	album.Photos = album.Photos[:2]
+	submitted, err := service.Submit(album, selection.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, submitted.SubmittedAt)
+	assert.Equal(t, []string{"p1"}, submitted.Favorites)
+
+	locked, err := service.SetLocked(album.ID, selection.ID, true)
+	require.NoError(t, err)
+	assert.True(t, locked.IsLocked())
+
+	_, err = service.Update(album, selection.ID, &models.Selection{Favorites: []string{"p2"}})
+	assert.ErrorIs(t, err, ErrSelectionLocked)
+	_, err = service.Submit(album, selection.ID)
+	assert.ErrorIs(t, err, ErrSelectionLocked)
+
+	unlocked, err := service.SetLocked(album.ID, selection.ID, false)
+	require.NoError(t, err)
+	assert.False(t, unlocked.IsLocked())
+	_, err = service.Update(album, selection.ID, &models.Selection{Favorites: []string{"p2"}})
+	assert.NoError(t, err)
+
+	_, err = service.SetLocked("album-2", selection.ID, true)
+	assert.EqualError(t, err, "selection not found")
+}
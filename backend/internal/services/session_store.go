@@ -0,0 +1,195 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const sessionsFile = "sessions.json"
+
+// ErrSessionNotFound is returned by session stores for unknown session IDs.
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionStore keeps admin sessions. Sessions are stored by their ID, which
+// is the hash of the session token, so a leaked store cannot be used to sign
+// in. Stores return copies, so callers must Put a session to change it.
+type SessionStore interface {
+	Get(id string) (*Session, error)
+	Put(session *Session) error
+	Delete(id string) error
+	// List returns copies of every session that match reports true for.
+	List(match func(*Session) bool) ([]Session, error)
+	// DeleteWhere removes every session that match reports true for.
+	DeleteWhere(match func(*Session) bool) error
+}
+
+// MemorySessionStore keeps sessions in memory; they are lost on restart.
+type MemorySessionStore struct {
+	sessions map[string]Session
+	mu       sync.Mutex
+}
+
+// NewMemorySessionStore creates a new in-memory session store.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{
+		sessions: make(map[string]Session),
+	}
+}
+
+// Get returns a copy of a session.
+func (s *MemorySessionStore) Get(id string) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+// Put stores a session, replacing any with the same ID.
+func (s *MemorySessionStore) Put(session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[session.ID] = *session
+	return nil
+}
+
+// Delete removes a session.
+func (s *MemorySessionStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, id)
+	return nil
+}
+
+// List returns copies of every session that match reports true for.
+func (s *MemorySessionStore) List(match func(*Session) bool) ([]Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions := []Session{}
+	for _, session := range s.sessions {
+		if match(&session) {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+// DeleteWhere removes every session that match reports true for.
+func (s *MemorySessionStore) DeleteWhere(match func(*Session) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteWhere(match)
+	return nil
+}
+
+// deleteWhere removes matching sessions and reports whether any were removed.
+// The caller holds s.mu.
+func (s *MemorySessionStore) deleteWhere(match func(*Session) bool) bool {
+	deleted := false
+	for id, session := range s.sessions {
+		if match(&session) {
+			delete(s.sessions, id)
+			deleted = true
+		}
+	}
+	return deleted
+}
+
+// FileSessionStore keeps sessions in memory and writes them through to
+// sessions.json in the data directory, so they survive restarts.
+type FileSessionStore struct {
+	MemorySessionStore
+	fileService *FileService
+}
+
+// NewFileSessionStore creates a file-backed session store and loads the
+// sessions saved in the data directory, dropping expired ones.
+func NewFileSessionStore(fileService *FileService) (*FileSessionStore, error) {
+	s := &FileSessionStore{
+		MemorySessionStore: MemorySessionStore{sessions: make(map[string]Session)},
+		fileService:        fileService,
+	}
+
+	if fileService.FileExists(sessionsFile) {
+		var collection struct {
+			Sessions []Session `json:"sessions"`
+		}
+		if err := fileService.ReadJSON(sessionsFile, &collection); err != nil {
+			return nil, fmt.Errorf("failed to read sessions: %w", err)
+		}
+		now := time.Now()
+		for _, session := range collection.Sessions {
+			if now.Before(session.ExpiresAt) {
+				s.sessions[session.ID] = session
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// Put stores a session, replacing any with the same ID.
+func (s *FileSessionStore) Put(session *Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[session.ID] = *session
+	return s.save()
+}
+
+// Delete removes a session.
+func (s *FileSessionStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sessions[id]; !ok {
+		return nil
+	}
+	delete(s.sessions, id)
+	return s.save()
+}
+
+// DeleteWhere removes every session that match reports true for.
+func (s *FileSessionStore) DeleteWhere(match func(*Session) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.deleteWhere(match) {
+		return nil
+	}
+	return s.save()
+}
+
+// save writes every session to disk. The caller holds s.mu.
+func (s *FileSessionStore) save() error {
+	collection := struct {
+		Sessions []Session `json:"sessions"`
+	}{
+		Sessions: make([]Session, 0, len(s.sessions)),
+	}
+	for _, session := range s.sessions {
+		collection.Sessions = append(collection.Sessions, session)
+	}
+	if err := s.fileService.WriteJSON(sessionsFile, &collection); err != nil {
+		return fmt.Errorf("failed to write sessions: %w", err)
+	}
+	return nil
+}
+
+// hashSessionToken returns the ID under which a session token's session is
+// stored.
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
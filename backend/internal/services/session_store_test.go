@@ -0,0 +1,82 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSessionStore_SurvivesRestart(t *testing.T) {
+	dataDir := t.TempDir()
+	fileService, err := NewFileService(dataDir)
+	require.NoError(t, err)
+	hash, err := HashPassword("test123")
+	require.NoError(t, err)
+	_, err = NewUserService(fileService).Migrate(models.AdminConfig{Username: "testuser", PasswordHash: hash})
+	require.NoError(t, err)
+
+	newService := func() *AuthService {
+		store, err := NewFileSessionStore(fileService)
+		require.NoError(t, err)
+		service := NewAuthService(NewUserService(fileService), time.Hour)
+		service.SetSessionStore(store)
+		return service
+	}
+
+	service := newService()
+	token, err := service.Authenticate("testuser", "test123")
+	require.NoError(t, err)
+	expiredToken, err := service.Authenticate("testuser", "test123")
+	require.NoError(t, err)
+	expireSession(t, service, expiredToken)
+
+	// Only the token's hash is stored
+	data, err := os.ReadFile(filepath.Join(dataDir, sessionsFile))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), token)
+	assert.Contains(t, string(data), hashSessionToken(token))
+
+	// After a restart the session is still valid, and the expired one is gone
+	restarted := newService()
+	session, err := restarted.ValidateSession(token)
+	require.NoError(t, err)
+	assert.Equal(t, "testuser", session.Username)
+	_, err = restarted.store.Get(hashSessionToken(expiredToken))
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+
+	// Logging out is persisted too
+	restarted.InvalidateSession(token)
+	_, err = newService().ValidateSession(token)
+	assert.Error(t, err)
+}
+
+func TestAuthService_MaxSessionLifetime(t *testing.T) {
+	service := setupAuthService(t)
+	service.SetMaxSessionLifetime(2 * time.Hour)
+
+	token, session := login(t, service, "test123")
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), session.ExpiresAt, time.Minute, "capped below the 24 hour TTL")
+
+	// Activity extends the session, but not beyond the maximum lifetime
+	stored, err := service.store.Get(session.ID)
+	require.NoError(t, err)
+	stored.CreatedAt = time.Now().Add(-90 * time.Minute)
+	stored.ExpiresAt = time.Now().Add(time.Minute)
+	require.NoError(t, service.store.Put(stored))
+
+	session, err = service.ValidateSession(token)
+	require.NoError(t, err)
+	assert.WithinDuration(t, stored.CreatedAt.Add(2*time.Hour), session.ExpiresAt, time.Second)
+
+	// Once the lifetime is up, the session ends
+	stored.CreatedAt = time.Now().Add(-3 * time.Hour)
+	stored.ExpiresAt = stored.CreatedAt.Add(2 * time.Hour)
+	require.NoError(t, service.store.Put(stored))
+	_, err = service.ValidateSession(token)
+	assert.Error(t, err)
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+)
+
+const shareLinksFile = "share_links.json"
+
+// ErrInvalidShareLink is returned for share tokens that are unknown, revoked,
+// expired, out of views or for another album.
+var ErrInvalidShareLink = errors.New("invalid or expired share link")
+
+// ShareLinkService manages album share links and their access logs. The links
+// are kept in memory after the first read, since access tokens are checked
+// against them on every media request.
+type ShareLinkService struct {
+	fileService *FileService
+	links       []models.ShareLink
+	mu          sync.Mutex
+}
+
+// NewShareLinkService creates a new share link service.
+func NewShareLinkService(fileService *FileService) *ShareLinkService {
+	return &ShareLinkService{
+		fileService: fileService,
+	}
+}
+
+// Create stores a new share link for an album and returns its token. The token
+// is not stored and cannot be retrieved later.
+func (s *ShareLinkService) Create(albumID string, link *models.ShareLink) (string, error) {
+	if err := link.Validate(); err != nil {
+		return "", err
+	}
+	if !link.ExpiresAt.After(time.Now()) {
+		return "", errors.New("share link expiry must be in the future")
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate share token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	link.ID = uuid.New().String()
+	link.AlbumID = albumID
+	link.TokenHash = hashShareToken(token)
+	link.ExpiresAt = link.ExpiresAt.UTC()
+	link.CreatedAt = time.Now().UTC()
+	link.RevokedAt = nil
+	link.Views = 0
+	link.Downloads = 0
+	link.FirstUsedAt = nil
+	link.LastUsedAt = nil
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	links, err := s.getAll()
+	if err != nil {
+		return "", err
+	}
+	links = append(links, *link)
+	if err := s.save(links); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ListByAlbum returns an album's share links, including revoked and expired ones.
+func (s *ShareLinkService) ListByAlbum(albumID string) ([]models.ShareLink, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	links, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+
+	albumLinks := []models.ShareLink{}
+	for _, link := range links {
+		if link.AlbumID == albumID {
+			albumLinks = append(albumLinks, link)
+		}
+	}
+	return albumLinks, nil
+}
+
+// Get returns a share link by its ID.
+func (s *ShareLinkService) Get(id string) (*models.ShareLink, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	links, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+	for i := range links {
+		if links[i].ID == id {
+			return &links[i], nil
+		}
+	}
+	return nil, errors.New("share link not found")
+}
+
+// Revoke disables an album's share link. The link is kept for its access log.
+func (s *ShareLinkService) Revoke(albumID, id string) (*models.ShareLink, error) {
+	return s.update(id, func(link *models.ShareLink) error {
+		if link.AlbumID != albumID {
+			return errors.New("share link not found")
+		}
+		if link.RevokedAt == nil {
+			now := time.Now().UTC()
+			link.RevokedAt = &now
+		}
+		return nil
+	})
+}
+
+// Redeem opens an album's share link by its token, counting a view. With
+// previewOnly, as for draft albums, only preview links open.
+func (s *ShareLinkService) Redeem(albumID, token string, previewOnly bool) (*models.ShareLink, error) {
+	tokenHash := hashShareToken(token)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	links, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	for i := range links {
+		link := &links[i]
+		if link.TokenHash != tokenHash {
+			continue
+		}
+		if link.AlbumID != albumID || !link.CanRedeem(now) || (previewOnly && !link.Preview) {
+			return nil, ErrInvalidShareLink
+		}
+
+		link.Views++
+		if link.FirstUsedAt == nil {
+			link.FirstUsedAt = &now
+		}
+		link.LastUsedAt = &now
+
+		if err := s.save(links); err != nil {
+			return nil, err
+		}
+		return link, nil
+	}
+
+	return nil, ErrInvalidShareLink
+}
+
+// RecordDownload counts a download of an original through a share link.
+func (s *ShareLinkService) RecordDownload(id string) error {
+	_, err := s.update(id, func(link *models.ShareLink) error {
+		now := time.Now().UTC()
+		link.Downloads++
+		link.LastUsedAt = &now
+		return nil
+	})
+	return err
+}
+
+// update applies fn to a share link and saves it.
+func (s *ShareLinkService) update(id string, fn func(*models.ShareLink) error) (*models.ShareLink, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	links, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range links {
+		if links[i].ID != id {
+			continue
+		}
+		if err := fn(&links[i]); err != nil {
+			return nil, err
+		}
+		if err := s.save(links); err != nil {
+			return nil, err
+		}
+		return &links[i], nil
+	}
+
+	return nil, errors.New("share link not found")
+}
+
+// getAll returns a copy of the share links, reading them from disk the first
+// time. Callers must hold s.mu.
+func (s *ShareLinkService) getAll() ([]models.ShareLink, error) {
+	if s.links == nil {
+		links := []models.ShareLink{}
+		if s.fileService.FileExists(shareLinksFile) {
+			var collection models.ShareLinkCollection
+			if err := s.fileService.ReadJSON(shareLinksFile, &collection); err != nil {
+				return nil, fmt.Errorf("failed to read share links: %w", err)
+			}
+			if collection.ShareLinks != nil {
+				links = collection.ShareLinks
+			}
+		}
+		s.links = links
+	}
+	return slices.Clone(s.links), nil
+}
+
+// save writes the share links to disk and keeps them as the in-memory copy.
+// Callers must hold s.mu.
+func (s *ShareLinkService) save(links []models.ShareLink) error {
+	collection := models.ShareLinkCollection{ShareLinks: links}
+	if err := s.fileService.WriteJSON(shareLinksFile, &collection); err != nil {
+		return fmt.Errorf("failed to write share links: %w", err)
+	}
+	s.links = slices.Clone(links)
+	return nil
+}
+
+// hashShareToken returns the stored form of a share token.
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
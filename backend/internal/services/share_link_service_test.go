@@ -0,0 +1,127 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupShareLinkService(t *testing.T) *ShareLinkService {
+	fileService, err := NewFileService(t.TempDir())
+	require.NoError(t, err)
+	return NewShareLinkService(fileService)
+}
+
+func TestShareLinkService_Create(t *testing.T) {
+	service := setupShareLinkService(t)
+
+	_, err := service.Create("album-1", &models.ShareLink{})
+	assert.Error(t, err, "expiry is required")
+
+	_, err = service.Create("album-1", &models.ShareLink{ExpiresAt: time.Now().Add(-time.Hour)})
+	assert.Error(t, err, "expiry must be in the future")
+
+	_, err = service.Create("album-1", &models.ShareLink{ExpiresAt: time.Now().Add(time.Hour), MaxViews: -1})
+	assert.Error(t, err)
+
+	link := &models.ShareLink{Label: "Bride's family", ExpiresAt: time.Now().Add(time.Hour), Views: 5}
+	token, err := service.Create("album-1", link)
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+	assert.NotEmpty(t, link.ID)
+	assert.Equal(t, "album-1", link.AlbumID)
+	assert.Zero(t, link.Views)
+	assert.NotContains(t, link.TokenHash, token, "only a hash of the token is stored")
+	assert.Empty(t, link.Sanitized().TokenHash)
+	assert.NotEmpty(t, link.TokenHash, "sanitizing returns a copy")
+
+	_, err = service.Create("album-2", &models.ShareLink{ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	links, err := service.ListByAlbum("album-1")
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, "Bride's family", links[0].Label)
+}
+
+func TestShareLinkService_Redeem(t *testing.T) {
+	service := setupShareLinkService(t)
+
+	link := &models.ShareLink{ExpiresAt: time.Now().Add(time.Hour), MaxViews: 2}
+	token, err := service.Create("album-1", link)
+	require.NoError(t, err)
+
+	_, err = service.Redeem("album-1", "wrong", false)
+	assert.ErrorIs(t, err, ErrInvalidShareLink)
+	_, err = service.Redeem("album-2", token, false)
+	assert.ErrorIs(t, err, ErrInvalidShareLink)
+	_, err = service.Redeem("album-1", token, true)
+	assert.ErrorIs(t, err, ErrInvalidShareLink, "only preview links open drafts")
+
+	first, err := service.Redeem("album-1", token, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, first.Views)
+	require.NotNil(t, first.FirstUsedAt)
+
+	second, err := service.Redeem("album-1", token, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, second.Views)
+	assert.Equal(t, *first.FirstUsedAt, *second.FirstUsedAt)
+
+	// Out of views
+	_, err = service.Redeem("album-1", token, false)
+	assert.ErrorIs(t, err, ErrInvalidShareLink)
+}
+
+func TestShareLinkService_Revoke(t *testing.T) {
+	service := setupShareLinkService(t)
+
+	link := &models.ShareLink{ExpiresAt: time.Now().Add(time.Hour)}
+	token, err := service.Create("album-1", link)
+	require.NoError(t, err)
+
+	_, err = service.Revoke("album-2", link.ID)
+	assert.Error(t, err, "links are revoked through their own album")
+
+	revoked, err := service.Revoke("album-1", link.ID)
+	require.NoError(t, err)
+	assert.NotNil(t, revoked.RevokedAt)
+
+	_, err = service.Redeem("album-1", token, false)
+	assert.ErrorIs(t, err, ErrInvalidShareLink)
+
+	// Revoked links stay listed with their access log
+	links, err := service.ListByAlbum("album-1")
+	require.NoError(t, err)
+	assert.Len(t, links, 1)
+}
+
+func TestShareLinkService_KeepsLinksInMemory(t *testing.T) {
+	fileService, err := NewFileService(t.TempDir())
+	require.NoError(t, err)
+	service := NewShareLinkService(fileService)
+
+	link := &models.ShareLink{ExpiresAt: time.Now().Add(time.Hour)}
+	_, err = service.Create("album-1", link)
+	require.NoError(t, err)
+
+	// Saved links are on disk for a fresh service
+	reloaded, err := NewShareLinkService(fileService).Get(link.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "album-1", reloaded.AlbumID)
+
+	// Lookups are answered from memory rather than the file
+	require.NoError(t, fileService.WriteJSON(shareLinksFile, &models.ShareLinkCollection{}))
+	cached, err := service.Get(link.ID)
+	require.NoError(t, err)
+	assert.Equal(t, link.ID, cached.ID)
+
+	// Changing a returned link does not change the stored one
+	cached.AlbumID = "album-2"
+	links, err := service.ListByAlbum("album-1")
+	require.NoError(t, err)
+	assert.Len(t, links, 1)
+}
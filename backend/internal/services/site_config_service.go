@@ -11,7 +11,9 @@ const siteConfigFile = "site_config.json"
 
 // SiteConfigService handles site configuration operations.
 type SiteConfigService struct {
-	fileService *FileService
+	fileService    *FileService
+	publisher      Publisher
+	onPublishError func(error)
 }
 
 // NewSiteConfigService creates a new site config service.
@@ -21,6 +23,18 @@ func NewSiteConfigService(fileService *FileService) *SiteConfigService {
 	}
 }
 
+// SetPublisher sets the publisher that rebuilds the public dataset after the
+// configuration changes.
+func (s *SiteConfigService) SetPublisher(publisher Publisher) {
+	s.publisher = publisher
+}
+
+// SetPublishErrorHandler sets the function told when republishing after a
+// change fails. The change is saved by then, so it still succeeds.
+func (s *SiteConfigService) SetPublishErrorHandler(handler func(error)) {
+	s.onPublishError = handler
+}
+
 // Get returns the site configuration.
 func (s *SiteConfigService) Get() (*models.SiteConfig, error) {
 	var config models.SiteConfig
@@ -45,6 +59,12 @@ func (s *SiteConfigService) Update(config *models.SiteConfig) error {
 		return fmt.Errorf("failed to write site config: %w", err)
 	}
 
+	if s.publisher != nil {
+		if err := s.publisher.Publish(); err != nil && s.onPublishError != nil {
+			s.onPublishError(fmt.Errorf("failed to publish site config: %w", err))
+		}
+	}
+
 	return nil
 }
 
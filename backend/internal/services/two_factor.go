@@ -0,0 +1,254 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/hotp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	// totpPeriod is the RFC 6238 time step.
+	totpPeriod = 30
+	// totpSkew is how many time steps either side of now are accepted, to
+	// allow for clock drift between the server and the authenticator app.
+	totpSkew = 1
+	// recoveryCodeCount is how many recovery codes are issued at enrollment.
+	recoveryCodeCount = 10
+)
+
+// ErrInvalidTOTPCode is returned for wrong, reused and malformed two-factor
+// codes and recovery codes.
+var ErrInvalidTOTPCode = errors.New("invalid two-factor code")
+
+// TwoFactorStatus describes a user's two-factor authentication.
+type TwoFactorStatus struct {
+	Enabled                bool `json:"enabled"`
+	Pending                bool `json:"pending"` // Enrollment started but not verified
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+// TwoFactorStatus returns a user's two-factor authentication status.
+func (s *UserService) TwoFactorStatus(id string) (*TwoFactorStatus, error) {
+	user, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return &TwoFactorStatus{
+		Enabled:                user.TOTPEnabled,
+		Pending:                !user.TOTPEnabled && user.TOTPSecret != "",
+		RecoveryCodesRemaining: len(user.RecoveryCodeHashes),
+	}, nil
+}
+
+// StartTOTPEnrollment generates a new TOTP secret for a user, replacing any
+// earlier unverified one. Two-factor authentication is not enabled until a
+// code from the secret is verified with EnableTOTP.
+func (s *UserService) StartTOTPEnrollment(id, issuer string) (*otp.Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+	user := findUser(users, id)
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if user.TOTPEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: user.Username,
+		Period:      totpPeriod,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+
+	user.TOTPSecret = key.Secret()
+	user.UpdatedAt = time.Now().UTC()
+	if err := s.save(users); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// EnableTOTP enables two-factor authentication for a user once code matches
+// the secret from StartTOTPEnrollment. It returns new recovery codes; only
+// their hashes are kept, so they cannot be shown again.
+func (s *UserService) EnableTOTP(id, code string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+	user := findUser(users, id)
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if user.TOTPEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("two-factor enrollment has not been started")
+	}
+
+	step, ok := validateTOTP(user.TOTPSecret, code, time.Now(), 0)
+	if !ok {
+		return nil, ErrInvalidTOTPCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	user.TOTPEnabled = true
+	user.TOTPLastStep = step
+	user.RecoveryCodeHashes = hashes
+	user.UpdatedAt = time.Now().UTC()
+	if err := s.save(users); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// DisableTOTP turns off two-factor authentication for a user and forgets the
+// secret and recovery codes.
+func (s *UserService) DisableTOTP(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users, err := s.getAll()
+	if err != nil {
+		return err
+	}
+	user := findUser(users, id)
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	user.TOTPSecret = ""
+	user.TOTPEnabled = false
+	user.TOTPLastStep = 0
+	user.RecoveryCodeHashes = nil
+	user.UpdatedAt = time.Now().UTC()
+	return s.save(users)
+}
+
+// VerifySecondFactor checks a TOTP code or an unused recovery code for a user
+// with two-factor authentication enabled, and records the login. A recovery
+// code is used up, and a TOTP code cannot be used twice. It returns
+// ErrInvalidTOTPCode when the code does not match.
+func (s *UserService) VerifySecondFactor(id, code string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+	user := findUser(users, id)
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if !user.TOTPEnabled {
+		return nil, errors.New("two-factor authentication is not enabled")
+	}
+
+	if step, ok := validateTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastStep); ok {
+		user.TOTPLastStep = step
+	} else if i := findRecoveryCode(user.RecoveryCodeHashes, code); i >= 0 {
+		user.RecoveryCodeHashes = append(user.RecoveryCodeHashes[:i], user.RecoveryCodeHashes[i+1:]...)
+	} else {
+		return nil, ErrInvalidTOTPCode
+	}
+
+	now := time.Now().UTC()
+	user.LastLoginAt = &now
+	if err := s.save(users); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// validateTOTP checks a six-digit code against the secret for the time steps
+// around now, ignoring steps at or before lastStep. It returns the matching
+// time step.
+func validateTOTP(secret, code string, now time.Time, lastStep int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != int(otp.DigitsSix) {
+		return 0, false
+	}
+
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		expected, err := hotp.GenerateCodeCustom(secret, uint64(step), hotp.ValidateOpts{
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// generateRecoveryCodes returns new recovery codes, formatted as four groups of
+// four characters, and their hashes.
+func generateRecoveryCodes() ([]string, []string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 10) // 80 bits, 16 base32 characters
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		raw := strings.ToLower(encoding.EncodeToString(b))
+		codes[i] = raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes a recovery code, ignoring case, spaces and dashes.
+// The codes are random enough that a fast hash is safe.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// findRecoveryCode returns the index of the code's hash in hashes, or -1.
+func findRecoveryCode(hashes []string, code string) int {
+	hash := hashRecoveryCode(code)
+	for i, h := range hashes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// enableTwoFactor enrolls testuser in two-factor authentication and returns
+// the TOTP secret and recovery codes.
+func enableTwoFactor(t *testing.T, service *AuthService) (string, []string) {
+	users, err := service.users.GetAll()
+	require.NoError(t, err)
+	id := users[0].ID
+
+	key, err := service.users.StartTOTPEnrollment(id, "Test")
+	require.NoError(t, err)
+	assert.Contains(t, key.URL(), "otpauth://totp/Test:testuser")
+
+	_, err = service.users.EnableTOTP(id, "000000")
+	assert.ErrorIs(t, err, ErrInvalidTOTPCode)
+
+	code, err := totp.GenerateCode(key.Secret(), time.Now())
+	require.NoError(t, err)
+	recoveryCodes, err := service.users.EnableTOTP(id, code)
+	require.NoError(t, err)
+	assert.Len(t, recoveryCodes, recoveryCodeCount)
+	return key.Secret(), recoveryCodes
+}
+
+// twoFactorChallenge logs in as testuser with the password and returns the
+// challenge for the second factor.
+func twoFactorChallenge(t *testing.T, service *AuthService) string {
+	_, err := service.Authenticate("testuser", "test123")
+	var required *TwoFactorRequiredError
+	require.True(t, errors.As(err, &required))
+	return required.Challenge
+}
+
+func TestAuthService_TwoFactorLogin(t *testing.T) {
+	service := setupAuthService(t)
+	secret, recoveryCodes := enableTwoFactor(t, service)
+
+	// The code used for enrollment cannot be replayed, but the next one works
+	challenge := twoFactorChallenge(t, service)
+	code, err := totp.GenerateCode(secret, time.Now())
+	require.NoError(t, err)
+	_, err = service.CompleteTwoFactor("testuser", challenge, code)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	next, err := totp.GenerateCode(secret, time.Now().Add(totpPeriod*time.Second))
+	require.NoError(t, err)
+	_, err = service.CompleteTwoFactor("someone-else", challenge, next)
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "the challenge belongs to testuser")
+	token, err := service.CompleteTwoFactor("TestUser", challenge, next)
+	require.NoError(t, err)
+	session, err := service.ValidateSession(token)
+	require.NoError(t, err)
+	assert.Equal(t, "testuser", session.Username)
+
+	// Challenges are single-use
+	_, err = service.CompleteTwoFactor("testuser", challenge, next)
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	// Recovery codes work once, in any case and spacing
+	challenge = twoFactorChallenge(t, service)
+	_, err = service.CompleteTwoFactor("testuser", challenge, "  "+recoveryCodes[0]+" ")
+	require.NoError(t, err)
+	challenge = twoFactorChallenge(t, service)
+	_, err = service.CompleteTwoFactor("testuser", challenge, recoveryCodes[0])
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+	status, err := service.users.TwoFactorStatus(session.UserID)
+	require.NoError(t, err)
+	assert.True(t, status.Enabled)
+	assert.Equal(t, recoveryCodeCount-1, status.RecoveryCodesRemaining)
+
+	// The secrets never leave the service
+	user, err := service.users.Get(session.UserID)
+	require.NoError(t, err)
+	assert.NotEmpty(t, user.TOTPSecret)
+	assert.NotContains(t, user.RecoveryCodeHashes, recoveryCodes[1])
+	sanitized := user.Sanitized()
+	assert.Empty(t, sanitized.TOTPSecret)
+	assert.Empty(t, sanitized.RecoveryCodeHashes)
+	assert.True(t, sanitized.TOTPEnabled)
+}
+
+func TestAuthService_TwoFactorChallengeAttempts(t *testing.T) {
+	service := setupAuthService(t)
+	_, recoveryCodes := enableTwoFactor(t, service)
+
+	challenge := twoFactorChallenge(t, service)
+	for i := 0; i < maxChallengeAttempts; i++ {
+		_, err := service.CompleteTwoFactor("testuser", challenge, "wrong")
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	}
+	_, err := service.CompleteTwoFactor("testuser", challenge, recoveryCodes[0])
+	assert.ErrorIs(t, err, ErrInvalidCredentials, "too many wrong codes end the challenge")
+
+	// Expired challenges are rejected
+	challenge = twoFactorChallenge(t, service)
+	service.challenges[hashSessionToken(challenge)].expiresAt = time.Now().Add(-time.Second)
+	_, err = service.CompleteTwoFactor("testuser", challenge, recoveryCodes[0])
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	require.NoError(t, service.CleanupExpiredSessions())
+	assert.Empty(t, service.challenges)
+}
+
+func TestAuthService_TwoFactorParallelAttempts(t *testing.T) {
+	service := setupAuthService(t)
+	_, recoveryCodes := enableTwoFactor(t, service)
+
+	// Codes sent in parallel for one challenge are limited like sequential
+	// ones, and only one of them signs in
+	challenge := twoFactorChallenge(t, service)
+	var wg sync.WaitGroup
+	var sessions atomic.Int32
+	// Hold up the code checks until every attempt has looked up the challenge
+	service.users.mu.Lock()
+	for _, code := range recoveryCodes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := service.CompleteTwoFactor("testuser", challenge, code); err == nil {
+				sessions.Add(1)
+			}
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	service.users.mu.Unlock()
+	wg.Wait()
+	assert.Equal(t, int32(1), sessions.Load())
+
+	users, err := service.users.GetAll()
+	require.NoError(t, err)
+	status, err := service.users.TwoFactorStatus(users[0].ID)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, status.RecoveryCodesRemaining, len(recoveryCodes)-maxChallengeAttempts,
+		"no more codes are checked than the challenge allows")
+}
+
+func TestUserService_DisableTOTP(t *testing.T) {
+	service := setupAuthService(t)
+	enableTwoFactor(t, service)
+	users, err := service.users.GetAll()
+	require.NoError(t, err)
+	id := users[0].ID
+
+	_, err = service.users.StartTOTPEnrollment(id, "Test")
+	assert.Error(t, err, "already enabled")
+
+	require.NoError(t, service.users.DisableTOTP(id))
+	status, err := service.users.TwoFactorStatus(id)
+	require.NoError(t, err)
+	assert.False(t, status.Enabled)
+	assert.Zero(t, status.RecoveryCodesRemaining)
+
+	// Logins need only the password again
+	token, err := service.Authenticate("testuser", "test123")
+	require.NoError(t, err)
+	assert.NotEmpty(t, token)
+}
@@ -0,0 +1,443 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+)
+
+const usersFile = "users.json"
+
+// ErrInvalidCredentials is returned for unknown usernames and wrong passwords
+// alike.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ErrUserNotFound is returned for user IDs that do not exist.
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrLastOwner is returned when a change would leave no owner to manage users.
+var ErrLastOwner = errors.New("at least one owner is required")
+
+// UserService manages the admin user accounts.
+type UserService struct {
+	fileService *FileService
+	mu          sync.Mutex
+}
+
+// NewUserService creates a new user service.
+func NewUserService(fileService *FileService) *UserService {
+	return &UserService{
+		fileService: fileService,
+	}
+}
+
+// Migrate creates the first owner from the single account in
+// admin_config.json. It does nothing once users exist and reports whether it
+// created the owner.
+func (s *UserService) Migrate(config models.AdminConfig) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users, err := s.getAll()
+	if err != nil {
+		return false, err
+	}
+	if len(users) > 0 {
+		return false, nil
+	}
+	if config.PasswordHash == "" {
+		return false, errors.New("admin config has no password hash")
+	}
+
+	now := time.Now().UTC()
+	owner := models.User{
+		ID:           uuid.New().String(),
+		Username:     config.Username,
+		PasswordHash: config.PasswordHash, // pragma: allowlist secret
+		Role:         models.RoleOwner,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := owner.Validate(); err != nil {
+		return false, err
+	}
+
+	if err := s.save([]models.User{owner}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// HasUsers reports whether any user exists.
+func (s *UserService) HasUsers() (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users, err := s.getAll()
+	if err != nil {
+		return false, err
+	}
+	return len(users) > 0, nil
+}
+
+// GetAll returns every user.
+func (s *UserService) GetAll() ([]models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.getAll()
+}
+
+// Get returns a user by ID.
+func (s *UserService) Get(id string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+	user := findUser(users, id)
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// Create stores a new user with the given password. Only its hash is kept.
+func (s *UserService) Create(user *models.User, password string) error {
+	if err := user.Validate(); err != nil {
+		return err
+	}
+	if password == "" {
+		return errors.New("password is required")
+	}
+	if err := ValidatePassword(password); err != nil {
+		return err
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users, err := s.getAll()
+	if err != nil {
+		return err
+	}
+	if usernameTaken(users, user.Username, "") {
+		return errors.New("username is already taken")
+	}
+
+	now := time.Now().UTC()
+	user.ID = uuid.New().String()
+	user.PasswordHash = hash // pragma: allowlist secret
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	user.LastLoginAt = nil
+
+	users = append(users, *user)
+	return s.save(users)
+}
+
+// Update changes a user's username and role.
+func (s *UserService) Update(id string, update *models.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users, err := s.getAll()
+	if err != nil {
+		return err
+	}
+	user := findUser(users, id)
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	if err := update.Validate(); err != nil {
+		return err
+	}
+	if usernameTaken(users, update.Username, id) {
+		return errors.New("username is already taken")
+	}
+	if user.Role == models.RoleOwner && update.Role != models.RoleOwner && countOwners(users) == 1 {
+		return ErrLastOwner
+	}
+
+	user.Username = update.Username
+	user.Role = update.Role
+	user.UpdatedAt = time.Now().UTC()
+	if err := s.save(users); err != nil {
+		return err
+	}
+
+	*update = *user
+	return nil
+}
+
+// SetPassword replaces a user's password without checking the current one.
+// The password must meet the password policy.
+func (s *UserService) SetPassword(id, password string) error {
+	if password == "" {
+		return errors.New("password is required")
+	}
+	if err := ValidatePassword(password); err != nil {
+		return err
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users, err := s.getAll()
+	if err != nil {
+		return err
+	}
+	user := findUser(users, id)
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	user.PasswordHash = hash // pragma: allowlist secret
+	user.UpdatedAt = time.Now().UTC()
+	return s.save(users)
+}
+
+// ChangePassword replaces a user's password after checking the current one.
+func (s *UserService) ChangePassword(id, oldPassword, newPassword string) error {
+	if err := s.VerifyPassword(id, oldPassword); err != nil {
+		return err
+	}
+	return s.SetPassword(id, newPassword)
+}
+
+// VerifyPassword checks a user's current password.
+func (s *UserService) VerifyPassword(id, password string) error {
+	user, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	if match, _ := CheckPassword(user.PasswordHash, password); !match {
+		return errors.New("invalid current password")
+	}
+	return nil
+}
+
+// Delete removes a user. The last owner cannot be deleted.
+func (s *UserService) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users, err := s.getAll()
+	if err != nil {
+		return err
+	}
+	for i := range users {
+		if users[i].ID != id {
+			continue
+		}
+		if users[i].Role == models.RoleOwner && countOwners(users) == 1 {
+			return ErrLastOwner
+		}
+		return s.save(append(users[:i], users[i+1:]...))
+	}
+	return ErrUserNotFound
+}
+
+// Authenticate checks a username and password and records the login, unless
+// the user has two-factor authentication enabled, in which case the login is
+// recorded by VerifySecondFactor. A bcrypt or outdated password hash is
+// replaced with a current one. It returns ErrInvalidCredentials when either is
+// wrong. The password is checked without holding the service's lock, so
+// logins do not hold up session checks.
+func (s *UserService) Authenticate(username, password string) (*models.User, error) {
+	s.mu.Lock()
+	users, err := s.getAll()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var user *models.User
+	for i := range users {
+		if strings.EqualFold(users[i].Username, username) {
+			user = &users[i]
+			break
+		}
+	}
+	if user == nil {
+		// Unknown usernames take as long to reject as wrong passwords
+		_, _ = HashPassword(password)
+		return nil, ErrInvalidCredentials
+	}
+	match, rehash := CheckPassword(user.PasswordHash, password)
+	if !match {
+		return nil, ErrInvalidCredentials
+	}
+
+	newHash := ""
+	if rehash {
+		// Upgrade bcrypt hashes and outdated parameters while the password is known
+		if newHash, err = HashPassword(password); err != nil {
+			return nil, fmt.Errorf("failed to hash password: %w", err)
+		}
+	}
+	return s.recordLogin(user, newHash)
+}
+
+// recordLogin stores newHash, when set, for a user whose password was just
+// checked, and records the login unless two-factor authentication is enabled.
+// It returns ErrInvalidCredentials if the user was deleted or their password
+// changed while it was being checked.
+func (s *UserService) recordLogin(checked *models.User, newHash string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+	user := findUser(users, checked.ID)
+	if user == nil || user.PasswordHash != checked.PasswordHash {
+		return nil, ErrInvalidCredentials
+	}
+
+	changed := false
+	if newHash != "" {
+		user.PasswordHash = newHash // pragma: allowlist secret
+		changed = true
+	}
+	if !user.TOTPEnabled {
+		now := time.Now().UTC()
+		user.LastLoginAt = &now
+		changed = true
+	}
+	if changed {
+		if err := s.save(users); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// LoginOIDC returns the user linked to a single sign-on identity and records
+// the login. On first sign-in the identity is linked to the unlinked user
+// whose username is its verified email, or else a user without a password is
+// created. The user's role is set to the identity's.
+func (s *UserService) LoginOIDC(identity *OIDCIdentity) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users, err := s.getAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var user *models.User
+	for i := range users {
+		if users[i].OIDCIssuer == identity.Issuer && users[i].OIDCSubject == identity.Subject {
+			user = &users[i]
+			break
+		}
+	}
+	if user == nil {
+		for i := range users {
+			if users[i].OIDCSubject == "" && strings.EqualFold(users[i].Username, identity.Username) {
+				user = &users[i]
+				break
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	if user == nil {
+		created := models.User{
+			ID:        uuid.New().String(),
+			Username:  identity.Username,
+			Role:      identity.Role,
+			CreatedAt: now,
+		}
+		if err := created.Validate(); err != nil {
+			return nil, err
+		}
+		if usernameTaken(users, created.Username, "") {
+			return nil, errors.New("username is already taken")
+		}
+		users = append(users, created)
+		user = &users[len(users)-1]
+	}
+
+	if user.Role == models.RoleOwner && identity.Role != models.RoleOwner && countOwners(users) == 1 {
+		return nil, ErrLastOwner
+	}
+	user.OIDCIssuer = identity.Issuer
+	user.OIDCSubject = identity.Subject
+	user.Role = identity.Role
+	user.UpdatedAt = now
+	user.LastLoginAt = &now
+	if err := s.save(users); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *UserService) getAll() ([]models.User, error) {
+	if !s.fileService.FileExists(usersFile) {
+		return []models.User{}, nil
+	}
+
+	var collection models.UserCollection
+	if err := s.fileService.ReadJSON(usersFile, &collection); err != nil {
+		return nil, fmt.Errorf("failed to read users: %w", err)
+	}
+	return collection.Users, nil
+}
+
+func (s *UserService) save(users []models.User) error {
+	collection := models.UserCollection{Users: users}
+	if err := s.fileService.WriteJSON(usersFile, &collection); err != nil {
+		return fmt.Errorf("failed to write users: %w", err)
+	}
+	return nil
+}
+
+func findUser(users []models.User, id string) *models.User {
+	for i := range users {
+		if users[i].ID == id {
+			return &users[i]
+		}
+	}
+	return nil
+}
+
+// usernameTaken reports whether a user other than exceptID has the username.
+// Usernames are compared case-insensitively.
+func usernameTaken(users []models.User, username, exceptID string) bool {
+	for _, user := range users {
+		if user.ID != exceptID && strings.EqualFold(user.Username, username) {
+			return true
+		}
+	}
+	return false
+}
+
+func countOwners(users []models.User) int {
+	owners := 0
+	for _, user := range users {
+		if user.Role == models.RoleOwner {
+			owners++
+		}
+	}
+	return owners
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/njoubert/nielsshootsfilm/backend/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupUserService(t *testing.T) (*UserService, *models.User) {
+	fileService, err := NewFileService(t.TempDir())
+	require.NoError(t, err)
+	service := NewUserService(fileService)
+
+	hash, err := HashPassword("owner-pass")
+	require.NoError(t, err)
+	migrated, err := service.Migrate(models.AdminConfig{Username: "admin", PasswordHash: hash})
+	require.NoError(t, err)
+	require.True(t, migrated)
+
+	users, err := service.GetAll()
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	return service, &users[0]
+}
+
+func TestUserService_Migrate(t *testing.T) {
+	service, owner := setupUserService(t)
+	assert.Equal(t, "admin", owner.Username)
+	assert.Equal(t, models.RoleOwner, owner.Role)
+
+	// The existing password keeps working
+	user, err := service.Authenticate("admin", "owner-pass")
+	require.NoError(t, err)
+	assert.Equal(t, owner.ID, user.ID)
+	assert.NotNil(t, user.LastLoginAt)
+
+	// Once users exist, migrating again does nothing
+	migrated, err := service.Migrate(models.AdminConfig{Username: "other", PasswordHash: owner.PasswordHash})
+	require.NoError(t, err)
+	assert.False(t, migrated)
+	users, err := service.GetAll()
+	require.NoError(t, err)
+	assert.Len(t, users, 1)
+}
+
+func TestUserService_Create(t *testing.T) {
+	service, _ := setupUserService(t)
+
+	user := &models.User{Username: "Shooter", Role: models.RoleUploader}
+	require.NoError(t, service.Create(user, "shooter-pass"))
+	assert.NotEmpty(t, user.ID)
+	assert.NotEqual(t, "shooter-pass", user.PasswordHash)
+
+	// Usernames are case-insensitive
+	user, err := service.Authenticate("shooter", "shooter-pass")
+	require.NoError(t, err)
+	assert.Equal(t, models.RoleUploader, user.Role)
+	err = service.Create(&models.User{Username: "SHOOTER", Role: models.RoleViewer}, "x")
+	assert.Error(t, err)
+
+	tests := []struct {
+		name     string
+		user     models.User
+		password string
+	}{
+		{name: "no username", user: models.User{Role: models.RoleViewer}, password: "x"},
+		{name: "bad username", user: models.User{Username: "a b", Role: models.RoleViewer}, password: "x"},
+		{name: "unknown role", user: models.User{Username: "someone", Role: "admin"}, password: "x"},
+		{name: "no password", user: models.User{Username: "someone", Role: models.RoleViewer}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, service.Create(&tt.user, tt.password))
+		})
+	}
+}
+
+func TestUserService_Authenticate(t *testing.T) {
+	service, _ := setupUserService(t)
+
+	_, err := service.Authenticate("admin", "wrong")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+	_, err = service.Authenticate("nobody", "owner-pass")
+	assert.ErrorIs(t, err, ErrInvalidCredentials)
+}
+
+func TestUserService_AuthenticateDoesNotBlockReads(t *testing.T) {
+	service, owner := setupUserService(t)
+
+	// Make password hashing slow
+	previous := *passwordConfig.Load()
+	t.Cleanup(func() { passwordConfig.Store(&previous) })
+	config := previous
+	config.Iterations = 40
+	require.NoError(t, SetPasswordConfig(config))
+
+	var loginTook time.Duration
+	loginDone := make(chan struct{})
+	go func() {
+		defer close(loginDone)
+		start := time.Now()
+		_, _ = service.Authenticate("nobody", "owner-pass")
+		loginTook = time.Since(start)
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// Reading a user, as every session check does, does not wait for the login
+	start := time.Now()
+	_, err := service.Get(owner.ID)
+	getTook := time.Since(start)
+	require.NoError(t, err)
+
+	<-loginDone
+	assert.Less(t, getTook, loginTook/2)
+}
+
+func TestUserService_LastOwner(t *testing.T) {
+	service, owner := setupUserService(t)
+
+	// The only owner can be neither demoted nor deleted
+	err := service.Update(owner.ID, &models.User{Username: "admin", Role: models.RoleEditor})
+	assert.ErrorIs(t, err, ErrLastOwner)
+	assert.ErrorIs(t, service.Delete(owner.ID), ErrLastOwner)
+
+	// With a second owner they can
+	second := &models.User{Username: "partner", Role: models.RoleOwner}
+	require.NoError(t, service.Create(second, "partner-pass"))
+	require.NoError(t, service.Update(owner.ID, &models.User{Username: "admin", Role: models.RoleEditor}))
+	assert.ErrorIs(t, service.Delete(second.ID), ErrLastOwner)
+	require.NoError(t, service.Delete(owner.ID))
+
+	_, err = service.Get(owner.ID)
+	assert.ErrorIs(t, err, ErrUserNotFound)
+}
+
+func TestUserService_Passwords(t *testing.T) {
+	service, owner := setupUserService(t)
+
+	err := service.ChangePassword(owner.ID, "wrong", "new-password")
+	assert.Error(t, err)
+	err = service.ChangePassword(owner.ID, "owner-pass", "short")
+	assert.ErrorIs(t, err, ErrWeakPassword)
+	require.NoError(t, service.ChangePassword(owner.ID, "owner-pass", "new-password"))
+	_, err = service.Authenticate("admin", "new-password")
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, service.SetPassword(owner.ID, "Password123"), ErrWeakPassword)
+	require.NoError(t, service.SetPassword(owner.ID, "reset-pass"))
+	_, err = service.Authenticate("admin", "new-password")
+	assert.Error(t, err)
+	_, err = service.Authenticate("admin", "reset-pass")
+	assert.NoError(t, err)
+}
+
+func TestRole_Includes(t *testing.T) {
+	assert.True(t, models.RoleOwner.Includes(models.RoleEditor))
+	assert.True(t, models.RoleEditor.Includes(models.RoleUploader))
+	assert.True(t, models.RoleUploader.Includes(models.RoleUploader))
+	assert.False(t, models.RoleUploader.Includes(models.RoleEditor))
+	assert.False(t, models.RoleViewer.Includes(models.RoleUploader))
+	assert.False(t, models.Role("").Includes(models.RoleViewer))
+}
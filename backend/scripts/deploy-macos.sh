@@ -10,8 +10,11 @@ PROJECT_ROOT="$(cd "$SCRIPT_DIR/../.." && pwd)"
 # Deployment paths
 DEPLOY_DIR="$HOME/webserver/sites/nielsshootsfilm.com/dynamic"
 LOG_DIR="$HOME/webserver/logs"
-DATA_DIR="$HOME/webserver/sites/nielsshootsfilm.com/public/data"
-UPLOAD_DIR="$HOME/webserver/sites/nielsshootsfilm.com/public/uploads"
+# Admin data and uploads stay out of the web root; the backend serves uploads
+# itself and publishes the sanitized public dataset to PUBLIC_DATA_DIR
+DATA_DIR="$HOME/webserver/sites/nielsshootsfilm.com/private/data"
+UPLOAD_DIR="$HOME/webserver/sites/nielsshootsfilm.com/private/uploads"
+PUBLIC_DATA_DIR="$HOME/webserver/sites/nielsshootsfilm.com/public/data"
 
 # Service configuration
 SERVICE_NAME="com.nielsshootsfilm.admin"
@@ -83,6 +86,7 @@ install_service() {
     mkdir -p "$DEPLOY_DIR"
     mkdir -p "$LOG_DIR"
     mkdir -p "$DATA_DIR"
+    mkdir -p "$PUBLIC_DATA_DIR"
     mkdir -p "$UPLOAD_DIR"
 
     # Build the backend
@@ -106,6 +110,7 @@ install_service() {
         echo "📝 Creating env file..."
         cp "$PROJECT_ROOT/env.example" "$DEPLOY_DIR/env"
         echo -e "${GREEN}✓ Created env file at $DEPLOY_DIR/env${NC}"
+        echo -e "${YELLOW}⚠️  Set DATA_DIR=$DATA_DIR, PUBLIC_DATA_DIR=$PUBLIC_DATA_DIR and UPLOAD_DIR=$UPLOAD_DIR in it${NC}"
         echo -e "${YELLOW}⚠️  You must configure admin credentials in $DATA_DIR/admin_config.json${NC}"
     fi
 
@@ -268,6 +273,7 @@ uninstall_service() {
     echo "Note: Binary, data, and logs are preserved at:"
     echo "  Binary:   $DEPLOY_DIR/admin"
     echo "  Data:     $DATA_DIR"
+    echo "  Public:   $PUBLIC_DATA_DIR"
     echo "  Logs:     $LOG_DIR"
     echo ""
     echo "To remove these manually, run:"
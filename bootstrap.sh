@@ -30,15 +30,21 @@ else
     echo -e "${YELLOW}⚠ env already exists, skipping${NC}\n"
 fi
 
-# Update DATA_DIR and UPLOAD_DIR with absolute paths
+# Update DATA_DIR, PUBLIC_DATA_DIR and UPLOAD_DIR with absolute paths
 if [ -f "$PROJECT_ROOT/env" ]; then
-    echo "Updating env with paths to DATA_DIR and UPLOAD_DIR..."
+    echo "Updating env with paths to DATA_DIR, PUBLIC_DATA_DIR and UPLOAD_DIR..."
     # Update or add DATA_DIR
     if grep -q "^DATA_DIR=" "$PROJECT_ROOT/env"; then
         sed -i.bak "s|^DATA_DIR=.*|DATA_DIR=$PROJECT_ROOT/data|" "$PROJECT_ROOT/env"
     else
         echo "DATA_DIR=$PROJECT_ROOT/data" >> "$PROJECT_ROOT/env"
     fi
+    # Update or add PUBLIC_DATA_DIR
+    if grep -q "^PUBLIC_DATA_DIR=" "$PROJECT_ROOT/env"; then
+        sed -i.bak "s|^PUBLIC_DATA_DIR=.*|PUBLIC_DATA_DIR=$PROJECT_ROOT/static/data|" "$PROJECT_ROOT/env"
+    else
+        echo "PUBLIC_DATA_DIR=$PROJECT_ROOT/static/data" >> "$PROJECT_ROOT/env"
+    fi
     # Update or add UPLOAD_DIR
     if grep -q "^UPLOAD_DIR=" "$PROJECT_ROOT/env"; then
         sed -i.bak "s|^UPLOAD_DIR=.*|UPLOAD_DIR=$PROJECT_ROOT/static/uploads|" "$PROJECT_ROOT/env"
@@ -46,7 +52,7 @@ if [ -f "$PROJECT_ROOT/env" ]; then
         echo "UPLOAD_DIR=$PROJECT_ROOT/static/uploads" >> "$PROJECT_ROOT/env"
     fi
     rm -f "$PROJECT_ROOT/env.bak"
-    echo -e "${GREEN}✓ Updated DATA_DIR and UPLOAD_DIR with absolute paths${NC}\n"
+    echo -e "${GREEN}✓ Updated DATA_DIR, PUBLIC_DATA_DIR and UPLOAD_DIR with absolute paths${NC}\n"
 fi
 
 # Create symlinks for env in backend and frontend if they don't exist
@@ -135,8 +141,11 @@ else
     echo -e "${YELLOW}⚠ site_config.json already exists, skipping${NC}\n"
 fi
 
-# Initialize admin_config.json if it doesn't exist
-if [ ! -f "$PROJECT_ROOT/data/admin_config.json" ]; then
+# Initialize admin_config.json if it doesn't exist. The server turns it into the
+# first owner in users.json, so it is not needed once users.json exists.
+if [ -f "$PROJECT_ROOT/data/users.json" ]; then
+    echo -e "${YELLOW}⚠ users.json already exists, skipping admin_config.json${NC}\n"
+elif [ ! -f "$PROJECT_ROOT/data/admin_config.json" ]; then
     echo -e "${YELLOW}admin_config.json does not exist. Creating it now.${NC}"
     echo "You will be prompted to set an admin password."
     echo ""
@@ -157,7 +166,7 @@ if [ ! -f "$PROJECT_ROOT/data/admin_config.json" ]; then
         exit 1
     fi
 
-    # Generate bcrypt hash using Go from backend directory (where bcrypt is installed)
+    # Generate an argon2id hash with the backend's hash-password utility
     echo "Generating password hash..."
     HASH=$(cd "$PROJECT_ROOT/backend" && "$PROJECT_ROOT/scripts/hash-password.sh" "$ADMIN_PASSWORD")
 
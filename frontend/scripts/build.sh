@@ -42,8 +42,10 @@ echo "📄 Moving built assets..."
 # Copy the built files from dist to final location
 cp -r "$TEMP_BUILD_DIR/dist/"* "$FINAL_BUILD_DIR/"
 
-echo "📊 Copying data directory..."
-cp -r "$PROJECT_ROOT/data" "$FINAL_BUILD_DIR/data"
+echo "📊 Copying public data directory..."
+# Only the dataset the backend publishes; the admin data directory holds secrets
+PUBLIC_DATA_DIR="$(grep -E "^PUBLIC_DATA_DIR=" "$PROJECT_ROOT/env" 2>/dev/null | cut -d= -f2-)"
+cp -r "${PUBLIC_DATA_DIR:-$PROJECT_ROOT/static/data}" "$FINAL_BUILD_DIR/data"
 
 echo "🖼️  Copying uploads directory..."
 mkdir -p "$FINAL_BUILD_DIR/uploads"
@@ -64,7 +66,7 @@ echo "� Directory structure:"
 echo "  $FINAL_BUILD_DIR/"
 echo "  ├── index.html"
 echo "  ├── assets/          (JS and CSS)"
-echo "  ├── data/            (JSON data files)"
+echo "  ├── data/            (public JSON data files)"
 echo "  └── uploads/         (images)"
 echo ""
 echo "The site expects to be served from the domain root (e.g., nielsshootsfilm.com/)"
@@ -0,0 +1,150 @@
+import { LitElement, css, html } from 'lit';
+import { customElement, property, state } from 'lit/decorators.js';
+import type { Selection } from '../types/data-models';
+import { fetchSelections, selectionExportUrl, setSelectionLocked } from '../utils/admin-api';
+
+/**
+ * Client selections of a proofing album, for the admin: who picked how many
+ * photos, exports as CSV or for Lightroom, and locking.
+ */
+@customElement('admin-selections')
+export class AdminSelections extends LitElement {
+  @property({ type: String }) albumId = '';
+
+  @state() private selections: Selection[] = [];
+  @state() private loading = false;
+  @state() private error = '';
+
+  static styles = css`
+    :host {
+      display: block;
+    }
+
+    .selection {
+      padding: 0.75rem 0;
+      border-bottom: 1px solid var(--color-border, #ddd);
+    }
+
+    .selection:last-child {
+      border-bottom: none;
+    }
+
+    .client {
+      font-weight: 600;
+    }
+
+    .meta {
+      font-size: 0.875rem;
+      color: var(--color-text-secondary, #666);
+      margin: 0.25rem 0 0.5rem;
+    }
+
+    .note {
+      font-size: 0.875rem;
+      font-style: italic;
+      margin: 0 0 0.5rem;
+    }
+
+    .actions {
+      display: flex;
+      flex-wrap: wrap;
+      gap: 0.5rem;
+    }
+
+    .actions a,
+    .actions button {
+      font-size: 0.875rem;
+      padding: 0.25rem 0.75rem;
+      border: 1px solid var(--color-border, #ccc);
+      border-radius: 4px;
+      background: none;
+      color: inherit;
+      text-decoration: none;
+      cursor: pointer;
+    }
+
+    .empty,
+    .error {
+      font-size: 0.875rem;
+      color: var(--color-text-secondary, #666);
+    }
+
+    .error {
+      color: #c00;
+    }
+  `;
+
+  updated(changedProperties: Map<string | number | symbol, unknown>) {
+    if (changedProperties.has('albumId') && this.albumId) {
+      void this.loadSelections();
+    }
+  }
+
+  private async loadSelections() {
+    this.loading = true;
+    this.error = '';
+    try {
+      this.selections = await fetchSelections(this.albumId);
+    } catch (err) {
+      this.error = err instanceof Error ? err.message : 'Failed to load selections';
+    } finally {
+      this.loading = false;
+    }
+  }
+
+  private async toggleLock(selection: Selection) {
+    this.error = '';
+    try {
+      const updated = await setSelectionLocked(this.albumId, selection.id, !selection.locked_at);
+      this.selections = this.selections.map((s) => (s.id === updated.id ? updated : s));
+    } catch (err) {
+      this.error = err instanceof Error ? err.message : 'Failed to update selection';
+    }
+  }
+
+  render() {
+    if (this.loading) {
+      return html`<p class="empty">Loading selections...</p>`;
+    }
+
+    return html`
+      ${this.error ? html`<p class="error">${this.error}</p>` : ''}
+      ${this.selections.length === 0
+        ? html`<p class="empty">No client has started a selection yet.</p>`
+        : this.selections.map((selection) => this.renderSelection(selection))}
+    `;
+  }
+
+  private renderSelection(selection: Selection) {
+    const status = selection.locked_at
+      ? 'Locked'
+      : selection.submitted_at
+        ? `Submitted ${new Date(selection.submitted_at).toLocaleString()}`
+        : 'Not submitted';
+
+    return html`
+      <div class="selection">
+        <div class="client">
+          ${selection.client_name || (selection.share_link_id ? 'Share link client' : 'Anonymous')}
+        </div>
+        <div class="meta">${selection.favorites.length} photos · ${status}</div>
+        ${selection.note ? html`<p class="note">${selection.note}</p>` : ''}
+        <div class="actions">
+          <a href=${selectionExportUrl(this.albumId, selection.id, 'csv')} download>CSV</a>
+          <a href=${selectionExportUrl(this.albumId, selection.id, 'lightroom')} download>
+            Lightroom
+          </a>
+          <button type="button" @click=${() => this.toggleLock(selection)}>
+            ${selection.locked_at ? 'Unlock' : 'Lock'}
+          </button>
+        </div>
+      </div>
+    `;
+  }
+}
+
+declare global {
+  interface HTMLElementTagNameMap {
+    'admin-selections': AdminSelections;
+  }
+}
@@ -237,4 +237,27 @@ describe('PhotoGrid', () => {
 
     expect(photoItems).to.have.length(1);
   });
+
+  it('should not show favourite toggles outside proofing', async () => {
+    const el = await fixture<PhotoGrid>(html`<photo-grid .photos=${mockPhotos}></photo-grid>`);
+
+    expect(el.shadowRoot?.querySelectorAll('.favorite-toggle')).to.have.length(0);
+  });
+
+  it('should emit favorite-toggle without opening the photo', async () => {
+    const el = await fixture<PhotoGrid>(
+      html`<photo-grid .photos=${mockPhotos} .favorites=${new Set(['photo-2'])}></photo-grid>`
+    );
+    const toggles = el.shadowRoot?.querySelectorAll('.favorite-toggle');
+    expect(toggles).to.have.length(3);
+    expect(toggles?.[1].classList.contains('selected')).to.be.true;
+
+    let opened = false;
+    el.addEventListener('photo-click', () => (opened = true));
+    setTimeout(() => (toggles?.[0] as HTMLElement).click());
+    const event = (await oneEvent(el, 'favorite-toggle')) as CustomEvent<{ photo: Photo }>;
+
+    expect(event.detail.photo.id).to.equal('photo-1');
+    expect(opened).to.be.false;
+  });
 });
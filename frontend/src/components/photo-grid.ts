@@ -6,11 +6,14 @@ import './lazy-image';
 /**
  * Photo grid component with masonry layout.
  * Emits 'photo-click' event when a photo is clicked.
+ * With `favorites` set (proofing albums), each photo gets a favourite toggle
+ * that emits 'favorite-toggle'.
  */
 @customElement('photo-grid')
 export class PhotoGrid extends LitElement {
   @property({ type: Array }) photos: Photo[] = [];
   @property({ type: String }) layout: 'masonry' | 'grid' | 'justified' | 'square' = 'masonry';
+  @property({ attribute: false }) favorites?: Set<string>;
 
   static styles = css`
     :host {
@@ -67,11 +70,31 @@ export class PhotoGrid extends LitElement {
     }
 
     .photo-item {
+      position: relative;
       cursor: pointer;
       overflow: hidden;
       border-radius: 0px;
     }
 
+    .favorite-toggle {
+      position: absolute;
+      top: 0.5rem;
+      right: 0.5rem;
+      width: 2.25rem;
+      height: 2.25rem;
+      border: none;
+      border-radius: 50%;
+      background: rgba(0, 0, 0, 0.45);
+      color: #fff;
+      font-size: 1.25rem;
+      line-height: 1;
+      cursor: pointer;
+    }
+
+    .favorite-toggle.selected {
+      background: rgba(200, 30, 60, 0.85);
+    }
+
     .photo-item:hover {
       transform: translateY(-2px);
       box-shadow: 0 4px 12px rgba(0, 0, 0, 0.15);
@@ -136,10 +159,34 @@ export class PhotoGrid extends LitElement {
           alt="${photo.alt_text || photo.caption || `Photo ${index + 1}`}"
           aspectRatio="${aspectRatio}"
         ></lazy-image>
+        ${this.favorites ? this.renderFavoriteToggle(photo) : ''}
       </div>
     `;
   }
 
+  private renderFavoriteToggle(photo: Photo) {
+    const selected = this.favorites?.has(photo.id) ?? false;
+    return html`
+      <button
+        class="favorite-toggle ${selected ? 'selected' : ''}"
+        aria-pressed=${selected ? 'true' : 'false'}
+        aria-label=${selected ? 'Remove from favourites' : 'Add to favourites'}
+        @click=${(e: Event) => {
+          e.stopPropagation();
+          this.dispatchEvent(
+            new CustomEvent('favorite-toggle', {
+              detail: { photo },
+              bubbles: true,
+              composed: true,
+            })
+          );
+        }}
+      >
+        ${selected ? '♥' : '♡'}
+      </button>
+    `;
+  }
+
   private handlePhotoClick(photo: Photo, index: number) {
     this.dispatchEvent(
       new CustomEvent('photo-click', {
@@ -0,0 +1,152 @@
+import { LitElement, css, html } from 'lit';
+import { customElement, property, state } from 'lit/decorators.js';
+import type { Selection } from '../types/data-models';
+
+/**
+ * Summary bar for a client's selection on a proofing album: how many photos are
+ * picked, a note for the photographer and the submit button.
+ * Emits 'selection-submit' with the client's name and note.
+ */
+@customElement('proofing-panel')
+export class ProofingPanel extends LitElement {
+  @property({ attribute: false }) selection?: Selection;
+  @property({ type: Number }) limit = 0;
+  @property({ type: Boolean }) busy = false;
+  @property({ type: String }) error = '';
+
+  @state() private clientName = '';
+  @state() private note = '';
+
+  static styles = css`
+    :host {
+      display: block;
+      position: sticky;
+      bottom: 0;
+      z-index: 10;
+    }
+
+    .panel {
+      display: flex;
+      flex-wrap: wrap;
+      align-items: center;
+      gap: 0.75rem;
+      padding: 0.75rem 1rem;
+      background-color: var(--color-surface);
+      border-top: 1px solid var(--color-border);
+      box-shadow: 0 -2px 8px rgba(0, 0, 0, 0.08);
+    }
+
+    .count {
+      font-weight: 600;
+      color: var(--color-text-primary);
+    }
+
+    .status {
+      font-size: 0.875rem;
+      color: var(--color-text-secondary);
+    }
+
+    .error {
+      width: 100%;
+      font-size: 0.875rem;
+      color: #c00;
+    }
+
+    input {
+      flex: 1;
+      min-width: 10rem;
+      padding: 0.5rem;
+      border: 1px solid var(--color-border);
+      border-radius: 4px;
+      background-color: var(--color-background);
+      color: var(--color-text-primary);
+    }
+
+    button {
+      padding: 0.5rem 1.25rem;
+      border: none;
+      border-radius: 4px;
+      background-color: var(--color-text-primary);
+      color: var(--color-background);
+      cursor: pointer;
+    }
+
+    button:disabled {
+      opacity: 0.5;
+      cursor: not-allowed;
+    }
+  `;
+
+  updated(changedProperties: Map<string | number | symbol, unknown>) {
+    // Start from the saved name and note when a selection is opened
+    const previous = changedProperties.get('selection') as Selection | undefined;
+    if (changedProperties.has('selection') && this.selection && previous?.id !== this.selection.id) {
+      this.clientName = this.selection.client_name ?? '';
+      this.note = this.selection.note ?? '';
+    }
+  }
+
+  render() {
+    if (!this.selection) {
+      return '';
+    }
+
+    const count = this.selection.favorites.length;
+    const locked = Boolean(this.selection.locked_at);
+    const overLimit = this.limit > 0 && count > this.limit;
+
+    return html`
+      <div class="panel">
+        <span class="count">
+          ${this.limit > 0 ? `${count} of ${this.limit} chosen` : `${count} chosen`}
+        </span>
+        ${locked
+          ? html`<span class="status">Your selection is locked by the photographer.</span>`
+          : html`
+              <input
+                type="text"
+                placeholder="Your name"
+                aria-label="Your name"
+                maxlength="200"
+                .value=${this.clientName}
+                @input=${(e: Event) => (this.clientName = (e.target as HTMLInputElement).value)}
+              />
+              <input
+                type="text"
+                placeholder="Note for the photographer"
+                aria-label="Note for the photographer"
+                maxlength="2000"
+                .value=${this.note}
+                @input=${(e: Event) => (this.note = (e.target as HTMLInputElement).value)}
+              />
+              <button
+                ?disabled=${this.busy || count === 0 || overLimit}
+                @click=${() => this.handleSubmit()}
+              >
+                ${this.selection.submitted_at ? 'Submit again' : 'Submit selection'}
+              </button>
+            `}
+        ${this.selection.submitted_at && !locked
+          ? html`<span class="status">Submitted – you can still make changes.</span>`
+          : ''}
+        ${this.error ? html`<div class="error">${this.error}</div>` : ''}
+      </div>
+    `;
+  }
+
+  private handleSubmit() {
+    this.dispatchEvent(
+      new CustomEvent('selection-submit', {
+        detail: { clientName: this.clientName.trim(), note: this.note },
+        bubbles: true,
+        composed: true,
+      })
+    );
+  }
+}
+
+declare global {
+  interface HTMLElementTagNameMap {
+    'proofing-panel': ProofingPanel;
+  }
+}
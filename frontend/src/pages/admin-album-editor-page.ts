@@ -5,11 +5,19 @@
 import { LitElement, css, html } from 'lit';
 import { customElement, property, state } from 'lit/decorators.js';
 import '../components/admin-header';
+import '../components/admin-selections';
 import '../components/toast-notification';
 import '../components/upload-placeholder';
-import type { Album, Photo, SiteConfig } from '../types/data-models';
+import type {
+  Album,
+  AlbumExpiryAction,
+  AlbumVisibility,
+  Photo,
+  SiteConfig,
+} from '../types/data-models';
 import {
   createAlbum,
+  createPreviewLink,
   deleteAlbum,
   deleteAllPhotos,
   deletePhoto,
@@ -26,6 +34,19 @@ import { onLogout } from '../utils/auth-state';
 import { CONCURRENT_UPLOAD_COUNT, MAX_UPLOAD_BATCH_SIZE } from '../utils/constants';
 import { navigateTo, navigateToAlbum, routes } from '../utils/navigation';
 
+/** Formats an ISO timestamp for a datetime-local input, in local time. */
+function toDateTimeLocal(iso?: string): string {
+  if (!iso) return '';
+  const date = new Date(iso);
+  const local = new Date(date.getTime() - date.getTimezoneOffset() * 60000);
+  return local.toISOString().slice(0, 16);
+}
+
+/** Parses a datetime-local input value to an ISO timestamp, or undefined when empty. */
+function fromDateTimeLocal(value: string): string | undefined {
+  return value ? new Date(value).toISOString() : undefined;
+}
+
 @customElement('admin-album-editor-page')
 export class AdminAlbumEditorPage extends LitElement {
   static styles = css`
@@ -515,6 +536,9 @@ export class AdminAlbumEditorPage extends LitElement {
   @state()
   private hasUnsavedChanges = false;
 
+  @state()
+  private previewUrl = '';
+
   @state()
   private uploadProgress: Map<string, UploadProgress> = new Map();
 
@@ -1105,6 +1129,129 @@ export class AdminAlbumEditorPage extends LitElement {
     }
   }
 
+  /**
+   * Preview links let reviewers see a draft without logging in.
+   */
+  private renderPreviewLink() {
+    return html`
+      <div class="form-group">
+        <label for="preview_url">Preview link</label>
+        ${this.previewUrl
+          ? html`<input type="text" id="preview_url" readonly .value=${this.previewUrl} />`
+          : ''}
+        <button type="button" class="btn btn-secondary" @click=${() => this.createPreview()}>
+          ${this.previewUrl ? 'Create another' : 'Create preview link'}
+        </button>
+        <small style="color: var(--color-text-secondary, #666); font-size: 0.875rem;">
+          Drafts are never published. Anyone with the link sees the album for a week.
+        </small>
+      </div>
+    `;
+  }
+
+  private async createPreview() {
+    this.error = '';
+    try {
+      const { url } = await createPreviewLink(this.albumId);
+      this.previewUrl = new URL(url, window.location.origin).toString();
+    } catch (err) {
+      this.error = err instanceof Error ? err.message : 'Failed to create preview link';
+    }
+  }
+
+  /**
+   * Scheduled publishing and expiry. Expired albums disappear for visitors at
+   * the expiration date; archived ones stay gone until restored.
+   */
+  private renderSchedule() {
+    const hint = 'color: var(--color-text-secondary, #666); font-size: 0.875rem;';
+
+    return html`
+      <div class="form-row">
+        <div class="form-group">
+          <label for="publish_at">Publish at</label>
+          <input
+            type="datetime-local"
+            id="publish_at"
+            .value=${toDateTimeLocal(this.album.publish_at)}
+            @change=${(e: Event) =>
+              this.updateField(
+                'publish_at',
+                fromDateTimeLocal((e.target as HTMLInputElement).value)
+              )}
+          />
+          ${this.album.publish_at
+            ? html`
+                <select
+                  id="publish_visibility"
+                  aria-label="Visibility at publish time"
+                  .value=${this.album.publish_visibility || 'public'}
+                  @change=${(e: Event) =>
+                    this.updateField(
+                      'publish_visibility',
+                      (e.target as HTMLSelectElement).value as Album['publish_visibility']
+                    )}
+                >
+                  <option value="public">Becomes public</option>
+                  <option value="unlisted">Becomes unlisted</option>
+                  <option value="password_protected">Becomes password protected</option>
+                </select>
+              `
+            : html`<small style=${hint}>Leave empty to keep the current visibility</small>`}
+        </div>
+
+        <div class="form-group">
+          <label for="expiration_date">Expires at</label>
+          <input
+            type="datetime-local"
+            id="expiration_date"
+            .value=${toDateTimeLocal(this.album.expiration_date)}
+            @change=${(e: Event) =>
+              this.updateField(
+                'expiration_date',
+                fromDateTimeLocal((e.target as HTMLInputElement).value)
+              )}
+          />
+          ${this.album.expiration_date
+            ? html`
+                <select
+                  id="expiry_action"
+                  aria-label="On expiry"
+                  .value=${this.album.expiry_action || 'hide'}
+                  @change=${(e: Event) =>
+                    this.updateField(
+                      'expiry_action',
+                      (e.target as HTMLSelectElement).value as AlbumExpiryAction
+                    )}
+                >
+                  <option value="hide">Hide until the date is moved</option>
+                  <option value="archive">Archive</option>
+                </select>
+              `
+            : ''}
+          ${this.album.archived_at
+            ? html`
+                <small style=${hint}>
+                  Archived ${new Date(this.album.archived_at).toLocaleString()}.
+                  <button type="button" class="btn btn-secondary" @click=${() => this.restore()}>
+                    Restore
+                  </button>
+                </small>
+              `
+            : ''}
+        </div>
+      </div>
+    `;
+  }
+
+  /**
+   * Bring an archived album back by clearing the archive and its expiry.
+   */
+  private async restore() {
+    this.album = { ...this.album, archived_at: undefined, expiration_date: undefined };
+    await this.autoSave();
+  }
+
   private formatBytes(bytes: number): string {
     const units = ['B', 'KB', 'MB', 'GB', 'TB'];
     let size = bytes;
@@ -1206,7 +1353,7 @@ export class AdminAlbumEditorPage extends LitElement {
                     .value=${this.album.visibility || 'public'}
                     @change=${(e: Event) => {
                       const select = e.target as HTMLSelectElement;
-                      const value = select.value as 'public' | 'unlisted' | 'password_protected';
+                      const value = select.value as AlbumVisibility;
                       this.updateField('visibility', value);
                       // Auto-save unless switching to password_protected (user needs to enter password first)
                       if (value !== 'password_protected') {
@@ -1217,6 +1364,7 @@ export class AdminAlbumEditorPage extends LitElement {
                     <option value="public">Public</option>
                     <option value="unlisted">Unlisted</option>
                     <option value="password_protected">Password Protected</option>
+                    <option value="draft">Draft</option>
                   </select>
                 </div>
 
@@ -1242,7 +1390,9 @@ export class AdminAlbumEditorPage extends LitElement {
                         </small>
                       </div>
                     `
-                  : html` <div class="form-group"></div> `}
+                  : this.album.visibility === 'draft' && !isNew
+                    ? this.renderPreviewLink()
+                    : html` <div class="form-group"></div> `}
               </div>
 
               <div class="form-group">
@@ -1260,6 +1410,55 @@ export class AdminAlbumEditorPage extends LitElement {
                 </div>
               </div>
 
+              <div class="form-row">
+                <div class="form-group">
+                  <div class="checkbox-group">
+                    <input
+                      type="checkbox"
+                      id="proofing_enabled"
+                      .checked=${this.album.proofing?.enabled ?? false}
+                      @change=${(e: Event) => {
+                        this.updateField('proofing', {
+                          ...this.album.proofing,
+                          enabled: (e.target as HTMLInputElement).checked,
+                        });
+                        void this.autoSave();
+                      }}
+                    />
+                    <label for="proofing_enabled">Client proofing</label>
+                  </div>
+                  <small style="color: var(--color-text-secondary, #666); font-size: 0.875rem;">
+                    Visitors with the password or a share link can pick and submit favourites
+                  </small>
+                </div>
+                ${this.album.proofing?.enabled
+                  ? html`
+                      <div class="form-group">
+                        <label for="selection_limit">Selection limit</label>
+                        <input
+                          type="number"
+                          id="selection_limit"
+                          min="0"
+                          .value=${String(this.album.proofing.selection_limit ?? 0)}
+                          @input=${(e: Event) =>
+                            this.updateField('proofing', {
+                              enabled: true,
+                              selection_limit:
+                                parseInt((e.target as HTMLInputElement).value, 10) || 0,
+                            })}
+                        />
+                        <small
+                          style="color: var(--color-text-secondary, #666); font-size: 0.875rem;"
+                        >
+                          0 for no limit
+                        </small>
+                      </div>
+                    `
+                  : html` <div class="form-group"></div> `}
+              </div>
+
+              ${this.renderSchedule()}
+
               <div
                 style="display: flex; justify-content: flex-start; gap: 1rem; margin-top: 1.5rem;"
               >
@@ -1288,6 +1487,14 @@ export class AdminAlbumEditorPage extends LitElement {
             </div>
           </form>
 
+          ${!isNew && this.album.proofing?.enabled
+            ? html`
+                <div class="form-section">
+                  <h2>Client Selections</h2>
+                  <admin-selections .albumId=${this.albumId}></admin-selections>
+                </div>
+              `
+            : ''}
           ${!isNew
             ? html`
                 <div class="form-section">
@@ -181,6 +181,11 @@ export class AdminAlbumsPage extends LitElement {
       color: var(--color-warning-text, #856404);
     }
 
+    .visibility-draft {
+      background: var(--color-border, #eee);
+      color: var(--color-text-secondary, #666);
+    }
+
     .visibility-password_protected {
       background: var(--color-danger-bg, #f8d7da);
       color: var(--color-danger-text, #721c24);
@@ -8,8 +8,8 @@ import { customElement, state } from 'lit/decorators.js';
 import '../components/admin-header';
 import '../components/storage-stats';
 import '../components/toast-notification';
-import type { Album, SiteConfig } from '../types/data-models';
-import { fetchAdminSiteConfig, fetchAllAlbums } from '../utils/admin-api';
+import type { Album, ScheduleEntry, SiteConfig } from '../types/data-models';
+import { fetchAdminSiteConfig, fetchAllAlbums, fetchSchedule } from '../utils/admin-api';
 import { onLogout } from '../utils/auth-state';
 import { handleNavClick, routes } from '../utils/navigation';
 
@@ -145,6 +145,33 @@ export class AdminDashboardPage extends LitElement {
       margin: 0;
     }
 
+    .schedule {
+      background: var(--color-surface, white);
+      padding: 1.5rem;
+      margin-bottom: 2rem;
+      box-shadow: var(--shadow-sm);
+    }
+
+    .schedule-entry {
+      display: flex;
+      justify-content: space-between;
+      gap: 1rem;
+      padding: 0.5rem 0;
+      font-size: 0.875rem;
+      color: var(--color-text-primary, #333);
+      text-decoration: none;
+      border-bottom: 1px solid var(--color-border, #ddd);
+    }
+
+    .schedule-entry:last-child {
+      border-bottom: none;
+    }
+
+    .schedule-entry.soon {
+      color: #c00;
+      font-weight: 600;
+    }
+
     .loading,
     .error {
       text-align: center;
@@ -169,6 +196,9 @@ export class AdminDashboardPage extends LitElement {
   @state()
   private siteConfig: SiteConfig | null = null;
 
+  @state()
+  private schedule: ScheduleEntry[] = [];
+
   @state()
   private loading = true;
 
@@ -202,6 +232,7 @@ export class AdminDashboardPage extends LitElement {
   private clearState() {
     this.albums = [];
     this.siteConfig = null;
+    this.schedule = [];
     this.loading = false;
     this.error = '';
   }
@@ -212,9 +243,10 @@ export class AdminDashboardPage extends LitElement {
     this.error = '';
 
     try {
-      [this.albums, this.siteConfig] = await Promise.all([
+      [this.albums, this.siteConfig, this.schedule] = await Promise.all([
         fetchAllAlbums(),
         fetchAdminSiteConfig(),
+        fetchSchedule(),
       ]);
     } catch (err) {
       this.error = err instanceof Error ? err.message : 'Failed to load data';
@@ -312,6 +344,8 @@ export class AdminDashboardPage extends LitElement {
         </div>
       </div>
 
+      ${this.schedule.length > 0 ? this.renderSchedule() : ''}
+
       <div class="quick-actions">
         <h2 class="section-title">Quick Actions</h2>
         <div class="actions-grid">
@@ -344,6 +378,35 @@ export class AdminDashboardPage extends LitElement {
       <storage-stats style="margin-top: 2rem;"></storage-stats>
     `;
   }
+
+  /**
+   * Upcoming scheduled publishes and expiries. Albums expiring within the
+   * warning window are highlighted.
+   */
+  private renderSchedule() {
+    return html`
+      <div class="schedule">
+        <h2 class="section-title">Schedule</h2>
+        ${this.schedule.map(
+          (entry) => html`
+            <a
+              href=${routes.admin.editAlbum(entry.album_id)}
+              class="schedule-entry ${entry.soon ? 'soon' : ''}"
+              @click=${handleNavClick}
+            >
+              <span>
+                ${entry.title} –
+                ${entry.event === 'publish'
+                  ? `becomes ${(entry.visibility ?? 'public').replace('_', ' ')}`
+                  : 'expires'}
+              </span>
+              <span>${new Date(entry.at).toLocaleString()}</span>
+            </a>
+          `
+        )}
+      </div>
+    `;
+  }
 }
 declare global {
   interface HTMLElementTagNameMap {
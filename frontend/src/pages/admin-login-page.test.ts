@@ -106,6 +106,52 @@ describe('AdminLoginPage', () => {
     });
   });
 
+  it('should ask for a two-factor code when required', async () => {
+    loginStub.onFirstCall().resolves({
+      message: 'Two-factor code required',
+      two_factor_required: true,
+      challenge: 'challenge-token',
+    });
+    loginStub.onSecondCall().resolves({ message: 'Login successful' });
+
+    const el = await fixture<AdminLoginPage>(html`<admin-login-page></admin-login-page>`);
+
+    const usernameInput = el.shadowRoot?.querySelector(
+      'input[name="username"]'
+    ) as HTMLInputElement;
+    const passwordInput = el.shadowRoot?.querySelector(
+      'input[name="password"]'
+    ) as HTMLInputElement;
+    usernameInput.value = 'admin';
+    usernameInput.dispatchEvent(new Event('input', { bubbles: true }));
+    passwordInput.value = 'test123';
+    passwordInput.dispatchEvent(new Event('input', { bubbles: true }));
+    await el.updateComplete;
+
+    let form = el.shadowRoot?.querySelector('form') as HTMLFormElement;
+    form.dispatchEvent(new Event('submit', { bubbles: true, cancelable: true }));
+    await waitUntil(
+      () => el.shadowRoot?.querySelector('input[name="code"]'),
+      'code input should be shown'
+    );
+    expect(el.shadowRoot?.querySelector('input[name="password"]')).to.be.null;
+
+    const codeInput = el.shadowRoot?.querySelector('input[name="code"]') as HTMLInputElement;
+    codeInput.value = '123456';
+    codeInput.dispatchEvent(new Event('input', { bubbles: true }));
+    await el.updateComplete;
+
+    form = el.shadowRoot?.querySelector('form') as HTMLFormElement;
+    form.dispatchEvent(new Event('submit', { bubbles: true, cancelable: true }));
+    await waitUntil(() => loginStub.calledTwice, 'login should be called again');
+
+    expect(loginStub.secondCall).to.have.been.calledWith({
+      username: 'admin',
+      challenge: 'challenge-token',
+      code: '123456',
+    });
+  });
+
   it('should display error message on login failure', async () => {
     loginStub.rejects(new Error('Invalid credentials'));
 
@@ -5,7 +5,7 @@
 import { LitElement, css, html } from 'lit';
 import { customElement, state } from 'lit/decorators.js';
 import '../components/toast-notification';
-import { login } from '../utils/admin-api';
+import { fetchOIDCStatus, login, oidcLoginURL } from '../utils/admin-api';
 import { navigateTo, routes } from '../utils/navigation';
 
 @customElement('admin-login-page')
@@ -106,6 +106,33 @@ export class AdminLoginPage extends LitElement {
     .loading {
       opacity: 0.6;
     }
+
+    .sso {
+      margin-top: 1.5rem;
+      text-align: center;
+    }
+
+    .sso p {
+      margin: 0 0 1rem;
+      color: var(--color-text-secondary, #666);
+      font-size: 0.875rem;
+    }
+
+    .sso a {
+      display: block;
+      padding: 0.875rem;
+      border: 1px solid var(--color-primary, #007bff);
+      color: var(--color-primary, #007bff);
+      font-size: 1rem;
+      font-weight: 500;
+      text-decoration: none;
+      text-transform: uppercase;
+      letter-spacing: 0.05em;
+    }
+
+    .sso a:hover {
+      background: var(--color-background, #f5f5f5);
+    }
   `;
 
   @state()
@@ -114,6 +141,13 @@ export class AdminLoginPage extends LitElement {
   @state()
   private password = '';
 
+  // Set once the password is accepted and a two-factor code is needed
+  @state()
+  private challenge = '';
+
+  @state()
+  private code = '';
+
   @state()
   private loading = false;
 
@@ -123,6 +157,21 @@ export class AdminLoginPage extends LitElement {
   @state()
   private success = false;
 
+  @state()
+  private ssoEnabled = false;
+
+  connectedCallback() {
+    super.connectedCallback();
+    // The server sends failed single sign-on logins back here
+    const ssoError = new URLSearchParams(window.location.search).get('sso_error');
+    if (ssoError === 'denied') {
+      this.error = 'Your account is not allowed to sign in here';
+    } else if (ssoError) {
+      this.error = 'Single sign-on failed, please try again';
+    }
+    void fetchOIDCStatus().then((enabled) => (this.ssoEnabled = enabled));
+  }
+
   private handleSubmit(e: Event) {
     e.preventDefault();
     void this.performLogin();
@@ -133,10 +182,16 @@ export class AdminLoginPage extends LitElement {
     this.loading = true;
 
     try {
-      await login({
-        username: this.username,
-        password: this.password,
-      });
+      const result = this.challenge
+        ? await login({ username: this.username, challenge: this.challenge, code: this.code })
+        : await login({ username: this.username, password: this.password });
+
+      if (result?.two_factor_required && result.challenge) {
+        this.challenge = result.challenge;
+        this.password = '';
+        this.loading = false;
+        return;
+      }
 
       this.success = true;
 
@@ -151,6 +206,56 @@ export class AdminLoginPage extends LitElement {
     }
   }
 
+  private renderCredentialFields() {
+    return html`
+      <div class="form-group">
+        <label for="username">Username</label>
+        <input
+          type="text"
+          id="username"
+          name="username"
+          .value=${this.username}
+          @input=${(e: Event) => (this.username = (e.target as HTMLInputElement).value)}
+          required
+          autocomplete="username"
+          ?disabled=${this.loading}
+        />
+      </div>
+
+      <div class="form-group">
+        <label for="password">Password</label>
+        <input
+          type="password"
+          id="password"
+          name="password"
+          .value=${this.password}
+          @input=${(e: Event) => (this.password = (e.target as HTMLInputElement).value)}
+          required
+          autocomplete="current-password"
+          ?disabled=${this.loading}
+        />
+      </div>
+    `;
+  }
+
+  private renderCodeField() {
+    return html`
+      <div class="form-group">
+        <label for="code">Authentication code or recovery code</label>
+        <input
+          type="text"
+          id="code"
+          name="code"
+          .value=${this.code}
+          @input=${(e: Event) => (this.code = (e.target as HTMLInputElement).value)}
+          required
+          autocomplete="one-time-code"
+          ?disabled=${this.loading}
+        />
+      </div>
+    `;
+  }
+
   render() {
     return html`
       <div class="login-container">
@@ -159,38 +264,21 @@ export class AdminLoginPage extends LitElement {
           <p class="subtitle">Photography Portfolio Admin</p>
 
           <form @submit=${(e: Event) => this.handleSubmit(e)}>
-            <div class="form-group">
-              <label for="username">Username</label>
-              <input
-                type="text"
-                id="username"
-                name="username"
-                .value=${this.username}
-                @input=${(e: Event) => (this.username = (e.target as HTMLInputElement).value)}
-                required
-                autocomplete="username"
-                ?disabled=${this.loading}
-              />
-            </div>
-
-            <div class="form-group">
-              <label for="password">Password</label>
-              <input
-                type="password"
-                id="password"
-                name="password"
-                .value=${this.password}
-                @input=${(e: Event) => (this.password = (e.target as HTMLInputElement).value)}
-                required
-                autocomplete="current-password"
-                ?disabled=${this.loading}
-              />
-            </div>
+            ${this.challenge ? this.renderCodeField() : this.renderCredentialFields()}
 
             <button type="submit" ?disabled=${this.loading} class=${this.loading ? 'loading' : ''}>
               ${this.loading ? 'Logging in...' : 'Login'}
             </button>
           </form>
+
+          ${this.ssoEnabled && !this.challenge
+            ? html`
+                <div class="sso">
+                  <p>or</p>
+                  <a href=${oidcLoginURL()}>Sign in with single sign-on</a>
+                </div>
+              `
+            : ''}
         </div>
       </div>
 
@@ -732,6 +732,45 @@ export class AdminSettingsPage extends LitElement {
               </p>
             </div>
 
+            <div class="form-group">
+              <label for="storage-max-megapixels">Maximum Image Resolution (megapixels)</label>
+              <input
+                type="number"
+                id="storage-max-megapixels"
+                min="1"
+                max="1000"
+                .value=${this.config?.storage?.max_image_megapixels?.toString() || '150'}
+                @input=${(e: Event) => {
+                  const value = parseInt((e.target as HTMLInputElement).value, 10);
+                  if (value >= 1 && value <= 1000) {
+                    this.updateConfigField('storage.max_image_megapixels', value);
+                  }
+                }}
+              />
+              <p class="help-text">
+                Largest image accepted, counting every frame of an animation (1-1000). Default is
+                150 megapixels.
+              </p>
+            </div>
+
+            <div class="form-group">
+              <label for="storage-max-dimension">Maximum Image Width or Height (pixels)</label>
+              <input
+                type="number"
+                id="storage-max-dimension"
+                min="1000"
+                max="65000"
+                .value=${this.config?.storage?.max_image_dimension?.toString() || '30000'}
+                @input=${(e: Event) => {
+                  const value = parseInt((e.target as HTMLInputElement).value, 10);
+                  if (value >= 1000 && value <= 65000) {
+                    this.updateConfigField('storage.max_image_dimension', value);
+                  }
+                }}
+              />
+              <p class="help-text">Longest side accepted (1000-65000). Default is 30000 pixels.</p>
+            </div>
+
             <button type="submit" class="btn btn-primary" ?disabled=${this.saving}>
               ${this.saving ? 'Saving...' : 'Save Storage Settings'}
             </button>
@@ -3,8 +3,19 @@ import { customElement, property, state } from 'lit/decorators.js';
 import '../components/album-cover-hero';
 import '../components/loading-spinner';
 import '../components/photo-grid';
-import type { Album, SiteConfig } from '../types/data-models';
-import { fetchAlbumBySlug, fetchSiteConfig, hasAlbumAccess } from '../utils/api';
+import '../components/proofing-panel';
+import type { Album, Photo, Selection, SiteConfig } from '../types/data-models';
+import {
+  albumDownloadUrl,
+  fetchAlbumBySlug,
+  fetchSiteConfig,
+  hasAlbumAccess,
+  redeemShareLink,
+  saveSelection,
+  startSelection,
+  storeAlbumToken,
+  submitSelection,
+} from '../utils/api';
 import { createPhotoClickHandler, navigateToPhoto } from '../utils/navigation';
 import './password-form';
 
@@ -19,6 +30,9 @@ export class AlbumDetailPage extends LitElement {
   @state() private siteConfig?: SiteConfig;
   @state() private loading = true;
   @state() private error = '';
+  @state() private selection?: Selection;
+  @state() private proofingBusy = false;
+  @state() private proofingError = '';
   @state() private needsPassword = false;
 
   static styles = css`
@@ -55,6 +69,27 @@ export class AlbumDetailPage extends LitElement {
       padding: 0 0 2rem 0;
     }
 
+    .download-section {
+      display: flex;
+      justify-content: center;
+      gap: 1.5rem;
+      padding: 0 2rem 2rem;
+      font-size: 0.875rem;
+    }
+
+    .download-section a {
+      color: var(--color-text-secondary);
+    }
+
+    .preview-banner {
+      padding: 0.5rem 1rem;
+      text-align: center;
+      font-size: 0.875rem;
+      background-color: var(--color-surface);
+      color: var(--color-text-secondary);
+      border-bottom: 1px solid var(--color-border);
+    }
+
     .password-container {
       display: flex;
       align-items: center;
@@ -84,6 +119,14 @@ export class AlbumDetailPage extends LitElement {
       this.loading = true;
       this.needsPassword = false;
       this.error = '';
+      this.selection = undefined;
+      this.proofingError = '';
+
+      const shareError = await this.redeemShareParameter();
+      if (shareError) {
+        this.error = shareError;
+        return;
+      }
 
       const [album, siteConfig] = await Promise.all([
         fetchAlbumBySlug(this.slug),
@@ -101,6 +144,20 @@ export class AlbumDetailPage extends LitElement {
       // Check if password is required
       if (this.album.visibility === 'password_protected' && !hasAlbumAccess(this.album.id)) {
         this.needsPassword = true;
+        return;
+      }
+
+      // Clients with a password or share link can pick favourites; reviewers of a draft can't
+      if (
+        this.album.proofing?.enabled &&
+        this.album.visibility !== 'draft' &&
+        hasAlbumAccess(this.album.id)
+      ) {
+        try {
+          this.selection = await startSelection(this.album);
+        } catch (err) {
+          console.error(err);
+        }
       }
     } catch (err) {
       this.error = 'Failed to load album';
@@ -110,6 +167,29 @@ export class AlbumDetailPage extends LitElement {
     }
   }
 
+  /**
+   * Redeem a ?share=token link, storing the album access token it grants and
+   * removing the token from the address bar. Returns an error message if the
+   * link could not be redeemed.
+   */
+  private async redeemShareParameter(): Promise<string | undefined> {
+    const url = new URL(window.location.href);
+    const shareToken = url.searchParams.get('share');
+    if (!shareToken) {
+      return undefined;
+    }
+
+    url.searchParams.delete('share');
+    window.history.replaceState(window.history.state, '', url.pathname + url.search + url.hash);
+
+    const result = await redeemShareLink(this.slug, shareToken);
+    if (!result.success || !result.albumId || !result.token) {
+      return result.error || 'This link is invalid or has expired.';
+    }
+    storeAlbumToken(result.albumId, result.token);
+    return undefined;
+  }
+
   connectedCallback() {
     super.connectedCallback();
     // Don't load here - wait for slug to be set in updated()
@@ -160,8 +240,8 @@ export class AlbumDetailPage extends LitElement {
         <div class="password-container">
           <password-form
             .albumId=${this.album.id}
+            .albumSlug=${this.album.slug}
             .albumTitle=${this.album.title}
-            .passwordHash=${this.album.password_hash || ''}
             @password-success=${() => this.handlePasswordSuccess()}
           ></password-form>
         </div>
@@ -172,6 +252,9 @@ export class AlbumDetailPage extends LitElement {
       this.album.photos.find((p) => p.id === this.album?.cover_photo_id) || this.album.photos[0];
 
     return html`
+      ${this.album.visibility === 'draft'
+        ? html`<div class="preview-banner">Preview – this album is not published yet.</div>`
+        : ''}
       <album-cover-hero
         .coverPhoto=${coverPhoto}
         .title=${this.album.title}
@@ -188,9 +271,81 @@ export class AlbumDetailPage extends LitElement {
             | 'grid'
             | 'justified'
             | 'square'}
+          .favorites=${this.selection ? new Set(this.selection.favorites) : undefined}
           @photo-click=${this.handlePhotoClick}
+          @favorite-toggle=${(e: CustomEvent<{ photo: Photo }>) =>
+            void this.handleFavoriteToggle(e.detail.photo)}
         ></photo-grid>
       </div>
+
+      ${this.album.allow_downloads && this.album.photos.length > 0
+        ? this.renderDownloads()
+        : ''}
+
+      ${this.selection
+        ? html`
+            <proofing-panel
+              .selection=${this.selection}
+              .limit=${this.album.proofing?.selection_limit ?? 0}
+              .busy=${this.proofingBusy}
+              .error=${this.proofingError}
+              @selection-submit=${(e: CustomEvent<{ clientName: string; note: string }>) =>
+                void this.handleSelectionSubmit(e.detail.clientName, e.detail.note)}
+            ></proofing-panel>
+          `
+        : ''}
+    `;
+  }
+
+  private async handleFavoriteToggle(photo: Photo) {
+    if (!this.album || !this.selection || this.selection.locked_at) {
+      return;
+    }
+
+    const previous = this.selection;
+    const favorites = previous.favorites.includes(photo.id)
+      ? previous.favorites.filter((id) => id !== photo.id)
+      : [...previous.favorites, photo.id];
+
+    // Show the change right away, and undo it if it cannot be saved
+    this.selection = { ...previous, favorites };
+    this.proofingError = '';
+    try {
+      this.selection = await saveSelection(this.album, { ...previous, favorites });
+    } catch (err) {
+      this.selection = previous;
+      this.proofingError = err instanceof Error ? err.message : 'Failed to save selection';
+    }
+  }
+
+  private async handleSelectionSubmit(clientName: string, note: string) {
+    if (!this.album || !this.selection) {
+      return;
+    }
+
+    this.proofingBusy = true;
+    this.proofingError = '';
+    try {
+      const saved = await saveSelection(this.album, {
+        ...this.selection,
+        client_name: clientName,
+        note,
+      });
+      this.selection = await submitSelection(this.album, saved.id);
+    } catch (err) {
+      this.proofingError = err instanceof Error ? err.message : 'Failed to submit selection';
+    } finally {
+      this.proofingBusy = false;
+    }
+  }
+
+  private renderDownloads() {
+    const slug = this.album?.slug || '';
+    return html`
+      <div class="download-section">
+        <a href=${albumDownloadUrl(slug, 'original')} download>Download originals (ZIP)</a>
+        <a href=${albumDownloadUrl(slug, 'large')} download>Download large JPEGs (ZIP)</a>
+      </div>
     `;
   }
 
@@ -203,8 +358,8 @@ export class AlbumDetailPage extends LitElement {
   }
 
   private handlePasswordSuccess() {
-    this.needsPassword = false;
-    this.requestUpdate();
+    // Reload to fetch the photos with the new access token
+    void this.loadData();
   }
 }
 
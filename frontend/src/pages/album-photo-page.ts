@@ -644,10 +644,10 @@ export class AlbumPhotoPage extends LitElement {
   };
 
   private handleDownload = () => {
-    if (!this.currentPhoto) return;
+    if (!this.currentPhoto?.url_original) return;
     const link = document.createElement('a');
     link.href = this.currentPhoto.url_original;
-    link.download = this.currentPhoto.filename_original;
+    link.download = this.currentPhoto.filename_original ?? '';
     link.click();
   };
 
@@ -735,9 +735,11 @@ export class AlbumPhotoPage extends LitElement {
       <div class="page-container">
         <div class="toolbar">
           <span class="photo-counter">${this.currentIndex + 1} / ${this.album.photos.length}</span>
-          <button class="toolbar-button" @click=${this.handleDownload} title="Download">
-            ${unsafeSVG(downloadIcon)}
-          </button>
+          ${this.currentPhoto.url_original
+            ? html`<button class="toolbar-button" @click=${this.handleDownload} title="Download">
+                ${unsafeSVG(downloadIcon)}
+              </button>`
+            : ''}
           <button class="toolbar-button" @click=${() => void this.handleShare()} title="Share">
             ${unsafeSVG(shareIcon)}
           </button>
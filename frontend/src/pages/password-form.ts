@@ -1,18 +1,17 @@
-import { compare } from 'bcrypt-ts';
 import { LitElement, css, html } from 'lit';
 import { customElement, property, state } from 'lit/decorators.js';
 import '../components/loading-spinner';
-import { storeAlbumToken } from '../utils/api';
+import { storeAlbumToken, verifyAlbumPassword } from '../utils/api';
 
 /**
  * Password entry form for password-protected albums.
- * Verifies password client-side against bcrypt hash for static site compatibility.
+ * The password is checked by the server, which returns an access token for the album.
  */
 @customElement('password-form')
 export class PasswordForm extends LitElement {
   @property({ type: String }) albumId = '';
+  @property({ type: String }) albumSlug = '';
   @property({ type: String }) albumTitle = '';
-  @property({ type: String }) passwordHash = '';
 
   @state() private password = '';
   @state() private error = '';
@@ -160,22 +159,14 @@ export class PasswordForm extends LitElement {
       return;
     }
 
-    if (!this.passwordHash) {
-      this.error = 'Album configuration error - no password hash';
-      return;
-    }
-
     try {
       this.loading = true;
       this.error = '';
 
-      // Verify password client-side using bcrypt
-      const isValid = await compare(this.password, this.passwordHash);
+      const result = await verifyAlbumPassword(this.albumSlug, this.password);
 
-      if (isValid) {
-        // Generate a simple token (timestamp) to indicate successful verification
-        const token = `${this.albumId}_${Date.now()}`;
-        storeAlbumToken(this.albumId, token);
+      if (result.success && result.token) {
+        storeAlbumToken(this.albumId, result.token);
 
         this.dispatchEvent(
           new CustomEvent('password-success', {
@@ -184,7 +175,7 @@ export class PasswordForm extends LitElement {
           })
         );
       } else {
-        this.error = 'Invalid password';
+        this.error = result.error || 'Invalid password';
       }
     } catch (err) {
       this.error = 'An error occurred. Please try again.';
@@ -9,8 +9,8 @@
 
 export interface Photo {
   id: string;
-  filename_original: string;
-  url_original: string;
+  filename_original?: string; // Admin API only
+  url_original?: string; // Omitted from public data unless the album allows downloads
   url_display: string;
   url_thumbnail: string;
   caption?: string;
@@ -18,13 +18,59 @@ export interface Photo {
   order: number;
   width: number;
   height: number;
-  file_size_original: number;
+  frame_count?: number; // Animated GIF/WebP only
+  file_size_original?: number;
   file_size_display: number;
   file_size_thumbnail: number;
+  focal_point?: FocalPoint;
+  url_square?: string;
+  url_portrait?: string;
+  url_social?: string;
+  file_size_square?: number;
+  file_size_portrait?: number;
+  file_size_social?: number;
+  edits?: PhotoEdits; // Admin API only
+  versions?: PhotoVersion[]; // Admin API only; previous files, oldest first
   exif?: ExifData;
   uploaded_at: string;
 }
 
+/** Relative (0-1) point of interest used to center cropped photo versions. */
+export interface FocalPoint {
+  x: number;
+  y: number;
+}
+
+/** Non-destructive edit stack; derivatives are rendered from the original. */
+export interface PhotoEdits {
+  rotate?: 0 | 90 | 180 | 270;
+  flip_horizontal?: boolean;
+  flip_vertical?: boolean;
+  straighten?: number;
+  crop?: CropRect;
+}
+
+/** Crop rectangle relative (0-1) to the rotated and straightened image. */
+export interface PhotoVersion {
+  id: string;
+  filename_original: string;
+  url_original: string;
+  width: number;
+  height: number;
+  file_size_original: number;
+  edits?: PhotoEdits;
+  focal_point?: FocalPoint;
+  exif?: ExifData;
+  replaced_at: string;
+}
+
+export interface CropRect {
+  x: number;
+  y: number;
+  width: number;
+  height: number;
+}
+
 export interface ExifData {
   camera?: string;
   lens?: string;
@@ -35,7 +81,8 @@ export interface ExifData {
   date_taken?: string;
 }
 
-export type AlbumVisibility = 'public' | 'unlisted' | 'password_protected';
+export type AlbumVisibility = 'public' | 'unlisted' | 'password_protected' | 'draft';
+export type AlbumExpiryAction = 'hide' | 'archive';
 export type ThemeMode = 'system' | 'light' | 'dark';
 
 export interface Album {
@@ -46,8 +93,13 @@ export interface Album {
   description?: string;
   cover_photo_id?: string;
   visibility: AlbumVisibility;
-  password_hash?: string;
+  password_hash?: string; // Admin API only
   expiration_date?: string;
+  expiry_action?: AlbumExpiryAction; // Admin API only
+  archived_at?: string; // Admin API only
+  expiry_warned_at?: string; // Admin API only
+  publish_at?: string; // Admin API only
+  publish_visibility?: Exclude<AlbumVisibility, 'draft'>; // Admin API only; public when unset
   allow_downloads: boolean;
   order: number;
   theme_override?: ThemeMode;
@@ -55,7 +107,41 @@ export interface Album {
   updated_at: string;
   date_of_album_start?: string;
   date_of_album_end?: string;
-  photos: Photo[];
+  proofing?: AlbumProofing;
+  photo_count?: number; // Public data only
+  cover_photo?: Photo; // Public data only
+  photos: Photo[]; // Empty in public data for password-protected albums and in the index
+}
+
+/** An upcoming scheduled publish or expiry of an album. */
+export interface ScheduleEntry {
+  album_id: string;
+  title: string;
+  event: 'publish' | 'expire';
+  visibility?: AlbumVisibility; // Visibility after publishing
+  at: string;
+  soon?: boolean; // Expiry within the warning window
+}
+
+/** Client proofing settings of an album. */
+export interface AlbumProofing {
+  enabled: boolean;
+  selection_limit?: number; // Unlimited when unset or 0
+}
+
+/** A client's pick of photos from a proofing album. */
+export interface Selection {
+  id: string;
+  album_id: string;
+  share_link_id?: string;
+  client_name?: string;
+  favorites: string[]; // Photo IDs, in the order picked
+  photo_notes?: Record<string, string>;
+  note?: string;
+  created_at: string;
+  updated_at: string;
+  submitted_at?: string;
+  locked_at?: string;
 }
 
 export interface AlbumsData {
@@ -64,6 +150,43 @@ export interface AlbumsData {
   albums: Album[];
 }
 
+/** Client share link for an album (admin API). */
+export interface ShareLink {
+  id: string;
+  album_id: string;
+  label?: string;
+  expires_at: string;
+  allow_downloads: boolean;
+  max_views?: number; // Unlimited when unset
+  created_at: string;
+  revoked_at?: string;
+  preview?: boolean; // Preview links also open drafts
+  views: number;
+  downloads: number;
+  first_used_at?: string;
+  last_used_at?: string;
+}
+
+/** Named guest password of a password-protected album (admin API). */
+export interface AlbumPassword {
+  id: string;
+  album_id: string;
+  name: string;
+  expires_at?: string; // Never expires when unset
+  max_uses?: number; // Unlimited when unset
+  created_at: string;
+  uses: number;
+  first_used_at?: string;
+  last_used_at?: string;
+}
+
+/** Published index of public albums (/data/albums.json). */
+export interface PublicAlbumIndex {
+  last_updated: string;
+  main_album_slug?: string;
+  albums: Album[];
+}
+
 // ============================================================================
 // Site Configuration Types
 // ============================================================================
@@ -171,4 +294,118 @@ export interface SiteConfig {
 export interface StorageConfig {
   max_disk_usage_percent: number;
   max_image_size_mb: number;
+  max_image_megapixels?: number; // 0 or unset uses the server default (150)
+  max_image_dimension?: number; // 0 or unset uses the server default (30000)
+}
+
+// ============================================================================
+// Admin User Types
+// ============================================================================
+
+/** Admin user role; each role can do everything the roles after it can. */
+export type UserRole = 'owner' | 'editor' | 'uploader' | 'viewer';
+
+/** Admin user account (admin API). */
+export interface User {
+  id: string;
+  username: string;
+  role: UserRole;
+  created_at: string;
+  updated_at: string;
+  last_login_at?: string;
+  totp_enabled: boolean;
+  // Set once the user has signed in with single sign-on
+  oidc_issuer?: string;
+  oidc_subject?: string;
+}
+
+/** The signed-in user, as returned by login and the auth check. */
+export interface SessionUser {
+  id: string;
+  username: string;
+  role: UserRole;
+}
+
+/** The signed-in user's two-factor authentication (admin API). */
+export interface TwoFactorStatus {
+  enabled: boolean;
+  pending: boolean; // Enrollment started but not verified
+  recovery_codes_remaining: number;
+}
+
+/** A started two-factor enrollment, to add to an authenticator app. */
+export interface TwoFactorEnrollment {
+  secret: string;
+  provisioning_uri: string; // otpauth:// URI
+  qr_code: string; // PNG data URL of the provisioning URI
+}
+
+/** Permission of an API token. */
+export type APITokenScope = 'albums:read' | 'albums:write' | 'upload' | 'config';
+
+/** A personal access token for scripts (admin API). The token itself is only returned on creation. */
+export interface APIToken {
+  id: string;
+  user_id: string;
+  name: string;
+  prefix: string; // Start of the token, to tell tokens apart
+  scopes: APITokenScope[];
+  created_at: string;
+  expires_at?: string;
+  last_used_at?: string;
+}
+
+/** One of the signed-in user's sessions (admin API). */
+export interface AdminSession {
+  id: string;
+  user_id: string;
+  username: string;
+  role: UserRole;
+  created_at: string;
+  expires_at: string;
+  last_seen_at: string; // Accurate to the minute
+  ip_hash?: string; // Short hash of the sign-in IP
+  user_agent?: string;
+  current: boolean; // Whether this is the session making the request
+}
+
+/** Recent failed admin logins of one username or client IP (admin API). */
+export interface LoginFailure {
+  kind: 'username' | 'ip';
+  key: string; // Lowercased username, or IP address
+  failures: number;
+  first_failed_at: string;
+  last_failed_at: string;
+  last_ip?: string; // For usernames
+  locked_until?: string;
+}
+
+/** One state-changing admin request in the audit log (admin API). */
+export interface AuditEntry {
+  id: string;
+  time: string;
+  request_id?: string;
+  user_id: string;
+  username: string;
+  role: UserRole;
+  token_id?: string; // API token used, if any
+  action: string; // Method and route, e.g. "DELETE /api/admin/albums/{id}"
+  path: string;
+  album_id?: string;
+  photo_id?: string;
+  status: number;
+  before?: Record<string, unknown>; // Changed fields, hashes and secrets redacted
+  after?: Record<string, unknown>;
+}
+
+/** Filters of the audit log (admin API). */
+export interface AuditFilter {
+  actor?: string; // Username or user ID
+  action?: string; // Part of the method and route
+  album_id?: string;
+  photo_id?: string;
+  request_id?: string;
+  since?: string; // RFC 3339
+  until?: string; // RFC 3339
+  limit?: number; // Newest entries returned, at most 1000
 }
@@ -25,10 +25,13 @@ describe('admin-api utilities', () => {
 
   beforeEach(() => {
     vi.clearAllMocks();
+    // The session's CSRF token, as set by login
+    document.cookie = 'photoadmin_csrf=test-csrf';
   });
 
   afterEach(() => {
     vi.restoreAllMocks();
+    document.cookie = 'photoadmin_csrf=; max-age=0';
   });
 
   describe('Authentication', () => {
@@ -77,6 +80,7 @@ describe('admin-api utilities', () => {
 
         expect(global.fetch).toHaveBeenCalledWith(`${API_BASE_URL}/api/admin/logout`, {
           method: 'POST',
+          headers: { 'X-CSRF-Token': 'test-csrf' },
           credentials: 'include',
         });
       });
@@ -162,7 +166,7 @@ describe('admin-api utilities', () => {
 
         expect(global.fetch).toHaveBeenCalledWith(`${API_BASE_URL}/api/admin/albums`, {
           method: 'POST',
-          headers: { 'Content-Type': 'application/json' },
+          headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': 'test-csrf' },
           credentials: 'include',
           body: JSON.stringify({ title: 'New Album', visibility: 'public', order: 1 }),
         });
@@ -253,6 +257,7 @@ describe('admin-api utilities', () => {
 
         expect(global.fetch).toHaveBeenCalledWith(`${API_BASE_URL}/api/admin/albums/album-1`, {
           method: 'DELETE',
+          headers: { 'X-CSRF-Token': 'test-csrf' },
           credentials: 'include',
         });
       });
@@ -288,7 +293,7 @@ describe('admin-api utilities', () => {
 
         const result = await fetchAlbumById('album-1');
 
-        expect(global.fetch).toHaveBeenCalledWith(`${API_BASE_URL}/api/albums/album-1`);
+        expect(global.fetch).toHaveBeenCalledWith(`${API_BASE_URL}/api/admin/albums/album-1`);
         expect(result).toEqual(mockAlbum);
       });
 
@@ -348,6 +353,7 @@ describe('admin-api utilities', () => {
         const xhrMock = {
           open: vi.fn(),
           send: vi.fn(),
+          setRequestHeader: vi.fn(),
           upload: {
             addEventListener: vi.fn(),
           },
@@ -381,6 +387,7 @@ describe('admin-api utilities', () => {
 
         expect(result.uploaded).toHaveLength(1);
         expect(result.errors).toHaveLength(0);
+        expect(xhrMock.setRequestHeader).toHaveBeenCalledWith('X-CSRF-Token', 'test-csrf');
       });
 
       it('should handle empty file array', async () => {
@@ -403,6 +410,7 @@ describe('admin-api utilities', () => {
           `${API_BASE_URL}/api/admin/albums/album-1/photos/photo-1`,
           {
             method: 'DELETE',
+            headers: { 'X-CSRF-Token': 'test-csrf' },
             credentials: 'include',
           }
         );
@@ -430,7 +438,7 @@ describe('admin-api utilities', () => {
           `${API_BASE_URL}/api/admin/albums/album-1/set-cover`,
           {
             method: 'POST',
-            headers: { 'Content-Type': 'application/json' },
+            headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': 'test-csrf' },
             credentials: 'include',
             body: JSON.stringify({ photo_id: 'photo-1' }),
           }
@@ -461,7 +469,7 @@ describe('admin-api utilities', () => {
           `${API_BASE_URL}/api/admin/albums/album-1/set-password`,
           {
             method: 'POST',
-            headers: { 'Content-Type': 'application/json' },
+            headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': 'test-csrf' },
             credentials: 'include',
             body: JSON.stringify({ password: 'secret123' }),
           }
@@ -490,6 +498,7 @@ describe('admin-api utilities', () => {
           `${API_BASE_URL}/api/admin/albums/album-1/password`,
           {
             method: 'DELETE',
+            headers: { 'X-CSRF-Token': 'test-csrf' },
             credentials: 'include',
           }
         );
@@ -628,7 +637,7 @@ describe('admin-api utilities', () => {
 
         expect(global.fetch).toHaveBeenCalledWith(`${API_BASE_URL}/api/admin/config`, {
           method: 'PUT',
-          headers: { 'Content-Type': 'application/json' },
+          headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': 'test-csrf' },
           credentials: 'include',
           body: JSON.stringify(mockConfig),
         });
@@ -657,7 +666,7 @@ describe('admin-api utilities', () => {
           `${API_BASE_URL}/api/admin/config/main-portfolio-album`,
           {
             method: 'PUT',
-            headers: { 'Content-Type': 'application/json' },
+            headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': 'test-csrf' },
             credentials: 'include',
             body: JSON.stringify({ album_id: 'album-1' }),
           }
@@ -684,7 +693,7 @@ describe('admin-api utilities', () => {
 
         expect(global.fetch).toHaveBeenCalledWith(`${API_BASE_URL}/api/admin/change-password`, {
           method: 'POST',
-          headers: { 'Content-Type': 'application/json' },
+          headers: { 'Content-Type': 'application/json', 'X-CSRF-Token': 'test-csrf' },
           credentials: 'include',
           body: JSON.stringify({ old_password: 'oldpass', new_password: 'newpass' }),
         });
@@ -3,28 +3,76 @@
  * These functions interact with the Go admin server endpoints.
  */
 
-import type { Album, Photo, SiteConfig } from '../types/data-models';
+import type {
+  AdminSession,
+  Album,
+  APIToken,
+  APITokenScope,
+  AlbumPassword,
+  AlbumVisibility,
+  AuditEntry,
+  AuditFilter,
+  LoginFailure,
+  Photo,
+  ScheduleEntry,
+  Selection,
+  ShareLink,
+  SessionUser,
+  SiteConfig,
+  TwoFactorEnrollment,
+  TwoFactorStatus,
+  User,
+  UserRole,
+} from '../types/data-models';
 import { dispatchLoginEvent, dispatchLogoutEvent } from './auth-state';
 
 // Use relative URLs in production, localhost in development
 const API_BASE_URL = (import.meta.env.VITE_API_BASE_URL as string | undefined) || '';
 
+// ============================================================================
+// CSRF Protection
+// ============================================================================
+
+// Cookie holding the session's CSRF token, set by login and the auth check
+const CSRF_COOKIE = 'photoadmin_csrf';
+
+/**
+ * Headers for a state-changing admin request: the given ones plus the
+ * session's CSRF token, which the server requires on every mutation.
+ */
+function csrfHeaders(headers: Record<string, string> = {}): Record<string, string> {
+  const token = document.cookie
+    .split('; ')
+    .find((cookie) => cookie.startsWith(`${CSRF_COOKIE}=`))
+    ?.slice(CSRF_COOKIE.length + 1);
+  return token ? { ...headers, 'X-CSRF-Token': decodeURIComponent(token) } : headers;
+}
+
 // ============================================================================
 // Authentication
 // ============================================================================
 
 export interface LoginCredentials {
   username: string;
-  password: string;
+  password?: string;
+  // Second step for users with two-factor authentication
+  challenge?: string;
+  code?: string; // TOTP or recovery code
 }
 
 export interface LoginResponse {
   message: string;
+  user?: SessionUser;
+  csrf_token?: string;
+  two_factor_required?: boolean;
+  challenge?: string;
 }
 
 /**
  * Login to admin panel.
- * Sets HTTP-only cookie on success.
+ * Sets HTTP-only cookie on success. For users with two-factor authentication,
+ * the password step returns `two_factor_required` and a challenge, to send
+ * again with the username and a code.
  */
 export async function login(credentials: LoginCredentials): Promise<LoginResponse> {
   const response = await fetch(`${API_BASE_URL}/api/admin/login`, {
@@ -41,10 +89,12 @@ export async function login(credentials: LoginCredentials): Promise<LoginRespons
     throw new Error(error || 'Login failed');
   }
 
-  const result = (await response.json()) as Promise<LoginResponse>;
+  const result = (await response.json()) as LoginResponse;
 
   // Dispatch login event to notify components
-  dispatchLoginEvent();
+  if (!result.two_factor_required) {
+    dispatchLoginEvent();
+  }
 
   return result;
 }
@@ -56,6 +106,7 @@ export async function login(credentials: LoginCredentials): Promise<LoginRespons
 export async function logout(): Promise<void> {
   const response = await fetch(`${API_BASE_URL}/api/admin/logout`, {
     method: 'POST',
+    headers: csrfHeaders(),
     credentials: 'include',
   });
 
@@ -67,6 +118,33 @@ export async function logout(): Promise<void> {
   dispatchLogoutEvent();
 }
 
+/**
+ * Check whether single sign-on with an identity provider is enabled.
+ */
+export async function fetchOIDCStatus(): Promise<boolean> {
+  try {
+    const response = await fetch(`${API_BASE_URL}/api/admin/oidc`, {
+      credentials: 'include',
+    });
+    if (!response.ok) {
+      return false;
+    }
+    const data = (await response.json()) as { enabled: boolean };
+    return data.enabled;
+  } catch {
+    return false;
+  }
+}
+
+/**
+ * URL that starts a single sign-on login. The browser navigates there, and
+ * the server sends it back to the admin panel, or to the login page with an
+ * `sso_error` query parameter.
+ */
+export function oidcLoginURL(): string {
+  return `${API_BASE_URL}/api/admin/oidc/login`;
+}
+
 /**
  * Check if user is authenticated by calling the dedicated auth check endpoint.
  */
@@ -83,6 +161,389 @@ export async function checkAuth(): Promise<boolean> {
   }
 }
 
+/**
+ * Fetch the signed-in user, or null when not signed in.
+ */
+export async function fetchCurrentUser(): Promise<SessionUser | null> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/auth/check`, {
+    method: 'GET',
+    credentials: 'include',
+  });
+
+  if (!response.ok) {
+    return null;
+  }
+
+  const data = (await response.json()) as { user: SessionUser };
+  return data.user;
+}
+
+// ============================================================================
+// User Management (owners only)
+// ============================================================================
+
+export interface CreateUserRequest {
+  username: string;
+  password: string;
+  role: UserRole;
+}
+
+export interface UpdateUserRequest {
+  username: string;
+  role: UserRole;
+  password?: string; // Resets the user's password when set
+}
+
+/**
+ * Fetch all admin users.
+ */
+export async function fetchUsers(): Promise<User[]> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/users`, {
+    credentials: 'include',
+  });
+
+  if (!response.ok) {
+    throw new Error('Failed to fetch users');
+  }
+
+  const data = (await response.json()) as { users: User[] };
+  return data.users;
+}
+
+/**
+ * Create an admin user.
+ */
+export async function createUser(request: CreateUserRequest): Promise<User> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/users`, {
+    method: 'POST',
+    headers: csrfHeaders({
+      'Content-Type': 'application/json',
+    }),
+    credentials: 'include',
+    body: JSON.stringify(request),
+  });
+
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to create user');
+  }
+
+  const data = (await response.json()) as { user: User };
+  return data.user;
+}
+
+/**
+ * Change an admin user's username and role, and optionally reset their password.
+ */
+export async function updateUser(userId: string, request: UpdateUserRequest): Promise<User> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/users/${userId}`, {
+    method: 'PUT',
+    headers: csrfHeaders({
+      'Content-Type': 'application/json',
+    }),
+    credentials: 'include',
+    body: JSON.stringify(request),
+  });
+
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to update user');
+  }
+
+  const data = (await response.json()) as { user: User };
+  return data.user;
+}
+
+/**
+ * Delete an admin user.
+ */
+export async function deleteUser(userId: string): Promise<void> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/users/${userId}`, {
+    method: 'DELETE',
+    headers: csrfHeaders(),
+    credentials: 'include',
+  });
+
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to delete user');
+  }
+}
+
+/**
+ * Turn off a user's two-factor authentication (owners only).
+ */
+export async function resetUserTwoFactor(userId: string): Promise<void> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/users/${userId}/two-factor`, {
+    method: 'DELETE',
+    headers: csrfHeaders(),
+    credentials: 'include',
+  });
+
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to reset two-factor authentication');
+  }
+}
+
+/**
+ * Fetch the signed-in user's two-factor status.
+ */
+export async function fetchTwoFactorStatus(): Promise<TwoFactorStatus> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/two-factor`, {
+    credentials: 'include',
+  });
+
+  if (!response.ok) {
+    throw new Error('Failed to fetch two-factor status');
+  }
+
+  const data = (await response.json()) as { two_factor: TwoFactorStatus };
+  return data.two_factor;
+}
+
+/**
+ * Start two-factor enrollment for the signed-in user.
+ */
+export async function enrollTwoFactor(): Promise<TwoFactorEnrollment> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/two-factor/enroll`, {
+    method: 'POST',
+    headers: csrfHeaders(),
+    credentials: 'include',
+  });
+
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to start two-factor enrollment');
+  }
+
+  return (await response.json()) as TwoFactorEnrollment;
+}
+
+/**
+ * Enable two-factor authentication with a code from the authenticator app.
+ * Returns the recovery codes, which cannot be fetched again.
+ */
+export async function verifyTwoFactor(code: string): Promise<string[]> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/two-factor/verify`, {
+    method: 'POST',
+    headers: csrfHeaders({
+      'Content-Type': 'application/json',
+    }),
+    credentials: 'include',
+    body: JSON.stringify({ code }),
+  });
+
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to verify two-factor code');
+  }
+
+  const data = (await response.json()) as { recovery_codes: string[] };
+  return data.recovery_codes;
+}
+
+/**
+ * Disable two-factor authentication for the signed-in user.
+ */
+export async function disableTwoFactor(password: string): Promise<void> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/two-factor/disable`, {
+    method: 'POST',
+    headers: csrfHeaders({
+      'Content-Type': 'application/json',
+    }),
+    credentials: 'include',
+    body: JSON.stringify({ password }),
+  });
+
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to disable two-factor authentication');
+  }
+}
+
+/**
+ * Fetch the signed-in user's API tokens, newest first.
+ */
+export async function fetchAPITokens(): Promise<APIToken[]> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/tokens`, {
+    credentials: 'include',
+  });
+
+  if (!response.ok) {
+    throw new Error('Failed to fetch API tokens');
+  }
+
+  const data = (await response.json()) as { api_tokens: APIToken[] };
+  return data.api_tokens;
+}
+
+export interface CreateAPITokenRequest {
+  name: string;
+  scopes: APITokenScope[];
+  expires_at?: string;
+}
+
+/**
+ * Create an API token. The returned token is not shown again.
+ */
+export async function createAPIToken(
+  request: CreateAPITokenRequest
+): Promise<{ api_token: APIToken; token: string }> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/tokens`, {
+    method: 'POST',
+    headers: csrfHeaders({
+      'Content-Type': 'application/json',
+    }),
+    credentials: 'include',
+    body: JSON.stringify(request),
+  });
+
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to create API token');
+  }
+
+  return (await response.json()) as { api_token: APIToken; token: string };
+}
+
+/**
+ * Revoke one of the signed-in user's API tokens.
+ */
+export async function revokeAPIToken(tokenId: string): Promise<void> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/tokens/${tokenId}`, {
+    method: 'DELETE',
+    headers: csrfHeaders(),
+    credentials: 'include',
+  });
+
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to revoke API token');
+  }
+}
+
+/**
+ * Fetch the signed-in user's sessions, most recently seen first.
+ */
+export async function fetchSessions(): Promise<AdminSession[]> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/sessions`, {
+    credentials: 'include',
+  });
+
+  if (!response.ok) {
+    throw new Error('Failed to fetch sessions');
+  }
+
+  const data = (await response.json()) as { sessions: AdminSession[] };
+  return data.sessions;
+}
+
+/**
+ * Sign out one of the signed-in user's sessions.
+ */
+export async function revokeSession(sessionId: string): Promise<void> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/sessions/${sessionId}`, {
+    method: 'DELETE',
+    headers: csrfHeaders(),
+    credentials: 'include',
+  });
+
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to revoke session');
+  }
+}
+
+/**
+ * Sign out every session of the signed-in user but the current one.
+ */
+export async function revokeOtherSessions(): Promise<void> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/sessions/revoke-others`, {
+    method: 'POST',
+    headers: csrfHeaders(),
+    credentials: 'include',
+  });
+
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to revoke other sessions');
+  }
+}
+
+/**
+ * Fetch the usernames and IPs with recent failed logins, locked usernames first.
+ */
+export async function fetchLoginFailures(): Promise<LoginFailure[]> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/login-failures`, {
+    credentials: 'include',
+  });
+
+  if (!response.ok) {
+    throw new Error('Failed to fetch login failures');
+  }
+
+  const data = (await response.json()) as { login_failures: LoginFailure[] };
+  return data.login_failures;
+}
+
+/**
+ * Forget the failed logins of a username or IP, unlocking it.
+ */
+export async function clearLoginFailures(kind: LoginFailure['kind'], key: string): Promise<void> {
+  const response = await fetch(
+    `${API_BASE_URL}/api/admin/login-failures/${kind}/${encodeURIComponent(key)}`,
+    {
+      method: 'DELETE',
+      headers: csrfHeaders(),
+      credentials: 'include',
+    }
+  );
+
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to clear login failures');
+  }
+}
+
+function auditQuery(filter: AuditFilter): URLSearchParams {
+  const params = new URLSearchParams();
+  for (const [name, value] of Object.entries(filter)) {
+    if (value !== undefined && value !== '') {
+      params.set(name, String(value));
+    }
+  }
+  return params;
+}
+
+/**
+ * Fetch the audit log entries that match a filter, newest first.
+ */
+export async function fetchAuditLog(filter: AuditFilter = {}): Promise<AuditEntry[]> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/audit?${auditQuery(filter).toString()}`, {
+    credentials: 'include',
+  });
+
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to fetch audit log');
+  }
+
+  const data = (await response.json()) as { entries: AuditEntry[] };
+  return data.entries;
+}
+
+/**
+ * URL that downloads every audit log entry matching a filter as JSON Lines,
+ * oldest first.
+ */
+export function auditExportURL(filter: AuditFilter = {}): string {
+  const params = auditQuery(filter);
+  params.delete('limit');
+  params.set('format', 'jsonl');
+  return `${API_BASE_URL}/api/admin/audit?${params.toString()}`;
+}
+
 // ============================================================================
 // Album Management
 // ============================================================================
@@ -91,7 +552,7 @@ export interface CreateAlbumRequest {
   title: string;
   subtitle?: string;
   description?: string;
-  visibility: 'public' | 'unlisted' | 'password_protected';
+  visibility: AlbumVisibility;
   allow_downloads?: boolean;
   order?: number;
 }
@@ -104,9 +565,9 @@ export type UpdateAlbumRequest = Partial<Album>;
 export async function createAlbum(albumData: CreateAlbumRequest): Promise<Album> {
   const response = await fetch(`${API_BASE_URL}/api/admin/albums`, {
     method: 'POST',
-    headers: {
+    headers: csrfHeaders({
       'Content-Type': 'application/json',
-    },
+    }),
     credentials: 'include',
     body: JSON.stringify(albumData),
   });
@@ -132,9 +593,9 @@ export async function updateAlbum(albumId: string, updates: UpdateAlbumRequest):
 
   const response = await fetch(`${API_BASE_URL}/api/admin/albums/${albumId}`, {
     method: 'PUT',
-    headers: {
+    headers: csrfHeaders({
       'Content-Type': 'application/json',
-    },
+    }),
     credentials: 'include',
     body: JSON.stringify(updatedAlbum),
   });
@@ -153,6 +614,7 @@ export async function updateAlbum(albumId: string, updates: UpdateAlbumRequest):
 export async function deleteAlbum(albumId: string): Promise<void> {
   const response = await fetch(`${API_BASE_URL}/api/admin/albums/${albumId}`, {
     method: 'DELETE',
+    headers: csrfHeaders(),
     credentials: 'include',
   });
 
@@ -163,10 +625,10 @@ export async function deleteAlbum(albumId: string): Promise<void> {
 }
 
 /**
- * Fetch album by ID (from public API).
+ * Fetch album by ID.
  */
 export async function fetchAlbumById(albumId: string): Promise<Album> {
-  const response = await fetch(`${API_BASE_URL}/api/albums/${albumId}`);
+  const response = await fetch(`${API_BASE_URL}/api/admin/albums/${albumId}`);
 
   if (!response.ok) {
     throw new Error('Album not found');
@@ -336,6 +798,9 @@ function uploadSinglePhoto(
     // Open and send request
     xhr.open('POST', `${API_BASE_URL}/api/admin/albums/${albumId}/photos/upload`);
     xhr.withCredentials = true; // Include cookies for authentication
+    for (const [name, value] of Object.entries(csrfHeaders())) {
+      xhr.setRequestHeader(name, value);
+    }
     xhr.send(formData);
   });
 }
@@ -403,6 +868,7 @@ export async function uploadPhotos(
 export async function deletePhoto(albumId: string, photoId: string): Promise<void> {
   const response = await fetch(`${API_BASE_URL}/api/admin/albums/${albumId}/photos/${photoId}`, {
     method: 'DELETE',
+    headers: csrfHeaders(),
     credentials: 'include',
   });
 
@@ -420,6 +886,7 @@ export async function deleteAllPhotos(
 ): Promise<{ deleted: number; total: number; errors?: string[] }> {
   const response = await fetch(`${API_BASE_URL}/api/admin/albums/${albumId}/photos`, {
     method: 'DELETE',
+    headers: csrfHeaders(),
     credentials: 'include',
   });
 
@@ -437,9 +904,9 @@ export async function deleteAllPhotos(
 export async function setCoverPhoto(albumId: string, photoId: string): Promise<void> {
   const response = await fetch(`${API_BASE_URL}/api/admin/albums/${albumId}/set-cover`, {
     method: 'POST',
-    headers: {
+    headers: csrfHeaders({
       'Content-Type': 'application/json',
-    },
+    }),
     credentials: 'include',
     body: JSON.stringify({ photo_id: photoId }),
   });
@@ -456,9 +923,9 @@ export async function setCoverPhoto(albumId: string, photoId: string): Promise<v
 export async function reorderPhotos(albumId: string, photoIds: string[]): Promise<void> {
   const response = await fetch(`${API_BASE_URL}/api/admin/albums/${albumId}/reorder-photos`, {
     method: 'POST',
-    headers: {
+    headers: csrfHeaders({
       'Content-Type': 'application/json',
-    },
+    }),
     credentials: 'include',
     body: JSON.stringify({ photo_ids: photoIds }),
   });
@@ -475,9 +942,9 @@ export async function reorderPhotos(albumId: string, photoIds: string[]): Promis
 export async function setAlbumPassword(albumId: string, password: string): Promise<void> {
   const response = await fetch(`${API_BASE_URL}/api/admin/albums/${albumId}/set-password`, {
     method: 'POST',
-    headers: {
+    headers: csrfHeaders({
       'Content-Type': 'application/json',
-    },
+    }),
     credentials: 'include',
     body: JSON.stringify({ password }),
   });
@@ -494,6 +961,7 @@ export async function setAlbumPassword(albumId: string, password: string): Promi
 export async function removeAlbumPassword(albumId: string): Promise<void> {
   const response = await fetch(`${API_BASE_URL}/api/admin/albums/${albumId}/password`, {
     method: 'DELETE',
+    headers: csrfHeaders(),
     credentials: 'include',
   });
 
@@ -503,6 +971,229 @@ export async function removeAlbumPassword(albumId: string): Promise<void> {
   }
 }
 
+// ============================================================================
+// Named Album Passwords
+// ============================================================================
+
+export interface CreateAlbumPasswordRequest {
+  name: string;
+  password: string;
+  expires_at?: string;
+  max_uses?: number;
+}
+
+/**
+ * Fetch an album's named passwords with their access logs.
+ */
+export async function fetchAlbumPasswords(albumId: string): Promise<AlbumPassword[]> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/albums/${albumId}/passwords`, {
+    credentials: 'include',
+  });
+
+  if (!response.ok) {
+    throw new Error('Failed to fetch album passwords');
+  }
+
+  const data = (await response.json()) as { passwords: AlbumPassword[] };
+  return data.passwords;
+}
+
+/**
+ * Add a named password to an album, alongside its main password.
+ */
+export async function createAlbumPassword(
+  albumId: string,
+  request: CreateAlbumPasswordRequest
+): Promise<AlbumPassword> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/albums/${albumId}/passwords`, {
+    method: 'POST',
+    headers: csrfHeaders({
+      'Content-Type': 'application/json',
+    }),
+    credentials: 'include',
+    body: JSON.stringify(request),
+  });
+
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to create album password');
+  }
+
+  const data = (await response.json()) as { password: AlbumPassword };
+  return data.password;
+}
+
+/**
+ * Delete a named album password.
+ */
+export async function deleteAlbumPassword(albumId: string, passwordId: string): Promise<void> {
+  const response = await fetch(
+    `${API_BASE_URL}/api/admin/albums/${albumId}/passwords/${passwordId}`,
+    {
+      method: 'DELETE',
+      headers: csrfHeaders(),
+      credentials: 'include',
+    }
+  );
+
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to delete album password');
+  }
+}
+
+// ============================================================================
+// Share Links
+// ============================================================================
+
+export interface CreateShareLinkRequest {
+  label?: string;
+  expires_at: string;
+  allow_downloads?: boolean;
+  max_views?: number;
+}
+
+export interface CreateShareLinkResponse {
+  share_link: ShareLink;
+  token: string; // Only returned once
+  url: string;
+}
+
+/**
+ * Fetch an album's share links with their access logs.
+ */
+export async function fetchShareLinks(albumId: string): Promise<ShareLink[]> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/albums/${albumId}/shares`, {
+    credentials: 'include',
+  });
+
+  if (!response.ok) {
+    throw new Error('Failed to fetch share links');
+  }
+
+  const data = (await response.json()) as { share_links: ShareLink[] };
+  return data.share_links;
+}
+
+/**
+ * Create a share link for an album.
+ */
+export async function createShareLink(
+  albumId: string,
+  request: CreateShareLinkRequest
+): Promise<CreateShareLinkResponse> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/albums/${albumId}/shares`, {
+    method: 'POST',
+    headers: csrfHeaders({
+      'Content-Type': 'application/json',
+    }),
+    credentials: 'include',
+    body: JSON.stringify(request),
+  });
+
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to create share link');
+  }
+
+  return response.json() as Promise<CreateShareLinkResponse>;
+}
+
+/**
+ * Create a preview link for a draft album, lasting a week unless expires_at is given.
+ */
+export async function createPreviewLink(
+  albumId: string,
+  request: { label?: string; expires_at?: string } = {}
+): Promise<CreateShareLinkResponse> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/albums/${albumId}/previews`, {
+    method: 'POST',
+    headers: csrfHeaders({
+      'Content-Type': 'application/json',
+    }),
+    credentials: 'include',
+    body: JSON.stringify(request),
+  });
+
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to create preview link');
+  }
+
+  return response.json() as Promise<CreateShareLinkResponse>;
+}
+
+/**
+ * Revoke a share link.
+ */
+export async function revokeShareLink(albumId: string, shareId: string): Promise<void> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/albums/${albumId}/shares/${shareId}`, {
+    method: 'DELETE',
+    headers: csrfHeaders(),
+    credentials: 'include',
+  });
+
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to revoke share link');
+  }
+}
+
+// ============================================================================
+// Client Proofing
+// ============================================================================
+
+/**
+ * Fetch the client selections of a proofing album.
+ */
+export async function fetchSelections(albumId: string): Promise<Selection[]> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/albums/${albumId}/selections`, {
+    credentials: 'include',
+  });
+
+  if (!response.ok) {
+    throw new Error('Failed to fetch selections');
+  }
+
+  const data = (await response.json()) as { selections: Selection[] };
+  return data.selections;
+}
+
+/**
+ * URL to download a selection as CSV or as a filename list for Lightroom.
+ */
+export function selectionExportUrl(
+  albumId: string,
+  selectionId: string,
+  format: 'csv' | 'lightroom'
+): string {
+  return `${API_BASE_URL}/api/admin/albums/${albumId}/selections/${selectionId}/export?format=${format}`;
+}
+
+/**
+ * Lock or unlock a selection against changes by the client.
+ */
+export async function setSelectionLocked(
+  albumId: string,
+  selectionId: string,
+  locked: boolean
+): Promise<Selection> {
+  const response = await fetch(
+    `${API_BASE_URL}/api/admin/albums/${albumId}/selections/${selectionId}/lock`,
+    {
+      method: locked ? 'POST' : 'DELETE',
+      credentials: 'include',
+    }
+  );
+
+  if (!response.ok) {
+    const error = await response.text();
+    throw new Error(error || 'Failed to update selection');
+  }
+
+  return response.json() as Promise<Selection>;
+}
+
 // ============================================================================
 // Site Configuration
 // ============================================================================
@@ -531,9 +1222,9 @@ export async function fetchAdminSiteConfig(): Promise<SiteConfig> {
 export async function updateSiteConfig(config: Partial<SiteConfig>): Promise<SiteConfig> {
   const response = await fetch(`${API_BASE_URL}/api/admin/config`, {
     method: 'PUT',
-    headers: {
+    headers: csrfHeaders({
       'Content-Type': 'application/json',
-    },
+    }),
     credentials: 'include',
     body: JSON.stringify(config),
   });
@@ -552,9 +1243,9 @@ export async function updateSiteConfig(config: Partial<SiteConfig>): Promise<Sit
 export async function setMainPortfolioAlbum(albumId: string): Promise<void> {
   const response = await fetch(`${API_BASE_URL}/api/admin/config/main-portfolio-album`, {
     method: 'PUT',
-    headers: {
+    headers: csrfHeaders({
       'Content-Type': 'application/json',
-    },
+    }),
     credentials: 'include',
     body: JSON.stringify({ album_id: albumId }),
   });
@@ -566,14 +1257,14 @@ export async function setMainPortfolioAlbum(albumId: string): Promise<void> {
 }
 
 /**
- * Change admin password.
+ * Change the signed-in user's password.
  */
 export async function changePassword(oldPassword: string, newPassword: string): Promise<void> {
   const response = await fetch(`${API_BASE_URL}/api/admin/change-password`, {
     method: 'POST',
-    headers: {
+    headers: csrfHeaders({
       'Content-Type': 'application/json',
-    },
+    }),
     credentials: 'include',
     body: JSON.stringify({
       old_password: oldPassword,
@@ -586,3 +1277,19 @@ export async function changePassword(oldPassword: string, newPassword: string):
     throw new Error(error || 'Failed to change password');
   }
 }
+
+/**
+ * Fetch the upcoming scheduled publishes and expiries of all albums, soonest first.
+ */
+export async function fetchSchedule(): Promise<ScheduleEntry[]> {
+  const response = await fetch(`${API_BASE_URL}/api/admin/schedule`, {
+    credentials: 'include',
+  });
+
+  if (!response.ok) {
+    throw new Error('Failed to fetch schedule');
+  }
+
+  const data = (await response.json()) as { schedule: ScheduleEntry[] };
+  return data.schedule;
+}
@@ -1,12 +1,14 @@
 import { afterEach, beforeEach, describe, expect, it, vi } from 'vitest';
 import type { Album, SiteConfig } from '../types/data-models';
 import {
+  albumDownloadUrl,
   fetchAlbumBySlug,
   fetchAlbumsData,
   fetchMainPortfolioAlbum,
   fetchPublicAlbums,
   fetchSiteConfig,
   hasAlbumAccess,
+  redeemShareLink,
   storeAlbumToken,
   verifyAlbumPassword,
 } from './api';
@@ -133,6 +135,33 @@ describe('api utilities', () => {
 
       await expect(fetchAlbumsData()).rejects.toThrow('Failed to fetch albums');
     });
+
+    it('should drop albums that expired since the data was published', async () => {
+      const album = {
+        slug: 'client',
+        title: 'Client',
+        visibility: 'unlisted' as const,
+        photos: [],
+        allow_downloads: false,
+        order: 1,
+        created_at: '2025-10-19T00:00:00Z',
+        updated_at: '2025-10-19T00:00:00Z',
+      };
+      global.fetch = vi.fn().mockResolvedValue({
+        ok: true,
+        json: () =>
+          Promise.resolve({
+            albums: [
+              { ...album, id: 'expired', expiration_date: '2000-01-01T00:00:00Z' },
+              { ...album, id: 'current', expiration_date: '2999-01-01T00:00:00Z' },
+            ],
+          }),
+      } as Response);
+
+      const result = await fetchAlbumsData();
+
+      expect(result.albums.map((a) => a.id)).toEqual(['current']);
+    });
   });
 
   describe('fetchAlbumBySlug', () => {
@@ -149,74 +178,159 @@ describe('api utilities', () => {
         updated_at: '2025-10-19T00:00:00Z',
       };
 
-      const mockAlbums = { albums: [mockAlbum] };
-
       global.fetch = vi.fn().mockResolvedValue({
         ok: true,
-        json: () => Promise.resolve(mockAlbums),
+        status: 200,
+        json: () => Promise.resolve(mockAlbum),
       } as Response);
 
       const result = await fetchAlbumBySlug('test-album');
 
+      expect(global.fetch).toHaveBeenCalledWith('/data/albums/test-album.json');
       expect(result).toEqual(mockAlbum);
     });
 
     it('should return null for non-existent slug', async () => {
       global.fetch = vi.fn().mockResolvedValue({
-        ok: true,
-        json: () => Promise.resolve({ albums: [] }),
+        ok: false,
+        status: 404,
+        statusText: 'Not Found',
       } as Response);
 
       const result = await fetchAlbumBySlug('nonexistent');
 
       expect(result).toBeNull();
     });
+
+    it('should fetch drafts opened with a preview link from the API', async () => {
+      const draft: Album = {
+        id: 'album-1',
+        slug: 'upcoming',
+        title: 'Upcoming',
+        visibility: 'draft',
+        photos: [],
+        allow_downloads: false,
+        order: 1,
+        created_at: '2025-10-19T00:00:00Z',
+        updated_at: '2025-10-19T00:00:00Z',
+      };
+      global.fetch = vi
+        .fn()
+        .mockResolvedValueOnce({ ok: false, status: 404, statusText: 'Not Found' } as Response)
+        .mockResolvedValueOnce({
+          ok: true,
+          status: 200,
+          json: () => Promise.resolve(draft),
+        } as Response);
+
+      const result = await fetchAlbumBySlug('upcoming');
+
+      expect(global.fetch).toHaveBeenLastCalledWith('/api/albums/upcoming', {
+        credentials: 'same-origin',
+      });
+      expect(result).toEqual(draft);
+    });
+
+    it('should return null for an expired album', async () => {
+      const mockAlbum: Album = {
+        id: 'album-1',
+        slug: 'client',
+        title: 'Client',
+        visibility: 'unlisted',
+        expiration_date: '2000-01-01T00:00:00Z',
+        photos: [],
+        allow_downloads: false,
+        order: 1,
+        created_at: '2025-10-19T00:00:00Z',
+        updated_at: '2025-10-19T00:00:00Z',
+      };
+      global.fetch = vi.fn().mockResolvedValue({
+        ok: true,
+        status: 200,
+        json: () => Promise.resolve(mockAlbum),
+      } as Response);
+
+      const result = await fetchAlbumBySlug('client');
+
+      expect(result).toBeNull();
+    });
+
+    describe('password-protected albums', () => {
+      const publishedAlbum: Album = {
+        id: 'album-2',
+        slug: 'private',
+        title: 'Private',
+        visibility: 'password_protected',
+        photos: [],
+        allow_downloads: false,
+        order: 1,
+        created_at: '2025-10-19T00:00:00Z',
+        updated_at: '2025-10-19T00:00:00Z',
+      };
+
+      it('should return the published album without a token', async () => {
+        global.fetch = vi.fn().mockResolvedValue({
+          ok: true,
+          status: 200,
+          json: () => Promise.resolve(publishedAlbum),
+        } as Response);
+
+        const result = await fetchAlbumBySlug('private');
+
+        expect(global.fetch).toHaveBeenCalledTimes(1);
+        expect(result).toEqual(publishedAlbum);
+      });
+
+      it('should fetch the full album with a stored token', async () => {
+        storeAlbumToken('album-2', 'access-token');
+        const unlockedAlbum = { ...publishedAlbum, photos: [{ id: 'photo-1' }] };
+
+        global.fetch = vi
+          .fn()
+          .mockResolvedValueOnce({
+            ok: true,
+            status: 200,
+            json: () => Promise.resolve(publishedAlbum),
+          } as Response)
+          .mockResolvedValueOnce({
+            ok: true,
+            status: 200,
+            json: () => Promise.resolve(unlockedAlbum),
+          } as Response);
+
+        const result = await fetchAlbumBySlug('private');
+
+        expect(global.fetch).toHaveBeenLastCalledWith('/api/albums/private', {
+          headers: { Authorization: 'Bearer access-token' },
+        });
+        expect(result).toEqual(unlockedAlbum);
+      });
+
+      it('should forget a token that is no longer accepted', async () => {
+        storeAlbumToken('album-2', 'expired-token');
+
+        global.fetch = vi
+          .fn()
+          .mockResolvedValueOnce({
+            ok: true,
+            status: 200,
+            json: () => Promise.resolve(publishedAlbum),
+          } as Response)
+          .mockResolvedValueOnce({
+            ok: false,
+            status: 401,
+          } as Response);
+
+        const result = await fetchAlbumBySlug('private');
+
+        expect(result).toEqual(publishedAlbum);
+        expect(hasAlbumAccess('album-2')).toBe(false);
+      });
+    });
   });
 
   describe('fetchMainPortfolioAlbum', () => {
     it('should fetch main portfolio album', async () => {
-      const mockConfig: SiteConfig = {
-        version: '1.0.0',
-        last_updated: '2025-10-19T00:00:00Z',
-        site: { title: 'Test', language: 'en', timezone: 'UTC' },
-        owner: {},
-        social: {},
-        branding: {
-          primary_color: '#000',
-          secondary_color: '#666',
-          accent_color: '#f00',
-          theme: {
-            mode: 'system',
-            light: {
-              background: '#fff',
-              surface: '#f5f5f5',
-              text_primary: '#000',
-              text_secondary: '#666',
-              border: '#e0e0e0',
-            },
-            dark: {
-              background: '#0a0a0a',
-              surface: '#1a1a1a',
-              text_primary: '#fff',
-              text_secondary: '#999',
-              border: '#333',
-            },
-          },
-        },
-        portfolio: { main_album_id: 'main-album', show_exif_data: true, enable_lightbox: true },
-        navigation: {
-          show_home: true,
-          show_albums: true,
-          show_about: true,
-          show_contact: true,
-        },
-        features: { enable_analytics: false },
-        storage: {
-          max_disk_usage_percent: 80,
-          max_image_size_mb: 50,
-        },
-      };
-
       const mockAlbum: Album = {
         id: 'main-album',
         slug: 'portfolio',
@@ -233,20 +347,37 @@ describe('api utilities', () => {
         .fn()
         .mockResolvedValueOnce({
           ok: true,
-          json: () => Promise.resolve(mockConfig),
+          json: () =>
+            Promise.resolve({
+              last_updated: '2025-10-19T00:00:00Z',
+              main_album_slug: 'portfolio',
+              albums: [],
+            }),
         } as Response)
         .mockResolvedValueOnce({
           ok: true,
-          json: () => Promise.resolve({ albums: [mockAlbum] }),
+          status: 200,
+          json: () => Promise.resolve(mockAlbum),
         } as Response);
 
       const result = await fetchMainPortfolioAlbum();
 
+      expect(global.fetch).toHaveBeenLastCalledWith('/data/albums/portfolio.json');
       expect(result).toEqual(mockAlbum);
     });
 
+    it('should return null when nothing is published', async () => {
+      global.fetch = vi.fn().mockResolvedValue({
+        ok: true,
+        json: () => Promise.resolve({ last_updated: '2025-10-19T00:00:00Z', albums: [] }),
+      } as Response);
+
+      const result = await fetchMainPortfolioAlbum();
+
+      expect(result).toBeNull();
+    });
+
     it('should throw error when fetch fails', async () => {
-      // Mock only config fetch - albums fetch will fail
       global.fetch = vi.fn().mockRejectedValue(new Error('Failed to fetch albums'));
 
       await expect(fetchMainPortfolioAlbum()).rejects.toThrow('Failed to fetch albums');
@@ -322,6 +453,18 @@ describe('api utilities', () => {
     });
   });
 
+  describe('albumDownloadUrl', () => {
+    it('should default to the original tier', () => {
+      expect(albumDownloadUrl('summer trip')).toBe(
+        '/api/albums/summer%20trip/download?tier=original'
+      );
+    });
+
+    it('should use the given tier', () => {
+      expect(albumDownloadUrl('wedding', 'large')).toBe('/api/albums/wedding/download?tier=large');
+    });
+  });
+
   describe('verifyAlbumPassword', () => {
     it('should verify password successfully', async () => {
       const mockData = {
@@ -335,10 +478,10 @@ describe('api utilities', () => {
 
       const result = await verifyAlbumPassword('test-album', 'correct-password');
 
-      expect(global.fetch).toHaveBeenCalledWith('/api/albums/verify-password', {
+      expect(global.fetch).toHaveBeenCalledWith('/api/albums/test-album/access', {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ album_id: 'test-album', password: 'correct-password' }),
+        body: JSON.stringify({ password: 'correct-password' }),
       });
       expect(result).toEqual({ success: true, token: 'test-token' });
     });
@@ -360,10 +503,52 @@ describe('api utilities', () => {
       expect(result.error).toBeDefined();
     });
 
+    it('should report rate limiting', async () => {
+      global.fetch = vi.fn().mockResolvedValue({
+        ok: false,
+        status: 429,
+      } as Response);
+
+      const result = await verifyAlbumPassword('album-id', 'wrong');
+
+      expect(result.success).toBe(false);
+      expect(result.error).toContain('Too many attempts');
+    });
+
     it('should handle network error', async () => {
       global.fetch = vi.fn().mockRejectedValue(new Error('Network error'));
 
       await expect(verifyAlbumPassword('album-id', 'password')).rejects.toThrow('Network error');
     });
   });
+
+  describe('redeemShareLink', () => {
+    it('should redeem a share link', async () => {
+      global.fetch = vi.fn().mockResolvedValue({
+        ok: true,
+        json: () => Promise.resolve({ token: 'test-token', album_id: 'album-1' }),
+      } as Response);
+
+      const result = await redeemShareLink('test-album', 'share-token');
+
+      expect(global.fetch).toHaveBeenCalledWith('/api/albums/test-album/access', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ share_token: 'share-token' }),
+      });
+      expect(result).toEqual({ success: true, albumId: 'album-1', token: 'test-token' });
+    });
+
+    it('should report invalid or expired links', async () => {
+      global.fetch = vi.fn().mockResolvedValue({
+        ok: false,
+        status: 404,
+      } as Response);
+
+      const result = await redeemShareLink('test-album', 'expired');
+
+      expect(result.success).toBe(false);
+      expect(result.error).toContain('expired');
+    });
+  });
 });
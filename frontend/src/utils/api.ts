@@ -2,7 +2,7 @@
  * API utility for fetching JSON data from the static data files.
  */
 
-import type { Album, AlbumsData, SiteConfig } from '../types/data-models';
+import type { Album, PublicAlbumIndex, Selection, SiteConfig } from '../types/data-models';
 
 /**
  * Fetch site configuration.
@@ -17,24 +17,81 @@ export async function fetchSiteConfig(): Promise<SiteConfig> {
 }
 
 /**
- * Fetch all albums data.
+ * Whether an album's expiration date has passed. The published data is only
+ * rebuilt when the backend scheduler runs, so expired albums are also dropped here.
  */
-export async function fetchAlbumsData(): Promise<AlbumsData> {
-  console.debug('Fetching all albums data');
+export function isAlbumExpired(album: Pick<Album, 'expiration_date'>, now = new Date()): boolean {
+  return Boolean(album.expiration_date && new Date(album.expiration_date) <= now);
+}
+
+/**
+ * Fetch the public album index (public albums, without their photos).
+ */
+export async function fetchAlbumsData(): Promise<PublicAlbumIndex> {
+  console.debug('Fetching public album index');
   const response = await fetch('/data/albums.json');
   if (!response.ok) {
     throw new Error(`Failed to fetch albums: ${response.statusText}`);
   }
-  return response.json() as Promise<AlbumsData>;
+  const index = (await response.json()) as PublicAlbumIndex;
+  return { ...index, albums: index.albums.filter((album) => !isAlbumExpired(album)) };
 }
 
 /**
  * Fetch a single album by slug.
+ * Password-protected albums are published without their photos; with a stored
+ * access token (from a password or a share link) the full album is fetched
+ * from the API instead. Drafts are never published, and are only found through
+ * the API with the access cookie of a preview link.
  */
 export async function fetchAlbumBySlug(slug: string): Promise<Album | null> {
   console.debug(`Fetching album by slug: ${slug}`);
-  const data = await fetchAlbumsData();
-  return data.albums.find((album) => album.slug === slug) || null;
+  const response = await fetch(`/data/albums/${encodeURIComponent(slug)}.json`);
+  if (response.status === 404) {
+    return fetchPreviewAlbum(slug);
+  }
+  if (!response.ok) {
+    throw new Error(`Failed to fetch album: ${response.statusText}`);
+  }
+  const album = (await response.json()) as Album;
+  if (isAlbumExpired(album)) {
+    return null;
+  }
+
+  if (hasAlbumAccess(album.id)) {
+    return (await fetchUnlockedAlbum(album)) || album;
+  }
+  return album;
+}
+
+/**
+ * Fetch an album with its stored access token.
+ * Returns null, and forgets the token, if the token is no longer accepted.
+ */
+async function fetchUnlockedAlbum(album: Album): Promise<Album | null> {
+  const token = sessionStorage.getItem(`album_token_${album.id}`);
+  const response = await fetch(`/api/albums/${encodeURIComponent(album.slug)}`, {
+    headers: { Authorization: `Bearer ${token}` },
+  });
+  if (!response.ok) {
+    clearAlbumToken(album.id);
+    return null;
+  }
+  return response.json() as Promise<Album>;
+}
+
+/**
+ * Fetch a draft album opened with a preview link, or null.
+ */
+async function fetchPreviewAlbum(slug: string): Promise<Album | null> {
+  const response = await fetch(`/api/albums/${encodeURIComponent(slug)}`, {
+    credentials: 'same-origin',
+  });
+  if (!response.ok) {
+    return null;
+  }
+  const album = (await response.json()) as Album;
+  return album.visibility === 'draft' ? album : null;
 }
 
 /**
@@ -42,15 +99,15 @@ export async function fetchAlbumBySlug(slug: string): Promise<Album | null> {
  */
 export async function fetchMainPortfolioAlbum(): Promise<Album | null> {
   console.debug('Fetching main portfolio album');
-  const [siteConfig, albumsData] = await Promise.all([fetchSiteConfig(), fetchAlbumsData()]);
+  const albumsData = await fetchAlbumsData();
 
-  const mainAlbumId = siteConfig.portfolio.main_album_id;
-  if (!mainAlbumId) {
-    // Return first public album as fallback.
-    return albumsData.albums.find((album) => album.visibility === 'public') || null;
+  // Fall back to the first public album.
+  const slug = albumsData.main_album_slug || albumsData.albums[0]?.slug;
+  if (!slug) {
+    return null;
   }
 
-  return albumsData.albums.find((album) => album.id === mainAlbumId) || null;
+  return fetchAlbumBySlug(slug);
 }
 
 /**
@@ -66,29 +123,71 @@ export async function fetchPublicAlbums(): Promise<Album[]> {
 
 /**
  * Verify password for a password-protected album.
- * Returns session token on success.
+ * Returns an access token for the album on success.
  */
 export async function verifyAlbumPassword(
-  albumId: string,
+  slug: string,
   password: string
 ): Promise<{ success: boolean; token?: string; error?: string }> {
-  console.debug(`Verifying password for album ID: ${albumId}`);
-  const response = await fetch('/api/albums/verify-password', {
+  console.debug(`Verifying password for album: ${slug}`);
+  const response = await fetch(`/api/albums/${encodeURIComponent(slug)}/access`, {
     method: 'POST',
     headers: {
       'Content-Type': 'application/json',
     },
-    body: JSON.stringify({ album_id: albumId, password }),
+    body: JSON.stringify({ password }),
   });
 
+  if (response.status === 429) {
+    return { success: false, error: 'Too many attempts. Please try again later.' };
+  }
   if (!response.ok) {
     return { success: false, error: 'Invalid password' };
   }
 
-  const data = (await response.json()) as { token: string };
+  const data = (await response.json()) as { token: string; expires_at: string };
   return { success: true, token: data.token };
 }
 
+/**
+ * Redeem a share link for an album.
+ * Returns the album's ID and an access token for it on success.
+ */
+export async function redeemShareLink(
+  slug: string,
+  shareToken: string
+): Promise<{ success: boolean; albumId?: string; token?: string; error?: string }> {
+  console.debug(`Redeeming share link for album: ${slug}`);
+  const response = await fetch(`/api/albums/${encodeURIComponent(slug)}/access`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ share_token: shareToken }),
+  });
+
+  if (response.status === 429) {
+    return { success: false, error: 'Too many attempts. Please try again later.' };
+  }
+  if (!response.ok) {
+    return { success: false, error: 'This link is invalid or has expired.' };
+  }
+
+  const data = (await response.json()) as { token: string; album_id: string };
+  return { success: true, albumId: data.album_id, token: data.token };
+}
+
+/** Download tiers of album ZIP archives. */
+export type DownloadTier = 'original' | 'large';
+
+/**
+ * URL of an album's ZIP download. The album access cookie set when unlocking
+ * the album authorizes it.
+ */
+export function albumDownloadUrl(slug: string, tier: DownloadTier = 'original'): string {
+  return `/api/albums/${encodeURIComponent(slug)}/download?tier=${tier}`;
+}
+
 /**
  * Check if user has access to a password-protected album.
  */
@@ -113,3 +212,80 @@ export function clearAlbumToken(albumId: string): void {
   console.debug(`Clearing album token for album ID: ${albumId}`);
   sessionStorage.removeItem(`album_token_${albumId}`);
 }
+
+/**
+ * Open the visitor's selection on a proofing album: the share link's
+ * selection, or the one this browser started, or a new one.
+ */
+export async function startSelection(album: Album): Promise<Selection> {
+  const storedId = localStorage.getItem(`album_selection_${album.id}`);
+  if (storedId) {
+    const response = await fetch(
+      `/api/albums/${encodeURIComponent(album.slug)}/selections/${encodeURIComponent(storedId)}`,
+      { headers: albumAuthHeaders(album.id) }
+    );
+    if (response.ok) {
+      return response.json() as Promise<Selection>;
+    }
+    localStorage.removeItem(`album_selection_${album.id}`);
+  }
+
+  const response = await fetch(`/api/albums/${encodeURIComponent(album.slug)}/selections`, {
+    method: 'POST',
+    headers: albumAuthHeaders(album.id),
+  });
+  if (!response.ok) {
+    throw new Error('Failed to open selection');
+  }
+  const selection = (await response.json()) as Selection;
+  localStorage.setItem(`album_selection_${album.id}`, selection.id);
+  return selection;
+}
+
+/**
+ * Save the favourites and notes of a selection.
+ */
+export async function saveSelection(
+  album: Album,
+  selection: Pick<Selection, 'id' | 'favorites' | 'photo_notes' | 'note' | 'client_name'>
+): Promise<Selection> {
+  const response = await fetch(
+    `/api/albums/${encodeURIComponent(album.slug)}/selections/${encodeURIComponent(selection.id)}`,
+    {
+      method: 'PUT',
+      headers: { ...albumAuthHeaders(album.id), 'Content-Type': 'application/json' },
+      body: JSON.stringify({
+        client_name: selection.client_name,
+        favorites: selection.favorites,
+        photo_notes: selection.photo_notes,
+        note: selection.note,
+      }),
+    }
+  );
+  if (!response.ok) {
+    throw new Error((await response.text()) || 'Failed to save selection');
+  }
+  return response.json() as Promise<Selection>;
+}
+
+/**
+ * Submit a selection to the photographer.
+ */
+export async function submitSelection(album: Album, selectionId: string): Promise<Selection> {
+  const response = await fetch(
+    `/api/albums/${encodeURIComponent(album.slug)}/selections/${encodeURIComponent(selectionId)}/submit`,
+    {
+      method: 'POST',
+      headers: albumAuthHeaders(album.id),
+    }
+  );
+  if (!response.ok) {
+    throw new Error((await response.text()) || 'Failed to submit selection');
+  }
+  return response.json() as Promise<Selection>;
+}
+
+function albumAuthHeaders(albumId: string): Record<string, string> {
+  const token = sessionStorage.getItem(`album_token_${albumId}`);
+  return token ? { Authorization: `Bearer ${token}` } : {};
+}
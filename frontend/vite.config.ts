@@ -1,10 +1,11 @@
-import fs from 'fs';
 import { resolve } from 'path';
 import { defineConfig } from 'vite';
 
 export default defineConfig({
   root: 'src',
-  publicDir: resolve(__dirname, '../data'),
+  // Only the dataset the backend publishes is public: it is served at /data from
+  // src/data (a symlink to static/data) and copied by scripts/build.sh
+  publicDir: false,
   build: {
     outDir: '../dist',
     emptyOutDir: true,
@@ -19,42 +20,15 @@ export default defineConfig({
         target: 'http://localhost:6180',
         changeOrigin: true,
       },
+      // Uploaded images are served by the backend, which enforces album access
+      '/uploads': {
+        target: 'http://localhost:6180',
+        changeOrigin: true,
+      },
     },
     fs: {
-      // Allow serving files from parent directory (for /data)
+      // Allow serving files from parent directory (for /data, via the symlink)
       allow: ['..', '../..'],
     },
   },
-  plugins: [
-    {
-      name: 'serve-static-uploads',
-      configureServer(server) {
-        server.middlewares.use('/uploads', (req, res, next) => {
-          if (!req.url) {
-            next();
-            return;
-          }
-
-          const staticPath = resolve(__dirname, '../static/uploads');
-          const filePath = resolve(staticPath, req.url.substring(1));
-
-          // Security check: ensure path is within static/uploads
-          if (!filePath.startsWith(staticPath)) {
-            res.statusCode = 403;
-            res.end('Forbidden');
-            return;
-          }
-
-          if (fs.existsSync(filePath) && fs.statSync(filePath).isFile()) {
-            const stat = fs.statSync(filePath);
-            res.setHeader('Content-Type', 'image/jpeg');
-            res.setHeader('Content-Length', stat.size.toString());
-            fs.createReadStream(filePath).pipe(res);
-          } else {
-            next();
-          }
-        });
-      },
-    },
-  ],
 });